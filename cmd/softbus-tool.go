@@ -37,6 +37,11 @@ var cmdList = []CmdNode{
 	{"CreateSessionServer", createSessionServer},
 	{"OpenSession", openSession},
 	{"SendBytes", sendBytes},
+	{"SendMessage", sendMessage},
+	{"SendFile", sendFile},
+	{"RecvFile", recvFile},
+	{"SendStream", sendStream},
+	{"GetStreamStats", getStreamStats},
 	{"Exit", exitTool},
 }
 
@@ -294,6 +299,9 @@ func createSessionServer() {
 			fmt.Printf(">>>OnSessionOpened sessionID = %d\n", sessionID)
 			currentSessionID = sessionID
 		},
+		OnOpenFailed: func(sessionID int32, reason error) {
+			fmt.Printf(">>>OnSessionOpenFailed sessionID = %d, reason = %v\n", sessionID, reason)
+		},
 		OnShutdown: func(sessionID int32) {
 			fmt.Printf(">>>OnSessionClosed sessionID = %d\n", sessionID)
 		},
@@ -303,6 +311,10 @@ func createSessionServer() {
 		OnMessage: func(sessionID int32, data []byte) {
 			fmt.Printf(">>>OnMessageReceived sessionID = %d, len = %d, data = %s\n", sessionID, len(data), string(data))
 		},
+		OnStreamReceived: func(sessionID int32, data []byte, ext []byte, param *transmission.StreamFrameInfo) {
+			fmt.Printf(">>>OnStreamReceived sessionID = %d, seq = %d, frameType = %d, len = %d, data = %s\n",
+				sessionID, param.SeqNum, param.FrameType, len(data), string(data))
+		},
 	}
 
 	err := transmission.CreateSessionServer("softbus_tool", sessionName, listener)
@@ -317,6 +329,10 @@ func openSession() {
 	sessionName := getInputString("Please input session name:")
 	peerName := getInputString("Please input peer session name:")
 	networkID := getInputString("Please input peer network ID:")
+	dataType := getInputNumber("Please input session type(1-message 2-bytes 3-file 4-stream):")
+	if dataType < int(transmission.TypeMessage) || dataType > int(transmission.TypeStream) {
+		dataType = int(transmission.TypeBytes) // default bytes
+	}
 
 	// 从 Bus Center 获取节点信息
 	bc := bus_center.GetInstance()
@@ -329,14 +345,15 @@ func openSession() {
 	// 使用节点的 AuthSeq 作为 authID
 	authID := node.AuthSeq
 
-	sessionID, err := transmission.OpenSession(sessionName, peerName, networkID, authID)
+	attr := &transmission.SessionAttribute{DataType: transmission.SessionType(dataType)}
+	sessionID, err := transmission.OpenSession(sessionName, peerName, networkID, authID, attr)
 	if err != nil {
 		fmt.Printf("OpenSession fail: %v\n", err)
 		return
 	}
 
-	currentSessionID = sessionID
-	fmt.Printf("OpenSession success, sessionID = %d\n", sessionID)
+	// 握手完成后在OnSessionOpened中切换当前会话
+	fmt.Printf("OpenSession request sent, sessionID = %d\n", sessionID)
 }
 
 func sendBytes() {
@@ -355,6 +372,120 @@ func sendBytes() {
 	fmt.Printf("SendBytes success, sent %d bytes\n", len(data))
 }
 
+func sendMessage() {
+	if currentSessionID == -1 {
+		fmt.Println("No active session. Please OpenSession first.")
+		return
+	}
+
+	data := getInputString("Please input message to send:")
+
+	err := transmission.SendMessage(currentSessionID, []byte(data))
+	if err != nil {
+		fmt.Printf("SendMessage fail: %v\n", err)
+		return
+	}
+	fmt.Printf("SendMessage success, sent %d bytes\n", len(data))
+}
+
+func sendFile() {
+	if currentSessionID == -1 {
+		fmt.Println("No active session. Please OpenSession first.")
+		return
+	}
+
+	session, err := transmission.GetSession(currentSessionID)
+	if err != nil {
+		fmt.Printf("SendFile fail: %v\n", err)
+		return
+	}
+
+	srcPath := getInputString("Please input local file path:")
+	dstPath := getInputString("Please input peer file path (relative to peer root dir):")
+
+	listener := &transmission.FileSendListener{
+		OnSendFileProcess: func(sessionID int32, bytesUpload uint64, bytesTotal uint64) {
+			fmt.Printf(">>>OnSendFileProcess sessionID = %d, %d/%d\n", sessionID, bytesUpload, bytesTotal)
+		},
+		OnSendFileFinished: func(sessionID int32, firstFile string) {
+			fmt.Printf(">>>OnSendFileFinished sessionID = %d, firstFile = %s\n", sessionID, firstFile)
+		},
+		OnFileTransError: func(sessionID int32, err error) {
+			fmt.Printf(">>>OnFileTransError sessionID = %d, err = %v\n", sessionID, err)
+		},
+	}
+	if err := transmission.SetFileSendListener("softbus_tool", session.SessionName, listener); err != nil {
+		fmt.Printf("SetFileSendListener fail: %v\n", err)
+		return
+	}
+
+	if err := transmission.SendFile(currentSessionID, []string{srcPath}, []string{dstPath}); err != nil {
+		fmt.Printf("SendFile fail: %v\n", err)
+		return
+	}
+	fmt.Println("SendFile started")
+}
+
+func recvFile() {
+	sessionName := getInputString("Please input session name:")
+	rootDir := getInputString("Please input receive root dir:")
+
+	listener := &transmission.FileReceiveListener{
+		OnReceiveFileStarted: func(sessionID int32, files []string) {
+			fmt.Printf(">>>OnReceiveFileStarted sessionID = %d, files = %v\n", sessionID, files)
+		},
+		OnReceiveFileProcess: func(sessionID int32, firstFile string, bytesUpload uint64, bytesTotal uint64) {
+			fmt.Printf(">>>OnReceiveFileProcess sessionID = %d, firstFile = %s, %d/%d\n", sessionID, firstFile, bytesUpload, bytesTotal)
+		},
+		OnReceiveFileFinished: func(sessionID int32, files []string) {
+			fmt.Printf(">>>OnReceiveFileFinished sessionID = %d, files = %v\n", sessionID, files)
+		},
+		OnFileTransError: func(sessionID int32, err error) {
+			fmt.Printf(">>>OnFileTransError sessionID = %d, err = %v\n", sessionID, err)
+		},
+	}
+	if err := transmission.SetFileReceiveListener("softbus_tool", sessionName, listener, rootDir); err != nil {
+		fmt.Printf("SetFileReceiveListener fail: %v\n", err)
+		return
+	}
+	fmt.Println("SetFileReceiveListener success")
+}
+
+func sendStream() {
+	if currentSessionID == -1 {
+		fmt.Println("No active session. Please OpenSession first.")
+		return
+	}
+
+	data := getInputString("Please input stream data to send:")
+	frameType := getInputNumber("Please input frame type(1-I 2-P):")
+
+	param := &transmission.StreamFrameInfo{FrameType: transmission.StreamFrameType(frameType)}
+	err := transmission.SendStream(currentSessionID, []byte(data), nil, param)
+	if err != nil {
+		fmt.Printf("SendStream fail: %v\n", err)
+		return
+	}
+	fmt.Printf("SendStream success, sent %d bytes\n", len(data))
+}
+
+func getStreamStats() {
+	if currentSessionID == -1 {
+		fmt.Println("No active session. Please OpenSession first.")
+		return
+	}
+
+	stats, err := transmission.GetStreamStats(currentSessionID)
+	if err != nil {
+		fmt.Printf("GetStreamStats fail: %v\n", err)
+		return
+	}
+	fmt.Printf("Sent: %d frames, %d bytes\n", stats.SentFrames, stats.SentBytes)
+	fmt.Printf("Received: %d frames, %d bytes\n", stats.RecvFrames, stats.RecvBytes)
+	fmt.Printf("Lost: %d, OutOfOrder: %d, Dropped: %d\n", stats.LostFrames, stats.OutOfOrderFrames, stats.DroppedFrames)
+	fmt.Printf("Jitter: %v\n", stats.Jitter)
+}
+
 func exitTool() {
 	fmt.Println("BYE!")
 }
@@ -0,0 +1,406 @@
+package authentication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 认证准入策略
+// ============================================================================
+//
+// 在三个时机检查对端是否允许接入:
+//   1. 服务端接受TCP连接时: IP允许/拒绝列表、单IP新建连接速率、未认证连接总数
+//   2. 服务端开始认证前（收到第一个认证数据）: 按认证数据中声明的UDID检查UDID允许/拒绝列表，
+//      在PAKE和PIN码输入之前拒绝；然后调用应用层审批回调
+//   3. 对端身份确认时（快速重连请求、认证完成，客户端同样检查）: 再次检查UDID允许/拒绝列表
+// 审批回调在独立goroutine中执行，不阻塞连接的接收，审批期间收到的数据暂存，通过后按序处理。
+// 未在AuthTimeout内完成认证的服务端连接会被断开，避免占满未认证连接配额。
+
+// ErrAdmissionDenied 对端未通过准入检查
+var ErrAdmissionDenied = errors.New("admission denied")
+
+// admissionMaxQueued 等待审批期间暂存的认证数据上限，超过时断开连接
+const admissionMaxQueued = 16
+
+// AdmissionPolicy 认证准入策略
+type AdmissionPolicy struct {
+	UdidAllowList  []string      // UDID允许列表，非空时只允许列表中的设备
+	UdidDenyList   []string      // UDID拒绝列表（优先于允许列表）
+	IpAllowList    []string      // IP允许列表（IP或CIDR），非空时只允许列表中的地址
+	IpDenyList     []string      // IP拒绝列表（IP或CIDR，优先于允许列表）
+	MaxUnauthConns int           // 同时存在的未认证连接上限，0表示不限制
+	RateLimit      int           // 单个IP在RateWindow内允许的新建连接数，0表示不限制
+	RateWindow     time.Duration // 速率统计窗口
+	AuthTimeout    time.Duration // 未认证连接的最长存活时间，0表示不限制
+}
+
+// DefaultAdmissionPolicy 默认准入策略（不限制地址和设备，仅限制资源占用）
+func DefaultAdmissionPolicy() AdmissionPolicy {
+	return AdmissionPolicy{
+		MaxUnauthConns: 32,
+		RateLimit:      20,
+		RateWindow:     time.Minute,
+		AuthTimeout:    30 * time.Second,
+	}
+}
+
+// AdmissionInfo 待审批的连接信息
+type AdmissionInfo struct {
+	ConnId uint64 // 连接ID
+	Ip     string // 对端IP
+	Port   int    // 对端端口
+}
+
+// AdmissionCallback 应用层审批回调，返回false拒绝连接
+type AdmissionCallback func(info *AdmissionInfo) bool
+
+// queuedAuthData 等待审批期间收到的认证数据
+type queuedAuthData struct {
+	head *AuthDataHead
+	data []byte
+}
+
+// pendingApproval 等待应用层审批的连接
+type pendingApproval struct {
+	queue []*queuedAuthData // 按接收顺序暂存的数据（含触发审批的第一个数据）
+}
+
+// admissionControl 准入控制状态
+type admissionControl struct {
+	policy    AdmissionPolicy
+	ipAllow   []*net.IPNet
+	ipDeny    []*net.IPNet
+	callback  AdmissionCallback
+	pending   map[uint64]*time.Timer      // 未认证的服务端连接 -> 认证超时定时器
+	approvals map[uint64]*pendingApproval // 等待审批的服务端连接
+	attempts  map[string][]time.Time      // IP -> 窗口内的连接时间
+	mu        sync.Mutex
+}
+
+var g_admission = &admissionControl{
+	policy:    DefaultAdmissionPolicy(),
+	pending:   make(map[uint64]*time.Timer),
+	approvals: make(map[uint64]*pendingApproval),
+	attempts:  make(map[string][]time.Time),
+}
+
+// SetAdmissionPolicy 设置认证准入策略
+// 返回: IP列表格式错误时返回错误，策略不生效
+func SetAdmissionPolicy(policy AdmissionPolicy) error {
+	ipAllow, err := parseIpList(policy.IpAllowList)
+	if err != nil {
+		return fmt.Errorf("invalid ip allow list: %w", err)
+	}
+	ipDeny, err := parseIpList(policy.IpDenyList)
+	if err != nil {
+		return fmt.Errorf("invalid ip deny list: %w", err)
+	}
+
+	g_admission.mu.Lock()
+	defer g_admission.mu.Unlock()
+	g_admission.policy = policy
+	g_admission.ipAllow = ipAllow
+	g_admission.ipDeny = ipDeny
+
+	log.Infof("[AUTH_ADMISSION] Policy set: udidAllow=%d, udidDeny=%d, ipAllow=%d, ipDeny=%d, maxUnauth=%d, rate=%d/%v, timeout=%v",
+		len(policy.UdidAllowList), len(policy.UdidDenyList), len(ipAllow), len(ipDeny),
+		policy.MaxUnauthConns, policy.RateLimit, policy.RateWindow, policy.AuthTimeout)
+	return nil
+}
+
+// GetAdmissionPolicy 获取当前认证准入策略
+func GetAdmissionPolicy() AdmissionPolicy {
+	g_admission.mu.Lock()
+	defer g_admission.mu.Unlock()
+	return g_admission.policy
+}
+
+// SetAdmissionCallback 设置应用层审批回调
+// 服务端收到第一个认证数据、开始认证之前在独立goroutine中调用，nil表示不审批
+func SetAdmissionCallback(callback AdmissionCallback) {
+	g_admission.mu.Lock()
+	defer g_admission.mu.Unlock()
+	g_admission.callback = callback
+}
+
+// parseIpList 解析IP/CIDR列表
+func parseIpList(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip: %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// matchIpList 判断IP是否在列表中
+func matchIpList(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsUdid 判断UDID是否在列表中
+func containsUdid(list []string, udid string) bool {
+	for _, item := range list {
+		if item == udid {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================
+// 准入检查
+// ============================================================================
+
+// admitConnection 接受服务端TCP连接前检查IP、速率和未认证连接数
+// 通过后登记为未认证连接，超时未完成认证时断开
+func admitConnection(connId uint64, ip string) error {
+	g_admission.mu.Lock()
+	defer g_admission.mu.Unlock()
+
+	policy := g_admission.policy
+	peerIp := net.ParseIP(ip)
+	if peerIp == nil {
+		return fmt.Errorf("%w: invalid ip %q", ErrAdmissionDenied, ip)
+	}
+	if matchIpList(g_admission.ipDeny, peerIp) {
+		return fmt.Errorf("%w: ip %s denied", ErrAdmissionDenied, ip)
+	}
+	if len(g_admission.ipAllow) > 0 && !matchIpList(g_admission.ipAllow, peerIp) {
+		return fmt.Errorf("%w: ip %s not allowed", ErrAdmissionDenied, ip)
+	}
+
+	if policy.RateLimit > 0 && policy.RateWindow > 0 {
+		now := time.Now()
+		g_admission.pruneAttempts(now.Add(-policy.RateWindow))
+		if len(g_admission.attempts[ip]) >= policy.RateLimit {
+			return fmt.Errorf("%w: ip %s exceeds rate limit", ErrAdmissionDenied, ip)
+		}
+		g_admission.attempts[ip] = append(g_admission.attempts[ip], now)
+	}
+
+	if policy.MaxUnauthConns > 0 && len(g_admission.pending) >= policy.MaxUnauthConns {
+		return fmt.Errorf("%w: too many unauthenticated connections", ErrAdmissionDenied)
+	}
+
+	var timer *time.Timer
+	if policy.AuthTimeout > 0 {
+		timer = time.AfterFunc(policy.AuthTimeout, func() {
+			log.Warnf("[AUTH_ADMISSION] Auth timeout, closing connection: connId=%d", connId)
+			DisconnectAuthDevice(connId)
+		})
+	}
+	g_admission.pending[connId] = timer
+	return nil
+}
+
+// pruneAttempts 清理统计窗口之外的连接记录（调用方持有锁）
+func (a *admissionControl) pruneAttempts(deadline time.Time) {
+	for ip, times := range a.attempts {
+		i := 0
+		for i < len(times) && times[i].Before(deadline) {
+			i++
+		}
+		if i == len(times) {
+			delete(a.attempts, ip)
+		} else if i > 0 {
+			a.attempts[ip] = times[i:]
+		}
+	}
+}
+
+// isConnPending 判断服务端连接是否仍处于未认证状态（连接断开时准入记录先于AuthManager释放）
+func isConnPending(connId uint64) bool {
+	g_admission.mu.Lock()
+	defer g_admission.mu.Unlock()
+	_, exists := g_admission.pending[connId]
+	return exists
+}
+
+// claimedPeerUdid 解析第一个认证数据中对端声明的UDID
+// HiChain请求取connDeviceId（或peerDeviceId），快速重连请求取udid；声明未经认证，仅用于提前拒绝
+func claimedPeerUdid(module int32, data []byte) string {
+	var msg struct {
+		ConnDeviceId string `json:"connDeviceId"`
+		PeerDeviceId string `json:"peerDeviceId"`
+		Udid         string `json:"udid"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return ""
+	}
+
+	if module == ModuleAuthResume {
+		return msg.Udid
+	}
+	if msg.ConnDeviceId != "" {
+		return msg.ConnDeviceId
+	}
+	return msg.PeerDeviceId
+}
+
+// checkClaimedUdid 开始认证前按对端声明的UDID检查允许/拒绝列表
+// 未声明UDID时只在配置了允许列表时拒绝
+func checkClaimedUdid(udid string) error {
+	if udid != "" {
+		return checkPeerUdid(udid)
+	}
+
+	g_admission.mu.Lock()
+	restricted := len(g_admission.policy.UdidAllowList) > 0
+	g_admission.mu.Unlock()
+	if restricted {
+		return fmt.Errorf("%w: udid unknown", ErrAdmissionDenied)
+	}
+	return nil
+}
+
+// requestApproval 服务端开始认证前发起应用层审批
+// 未设置回调时返回false，调用方直接处理数据；否则暂存数据并返回true:
+// 回调在独立goroutine中执行，通过后由process按接收顺序处理暂存的数据，拒绝时断开连接
+func requestApproval(connId uint64, connInfo *AuthConnInfo, head *AuthDataHead, data []byte,
+	process func(head *AuthDataHead, data []byte)) bool {
+	g_admission.mu.Lock()
+	callback := g_admission.callback
+	if callback == nil {
+		g_admission.mu.Unlock()
+		return false
+	}
+	approval := &pendingApproval{queue: []*queuedAuthData{newQueuedAuthData(head, data)}}
+	g_admission.approvals[connId] = approval
+	g_admission.mu.Unlock()
+
+	info := &AdmissionInfo{ConnId: connId}
+	if connInfo != nil {
+		info.Ip = connInfo.Ip
+		info.Port = connInfo.Port
+	}
+
+	go func() {
+		if !callback(info) {
+			g_admission.mu.Lock()
+			current := g_admission.approvals[connId] == approval
+			if current {
+				delete(g_admission.approvals, connId)
+			}
+			g_admission.mu.Unlock()
+
+			log.Warnf("[AUTH_ADMISSION] Connection rejected by application: connId=%d, ip=%s", connId, info.Ip)
+			if current {
+				DisconnectAuthDevice(connId)
+			}
+			return
+		}
+
+		// 逐个取出暂存数据处理，处理期间新到的数据继续排在队尾，保证顺序
+		for {
+			g_admission.mu.Lock()
+			if g_admission.approvals[connId] != approval {
+				// 审批期间连接已断开
+				g_admission.mu.Unlock()
+				return
+			}
+			if len(approval.queue) == 0 {
+				delete(g_admission.approvals, connId)
+				g_admission.mu.Unlock()
+				return
+			}
+			item := approval.queue[0]
+			approval.queue[0] = nil
+			approval.queue = approval.queue[1:]
+			g_admission.mu.Unlock()
+
+			process(item.head, item.data)
+		}
+	}()
+	return true
+}
+
+// queueApprovalData 连接等待审批时暂存数据，返回false表示连接不在审批中
+// 暂存数据超过上限时断开连接
+func queueApprovalData(connId uint64, head *AuthDataHead, data []byte) bool {
+	g_admission.mu.Lock()
+	approval, exists := g_admission.approvals[connId]
+	if !exists {
+		g_admission.mu.Unlock()
+		return false
+	}
+	if len(approval.queue) >= admissionMaxQueued {
+		delete(g_admission.approvals, connId)
+		g_admission.mu.Unlock()
+		log.Warnf("[AUTH_ADMISSION] Too much data while waiting for approval, closing connection: connId=%d", connId)
+		DisconnectAuthDevice(connId)
+		return true
+	}
+	approval.queue = append(approval.queue, newQueuedAuthData(head, data))
+	g_admission.mu.Unlock()
+	return true
+}
+
+// newQueuedAuthData 复制待暂存的数据（接收缓冲区会被复用）
+func newQueuedAuthData(head *AuthDataHead, data []byte) *queuedAuthData {
+	headCopy := *head
+	return &queuedAuthData{head: &headCopy, data: append([]byte(nil), data...)}
+}
+
+// checkPeerUdid 检查对端UDID是否允许接入
+func checkPeerUdid(udid string) error {
+	g_admission.mu.Lock()
+	policy := g_admission.policy
+	g_admission.mu.Unlock()
+
+	if containsUdid(policy.UdidDenyList, udid) {
+		return fmt.Errorf("%w: udid %s denied", ErrAdmissionDenied, udid)
+	}
+	if len(policy.UdidAllowList) > 0 && !containsUdid(policy.UdidAllowList, udid) {
+		return fmt.Errorf("%w: udid %s not allowed", ErrAdmissionDenied, udid)
+	}
+	return nil
+}
+
+// markConnAuthenticated 连接认证完成，不再计入未认证连接
+func markConnAuthenticated(connId uint64) {
+	releaseConnection(connId)
+}
+
+// releaseConnection 连接断开或认证完成时释放未认证连接配额
+func releaseConnection(connId uint64) {
+	g_admission.mu.Lock()
+	defer g_admission.mu.Unlock()
+
+	if timer, exists := g_admission.pending[connId]; exists {
+		if timer != nil {
+			timer.Stop()
+		}
+		delete(g_admission.pending, connId)
+	}
+	delete(g_admission.approvals, connId)
+}
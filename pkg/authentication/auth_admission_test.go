@@ -0,0 +1,246 @@
+package authentication
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// resetAdmission 恢复默认准入策略并清空状态
+func resetAdmission() {
+	SetAdmissionPolicy(DefaultAdmissionPolicy())
+	SetAdmissionCallback(nil)
+	g_admission.mu.Lock()
+	for connId, timer := range g_admission.pending {
+		if timer != nil {
+			timer.Stop()
+		}
+		delete(g_admission.pending, connId)
+	}
+	g_admission.approvals = make(map[uint64]*pendingApproval)
+	g_admission.attempts = make(map[string][]time.Time)
+	g_admission.mu.Unlock()
+}
+
+// 测试IP允许/拒绝列表
+func TestAdmission_IpList(t *testing.T) {
+	defer resetAdmission()
+
+	if err := SetAdmissionPolicy(AdmissionPolicy{IpAllowList: []string{"not-an-ip"}}); err == nil {
+		t.Error("Expected error for invalid ip list")
+	}
+
+	err := SetAdmissionPolicy(AdmissionPolicy{
+		IpAllowList: []string{"192.168.1.0/24"},
+		IpDenyList:  []string{"192.168.1.66"},
+	})
+	if err != nil {
+		t.Fatalf("SetAdmissionPolicy failed: %v", err)
+	}
+
+	if err := admitConnection(1, "192.168.1.10"); err != nil {
+		t.Errorf("Expected allowed ip to pass, got %v", err)
+	}
+	if err := admitConnection(2, "192.168.1.66"); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected denied ip to be rejected, got %v", err)
+	}
+	if err := admitConnection(3, "10.0.0.1"); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected ip outside allow list to be rejected, got %v", err)
+	}
+}
+
+// 测试单IP连接速率和未认证连接上限
+func TestAdmission_Limits(t *testing.T) {
+	defer resetAdmission()
+
+	SetAdmissionPolicy(AdmissionPolicy{RateLimit: 2, RateWindow: time.Minute})
+	admitConnection(1, "10.0.0.1")
+	admitConnection(2, "10.0.0.1")
+	if err := admitConnection(3, "10.0.0.1"); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected rate limit rejection, got %v", err)
+	}
+	if err := admitConnection(4, "10.0.0.2"); err != nil {
+		t.Errorf("Expected other ip to pass, got %v", err)
+	}
+
+	resetAdmission()
+	SetAdmissionPolicy(AdmissionPolicy{MaxUnauthConns: 1})
+	admitConnection(5, "10.0.0.3")
+	if err := admitConnection(6, "10.0.0.4"); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected unauthenticated limit rejection, got %v", err)
+	}
+
+	// 认证完成后释放配额
+	markConnAuthenticated(5)
+	if err := admitConnection(7, "10.0.0.4"); err != nil {
+		t.Errorf("Expected connection after release to pass, got %v", err)
+	}
+}
+
+// 测试UDID允许/拒绝列表
+func TestAdmission_UdidList(t *testing.T) {
+	defer resetAdmission()
+
+	SetAdmissionPolicy(AdmissionPolicy{
+		UdidAllowList: []string{"udid-A", "udid-B"},
+		UdidDenyList:  []string{"udid-B"},
+	})
+	if err := checkPeerUdid("udid-A"); err != nil {
+		t.Errorf("Expected udid-A to pass, got %v", err)
+	}
+	if err := checkPeerUdid("udid-B"); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected deny list to take precedence, got %v", err)
+	}
+	if err := checkPeerUdid("udid-C"); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected udid outside allow list to be rejected, got %v", err)
+	}
+}
+
+// 测试开始认证前按声明的UDID检查允许/拒绝列表
+func TestAdmission_ClaimedUdid(t *testing.T) {
+	defer resetAdmission()
+
+	pakeRequest := []byte(`{"message":1,"peerDeviceId":"udid-B","connDeviceId":"udid-B"}`)
+	resumeRequest := []byte(`{"msgType":"RESUME_REQ","udid":"udid-A"}`)
+	if udid := claimedPeerUdid(ModuleAuthSdk, pakeRequest); udid != "udid-B" {
+		t.Errorf("Unexpected claimed udid: %q", udid)
+	}
+	if udid := claimedPeerUdid(ModuleAuthResume, resumeRequest); udid != "udid-A" {
+		t.Errorf("Unexpected claimed udid: %q", udid)
+	}
+	if udid := claimedPeerUdid(ModuleAuthSdk, []byte("not json")); udid != "" {
+		t.Errorf("Expected empty udid for invalid data, got %q", udid)
+	}
+
+	// 未配置UDID列表时均放行
+	if err := checkClaimedUdid(""); err != nil {
+		t.Errorf("Expected unknown udid to pass without lists, got %v", err)
+	}
+
+	SetAdmissionPolicy(AdmissionPolicy{UdidDenyList: []string{"udid-B"}})
+	if err := checkClaimedUdid(claimedPeerUdid(ModuleAuthSdk, pakeRequest)); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected denied udid to be rejected before auth, got %v", err)
+	}
+	if err := checkClaimedUdid(""); err != nil {
+		t.Errorf("Expected unknown udid to pass with deny list only, got %v", err)
+	}
+
+	SetAdmissionPolicy(AdmissionPolicy{UdidAllowList: []string{"udid-A"}})
+	if err := checkClaimedUdid(claimedPeerUdid(ModuleAuthResume, resumeRequest)); err != nil {
+		t.Errorf("Expected allowed udid to pass, got %v", err)
+	}
+	if err := checkClaimedUdid(""); !errors.Is(err, ErrAdmissionDenied) {
+		t.Errorf("Expected unknown udid to be rejected with allow list, got %v", err)
+	}
+}
+
+// 测试应用层审批：回调不阻塞接收，审批期间的数据暂存并在通过后按序处理
+func TestAdmission_ApprovalQueue(t *testing.T) {
+	defer resetAdmission()
+
+	head := func(seq int64) *AuthDataHead { return &AuthDataHead{Module: ModuleAuthSdk, Seq: seq} }
+	processed := make(chan int64, admissionMaxQueued+1)
+	process := func(head *AuthDataHead, data []byte) { processed <- head.Seq }
+
+	// 未设置回调时直接处理
+	if requestApproval(20, nil, head(1), nil, process) {
+		t.Fatal("Expected no approval without callback")
+	}
+
+	var got *AdmissionInfo
+	release := make(chan bool)
+	SetAdmissionCallback(func(info *AdmissionInfo) bool {
+		got = info
+		return <-release
+	})
+
+	buf := []byte("first")
+	if !requestApproval(21, &AuthConnInfo{Ip: "10.0.0.8", Port: 5000}, head(1), buf, process) {
+		t.Fatal("Expected data to be queued for approval")
+	}
+	copy(buf, "XXXXX") // 接收缓冲区被复用不影响暂存数据
+	for seq := int64(2); seq <= 4; seq++ {
+		if !queueApprovalData(21, head(seq), nil) {
+			t.Fatalf("Expected seq=%d to be queued", seq)
+		}
+	}
+	select {
+	case seq := <-processed:
+		t.Fatalf("Unexpected data processed before approval: seq=%d", seq)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- true
+	for want := int64(1); want <= 4; want++ {
+		select {
+		case seq := <-processed:
+			if seq != want {
+				t.Fatalf("Expected seq=%d, got %d", want, seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for seq=%d", want)
+		}
+	}
+	if got == nil || got.ConnId != 21 || got.Ip != "10.0.0.8" || got.Port != 5000 {
+		t.Errorf("Unexpected admission info: %+v", got)
+	}
+
+	// 队列处理完后不再暂存
+	time.Sleep(20 * time.Millisecond)
+	if queueApprovalData(21, head(5), nil) {
+		t.Error("Expected data after approval not to be queued")
+	}
+
+	// 拒绝时不处理暂存数据
+	requestApproval(22, nil, head(1), nil, process)
+	release <- false
+	time.Sleep(50 * time.Millisecond)
+	if len(processed) != 0 || queueApprovalData(22, head(2), nil) {
+		t.Error("Expected rejected connection data to be dropped")
+	}
+
+	// 审批期间连接断开
+	requestApproval(23, nil, head(1), nil, process)
+	releaseConnection(23)
+	release <- true
+	time.Sleep(50 * time.Millisecond)
+	if len(processed) != 0 {
+		t.Error("Expected data of closed connection to be dropped")
+	}
+}
+
+// 测试审批期间暂存数据超过上限时断开
+func TestAdmission_ApprovalQueueLimit(t *testing.T) {
+	defer resetAdmission()
+
+	release := make(chan bool)
+	defer close(release)
+	SetAdmissionCallback(func(info *AdmissionInfo) bool { return <-release })
+
+	requestApproval(31, nil, &AuthDataHead{}, nil, func(*AuthDataHead, []byte) {})
+	for i := 1; i < admissionMaxQueued; i++ {
+		queueApprovalData(31, &AuthDataHead{}, nil)
+	}
+	if !queueApprovalData(31, &AuthDataHead{}, nil) {
+		t.Fatal("Expected overflow data to be consumed")
+	}
+
+	g_admission.mu.Lock()
+	_, exists := g_admission.approvals[31]
+	g_admission.mu.Unlock()
+	if exists {
+		t.Error("Expected approval to be dropped on overflow")
+	}
+}
+
+// 测试连接断开后不再创建服务端AuthManager
+func TestStartServerAuthAfterDisconnect(t *testing.T) {
+	defer resetAdmission()
+
+	if startServerAuth(41, &AuthConnInfo{Ip: "10.0.0.1"}) {
+		t.Error("Expected no auth to start for closed connection")
+	}
+	if _, err := GetAuthManagerByConnId(41); err == nil {
+		t.Error("Expected no auth manager for closed connection")
+	}
+}
@@ -0,0 +1,127 @@
+package authentication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 扩展能力协商
+// ============================================================================
+//
+// 认证通过后双方通过MODULE_AUTH_CONNECTION（设备信息同步）互发一次能力消息（加密传输）:
+//   CAPABILITY{capability=本端支持的扩展能力位图}
+// 扩展模块（密钥更新、快速重连）只在对端声明支持后使用；
+// 不支持该消息的对端不会回复，视为不支持任何扩展能力。
+// 对端能力按UDID缓存并随会话密钥持久化，客户端下次连接时据此决定是否尝试快速重连。
+
+// 扩展能力位
+const (
+	AuthCapabilityRekey  uint32 = 1 << 0 // 支持会话密钥更新（MODULE_AUTH_REKEY）
+	AuthCapabilityResume uint32 = 1 << 1 // 支持快速重连认证（MODULE_AUTH_RESUME）
+
+	// authLocalCapability 本端支持的扩展能力
+	authLocalCapability = AuthCapabilityRekey | AuthCapabilityResume
+
+	capabilityMsgType = "CAPABILITY"
+)
+
+var (
+	g_peerCapabilities   = make(map[string]uint32) // 对端UDID -> 扩展能力
+	g_peerCapabilitiesMu sync.RWMutex
+)
+
+// peerCapabilityStore 支持保存对端扩展能力的持久化实现（如FileSessionKeyPersistor）
+type peerCapabilityStore interface {
+	SaveCapabilityByUdid(udid string, capability uint32) error
+	LoadCapabilityByUdid(udid string) (uint32, error)
+}
+
+// capabilityMessage 扩展能力消息
+type capabilityMessage struct {
+	MsgType    string `json:"msgType"`
+	Capability uint32 `json:"capability"`
+}
+
+// sendCapability 认证通过后向对端声明本端扩展能力
+func sendCapability(manager *AuthManager) error {
+	data, err := json.Marshal(&capabilityMessage{
+		MsgType:    capabilityMsgType,
+		Capability: authLocalCapability,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal capability message: %w", err)
+	}
+	return AuthDevicePostTransData(manager.AuthId, ModuleAuthConnection, 0, data)
+}
+
+// handleCapabilityData 处理对端扩展能力消息
+func handleCapabilityData(manager *AuthManager, data []byte) {
+	var msg capabilityMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.MsgType != capabilityMsgType {
+		log.Warnf("[AUTH_MGR] Invalid capability message: authId=%d", manager.AuthId)
+		return
+	}
+
+	manager.mu.Lock()
+	manager.peerCapability = msg.Capability
+	manager.mu.Unlock()
+
+	log.Infof("[AUTH_MGR] Peer capability: authId=%d, capability=0x%x", manager.AuthId, msg.Capability)
+	recordPeerCapability(manager)
+}
+
+// recordPeerCapability 按对端UDID缓存并持久化扩展能力
+// 能力消息与认证结果的先后顺序不确定，收到能力消息和认证通过时各调用一次
+func recordPeerCapability(manager *AuthManager) {
+	manager.mu.RLock()
+	peerUdid := manager.PeerUdid
+	capability := manager.peerCapability
+	manager.mu.RUnlock()
+	if peerUdid == "" || capability == 0 {
+		return
+	}
+
+	g_peerCapabilitiesMu.Lock()
+	g_peerCapabilities[peerUdid] = capability
+	g_peerCapabilitiesMu.Unlock()
+
+	if store, ok := GetSessionKeyPersistor().(peerCapabilityStore); ok {
+		if err := store.SaveCapabilityByUdid(peerUdid, capability); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("[AUTH_MGR] Failed to persist peer capability: udid=%s, err=%v", peerUdid, err)
+		}
+	}
+}
+
+// getPeerCapability 获取对端设备曾声明的扩展能力
+// 返回false表示从未收到该设备的能力消息
+func getPeerCapability(udid string) (uint32, bool) {
+	g_peerCapabilitiesMu.RLock()
+	capability, ok := g_peerCapabilities[udid]
+	g_peerCapabilitiesMu.RUnlock()
+	if ok {
+		return capability, true
+	}
+
+	if store, ok := GetSessionKeyPersistor().(peerCapabilityStore); ok {
+		if capability, err := store.LoadCapabilityByUdid(udid); err == nil && capability != 0 {
+			g_peerCapabilitiesMu.Lock()
+			g_peerCapabilities[udid] = capability
+			g_peerCapabilitiesMu.Unlock()
+			return capability, true
+		}
+	}
+	return 0, false
+}
+
+// peerSupports 判断对端是否声明支持指定扩展能力
+func (m *AuthManager) peerSupports(capability uint32) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.peerCapability&capability != 0
+}
@@ -151,6 +151,7 @@ func AuthCloseChannel(channelId int) {
 
 // AuthPostChannelData 发送通道数据
 // 对应C函数: int32_t AuthPostChannelData(int32_t channelId, const AuthChannelData *data)
+// 通道认证通过后，非握手模块数据使用会话密钥加密（Seq改用加密发送序列号），认证通过前按明文发送
 // 参数:
 //   - channelId: 通道ID
 //   - data: 通道数据
@@ -169,11 +170,27 @@ func AuthPostChannelData(channelId int, data *AuthChannelData) error {
 		Flag:     data.Flag,
 		Len:      data.Len,
 	}
+	payload := data.Data
 
-	log.Infof("[AUTH_CHANNEL] Posting channel data: channelId=%d, module=%d, seq=%d, len=%d",
-		channelId, data.Module, data.Seq, data.Len)
+	manager := getEncryptManager(channelId, data.Module)
+	if manager != nil {
+		seq, flag, encrypted, err := encryptTransData(manager, data.Module, data.Flag, data.Data)
+		if err != nil {
+			return err
+		}
+		head.Seq, head.Flag, head.Len, payload = seq, flag, uint32(len(encrypted)), encrypted
+	}
+
+	log.Infof("[AUTH_CHANNEL] Posting channel data: channelId=%d, module=%d, seq=%d, len=%d, encrypted=%v",
+		channelId, data.Module, head.Seq, data.Len, manager != nil)
 
-	return SocketPostBytes(channelId, head, data.Data)
+	if err := SocketPostBytes(channelId, head, payload); err != nil {
+		return err
+	}
+	if manager != nil {
+		checkRekeyByBytes(manager, data.Module, len(data.Data))
+	}
+	return nil
 }
 
 // ============================================================================
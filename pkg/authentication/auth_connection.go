@@ -212,6 +212,7 @@ func DisconnectAuthDevice(connId uint64) {
 	delete(manager.connections, connId)
 	delete(manager.fdToConnId, fd)
 	manager.mu.Unlock()
+	releaseConnection(connId)
 
 	log.Infof("[AUTH_CONN] Disconnecting device: connId=%d, fd=%d", connId, fd)
 
@@ -353,12 +354,19 @@ func onAuthSocketConnected(fd int, isClient bool) {
 			return
 		}
 
-		manager.mu.Lock()
-		defer manager.mu.Unlock()
-
 		// 生成ConnId（服务端默认使用WiFi类型）
 		connId := GenConnId(AuthLinkTypeWifi, int32(fd))
 
+		// 准入检查：IP、连接速率、未认证连接数
+		if err := admitConnection(connId, connInfoRaw.Ip); err != nil {
+			log.Warnf("[AUTH_CONN] Connection rejected: fd=%d, ip=%s, err=%v", fd, connInfoRaw.Ip, err)
+			SocketDisconnectDevice(Auth, fd)
+			return
+		}
+
+		manager.mu.Lock()
+		defer manager.mu.Unlock()
+
 		// 创建连接记录
 		conn := &AuthConnection{
 			ConnId:   connId,
@@ -400,6 +408,7 @@ func onAuthSocketDisconnected(fd int) {
 	delete(manager.connections, connId)
 	delete(manager.fdToConnId, fd)
 	manager.mu.Unlock()
+	releaseConnection(connId)
 
 	log.Infof("[AUTH_CONN] Connection disconnected: connId=%d, fd=%d", connId, fd)
 
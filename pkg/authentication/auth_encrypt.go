@@ -0,0 +1,237 @@
+package authentication
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 认证后数据加密
+// ============================================================================
+//
+// 认证通过后，除握手模块外的所有模块数据均使用最新会话密钥进行AES-GCM加密：
+//   - 数据包头部Flag置位AuthFlagEncrypted
+//   - 数据内容格式: [密钥索引(4字节)] + [IV(12字节)] + [密文] + [Tag(16字节)]
+//   - 头部的 Seq/Module/Flag 作为AAD参与认证，篡改头部将导致解密失败
+//   - 接收端通过滑动窗口拒绝重复或过旧的序列号
+// AuthDevicePostTransData与AuthPostChannelData遵循同一规则：连接对应的AuthManager认证通过后，
+// 非握手模块数据一律加密发送；认证通过前（DM协商、HiChain认证阶段）按明文发送，接收端同样接受明文
+
+const (
+	// AuthFlagEncrypted 数据包已加密标志位（SocketPktHead.Flag）
+	AuthFlagEncrypted int32 = 0x40000000
+
+	// AuthEncryptOverhead 加密引入的额外长度: 索引(4) + IV(12) + Tag(16)
+	AuthEncryptOverhead int = SessionKeyIndexLen + 12 + 16
+
+	// AuthReplayWindowSize 重放窗口大小（序列号个数）
+	AuthReplayWindowSize int64 = 64
+)
+
+// isHandshakeModule 判断模块是否属于认证握手阶段（握手数据不加密）
+func isHandshakeModule(module int32) bool {
+	switch module {
+	case ModuleTrustEngine, ModuleAuthSdk, ModuleMetaAuth, ModuleAuthResume:
+		return true
+	default:
+		return false
+	}
+}
+
+// buildAuthFrameAAD 构造数据包的附加认证数据
+// 格式: Seq(8字节) + Module(4字节) + Flag(4字节)，小端序，与SocketPktHead一致
+func buildAuthFrameAAD(module int32, seq int64, flag int32) []byte {
+	aad := make([]byte, 16)
+	binary.LittleEndian.PutUint64(aad[0:8], uint64(seq))
+	binary.LittleEndian.PutUint32(aad[8:12], uint32(module))
+	binary.LittleEndian.PutUint32(aad[12:16], uint32(flag))
+	return aad
+}
+
+// ============================================================================
+// 重放窗口
+// ============================================================================
+
+// ReplayWindow 序列号滑动窗口（零值可用）
+// 记录最大已接收序列号及其之前 AuthReplayWindowSize 个序列号的接收情况
+type ReplayWindow struct {
+	maxSeq      int64  // 已接收的最大序列号
+	bitmap      uint64 // 第i位表示 maxSeq-i 是否已接收
+	initialized bool   // 是否已接收过数据
+
+	mu sync.Mutex
+}
+
+// Check 检查序列号是否可接收（不更新窗口）
+func (w *ReplayWindow) Check(seq int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.checkLocked(seq)
+}
+
+// Accept 检查并记录序列号
+// 应在数据完成解密校验之后调用，避免伪造数据推动窗口
+// 返回false表示序列号重复或已超出窗口
+func (w *ReplayWindow) Accept(seq int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.checkLocked(seq) {
+		return false
+	}
+
+	if !w.initialized {
+		w.initialized = true
+		w.maxSeq = seq
+		w.bitmap = 1
+		return true
+	}
+
+	if seq > w.maxSeq {
+		shift := seq - w.maxSeq
+		if shift >= AuthReplayWindowSize {
+			w.bitmap = 1
+		} else {
+			w.bitmap = w.bitmap<<uint(shift) | 1
+		}
+		w.maxSeq = seq
+		return true
+	}
+
+	w.bitmap |= 1 << uint(w.maxSeq-seq)
+	return true
+}
+
+func (w *ReplayWindow) checkLocked(seq int64) bool {
+	if !w.initialized || seq > w.maxSeq {
+		return true
+	}
+
+	offset := w.maxSeq - seq
+	if offset >= AuthReplayWindowSize {
+		return false
+	}
+
+	return w.bitmap&(1<<uint(offset)) == 0
+}
+
+// ============================================================================
+// 加密/解密
+// ============================================================================
+
+// encryptTransData 使用最新会话密钥加密发送数据
+// 分配新的发送序列号并置位加密标志，返回更新后的头部字段和密文
+func encryptTransData(manager *AuthManager, module int32, flag int32, data []byte) (int64, int32, []byte, error) {
+	if flag&AuthFlagEncrypted != 0 {
+		return 0, 0, nil, fmt.Errorf("invalid flag: 0x%x", flag)
+	}
+
+	if len(data)+AuthEncryptOverhead > AuthSocketMaxDataLen {
+		return 0, 0, nil, fmt.Errorf("data too large: %d bytes (max %d)",
+			len(data), AuthSocketMaxDataLen-AuthEncryptOverhead)
+	}
+
+	seq := manager.nextSendSeq()
+	flag |= AuthFlagEncrypted
+
+	encrypted, err := manager.SessionKeyMgr.EncryptWithAAD(manager.AuthId, data, buildAuthFrameAAD(module, seq, flag))
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to encrypt trans data: %w", err)
+	}
+
+	return seq, flag, encrypted, nil
+}
+
+// getAuthManagerByFd 根据Fd查找AuthManager
+func getAuthManagerByFd(fd int) *AuthManager {
+	conn, err := GetAuthConnectionByFd(fd)
+	if err != nil || conn == nil {
+		return nil
+	}
+
+	manager, err := GetAuthManagerByConnId(conn.ConnId)
+	if err != nil {
+		return nil
+	}
+	return manager
+}
+
+// decryptSocketData 解密接收到的加密数据包
+// 校验通过后返回清除加密标志的头部和明文数据
+func decryptSocketData(fd int, pktHead *SocketPktHead, data []byte) (*SocketPktHead, []byte, error) {
+	manager := getAuthManagerByFd(fd)
+	if manager == nil {
+		return nil, nil, fmt.Errorf("no auth manager for fd=%d", fd)
+	}
+
+	if !manager.recvWindow.Check(pktHead.Seq) {
+		return nil, nil, fmt.Errorf("replayed seq=%d", pktHead.Seq)
+	}
+
+	aad := buildAuthFrameAAD(pktHead.Module, pktHead.Seq, pktHead.Flag)
+	plaintext, err := manager.SessionKeyMgr.DecryptWithAAD(manager.AuthId, data, aad)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 解密成功后才更新窗口
+	if !manager.recvWindow.Accept(pktHead.Seq) {
+		return nil, nil, fmt.Errorf("replayed seq=%d", pktHead.Seq)
+	}
+
+	head := *pktHead
+	head.Flag &^= AuthFlagEncrypted
+	head.Len = uint32(len(plaintext))
+	return &head, plaintext, nil
+}
+
+// getEncryptManager 获取连接上需要加密该模块数据的AuthManager
+// 认证通过后的非握手模块返回对应的AuthManager，否则返回nil（按明文收发）
+func getEncryptManager(fd int, module int32) *AuthManager {
+	if isHandshakeModule(module) {
+		return nil
+	}
+
+	manager := getAuthManagerByFd(fd)
+	if manager == nil {
+		return nil
+	}
+
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	if !manager.HasAuthPassed {
+		return nil
+	}
+	return manager
+}
+
+// requireEncryptedData 判断明文数据包是否应被丢弃
+// 认证通过后，非握手模块的明文数据一律拒绝
+func requireEncryptedData(fd int, module int32) bool {
+	return getEncryptManager(fd, module) != nil
+}
+
+// filterSocketData 对接收数据执行解密与明文过滤
+// 返回false表示数据包应被丢弃
+func filterSocketData(fd int, pktHead *SocketPktHead, data []byte) (*SocketPktHead, []byte, bool) {
+	if pktHead.Flag&AuthFlagEncrypted != 0 {
+		head, plaintext, err := decryptSocketData(fd, pktHead, data)
+		if err != nil {
+			log.Warnf("[AUTH_ENCRYPT] Drop encrypted packet: fd=%d, module=%d, seq=%d, err=%v",
+				fd, pktHead.Module, pktHead.Seq, err)
+			return nil, nil, false
+		}
+		return head, plaintext, true
+	}
+
+	if requireEncryptedData(fd, pktHead.Module) {
+		log.Warnf("[AUTH_ENCRYPT] Drop plaintext packet after auth: fd=%d, module=%d, seq=%d",
+			fd, pktHead.Module, pktHead.Seq)
+		return nil, nil, false
+	}
+
+	return pktHead, data, true
+}
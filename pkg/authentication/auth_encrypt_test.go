@@ -0,0 +1,251 @@
+package authentication
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// 测试重放窗口：顺序、乱序、重复、过旧序列号
+func TestReplayWindow(t *testing.T) {
+	var w ReplayWindow
+
+	for seq := int64(1); seq <= 10; seq++ {
+		if !w.Accept(seq) {
+			t.Fatalf("Expected seq=%d to be accepted", seq)
+		}
+	}
+
+	// 重复序列号
+	if w.Accept(10) || w.Accept(5) {
+		t.Error("Expected duplicated seq to be rejected")
+	}
+
+	// 跳跃后窗口内的乱序序列号仍可接收一次
+	if !w.Accept(20) {
+		t.Error("Expected seq=20 to be accepted")
+	}
+	if !w.Accept(15) {
+		t.Error("Expected unseen seq=15 inside window to be accepted")
+	}
+	if w.Accept(15) {
+		t.Error("Expected seq=15 to be rejected on replay")
+	}
+
+	// 超出窗口的旧序列号
+	if !w.Accept(20 + AuthReplayWindowSize) {
+		t.Error("Expected window to slide forward")
+	}
+	if w.Check(20) {
+		t.Error("Expected seq older than window to be rejected")
+	}
+
+	t.Log("Replay window test passed")
+}
+
+// 测试加密数据包与头部AAD绑定
+func TestEncryptTransData(t *testing.T) {
+	manager := &AuthManager{
+		AuthId:        7001,
+		SessionKeyMgr: NewSessionKeyManager(),
+	}
+
+	data := []byte("post-auth payload")
+
+	// 无会话密钥时加密失败
+	if _, _, _, err := encryptTransData(manager, ModuleAuthMsg, 0, data); err == nil {
+		t.Fatal("Expected error without session key")
+	}
+
+	if _, err := manager.SessionKeyMgr.SetSessionKey(manager.AuthId, []byte("1234567890abcdef")); err != nil {
+		t.Fatalf("SetSessionKey failed: %v", err)
+	}
+
+	seq, flag, encrypted, err := encryptTransData(manager, ModuleAuthMsg, 0, data)
+	if err != nil {
+		t.Fatalf("encryptTransData failed: %v", err)
+	}
+	if flag&AuthFlagEncrypted == 0 {
+		t.Error("Expected encrypted flag to be set")
+	}
+	if len(encrypted) != len(data)+AuthEncryptOverhead {
+		t.Errorf("Unexpected ciphertext length: %d", len(encrypted))
+	}
+
+	// 序列号单调递增
+	seq2, _, _, err := encryptTransData(manager, ModuleAuthMsg, 0, data)
+	if err != nil || seq2 <= seq {
+		t.Errorf("Expected increasing seq, got %d then %d (err=%v)", seq, seq2, err)
+	}
+
+	// 头部一致时解密成功
+	plaintext, err := manager.SessionKeyMgr.DecryptWithAAD(manager.AuthId, encrypted, buildAuthFrameAAD(ModuleAuthMsg, seq, flag))
+	if err != nil {
+		t.Fatalf("DecryptWithAAD failed: %v", err)
+	}
+	if !bytes.Equal(plaintext, data) {
+		t.Errorf("Plaintext mismatch: %q", plaintext)
+	}
+
+	// 篡改 Seq/Module/Flag 任一字段均解密失败
+	tampered := [][]byte{
+		buildAuthFrameAAD(ModuleAuthMsg, seq+1, flag),
+		buildAuthFrameAAD(ModuleAuthChannel, seq, flag),
+		buildAuthFrameAAD(ModuleAuthMsg, seq, flag|1),
+	}
+	for i, aad := range tampered {
+		if _, err := manager.SessionKeyMgr.DecryptWithAAD(manager.AuthId, encrypted, aad); err == nil {
+			t.Errorf("Expected decryption failure for tampered header #%d", i)
+		}
+	}
+
+	// 调用方不能自行设置加密标志
+	if _, _, _, err := encryptTransData(manager, ModuleAuthMsg, AuthFlagEncrypted, data); err == nil {
+		t.Error("Expected error for reserved flag")
+	}
+
+	// 超出最大长度
+	if _, _, _, err := encryptTransData(manager, ModuleAuthMsg, 0, make([]byte, AuthSocketMaxDataLen)); err == nil {
+		t.Error("Expected error for oversized data")
+	}
+
+	t.Log("Encrypt trans data test passed")
+}
+
+// 测试握手模块判定
+func TestIsHandshakeModule(t *testing.T) {
+	for _, module := range []int32{ModuleTrustEngine, ModuleAuthSdk, ModuleMetaAuth} {
+		if !isHandshakeModule(module) {
+			t.Errorf("Expected module=%d to be handshake module", module)
+		}
+	}
+	for _, module := range []int32{ModuleAuthConnection, ModuleAuthChannel, ModuleAuthMsg} {
+		if isHandshakeModule(module) {
+			t.Errorf("Expected module=%d to be encrypted", module)
+		}
+	}
+}
+
+// 测试认证通过后AuthPostChannelData发送的数据加密后仍能被对端接收，明文数据被丢弃
+func TestAuthPostChannelData_AfterAuth(t *testing.T) {
+	serverFd := make(chan int, 1)
+	err := SetSocketCallback(&SocketCallback{
+		OnConnected: func(module ListenerModule, fd int, isClient bool) {
+			if !isClient {
+				serverFd <- fd
+			}
+		},
+		OnDisconnected: func(fd int) {},
+		OnDataReceived: func(module ListenerModule, fd int, head *AuthDataHead, data []byte) {},
+	})
+	if err != nil {
+		t.Fatalf("SetSocketCallback failed: %v", err)
+	}
+
+	received := make(chan *AuthChannelData, 4)
+	err = RegAuthChannelListener(ModuleAuthMsg, &AuthChannelListener{
+		OnDataReceived: func(channelId int, data *AuthChannelData) { received <- data },
+	})
+	if err != nil {
+		t.Fatalf("RegAuthChannelListener failed: %v", err)
+	}
+	defer UnregAuthChannelListener(ModuleAuthMsg)
+
+	port, err := StartSocketListening(Auth, "127.0.0.1", 0)
+	if err != nil {
+		t.Fatalf("StartSocketListening failed: %v", err)
+	}
+	defer StopSocketListening()
+
+	clientFd := AuthOpenChannel("127.0.0.1", port)
+	if clientFd == InvalidChannelId {
+		t.Fatal("AuthOpenChannel failed")
+	}
+	defer AuthCloseChannel(clientFd)
+
+	var peerFd int
+	select {
+	case peerFd = <-serverFd:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Server side not connected")
+	}
+
+	// 构造双方认证通过、会话密钥相同的连接
+	g_authConnManagerMu.Lock()
+	saved := g_authConnManager
+	g_authConnManager = &AuthConnectionManager{
+		connections: make(map[uint64]*AuthConnection),
+		fdToConnId:  make(map[int]uint64),
+		requests:    make(map[uint32]*ConnectRequest),
+	}
+	g_authConnManagerMu.Unlock()
+	defer func() {
+		g_authConnManagerMu.Lock()
+		g_authConnManager = saved
+		g_authConnManagerMu.Unlock()
+	}()
+
+	service := getAuthManagerService()
+	key := []byte("1234567890abcdef")
+	var managers []*AuthManager
+	for i, fd := range []int{clientFd, peerFd} {
+		connId := uint64(0x7000+i)<<32 | uint64(fd)
+		g_authConnManager.connections[connId] = &AuthConnection{ConnId: connId, Fd: fd}
+		g_authConnManager.fdToConnId[fd] = connId
+
+		manager := &AuthManager{
+			AuthId:        int64(9100 + i),
+			ConnId:        connId,
+			HasAuthPassed: true,
+			SessionKeyMgr: NewSessionKeyManager(),
+		}
+		manager.SessionKeyMgr.SetSessionKey(manager.AuthId, key)
+		service.mu.Lock()
+		service.managers[manager.AuthId] = manager
+		service.connIdToAuthId[connId] = manager.AuthId
+		service.mu.Unlock()
+		managers = append(managers, manager)
+	}
+	defer func() {
+		service.mu.Lock()
+		for _, manager := range managers {
+			delete(service.managers, manager.AuthId)
+			delete(service.connIdToAuthId, manager.ConnId)
+		}
+		service.mu.Unlock()
+	}()
+
+	payload := []byte(`{"MSG_TYPE":90}`)
+	err = AuthPostChannelData(clientFd, &AuthChannelData{
+		Module: ModuleAuthMsg,
+		Seq:    1,
+		Len:    uint32(len(payload)),
+		Data:   payload,
+	})
+	if err != nil {
+		t.Fatalf("AuthPostChannelData failed: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if !bytes.Equal(data.Data, payload) || data.Flag&AuthFlagEncrypted != 0 {
+			t.Errorf("Unexpected data: flag=0x%x, data=%q", data.Flag, data.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Post-auth channel data not delivered")
+	}
+	if managers[0].sendSeq != 1 {
+		t.Errorf("Expected data to be sent encrypted, sendSeq=%d", managers[0].sendSeq)
+	}
+
+	// 绕过加密直接发送的明文被丢弃
+	head := &AuthDataHead{DataType: DataTypeConnection, Module: ModuleAuthMsg, Seq: 2, Len: uint32(len(payload))}
+	if err := SocketPostBytes(clientFd, head, payload); err != nil {
+		t.Fatalf("SocketPostBytes failed: %v", err)
+	}
+	select {
+	case data := <-received:
+		t.Errorf("Expected plaintext after auth to be dropped, got %q", data.Data)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
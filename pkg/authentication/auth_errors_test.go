@@ -0,0 +1,29 @@
+package authentication
+
+import (
+	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth"
+)
+
+// 测试device_auth错误码到认证结果的转换：界面可区分PIN码错误、超时、取消等原因
+func TestAuthResultFromError(t *testing.T) {
+	tests := []struct {
+		errorCode int32
+		want      int32
+	}{
+		{device_auth.HC_ERR_PIN_MISMATCH, AuthResultPinMismatch},
+		{device_auth.HC_ERR_VERSION_UNSUPPORTED, AuthResultVersionUnsupported},
+		{device_auth.HC_ERR_REQUEST_CANCELLED, AuthResultCancelled},
+		{device_auth.HC_ERR_PEER_CANCELLED, AuthResultPeerCancelled},
+		{device_auth.HC_ERR_TIMEOUT, AuthResultTimeout},
+		{device_auth.HC_ERR_LOCKED_OUT, AuthResultLockedOut},
+		{device_auth.HC_ERR_REQUEST_REJECTED, AuthResultRejected},
+		{device_auth.HC_ERR, AuthResultFailed},
+	}
+	for _, tt := range tests {
+		if got := authResultFromError(tt.errorCode); got != tt.want {
+			t.Errorf("authResultFromError(%d) = %d, want %d", tt.errorCode, got, tt.want)
+		}
+	}
+}
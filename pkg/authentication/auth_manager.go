@@ -1,6 +1,8 @@
 package authentication
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -22,10 +24,16 @@ const (
 
 const (
 	// 认证结果常量
-	AuthResultSuccess        int32 = 0  // 认证成功
-	AuthResultFailed         int32 = -1 // 认证失败
-	AuthResultTimeout        int32 = -2 // 认证超时
-	AuthResultConnectionLost int32 = -3 // 连接断开
+	AuthResultSuccess            int32 = 0  // 认证成功
+	AuthResultFailed             int32 = -1 // 认证失败
+	AuthResultTimeout            int32 = -2 // 认证超时
+	AuthResultConnectionLost     int32 = -3 // 连接断开
+	AuthResultRejected           int32 = -4 // 被拒绝（准入策略或对端拒绝配对）
+	AuthResultCancelled          int32 = -5 // 认证请求被取消
+	AuthResultPinMismatch        int32 = -6 // PIN码错误
+	AuthResultVersionUnsupported int32 = -7 // 与对端协议版本不兼容
+	AuthResultPeerCancelled      int32 = -8 // 对端取消了认证
+	AuthResultLockedOut          int32 = -9 // 配对失败次数过多，处于锁定期
 )
 
 // ============================================================================
@@ -69,11 +77,28 @@ type AuthManager struct {
 	SessionKeyMgr  *SessionKeyManager // Session Key管理器
 	HiChainHandle  interface{}        // HiChain句柄（预留）
 	DeviceInfo     *DeviceInfo        // 对端设备信息
+	PeerUdid       string             // 对端设备UDID（认证完成后确定）
 	RequestId      uint32             // 原始请求ID（用于回调）
+	authFailed     bool               // HiChain已通过OnError上报认证失败
+
+	sendSeq         int64         // 加密数据发送序列号（原子递增）
+	recvWindow      ReplayWindow  // 加密数据接收重放窗口
+	bytesSinceRekey uint64        // 当前密钥已加密的字节数（原子累加）
+	rekey           *rekeyPending // 进行中的密钥更新请求
+	rekeyTriggered  int32         // 流量触发的密钥更新是否已在执行（原子标志）
+	rekeyExpired    int32         // 密钥超过硬上限已断开连接（原子标志）
+	rekeyFailures   int           // 连续失败的密钥更新次数
+	rekeyRetryAt    time.Time     // 失败后允许再次发起密钥更新的时间
+	peerCapability  uint32        // 对端声明的扩展能力
 
 	mu sync.RWMutex // 保护结构体字段
 }
 
+// nextSendSeq 分配下一个加密数据发送序列号
+func (m *AuthManager) nextSendSeq() int64 {
+	return atomic.AddInt64(&m.sendSeq, 1)
+}
+
 // AuthManagerService 认证管理服务
 // 全局单例，管理所有认证会话
 type AuthManagerService struct {
@@ -84,6 +109,8 @@ type AuthManagerService struct {
 	initialized    bool                   // 是否已初始化
 	authIdCounter  int64                  // AuthId计数器（原子递增）
 	seqCounter     int64                  // Seq计数器（原子递增）
+	rekeyStop      chan struct{}          // 停止密钥更新定时检查
+	persistor      SessionKeyPersistor    // 会话密钥持久化实现（跨重新初始化保留）
 
 	mu sync.RWMutex // 保护服务状态
 }
@@ -157,6 +184,10 @@ func AuthDeviceInit(callback *AuthConnCallback) error {
 	service.callback = callback
 	service.initialized = true
 
+	// 启动密钥更新定时检查
+	service.rekeyStop = make(chan struct{})
+	go rekeyMonitor(service.rekeyStop)
+
 	log.Info("[AUTH_MGR] Auth manager service initialized")
 	return nil
 }
@@ -176,6 +207,11 @@ func (s *AuthManagerService) deinitInternal() {
 		return
 	}
 
+	if s.rekeyStop != nil {
+		close(s.rekeyStop)
+		s.rekeyStop = nil
+	}
+
 	// 收集所有connId（先收集，避免在迭代时修改map）
 	connIds := make([]uint64, 0, len(s.managers))
 	for _, mgr := range s.managers {
@@ -208,6 +244,9 @@ func (s *AuthManagerService) deinitInternal() {
 
 	// 清理剩余资源
 	s.sessionKeyMgr = NewSessionKeyManager()
+	if s.persistor != nil {
+		s.sessionKeyMgr.RegisterPersistor(s.persistor)
+	}
 	s.callback = nil
 	s.initialized = false
 
@@ -243,6 +282,13 @@ func AuthDeviceOpenConn(connInfo *AuthConnInfo, requestId uint32, callback *Auth
 		return fmt.Errorf("connInfo cannot be nil")
 	}
 
+	// 目标设备UDID已知时，连接前检查UDID允许/拒绝列表
+	if connInfo.Udid != "" {
+		if err := checkPeerUdid(connInfo.Udid); err != nil {
+			return err
+		}
+	}
+
 	// 更新全局回调（如果提供）
 	if callback != nil {
 		service.mu.Lock()
@@ -320,11 +366,74 @@ func AuthDeviceCloseConn(authId int64) {
 	log.Infof("[AUTH_MGR] Auth manager removed: authId=%d, connId=%d", authId, connId)
 }
 
+// AuthDeviceCancelConn 取消进行中的认证请求（如用户关闭了配对对话框）
+// 取消HiChain认证（通知对端）并删除认证会话，连接仅为该请求打开，一并关闭
+// 结果通过OnConnOpenFailed(requestId, AuthResultCancelled)上报
+// requestId: AuthDeviceOpenConn使用的请求ID
+func AuthDeviceCancelConn(requestId uint32) error {
+	service := getAuthManagerService()
+	service.mu.RLock()
+	var manager *AuthManager
+	for _, mgr := range service.managers {
+		if !mgr.IsServer && mgr.RequestId == requestId {
+			manager = mgr
+			break
+		}
+	}
+	callback := service.callback
+	service.mu.RUnlock()
+
+	if manager == nil {
+		return fmt.Errorf("auth request not found: requestId=%d", requestId)
+	}
+	manager.mu.RLock()
+	passed := manager.HasAuthPassed
+	manager.mu.RUnlock()
+	if passed {
+		return fmt.Errorf("auth already finished: requestId=%d", requestId)
+	}
+
+	log.Infof("[AUTH_MGR] Cancelling auth request: requestId=%d, authId=%d, authSeq=%d",
+		requestId, manager.AuthId, manager.AuthSeq)
+
+	// HiChain认证进行中时由其OnError上报取消结果
+	reported := AuthSessionCancel(manager.AuthSeq)
+	AuthDeviceCloseConn(manager.AuthId)
+
+	if !reported && callback != nil && callback.OnConnOpenFailed != nil {
+		callback.OnConnOpenFailed(requestId, AuthResultCancelled)
+	}
+	return nil
+}
+
+// authResultFromError 将device_auth错误码转换为认证结果
+func authResultFromError(errorCode int32) int32 {
+	switch errorCode {
+	case device_auth.HC_ERR_REQUEST_CANCELLED:
+		return AuthResultCancelled
+	case device_auth.HC_ERR_PEER_CANCELLED:
+		return AuthResultPeerCancelled
+	case device_auth.HC_ERR_PIN_MISMATCH:
+		return AuthResultPinMismatch
+	case device_auth.HC_ERR_VERSION_UNSUPPORTED:
+		return AuthResultVersionUnsupported
+	case device_auth.HC_ERR_TIMEOUT:
+		return AuthResultTimeout
+	case device_auth.HC_ERR_LOCKED_OUT:
+		return AuthResultLockedOut
+	case device_auth.HC_ERR_REQUEST_REJECTED:
+		return AuthResultRejected
+	default:
+		return AuthResultFailed
+	}
+}
+
 // ============================================================================
 // 数据发送
 // ============================================================================
 
 // AuthDevicePostTransData 发送传输数据（对应C的AuthDevicePostTransData）
+// 除握手模块外，数据均使用最新会话密钥加密，未协商出会话密钥时返回错误
 // authId: 认证ID
 // module: 模块ID
 // flag: 标志位
@@ -349,6 +458,17 @@ func AuthDevicePostTransData(authId int64, module int32, flag int32, data []byte
 	authSeq := manager.AuthSeq
 	manager.mu.RUnlock()
 
+	// 非握手模块使用会话密钥加密，Seq改用独立的发送序列号
+	encrypt := !isHandshakeModule(module)
+	plainLen := len(data)
+	if encrypt {
+		seq, encFlag, encrypted, err := encryptTransData(manager, module, flag, data)
+		if err != nil {
+			return err
+		}
+		authSeq, flag, data = seq, encFlag, encrypted
+	}
+
 	// 构造数据头
 	head := &AuthDataHead{
 		DataType: ModuleToDataType(module),
@@ -371,6 +491,10 @@ func AuthDevicePostTransData(authId int64, module int32, flag int32, data []byte
 	manager.LastActiveTime = time.Now()
 	manager.mu.Unlock()
 
+	if encrypt {
+		checkRekeyByBytes(manager, module, plainLen)
+	}
+
 	return nil
 }
 
@@ -378,6 +502,39 @@ func AuthDevicePostTransData(authId int64, module int32, flag int32, data []byte
 // Session Key 管理
 // ============================================================================
 
+// SetSessionKeyPersistor 设置会话密钥持久化实现
+// 设置后对端会话密钥在认证完成、密钥更新时写入持久化存储，重新初始化后依然生效
+// persistor: 持久化实现，nil表示关闭持久化
+func SetSessionKeyPersistor(persistor SessionKeyPersistor) {
+	service := getAuthManagerService()
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	service.persistor = persistor
+	service.sessionKeyMgr.RegisterPersistor(persistor)
+}
+
+// EnableFileSessionKeyPersistor 启用基于文件的会话密钥持久化
+// dataDir: 数据目录
+func EnableFileSessionKeyPersistor(dataDir string) (*FileSessionKeyPersistor, error) {
+	persistor, err := NewFileSessionKeyPersistor(dataDir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	SetSessionKeyPersistor(persistor)
+	log.Infof("[AUTH_MGR] File session key persistor enabled: dir=%s", dataDir)
+	return persistor, nil
+}
+
+// GetSessionKeyPersistor 获取当前的会话密钥持久化实现
+func GetSessionKeyPersistor() SessionKeyPersistor {
+	service := getAuthManagerService()
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+	return service.persistor
+}
+
 // AuthManagerSetSessionKey 设置会话密钥（对应C的AuthManagerSetSessionKey）
 // authId: 认证ID
 // sessionKey: 会话密钥（16字节）
@@ -513,6 +670,27 @@ func AuthDeviceGetDeviceUuid(authId int64) (string, error) {
 	return manager.DeviceInfo.UUID, nil
 }
 
+// AuthDeviceGetPeerUdid 获取对端设备UDID
+// 优先使用认证过程中确认的对端身份，其次使用连接信息中的UDID
+// authId: 认证ID
+func AuthDeviceGetPeerUdid(authId int64) (string, error) {
+	manager, err := GetAuthManagerByAuthId(authId)
+	if err != nil {
+		return "", err
+	}
+
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	if manager.PeerUdid != "" {
+		return manager.PeerUdid, nil
+	}
+	if manager.ConnInfo != nil && manager.ConnInfo.Udid != "" {
+		return manager.ConnInfo.Udid, nil
+	}
+	return "", fmt.Errorf("peer udid not available: authId=%d", authId)
+}
+
 // AuthDeviceGetVersion 获取软总线版本（对应C的AuthDeviceGetVersion）
 // authId: 认证ID
 func AuthDeviceGetVersion(authId int64) (*SoftBusVersion, error) {
@@ -669,6 +847,58 @@ func onAuthConnectResult(requestId uint32, connId uint64, result int32, connInfo
 	}
 }
 
+// startServerAuth 服务端通过准入检查后创建AuthManager并启动认证会话
+// 返回false表示连接已断开或认证会话启动失败
+func startServerAuth(connId uint64, connInfo *AuthConnInfo) bool {
+	service := getAuthManagerService()
+	service.mu.Lock()
+	if _, exists := service.connIdToAuthId[connId]; exists {
+		service.mu.Unlock()
+		return true
+	}
+
+	// 审批期间连接可能已断开（断开时先释放准入记录，再移除AuthManager）
+	if !isConnPending(connId) {
+		service.mu.Unlock()
+		log.Warnf("[AUTH_MGR] Connection closed before auth started: connId=%d", connId)
+		return false
+	}
+
+	authId := atomic.AddInt64(&service.authIdCounter, 1)
+	authSeq := atomic.AddInt64(&service.seqCounter, 1)
+
+	manager := &AuthManager{
+		AuthId:         authId,
+		AuthSeq:        authSeq,
+		ConnId:         connId,
+		ConnInfo:       connInfo,
+		IsServer:       true,
+		HasAuthPassed:  false,
+		LastActiveTime: time.Now(),
+		SessionKeyMgr:  service.sessionKeyMgr,
+		RequestId:      0,
+	}
+
+	service.managers[authId] = manager
+	service.connIdToAuthId[connId] = authId
+	service.mu.Unlock()
+
+	log.Infof("[AUTH_MGR] Created server AuthManager: authId=%d, connId=%d", authId, connId)
+
+	// 启动认证会话并关联AuthManager
+	if err := AuthSessionStartAuth(authSeq, 0, connId, connInfo, true); err != nil {
+		log.Errorf("[AUTH_MGR] Failed to start auth session: %v", err)
+		return false
+	}
+
+	// 关联AuthSession到AuthManager
+	session, err := GetAuthSessionByConnId(connId)
+	if err == nil {
+		session.AuthManager = manager
+	}
+	return true
+}
+
 // onAuthDisconnected Auth Connection断开回调
 func onAuthDisconnected(connId uint64, connInfo *AuthConnInfo) {
 	service := getAuthManagerService()
@@ -695,55 +925,58 @@ func onAuthDisconnected(connId uint64, connInfo *AuthConnInfo) {
 
 // onAuthDataReceived Auth Connection数据接收回调
 func onAuthDataReceived(connId uint64, connInfo *AuthConnInfo, fromServer bool, head *AuthDataHead, data []byte) {
-	service := getAuthManagerService()
+	// 等待应用层审批的连接，数据按接收顺序暂存
+	if fromServer && queueApprovalData(connId, head, data) {
+		return
+	}
+	handleAuthData(connId, connInfo, fromServer, head, data, false)
+}
 
-	service.mu.Lock()
-	authId, exists := service.connIdToAuthId[connId]
-	if !exists {
-		// 服务端收到第一个认证数据时，创建AuthManager和AuthSession
-		if fromServer && head.Module == ModuleAuthSdk {
-			authId = atomic.AddInt64(&service.authIdCounter, 1)
-			authSeq := atomic.AddInt64(&service.seqCounter, 1)
-
-			manager := &AuthManager{
-				AuthId:         authId,
-				AuthSeq:        authSeq,
-				ConnId:         connId,
-				ConnInfo:       connInfo,
-				IsServer:       true,
-				HasAuthPassed:  false,
-				LastActiveTime: time.Now(),
-				SessionKeyMgr:  service.sessionKeyMgr,
-				RequestId:      0,
-			}
+// handleAuthData 处理认证连接数据，approved表示连接已通过应用层审批
+func handleAuthData(connId uint64, connInfo *AuthConnInfo, fromServer bool, head *AuthDataHead, data []byte, approved bool) {
+	service := getAuthManagerService()
 
-			service.managers[authId] = manager
-			service.connIdToAuthId[connId] = authId
-			service.mu.Unlock()
+	service.mu.RLock()
+	_, exists := service.connIdToAuthId[connId]
+	service.mu.RUnlock()
 
-			log.Infof("[AUTH_MGR] Created server AuthManager: authId=%d, connId=%d", authId, connId)
+	if !exists {
+		// 服务端收到第一个认证数据（HiChain或快速重连）时，通过准入检查后创建AuthManager和AuthSession
+		if !fromServer || (head.Module != ModuleAuthSdk && head.Module != ModuleAuthResume) {
+			log.Warnf("[AUTH_MGR] AuthId not found for connId=%d", connId)
+			return
+		}
 
-			// 启动认证会话并关联AuthManager
-			if err := AuthSessionStartAuth(authSeq, 0, connId, connInfo, true); err != nil {
-				log.Errorf("[AUTH_MGR] Failed to start auth session: %v", err)
+		if !approved {
+			// 按对端声明的UDID提前拒绝，不进入PAKE
+			if err := checkClaimedUdid(claimedPeerUdid(head.Module, data)); err != nil {
+				log.Warnf("[AUTH_MGR] Connection rejected before auth: connId=%d, err=%v", connId, err)
+				DisconnectAuthDevice(connId)
 				return
 			}
 
-			// 关联AuthSession到AuthManager
-			session, err := GetAuthSessionByConnId(connId)
-			if err == nil {
-				session.AuthManager = manager
+			// 应用层审批不在接收goroutine中执行，通过后重新处理暂存的数据
+			if requestApproval(connId, connInfo, head, data, func(head *AuthDataHead, data []byte) {
+				handleAuthData(connId, connInfo, fromServer, head, data, true)
+			}) {
+				return
 			}
+		}
 
-			// 继续处理数据
-			service.mu.Lock()
-		} else {
-			service.mu.Unlock()
-			log.Warnf("[AUTH_MGR] AuthId not found for connId=%d", connId)
+		if !startServerAuth(connId, connInfo) {
 			return
 		}
 	}
 
+	// 重新查找：期间连接可能已断开
+	service.mu.Lock()
+	authId, exists := service.connIdToAuthId[connId]
+	if !exists {
+		service.mu.Unlock()
+		log.Warnf("[AUTH_MGR] AuthId not found for connId=%d", connId)
+		return
+	}
+
 	manager := service.managers[authId]
 	service.mu.Unlock()
 
@@ -778,8 +1011,21 @@ func onAuthDataReceived(connId uint64, connInfo *AuthConnInfo, fromServer bool,
 
 	case ModuleAuthConnection:
 		// MODULE_AUTH_CONNECTION (5) - 设备信息交换
-		// TODO: 实现 AuthSessionProcessDevInfoData
-		log.Warnf("[AUTH_MGR] MODULE_AUTH_CONNECTION not yet implemented")
+		// TODO: 实现 AuthSessionProcessDevInfoData，目前仅处理扩展能力协商
+		handleCapabilityData(manager, data)
+
+	case ModuleAuthRekey:
+		// MODULE_AUTH_REKEY (30) - 会话密钥更新
+		handleRekeyData(manager, data)
+
+	case ModuleAuthResume:
+		// MODULE_AUTH_RESUME (31) - 快速重连
+		if err := AuthSessionProcessResumeData(connId, data); err != nil {
+			log.Errorf("[AUTH_MGR] Failed to process resume data: %v", err)
+			if errors.Is(err, ErrAdmissionDenied) {
+				DisconnectAuthDevice(connId)
+			}
+		}
 
 	case ModuleAuthMsg:
 		// MODULE_AUTH_MSG (9) - 业务数据，直接回调到应用层
@@ -834,8 +1080,13 @@ func handleHiChainData(manager *AuthManager, head *AuthDataHead, data []byte) {
 	// 调用 ProcessData 处理认证数据
 	if err := ga.ProcessData(head.Seq, data, callback); err != nil {
 		log.Errorf("[AUTH_MGR] HiChain ProcessData failed: %v", err)
-		// 通知应用层认证失败
-		notifyAuthResult(manager, AuthResultFailed)
+		// 通知应用层认证失败（已通过OnError上报具体错误码时不再重复通知）
+		manager.mu.RLock()
+		reported := manager.authFailed
+		manager.mu.RUnlock()
+		if !reported {
+			notifyAuthResult(manager, AuthResultFailed)
+		}
 	}
 }
 
@@ -910,9 +1161,10 @@ func createDeviceAuthCallback(manager *AuthManager) *device_auth.DeviceAuthCallb
 				manager.AuthId, operationCode)
 
 			// 标记认证成功
-			manager.mu.Lock()
-			manager.HasAuthPassed = true
-			manager.mu.Unlock()
+			if err := onAuthPassed(manager, returnData); err != nil {
+				notifyAuthResult(manager, AuthResultRejected)
+				return
+			}
 
 			// 通知应用层认证成功
 			notifyAuthResult(manager, AuthResultSuccess)
@@ -923,8 +1175,12 @@ func createDeviceAuthCallback(manager *AuthManager) *device_auth.DeviceAuthCallb
 			log.Errorf("[AUTH_MGR] HiChain OnError: authId=%d, errorCode=%d, error=%s",
 				manager.AuthId, errorCode, errorReturn)
 
+			manager.mu.Lock()
+			manager.authFailed = true
+			manager.mu.Unlock()
+
 			// 通知应用层认证失败
-			notifyAuthResult(manager, AuthResultFailed)
+			notifyAuthResult(manager, authResultFromError(errorCode))
 		},
 
 		// OnRequest: HiChain 请求参数（预留）
@@ -936,6 +1192,47 @@ func createDeviceAuthCallback(manager *AuthManager) *device_auth.DeviceAuthCallb
 	}
 }
 
+// onAuthPassed 认证成功：记录对端身份并持久化会话密钥
+// returnData: HiChain OnFinish返回的JSON（包含peerUdid）
+// 返回: 对端UDID未通过准入检查时返回错误，此时会话密钥被删除、连接被断开
+func onAuthPassed(manager *AuthManager, returnData string) error {
+	var result struct {
+		PeerUdid string `json:"peerUdid"`
+	}
+	json.Unmarshal([]byte(returnData), &result)
+
+	peerUdid := result.PeerUdid
+	if peerUdid == "" {
+		peerUdid, _ = AuthDeviceGetPeerUdid(manager.AuthId)
+	}
+	if err := checkPeerUdid(peerUdid); err != nil {
+		log.Warnf("[AUTH_MGR] Peer rejected after auth: authId=%d, err=%v", manager.AuthId, err)
+		manager.SessionKeyMgr.RemoveAllSessionKeys(manager.AuthId)
+		DisconnectAuthDevice(manager.ConnId)
+		return err
+	}
+	markConnAuthenticated(manager.ConnId)
+
+	manager.mu.Lock()
+	manager.HasAuthPassed = true
+	if result.PeerUdid != "" {
+		manager.PeerUdid = result.PeerUdid
+	}
+	manager.mu.Unlock()
+
+	// 认证前对端UDID可能未知，密钥派生时的自动持久化会失败，这里补充保存
+	if err := manager.SessionKeyMgr.Persist(manager.AuthId); err != nil {
+		log.Warnf("[AUTH_MGR] Failed to persist session key: authId=%d, err=%v", manager.AuthId, err)
+	}
+	saveResumeTicket(manager)
+	recordPeerCapability(manager)
+
+	if err := sendCapability(manager); err != nil {
+		log.Warnf("[AUTH_MGR] Failed to send capability: authId=%d, err=%v", manager.AuthId, err)
+	}
+	return nil
+}
+
 // notifyAuthResult 通知应用层认证结果
 func notifyAuthResult(manager *AuthManager, result int32) {
 	service := getAuthManagerService()
@@ -0,0 +1,483 @@
+package authentication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/crypto"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 会话密钥更新（Rekey）
+// ============================================================================
+//
+// 协议流程（MODULE_AUTH_REKEY，使用当前会话密钥加密传输）:
+//   1. 发起方生成随机数Ni，发送 REKEY_REQ{keyIndex=N+1, nonce=Ni}
+//   2. 响应方生成随机数Nr，派生新密钥，使用旧密钥回复 REKEY_RESP{keyIndex=N+1, nonce=Nr, peerNonce=Ni}，
+//      然后安装新密钥
+//   3. 发起方收到响应后派生并安装新密钥
+//
+// 新密钥 = HKDF-SHA256(旧密钥, salt=Ni||Nr, info="softbus_auth_rekey"||keyIndex)
+// 旧密钥保留 GracePeriod 用于解密在途数据，之后删除。
+// 双方同时发起时，服务端的请求优先，客户端放弃自己的请求。
+//
+// 只有对端在能力协商中声明AuthCapabilityRekey时才发起（见auth_capability.go）。
+// 请求超时未响应时按指数退避（最长 AuthRekeyMaxBackoff）由流量/时间触发条件继续重试，主动调用AuthDeviceRekey不受退避限制。
+// 密钥使用时间或加密字节数超过触发阈值的 AuthRekeyHardLimitFactor 倍仍未更新时断开连接，重新认证后使用新密钥。
+
+const (
+	AuthRekeyNonceLen      = 16               // 随机数长度
+	AuthRekeyTimeout       = 10 * time.Second // 等待响应超时
+	AuthRekeyCheckInterval = time.Minute      // 定时检查间隔
+	AuthRekeyMaxBackoff    = 10 * time.Minute // 失败后重试的最长退避时间
+
+	AuthRekeyHardLimitFactor = 2 // 密钥使用超过触发阈值的倍数仍未更新时断开连接
+
+	authRekeyInfo = "softbus_auth_rekey"
+
+	rekeyMsgRequest  = "REKEY_REQ"
+	rekeyMsgResponse = "REKEY_RESP"
+)
+
+// RekeyPolicy 密钥更新策略
+type RekeyPolicy struct {
+	Interval    time.Duration // 密钥最长使用时间，0表示不按时间触发
+	MaxBytes    uint64        // 单个密钥最多加密的字节数，0表示不按流量触发
+	GracePeriod time.Duration // 旧密钥保留时长
+}
+
+// DefaultRekeyPolicy 默认密钥更新策略
+func DefaultRekeyPolicy() RekeyPolicy {
+	return RekeyPolicy{
+		Interval:    24 * time.Hour,
+		MaxBytes:    1 << 30,
+		GracePeriod: 30 * time.Second,
+	}
+}
+
+var (
+	g_rekeyPolicy   = DefaultRekeyPolicy()
+	g_rekeyPolicyMu sync.RWMutex
+)
+
+// SetRekeyPolicy 设置密钥更新策略
+func SetRekeyPolicy(policy RekeyPolicy) {
+	g_rekeyPolicyMu.Lock()
+	defer g_rekeyPolicyMu.Unlock()
+	g_rekeyPolicy = policy
+	log.Infof("[AUTH_REKEY] Rekey policy set: interval=%v, maxBytes=%d, grace=%v",
+		policy.Interval, policy.MaxBytes, policy.GracePeriod)
+}
+
+// GetRekeyPolicy 获取当前密钥更新策略
+func GetRekeyPolicy() RekeyPolicy {
+	g_rekeyPolicyMu.RLock()
+	defer g_rekeyPolicyMu.RUnlock()
+	return g_rekeyPolicy
+}
+
+// rekeyMessage 密钥更新消息
+type rekeyMessage struct {
+	MsgType   string `json:"msgType"`
+	KeyIndex  int32  `json:"keyIndex"`
+	Nonce     string `json:"nonce"`
+	PeerNonce string `json:"peerNonce,omitempty"`
+}
+
+// ErrRekeyUnsupported 对端未声明支持会话密钥更新
+var ErrRekeyUnsupported = errors.New("peer does not support rekey")
+
+// rekeyPending 发起方等待响应的状态
+type rekeyPending struct {
+	index     int32
+	nonce     []byte
+	startTime time.Time
+	timer     *time.Timer // 等待响应超时定时器
+}
+
+// AuthDeviceRekey 主动发起会话密钥更新
+// authId: 认证ID
+func AuthDeviceRekey(authId int64) error {
+	manager, err := GetAuthManagerByAuthId(authId)
+	if err != nil {
+		return err
+	}
+	return startRekey(manager, true)
+}
+
+// checkRekeyAllowedLocked 检查当前是否可以发起密钥更新（调用方持有manager.mu）
+// manual: 主动发起的请求不受失败退避限制
+func checkRekeyAllowedLocked(manager *AuthManager, manual bool) error {
+	switch {
+	case !manager.HasAuthPassed:
+		return fmt.Errorf("auth not passed: authId=%d", manager.AuthId)
+	case manager.peerCapability&AuthCapabilityRekey == 0:
+		return fmt.Errorf("%w: authId=%d", ErrRekeyUnsupported, manager.AuthId)
+	case manager.rekey != nil:
+		return fmt.Errorf("rekey already in progress: authId=%d", manager.AuthId)
+	case !manual && time.Now().Before(manager.rekeyRetryAt):
+		return fmt.Errorf("rekey backoff until %v: authId=%d", manager.rekeyRetryAt.Format(time.RFC3339), manager.AuthId)
+	}
+	return nil
+}
+
+// rekeyAllowed 判断自动触发条件满足时是否应发起密钥更新
+func rekeyAllowed(manager *AuthManager) bool {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	return checkRekeyAllowedLocked(manager, false) == nil
+}
+
+// startRekey 发起密钥更新请求
+// manual: 是否为主动调用AuthDeviceRekey发起
+func startRekey(manager *AuthManager, manual bool) error {
+	manager.mu.Lock()
+	if err := checkRekeyAllowedLocked(manager, manual); err != nil {
+		manager.mu.Unlock()
+		return err
+	}
+
+	latest, err := manager.SessionKeyMgr.GetLatestSessionKey(manager.AuthId)
+	if err != nil {
+		manager.mu.Unlock()
+		return fmt.Errorf("no session key: %w", err)
+	}
+
+	nonce, err := crypto.GenerateRandomBytes(AuthRekeyNonceLen)
+	if err != nil {
+		manager.mu.Unlock()
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	pending := &rekeyPending{
+		index:     latest.Index + 1,
+		nonce:     nonce,
+		startTime: time.Now(),
+	}
+	pending.timer = time.AfterFunc(AuthRekeyTimeout, func() {
+		failRekey(manager, pending, "timeout")
+	})
+	manager.rekey = pending
+	manager.mu.Unlock()
+
+	req := &rekeyMessage{
+		MsgType:  rekeyMsgRequest,
+		KeyIndex: pending.index,
+		Nonce:    hex.EncodeToString(nonce),
+	}
+	if err := sendRekeyMessage(manager.AuthId, req); err != nil {
+		failRekey(manager, pending, err.Error())
+		return err
+	}
+
+	log.Infof("[AUTH_REKEY] Rekey requested: authId=%d, keyIndex=%d", manager.AuthId, pending.index)
+	return nil
+}
+
+// failRekey 发起的密钥更新失败（超时或发送失败），记录失败次数并设置退避时间
+func failRekey(manager *AuthManager, pending *rekeyPending, reason string) {
+	manager.mu.Lock()
+	if manager.rekey != pending {
+		manager.mu.Unlock()
+		return
+	}
+	pending.timer.Stop()
+	manager.rekey = nil
+	manager.rekeyFailures++
+	failures := manager.rekeyFailures
+	backoff := rekeyBackoff(failures)
+	manager.rekeyRetryAt = time.Now().Add(backoff)
+	manager.mu.Unlock()
+
+	log.Warnf("[AUTH_REKEY] Rekey failed, retry after %v: authId=%d, attempts=%d, reason=%s",
+		backoff, manager.AuthId, failures, reason)
+}
+
+// rekeyBackoff 连续失败failures次后的退避时间：从AuthRekeyTimeout开始翻倍，最长AuthRekeyMaxBackoff
+func rekeyBackoff(failures int) time.Duration {
+	backoff := AuthRekeyTimeout
+	for i := 1; i < failures && backoff < AuthRekeyMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > AuthRekeyMaxBackoff {
+		backoff = AuthRekeyMaxBackoff
+	}
+	return backoff
+}
+
+// closeStaleKeyConn 密钥超过硬上限仍未完成更新，断开连接而不是继续使用旧密钥（只执行一次）
+func closeStaleKeyConn(manager *AuthManager, reason string) {
+	if !atomic.CompareAndSwapInt32(&manager.rekeyExpired, 0, 1) {
+		return
+	}
+	manager.mu.RLock()
+	failures := manager.rekeyFailures
+	manager.mu.RUnlock()
+
+	log.Errorf("[AUTH_REKEY] Session key exceeded hard limit without rekey, closing connection: authId=%d, attempts=%d, %s",
+		manager.AuthId, failures, reason)
+	// 可能在发送路径上调用，异步关闭避免重入
+	go AuthDeviceCloseConn(manager.AuthId)
+}
+
+// clearRekeyPendingLocked 清除进行中的密钥更新请求（调用方持有manager.mu）
+func clearRekeyPendingLocked(manager *AuthManager) {
+	if manager.rekey != nil && manager.rekey.timer != nil {
+		manager.rekey.timer.Stop()
+	}
+	manager.rekey = nil
+}
+
+// handleRekeyData 处理密钥更新消息
+func handleRekeyData(manager *AuthManager, data []byte) {
+	manager.mu.RLock()
+	passed := manager.HasAuthPassed
+	manager.mu.RUnlock()
+
+	// 认证通过后接收层已保证该消息经过加密校验
+	if !passed {
+		log.Warnf("[AUTH_REKEY] Rekey message before auth passed: authId=%d", manager.AuthId)
+		return
+	}
+
+	var msg rekeyMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Errorf("[AUTH_REKEY] Failed to parse rekey message: %v", err)
+		return
+	}
+
+	nonce, err := hex.DecodeString(msg.Nonce)
+	if err != nil || len(nonce) != AuthRekeyNonceLen {
+		log.Errorf("[AUTH_REKEY] Invalid rekey nonce: authId=%d", manager.AuthId)
+		return
+	}
+
+	switch msg.MsgType {
+	case rekeyMsgRequest:
+		handleRekeyRequest(manager, &msg, nonce)
+	case rekeyMsgResponse:
+		handleRekeyResponse(manager, &msg, nonce)
+	default:
+		log.Warnf("[AUTH_REKEY] Unknown rekey message: %s", msg.MsgType)
+	}
+}
+
+// handleRekeyRequest 响应方处理密钥更新请求
+func handleRekeyRequest(manager *AuthManager, msg *rekeyMessage, peerNonce []byte) {
+	manager.mu.Lock()
+	if manager.rekey != nil && time.Since(manager.rekey.startTime) < AuthRekeyTimeout {
+		// 双方同时发起：服务端请求优先
+		if manager.IsServer {
+			manager.mu.Unlock()
+			log.Infof("[AUTH_REKEY] Rekey collision, keep local request: authId=%d", manager.AuthId)
+			return
+		}
+		log.Infof("[AUTH_REKEY] Rekey collision, yield to peer: authId=%d", manager.AuthId)
+	}
+	clearRekeyPendingLocked(manager)
+	manager.mu.Unlock()
+
+	latest, err := manager.SessionKeyMgr.GetLatestSessionKey(manager.AuthId)
+	if err != nil {
+		log.Errorf("[AUTH_REKEY] No session key: authId=%d, err=%v", manager.AuthId, err)
+		return
+	}
+	if msg.KeyIndex != latest.Index+1 {
+		log.Warnf("[AUTH_REKEY] Unexpected key index: authId=%d, index=%d, latest=%d",
+			manager.AuthId, msg.KeyIndex, latest.Index)
+		return
+	}
+
+	nonce, err := crypto.GenerateRandomBytes(AuthRekeyNonceLen)
+	if err != nil {
+		log.Errorf("[AUTH_REKEY] Failed to generate nonce: %v", err)
+		return
+	}
+
+	newKey, err := deriveRekeyKey(latest.Key, peerNonce, nonce, msg.KeyIndex)
+	if err != nil {
+		log.Errorf("[AUTH_REKEY] Failed to derive key: %v", err)
+		return
+	}
+
+	// 响应使用旧密钥加密，发送后再安装新密钥
+	resp := &rekeyMessage{
+		MsgType:   rekeyMsgResponse,
+		KeyIndex:  msg.KeyIndex,
+		Nonce:     hex.EncodeToString(nonce),
+		PeerNonce: msg.Nonce,
+	}
+	if err := sendRekeyMessage(manager.AuthId, resp); err != nil {
+		log.Errorf("[AUTH_REKEY] Failed to send rekey response: %v", err)
+		return
+	}
+
+	installRekey(manager, latest.Index, msg.KeyIndex, newKey)
+}
+
+// handleRekeyResponse 发起方处理密钥更新响应
+func handleRekeyResponse(manager *AuthManager, msg *rekeyMessage, peerNonce []byte) {
+	manager.mu.Lock()
+	pending := manager.rekey
+	if pending == nil || pending.index != msg.KeyIndex || msg.PeerNonce != hex.EncodeToString(pending.nonce) {
+		manager.mu.Unlock()
+		log.Warnf("[AUTH_REKEY] Unexpected rekey response: authId=%d, index=%d", manager.AuthId, msg.KeyIndex)
+		return
+	}
+	clearRekeyPendingLocked(manager)
+	manager.mu.Unlock()
+
+	oldKey, err := manager.SessionKeyMgr.GetSessionKey(manager.AuthId, pending.index-1)
+	if err != nil {
+		log.Errorf("[AUTH_REKEY] Base key not found: authId=%d, err=%v", manager.AuthId, err)
+		return
+	}
+
+	newKey, err := deriveRekeyKey(oldKey.Key, pending.nonce, peerNonce, pending.index)
+	if err != nil {
+		log.Errorf("[AUTH_REKEY] Failed to derive key: %v", err)
+		return
+	}
+
+	installRekey(manager, oldKey.Index, pending.index, newKey)
+}
+
+// installRekey 安装新密钥，并在宽限期后删除旧密钥
+func installRekey(manager *AuthManager, oldIndex int32, newIndex int32, newKey []byte) {
+	authId := manager.AuthId
+	if err := manager.SessionKeyMgr.SetSessionKeyWithIndex(authId, newIndex, newKey); err != nil {
+		log.Errorf("[AUTH_REKEY] Failed to install key: authId=%d, err=%v", authId, err)
+		return
+	}
+	atomic.StoreUint64(&manager.bytesSinceRekey, 0)
+	manager.mu.Lock()
+	manager.rekeyFailures = 0
+	manager.rekeyRetryAt = time.Time{}
+	manager.mu.Unlock()
+	saveResumeTicket(manager)
+
+	keyMgr := manager.SessionKeyMgr
+	time.AfterFunc(GetRekeyPolicy().GracePeriod, func() {
+		keyMgr.RemoveSessionKey(authId, oldIndex)
+	})
+
+	log.Infof("[AUTH_REKEY] Session key rotated: authId=%d, index=%d -> %d", authId, oldIndex, newIndex)
+}
+
+// deriveRekeyKey 从旧密钥和双方随机数派生新密钥
+func deriveRekeyKey(oldKey []byte, initNonce []byte, respNonce []byte, index int32) ([]byte, error) {
+	salt := make([]byte, 0, len(initNonce)+len(respNonce))
+	salt = append(salt, initNonce...)
+	salt = append(salt, respNonce...)
+
+	var info bytes.Buffer
+	info.WriteString(authRekeyInfo)
+	binary.Write(&info, binary.BigEndian, index)
+
+	return crypto.DeriveKeyHKDF(oldKey, salt, info.Bytes(), len(oldKey))
+}
+
+// sendRekeyMessage 发送密钥更新消息
+func sendRekeyMessage(authId int64, msg *rekeyMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rekey message: %w", err)
+	}
+	return AuthDevicePostTransData(authId, ModuleAuthRekey, 0, data)
+}
+
+// ============================================================================
+// 触发条件
+// ============================================================================
+
+// checkRekeyByBytes 累计发送字节数，超过阈值时触发密钥更新
+// 同一时间最多一个触发中的请求，对端不支持、请求未完成或处于退避期时不触发；
+// 超过阈值的AuthRekeyHardLimitFactor倍时断开连接
+func checkRekeyByBytes(manager *AuthManager, module int32, n int) {
+	maxBytes := GetRekeyPolicy().MaxBytes
+	if maxBytes == 0 || module == ModuleAuthRekey {
+		return
+	}
+
+	sent := atomic.AddUint64(&manager.bytesSinceRekey, uint64(n))
+	if sent < maxBytes {
+		return
+	}
+	if sent/AuthRekeyHardLimitFactor >= maxBytes {
+		closeStaleKeyConn(manager, fmt.Sprintf("bytes=%d, maxBytes=%d", sent, maxBytes))
+		return
+	}
+	if !rekeyAllowed(manager) {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&manager.rekeyTriggered, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&manager.rekeyTriggered, 0)
+		if err := startRekey(manager, false); err != nil {
+			log.Warnf("[AUTH_REKEY] Byte-triggered rekey failed: %v", err)
+		}
+	}()
+}
+
+// checkRekeyByTime 最新密钥使用时间超过阈值时触发密钥更新，超过阈值的AuthRekeyHardLimitFactor倍时断开连接
+func checkRekeyByTime(manager *AuthManager) {
+	interval := GetRekeyPolicy().Interval
+	if interval <= 0 {
+		return
+	}
+
+	manager.mu.RLock()
+	passed := manager.HasAuthPassed
+	manager.mu.RUnlock()
+	if !passed {
+		return
+	}
+
+	latest, err := manager.SessionKeyMgr.GetLatestSessionKey(manager.AuthId)
+	if err != nil {
+		return
+	}
+	age := time.Since(latest.CreateTime)
+	if age < interval {
+		return
+	}
+	if age >= interval*AuthRekeyHardLimitFactor {
+		closeStaleKeyConn(manager, fmt.Sprintf("keyAge=%v, interval=%v", age.Round(time.Second), interval))
+		return
+	}
+	if !rekeyAllowed(manager) {
+		return
+	}
+
+	if err := startRekey(manager, false); err != nil {
+		log.Warnf("[AUTH_REKEY] Time-triggered rekey failed: %v", err)
+	}
+}
+
+// rekeyMonitor 定时检查所有认证连接的密钥使用时间
+func rekeyMonitor(stop <-chan struct{}) {
+	ticker := time.NewTicker(AuthRekeyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, manager := range GetAllAuthManagers() {
+				checkRekeyByTime(manager)
+			}
+		}
+	}
+}
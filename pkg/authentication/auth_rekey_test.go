@@ -0,0 +1,298 @@
+package authentication
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试密钥派生：双方结果一致，不同随机数或索引结果不同
+func TestDeriveRekeyKey(t *testing.T) {
+	oldKey := []byte("1234567890abcdef")
+	ni := bytes.Repeat([]byte{1}, AuthRekeyNonceLen)
+	nr := bytes.Repeat([]byte{2}, AuthRekeyNonceLen)
+
+	k1, err := deriveRekeyKey(oldKey, ni, nr, 1)
+	if err != nil {
+		t.Fatalf("deriveRekeyKey failed: %v", err)
+	}
+	k2, _ := deriveRekeyKey(oldKey, ni, nr, 1)
+	if !bytes.Equal(k1, k2) || len(k1) != len(oldKey) {
+		t.Error("Expected deterministic 16-byte key")
+	}
+
+	k3, _ := deriveRekeyKey(oldKey, nr, ni, 1)
+	k4, _ := deriveRekeyKey(oldKey, ni, nr, 2)
+	if bytes.Equal(k1, k3) || bytes.Equal(k1, k4) || bytes.Equal(k1, oldKey) {
+		t.Error("Expected different keys for different inputs")
+	}
+}
+
+// 测试指定索引设置密钥
+func TestSessionKeyManager_SetWithIndex(t *testing.T) {
+	manager := NewSessionKeyManager()
+	authId := int64(8001)
+	key := []byte("1234567890abcdef")
+
+	manager.SetSessionKey(authId, key)
+	if err := manager.SetSessionKeyWithIndex(authId, 0, key); err == nil {
+		t.Error("Expected error for non-increasing index")
+	}
+	if err := manager.SetSessionKeyWithIndex(authId, 1, key); err != nil {
+		t.Fatalf("SetSessionKeyWithIndex failed: %v", err)
+	}
+
+	latest, _ := manager.GetLatestSessionKey(authId)
+	if latest.Index != 1 {
+		t.Errorf("Expected latest index 1, got %d", latest.Index)
+	}
+}
+
+// 测试发起方处理响应：派生出与响应方一致的新密钥，宽限期后删除旧密钥
+func TestHandleRekeyResponse(t *testing.T) {
+	policy := GetRekeyPolicy()
+	defer SetRekeyPolicy(policy)
+	SetRekeyPolicy(RekeyPolicy{GracePeriod: 50 * time.Millisecond})
+
+	manager := &AuthManager{
+		AuthId:        8002,
+		HasAuthPassed: true,
+		SessionKeyMgr: NewSessionKeyManager(),
+	}
+	oldKey := []byte("1234567890abcdef")
+	manager.SessionKeyMgr.SetSessionKey(manager.AuthId, oldKey)
+
+	ni := bytes.Repeat([]byte{3}, AuthRekeyNonceLen)
+	nr := bytes.Repeat([]byte{4}, AuthRekeyNonceLen)
+	manager.rekey = &rekeyPending{index: 1, nonce: ni, startTime: time.Now()}
+
+	// 回显的随机数不匹配时忽略
+	handleRekeyResponse(manager, &rekeyMessage{
+		MsgType:   rekeyMsgResponse,
+		KeyIndex:  1,
+		Nonce:     hex.EncodeToString(nr),
+		PeerNonce: hex.EncodeToString(nr),
+	}, nr)
+	if manager.SessionKeyMgr.GetSessionKeyCount(manager.AuthId) != 1 {
+		t.Fatal("Expected mismatched response to be ignored")
+	}
+
+	handleRekeyResponse(manager, &rekeyMessage{
+		MsgType:   rekeyMsgResponse,
+		KeyIndex:  1,
+		Nonce:     hex.EncodeToString(nr),
+		PeerNonce: hex.EncodeToString(ni),
+	}, nr)
+
+	expected, _ := deriveRekeyKey(oldKey, ni, nr, 1)
+	latest, err := manager.SessionKeyMgr.GetLatestSessionKey(manager.AuthId)
+	if err != nil || latest.Index != 1 || !bytes.Equal(latest.Key, expected) {
+		t.Fatalf("Expected rotated key at index 1, got %+v (err=%v)", latest, err)
+	}
+	if manager.rekey != nil {
+		t.Error("Expected pending rekey to be cleared")
+	}
+
+	// 宽限期内旧密钥仍可用于解密
+	if _, err := manager.SessionKeyMgr.GetSessionKey(manager.AuthId, 0); err != nil {
+		t.Error("Expected old key to be kept during grace period")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := manager.SessionKeyMgr.GetSessionKey(manager.AuthId, 0); err == nil {
+		t.Error("Expected old key to be removed after grace period")
+	}
+}
+
+// 测试未认证时不能发起密钥更新
+func TestStartRekeyBeforeAuth(t *testing.T) {
+	manager := &AuthManager{
+		AuthId:        8003,
+		SessionKeyMgr: NewSessionKeyManager(),
+	}
+	if err := startRekey(manager, false); err == nil {
+		t.Error("Expected error before auth passed")
+	}
+}
+
+// 测试对端未声明支持时不发起密钥更新，收到能力消息后允许发起
+func TestStartRekeyUnsupportedPeer(t *testing.T) {
+	manager := &AuthManager{
+		AuthId:        8004,
+		HasAuthPassed: true,
+		SessionKeyMgr: NewSessionKeyManager(),
+	}
+	manager.SessionKeyMgr.SetSessionKey(manager.AuthId, []byte("1234567890abcdef"))
+
+	if err := startRekey(manager, false); !errors.Is(err, ErrRekeyUnsupported) {
+		t.Fatalf("Expected ErrRekeyUnsupported, got %v", err)
+	}
+
+	handleCapabilityData(manager, []byte(`{"msgType":"CAPABILITY","capability":1}`))
+	if !manager.peerSupports(AuthCapabilityRekey) || manager.peerSupports(AuthCapabilityResume) {
+		t.Fatalf("Unexpected peer capability: 0x%x", manager.peerCapability)
+	}
+	if !rekeyAllowed(manager) {
+		t.Error("Expected rekey to be allowed after capability exchange")
+	}
+}
+
+// 测试密钥更新失败后按指数退避（有上限）继续重试，主动发起不受退避限制，密钥更新成功后恢复
+func TestRekeyFailureBackoff(t *testing.T) {
+	manager := &AuthManager{
+		AuthId:         8005,
+		HasAuthPassed:  true,
+		SessionKeyMgr:  NewSessionKeyManager(),
+		peerCapability: AuthCapabilityRekey,
+	}
+	manager.SessionKeyMgr.SetSessionKey(manager.AuthId, []byte("1234567890abcdef"))
+
+	attempts := 10
+	for i := 1; i <= attempts; i++ {
+		pending := &rekeyPending{index: 1, startTime: time.Now(), timer: time.NewTimer(time.Hour)}
+		manager.rekey = pending
+		if rekeyAllowed(manager) {
+			t.Fatal("Expected rekey to be blocked while in progress")
+		}
+
+		failRekey(manager, pending, "timeout")
+		if manager.rekey != nil || manager.rekeyFailures != i {
+			t.Fatalf("Attempt %d: unexpected state: pending=%v, failures=%d", i, manager.rekey, manager.rekeyFailures)
+		}
+		if rekeyAllowed(manager) {
+			t.Fatalf("Attempt %d: expected rekey to be blocked after failure", i)
+		}
+		if backoff := time.Until(manager.rekeyRetryAt); backoff > AuthRekeyMaxBackoff {
+			t.Fatalf("Attempt %d: expected backoff capped at %v, got %v", i, AuthRekeyMaxBackoff, backoff)
+		}
+
+		// 退避期结束后继续允许重试，不会永久停止
+		manager.rekeyRetryAt = time.Time{}
+		if !rekeyAllowed(manager) {
+			t.Fatalf("Attempt %d: expected retry after backoff", i)
+		}
+	}
+	if rekeyBackoff(1) != AuthRekeyTimeout || rekeyBackoff(2) != 2*AuthRekeyTimeout || rekeyBackoff(attempts) != AuthRekeyMaxBackoff {
+		t.Errorf("Unexpected backoff: %v, %v, %v", rekeyBackoff(1), rekeyBackoff(2), rekeyBackoff(attempts))
+	}
+
+	// 主动发起不受退避限制
+	manager.rekeyRetryAt = time.Now().Add(time.Hour)
+	manager.mu.RLock()
+	if err := checkRekeyAllowedLocked(manager, false); err == nil {
+		t.Error("Expected automatic rekey to wait for backoff")
+	}
+	if err := checkRekeyAllowedLocked(manager, true); err != nil {
+		t.Errorf("Expected manual rekey to bypass backoff, got %v", err)
+	}
+	manager.mu.RUnlock()
+
+	// 过期的失败通知不影响状态
+	failRekey(manager, &rekeyPending{timer: time.NewTimer(time.Hour)}, "stale")
+	if manager.rekeyFailures != attempts {
+		t.Errorf("Expected stale failure to be ignored, failures=%d", manager.rekeyFailures)
+	}
+
+	installRekey(manager, 0, 1, []byte("abcdef1234567890"))
+	if manager.rekeyFailures != 0 || !rekeyAllowed(manager) {
+		t.Error("Expected failures to be reset after rekey")
+	}
+}
+
+// 测试密钥超过硬上限仍未更新时断开连接
+func TestRekeyHardLimit(t *testing.T) {
+	policy := GetRekeyPolicy()
+	defer SetRekeyPolicy(policy)
+	SetRekeyPolicy(RekeyPolicy{Interval: time.Hour, MaxBytes: 100, GracePeriod: time.Second})
+
+	// 流量：超过阈值后发起密钥更新，超过硬上限后断开
+	manager := &AuthManager{
+		AuthId:         8007,
+		HasAuthPassed:  true,
+		SessionKeyMgr:  NewSessionKeyManager(),
+		peerCapability: AuthCapabilityRekey,
+	}
+	manager.SessionKeyMgr.SetSessionKey(manager.AuthId, []byte("1234567890abcdef"))
+	manager.rekey = &rekeyPending{index: 1, startTime: time.Now(), timer: time.NewTimer(time.Hour)}
+	checkRekeyByBytes(manager, ModuleAuthMsg, 150)
+	if atomic.LoadInt32(&manager.rekeyExpired) != 0 {
+		t.Fatal("Expected connection kept below hard limit")
+	}
+	checkRekeyByBytes(manager, ModuleAuthMsg, 50)
+	if atomic.LoadInt32(&manager.rekeyExpired) != 1 {
+		t.Fatal("Expected connection closed at hard limit")
+	}
+
+	// 时间：对端不支持密钥更新时同样在硬上限断开
+	manager = &AuthManager{
+		AuthId:        8008,
+		HasAuthPassed: true,
+		SessionKeyMgr: NewSessionKeyManager(),
+	}
+	manager.SessionKeyMgr.SetSessionKey(manager.AuthId, []byte("1234567890abcdef"))
+	key, _ := manager.SessionKeyMgr.GetLatestSessionKey(manager.AuthId)
+	key.CreateTime = time.Now().Add(-90 * time.Minute)
+	checkRekeyByTime(manager)
+	if atomic.LoadInt32(&manager.rekeyExpired) != 0 {
+		t.Fatal("Expected connection kept below hard limit")
+	}
+	key.CreateTime = time.Now().Add(-2 * time.Hour)
+	checkRekeyByTime(manager)
+	if atomic.LoadInt32(&manager.rekeyExpired) != 1 {
+		t.Error("Expected connection closed when key age passes hard limit")
+	}
+}
+
+// 测试超过流量阈值后的多次发送只触发一次密钥更新
+func TestCheckRekeyByBytesSingleTrigger(t *testing.T) {
+	policy := GetRekeyPolicy()
+	defer SetRekeyPolicy(policy)
+	// 阈值低于总发送量，硬上限高于总发送量
+	SetRekeyPolicy(RekeyPolicy{MaxBytes: 2000, GracePeriod: time.Second})
+
+	// 未注册到认证服务，请求发送失败
+	manager := &AuthManager{
+		AuthId:         8006,
+		HasAuthPassed:  true,
+		SessionKeyMgr:  NewSessionKeyManager(),
+		peerCapability: AuthCapabilityRekey,
+	}
+	manager.SessionKeyMgr.SetSessionKey(manager.AuthId, []byte("1234567890abcdef"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkRekeyByBytes(manager, ModuleAuthMsg, 64)
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		manager.mu.RLock()
+		failures := manager.rekeyFailures
+		manager.mu.RUnlock()
+		if failures > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 第一次失败后处于退避期，后续发送不再触发
+	for i := 0; i < 10; i++ {
+		checkRekeyByBytes(manager, ModuleAuthMsg, 64)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	if manager.rekeyFailures != 1 {
+		t.Errorf("Expected exactly one rekey attempt, failures=%d", manager.rekeyFailures)
+	}
+}
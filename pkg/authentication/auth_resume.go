@@ -0,0 +1,491 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/crypto"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 快速重连认证（Resume）
+// ============================================================================
+//
+// 双方曾认证成功时，会以对端UDID为索引保存最后一次的会话密钥K（内存缓存见device_auth，
+// 持久化见SessionKeyPersistor）。再次连接时客户端先尝试快速重连，无需PIN码:
+//   1. 客户端发送 RESUME_REQ{udid=本端UDID, keyId, nonce=Nc}
+//   2. 服务端按(udid, keyId)查找K，发送 RESUME_RESP{udid=本端UDID, nonce=Ns, proof=HMAC(K, "server"...)}
+//      找不到K时回复 RESUME_REJECT
+//   3. 客户端校验服务端证明，发送 RESUME_FINISH{proof=HMAC(K, "client"...)}
+//   4. 双方派生新会话密钥 = HKDF-SHA256(K, salt=Nc||Ns, info="softbus_auth_resume")
+//
+// keyId = HMAC(K, "softbus_auth_resume_id") 前8字节，用于双方确认持有同一密钥而不暴露K。
+// 客户端只对曾在能力协商中声明AuthCapabilityResume的对端尝试快速重连（见auth_capability.go），
+// 不支持的对端不会响应MODULE_AUTH_RESUME，直接进行完整认证，避免等待超时。
+// 客户端在被拒绝、证明校验失败或超时时回退到完整的HiChain认证。
+// 握手消息明文传输（MODULE_AUTH_RESUME属于握手模块），安全性由双方证明保证。
+
+const (
+	AuthResumeNonceLen = 16              // 随机数长度
+	AuthResumeTimeout  = 5 * time.Second // 等待服务端响应超时
+
+	// AuthResumeTicketLifetime 会话密钥可用于快速重连的最长时间，超过后必须完整认证
+	AuthResumeTicketLifetime = 7 * 24 * time.Hour
+
+	authResumeKeyInfo     = "softbus_auth_resume"
+	authResumeKeyIdInfo   = "softbus_auth_resume_id"
+	authResumeServerLabel = "softbus_auth_resume_server"
+	authResumeClientLabel = "softbus_auth_resume_client"
+	authResumeKeyIdLen    = 8
+
+	resumeMsgRequest  = "RESUME_REQ"
+	resumeMsgResponse = "RESUME_RESP"
+	resumeMsgFinish   = "RESUME_FINISH"
+	resumeMsgReject   = "RESUME_REJECT"
+)
+
+// resumeMessage 快速重连消息
+type resumeMessage struct {
+	MsgType string `json:"msgType"`
+	Udid    string `json:"udid,omitempty"`
+	KeyId   string `json:"keyId,omitempty"`
+	Nonce   string `json:"nonce,omitempty"`
+	Proof   string `json:"proof,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// resumeState 进行中的快速重连
+type resumeState struct {
+	peerUdid   string      // 对端UDID
+	key        []byte      // 用于重连的旧会话密钥
+	localNonce []byte      // 本端随机数
+	peerNonce  []byte      // 对端随机数
+	newKey     []byte      // 派生出的新会话密钥
+	timer      *time.Timer // 客户端等待响应的超时定时器
+}
+
+// resumeKeyLoader 支持按UDID加载会话密钥的持久化实现（如FileSessionKeyPersistor）
+type resumeKeyLoader interface {
+	LoadByUdid(udid string) ([]*SessionKey, error)
+}
+
+// ============================================================================
+// 重连凭据
+// ============================================================================
+
+// saveResumeTicket 认证成功或密钥更新后缓存最新会话密钥
+func saveResumeTicket(manager *AuthManager) {
+	manager.mu.RLock()
+	peerUdid := manager.PeerUdid
+	manager.mu.RUnlock()
+	if peerUdid == "" {
+		return
+	}
+
+	latest, err := manager.SessionKeyMgr.GetLatestSessionKey(manager.AuthId)
+	if err != nil {
+		return
+	}
+	device_auth.SaveDeviceSessionKey(peerUdid, latest.Key)
+}
+
+// findResumeKeys 获取与对端设备共享的、仍在有效期内的会话密钥（较新的在前）
+func findResumeKeys(udid string) [][]byte {
+	var keys [][]byte
+	deadline := time.Now().Add(-AuthResumeTicketLifetime)
+
+	if key, lastAuth := device_auth.GetDeviceSessionKey(udid); key != nil && time.Unix(lastAuth, 0).After(deadline) {
+		keys = append(keys, key)
+	}
+
+	if loader, ok := GetSessionKeyPersistor().(resumeKeyLoader); ok {
+		if persisted, err := loader.LoadByUdid(udid); err == nil {
+			for i := len(persisted) - 1; i >= 0; i-- {
+				if persisted[i].CreateTime.After(deadline) {
+					keys = append(keys, persisted[i].Key)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// lookupResumeKey 按keyId查找与对端设备共享的会话密钥
+func lookupResumeKey(udid string, keyId string) []byte {
+	for _, key := range findResumeKeys(udid) {
+		if hmac.Equal([]byte(resumeKeyId(key)), []byte(keyId)) {
+			return key
+		}
+	}
+	return nil
+}
+
+// resumeKeyId 计算会话密钥标识
+func resumeKeyId(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(authResumeKeyIdInfo))
+	return hex.EncodeToString(mac.Sum(nil)[:authResumeKeyIdLen])
+}
+
+// resumeProof 计算证明: HMAC-SHA256(K, label||Nc||Ns||clientUdid||0||serverUdid)
+func resumeProof(key []byte, label string, clientNonce, serverNonce []byte, clientUdid, serverUdid string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	mac.Write(clientNonce)
+	mac.Write(serverNonce)
+	mac.Write([]byte(clientUdid))
+	mac.Write([]byte{0})
+	mac.Write([]byte(serverUdid))
+	return mac.Sum(nil)
+}
+
+// deriveResumeKey 派生快速重连后的新会话密钥
+func deriveResumeKey(key []byte, clientNonce, serverNonce []byte) ([]byte, error) {
+	salt := make([]byte, 0, len(clientNonce)+len(serverNonce))
+	salt = append(salt, clientNonce...)
+	salt = append(salt, serverNonce...)
+	return crypto.DeriveKeyHKDF(key, salt, []byte(authResumeKeyInfo), len(key))
+}
+
+// ============================================================================
+// 客户端
+// ============================================================================
+
+// peerUdid 会话对端UDID（客户端来自连接参数，可能为空）
+func (s *AuthSession) peerUdid() string {
+	if s.AuthManager != nil && s.AuthManager.ConnInfo != nil && s.AuthManager.ConnInfo.Udid != "" {
+		return s.AuthManager.ConnInfo.Udid
+	}
+	if s.ConnInfo != nil {
+		return s.ConnInfo.Udid
+	}
+	return ""
+}
+
+// startResume 客户端尝试快速重连（调用方持有s.mu）
+// 返回false表示没有可用的重连凭据，需要完整认证
+func (s *AuthSession) startResume() bool {
+	peerUdid := s.peerUdid()
+	if peerUdid == "" || s.AuthManager == nil {
+		return false
+	}
+
+	if capability, ok := getPeerCapability(peerUdid); !ok || capability&AuthCapabilityResume == 0 {
+		log.Infof("[AUTH_RESUME] Peer does not support resume: authSeq=%d, peer=%s", s.AuthSeq, peerUdid)
+		return false
+	}
+
+	keys := findResumeKeys(peerUdid)
+	if len(keys) == 0 {
+		return false
+	}
+
+	localUdid, err := GetLocalUDID()
+	if err != nil {
+		return false
+	}
+
+	nonce, err := crypto.GenerateRandomBytes(AuthResumeNonceLen)
+	if err != nil {
+		return false
+	}
+
+	s.resume = &resumeState{
+		peerUdid:   peerUdid,
+		key:        keys[0],
+		localNonce: nonce,
+	}
+	s.State = StateResume
+
+	err = s.sendResumeMessage(&resumeMessage{
+		MsgType: resumeMsgRequest,
+		Udid:    localUdid,
+		KeyId:   resumeKeyId(keys[0]),
+		Nonce:   hex.EncodeToString(nonce),
+	})
+	if err != nil {
+		log.Warnf("[AUTH_RESUME] Failed to send resume request: authSeq=%d, err=%v", s.AuthSeq, err)
+		s.resume = nil
+		return false
+	}
+
+	s.resume.timer = time.AfterFunc(AuthResumeTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.State == StateResume {
+			s.fallbackToFullAuth("timeout")
+		}
+	})
+
+	log.Infof("[AUTH_RESUME] Resume started: authSeq=%d, peer=%s", s.AuthSeq, peerUdid)
+	return true
+}
+
+// handleResumeResponse 客户端处理服务端响应（调用方持有s.mu）
+// 返回true表示重连成功
+func (s *AuthSession) handleResumeResponse(msg *resumeMessage) (bool, error) {
+	if s.State != StateResume || s.resume == nil {
+		return false, fmt.Errorf("unexpected resume response: state=%d", s.State)
+	}
+
+	serverNonce, err := hex.DecodeString(msg.Nonce)
+	if err != nil || len(serverNonce) != AuthResumeNonceLen {
+		s.fallbackToFullAuth("invalid nonce")
+		return false, nil
+	}
+	proof, _ := hex.DecodeString(msg.Proof)
+
+	localUdid, err := GetLocalUDID()
+	if err != nil {
+		s.fallbackToFullAuth("local udid unavailable")
+		return false, nil
+	}
+
+	state := s.resume
+	expected := resumeProof(state.key, authResumeServerLabel, state.localNonce, serverNonce, localUdid, state.peerUdid)
+	if msg.Udid != state.peerUdid || !hmac.Equal(proof, expected) {
+		s.fallbackToFullAuth("server proof mismatch")
+		return false, nil
+	}
+
+	newKey, err := deriveResumeKey(state.key, state.localNonce, serverNonce)
+	if err != nil {
+		s.fallbackToFullAuth("key derivation failed")
+		return false, nil
+	}
+
+	err = s.sendResumeMessage(&resumeMessage{
+		MsgType: resumeMsgFinish,
+		Proof:   hex.EncodeToString(resumeProof(state.key, authResumeClientLabel, state.localNonce, serverNonce, localUdid, state.peerUdid)),
+	})
+	if err != nil {
+		s.fallbackToFullAuth("send finish failed")
+		return false, nil
+	}
+
+	state.timer.Stop()
+	state.peerNonce = serverNonce
+	state.newKey = newKey
+	s.State = StateAuthDone
+	return true, nil
+}
+
+// fallbackToFullAuth 客户端放弃快速重连，回退到完整的HiChain认证（调用方持有s.mu）
+func (s *AuthSession) fallbackToFullAuth(reason string) {
+	if s.resume != nil && s.resume.timer != nil {
+		s.resume.timer.Stop()
+	}
+	s.resume = nil
+
+	log.Infof("[AUTH_RESUME] Falling back to full auth: authSeq=%d, reason=%s", s.AuthSeq, reason)
+
+	s.State = StateDeviceAuth
+	if err := s.startHiChainAuth(); err != nil {
+		log.Errorf("[AUTH_RESUME] Failed to start full auth: %v", err)
+		go s.notifyAuthResult(AuthResultFailed)
+	}
+}
+
+// ============================================================================
+// 服务端
+// ============================================================================
+
+// handleResumeRequest 服务端处理快速重连请求（调用方持有s.mu）
+func (s *AuthSession) handleResumeRequest(msg *resumeMessage) error {
+	if s.State != StateSyncDeviceId {
+		return fmt.Errorf("unexpected resume request: state=%d", s.State)
+	}
+
+	clientNonce, err := hex.DecodeString(msg.Nonce)
+	if err != nil || len(clientNonce) != AuthResumeNonceLen || msg.Udid == "" {
+		return s.rejectResume("invalid request")
+	}
+
+	if err := checkPeerUdid(msg.Udid); err != nil {
+		s.State = StateFailed
+		return err
+	}
+
+	key := lookupResumeKey(msg.Udid, msg.KeyId)
+	if key == nil {
+		return s.rejectResume("no ticket")
+	}
+
+	localUdid, err := GetLocalUDID()
+	if err != nil {
+		return s.rejectResume("local udid unavailable")
+	}
+
+	serverNonce, err := crypto.GenerateRandomBytes(AuthResumeNonceLen)
+	if err != nil {
+		return s.rejectResume("internal error")
+	}
+
+	s.resume = &resumeState{
+		peerUdid:   msg.Udid,
+		key:        key,
+		localNonce: serverNonce,
+		peerNonce:  clientNonce,
+	}
+	s.State = StateResume
+
+	log.Infof("[AUTH_RESUME] Resume request accepted: authSeq=%d, peer=%s", s.AuthSeq, msg.Udid)
+
+	return s.sendResumeMessage(&resumeMessage{
+		MsgType: resumeMsgResponse,
+		Udid:    localUdid,
+		Nonce:   hex.EncodeToString(serverNonce),
+		Proof:   hex.EncodeToString(resumeProof(key, authResumeServerLabel, clientNonce, serverNonce, msg.Udid, localUdid)),
+	})
+}
+
+// handleResumeFinish 服务端校验客户端证明（调用方持有s.mu）
+// 返回true表示重连成功
+func (s *AuthSession) handleResumeFinish(msg *resumeMessage) (bool, error) {
+	if s.State != StateResume || s.resume == nil {
+		return false, fmt.Errorf("unexpected resume finish: state=%d", s.State)
+	}
+
+	localUdid, err := GetLocalUDID()
+	if err != nil {
+		return false, err
+	}
+
+	state := s.resume
+	proof, _ := hex.DecodeString(msg.Proof)
+	expected := resumeProof(state.key, authResumeClientLabel, state.peerNonce, state.localNonce, state.peerUdid, localUdid)
+	if !hmac.Equal(proof, expected) {
+		// 对端无法证明持有密钥，不是声称的设备
+		s.resume = nil
+		s.State = StateFailed
+		return false, fmt.Errorf("client proof mismatch: peer=%s", state.peerUdid)
+	}
+
+	newKey, err := deriveResumeKey(state.key, state.peerNonce, state.localNonce)
+	if err != nil {
+		s.resume = nil
+		s.State = StateFailed
+		return false, err
+	}
+
+	state.newKey = newKey
+	s.State = StateAuthDone
+	return true, nil
+}
+
+// rejectResume 服务端拒绝快速重连，等待客户端发起完整认证（调用方持有s.mu）
+func (s *AuthSession) rejectResume(reason string) error {
+	log.Infof("[AUTH_RESUME] Resume rejected: authSeq=%d, reason=%s", s.AuthSeq, reason)
+	return s.sendResumeMessage(&resumeMessage{
+		MsgType: resumeMsgReject,
+		Reason:  reason,
+	})
+}
+
+// ============================================================================
+// 公共处理
+// ============================================================================
+
+// AuthSessionProcessResumeData 处理快速重连数据（MODULE_AUTH_RESUME）
+// 按connId查找会话，不依赖对端填写的Seq
+func AuthSessionProcessResumeData(connId uint64, data []byte) error {
+	session, err := GetAuthSessionByConnId(connId)
+	if err != nil {
+		return err
+	}
+
+	var msg resumeMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("failed to parse resume message: %w", err)
+	}
+
+	session.mu.Lock()
+	passed := false
+	switch {
+	case session.IsServer && msg.MsgType == resumeMsgRequest:
+		err = session.handleResumeRequest(&msg)
+	case session.IsServer && msg.MsgType == resumeMsgFinish:
+		passed, err = session.handleResumeFinish(&msg)
+	case !session.IsServer && msg.MsgType == resumeMsgResponse:
+		passed, err = session.handleResumeResponse(&msg)
+	case !session.IsServer && msg.MsgType == resumeMsgReject:
+		if session.State == StateResume {
+			session.fallbackToFullAuth(msg.Reason)
+		}
+	default:
+		err = fmt.Errorf("unexpected resume message: %s", msg.MsgType)
+	}
+	session.mu.Unlock()
+
+	if passed {
+		session.onResumePassed()
+	}
+	return err
+}
+
+// onResumePassed 快速重连成功：安装新会话密钥并通知应用层
+func (s *AuthSession) onResumePassed() {
+	s.mu.Lock()
+	state := s.resume
+	s.resume = nil
+	s.mu.Unlock()
+
+	manager := s.AuthManager
+	if manager == nil || state == nil {
+		return
+	}
+
+	// 先记录对端UDID，使安装密钥时的自动持久化生效
+	manager.mu.Lock()
+	manager.PeerUdid = state.peerUdid
+	manager.mu.Unlock()
+
+	if _, err := manager.SessionKeyMgr.SetSessionKey(manager.AuthId, state.newKey); err != nil {
+		log.Errorf("[AUTH_RESUME] Failed to store session key: %v", err)
+		s.mu.Lock()
+		s.State = StateFailed
+		s.mu.Unlock()
+		s.notifyAuthResult(AuthResultFailed)
+		return
+	}
+
+	returnData, _ := json.Marshal(map[string]string{"peerUdid": state.peerUdid})
+	if err := onAuthPassed(manager, string(returnData)); err != nil {
+		s.mu.Lock()
+		s.State = StateFailed
+		s.mu.Unlock()
+		s.notifyAuthResult(AuthResultRejected)
+		return
+	}
+
+	log.Infof("[AUTH_RESUME] Resume finished: authSeq=%d, authId=%d, peer=%s",
+		s.AuthSeq, manager.AuthId, state.peerUdid)
+	s.notifyAuthResult(AuthResultSuccess)
+}
+
+// sendResumeMessage 发送快速重连消息
+func (s *AuthSession) sendResumeMessage(msg *resumeMessage) error {
+	if s.AuthManager == nil {
+		return fmt.Errorf("auth manager not attached: authSeq=%d", s.AuthSeq)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resume message: %w", err)
+	}
+	return AuthDevicePostTransData(s.AuthManager.AuthId, ModuleAuthResume, 0, data)
+}
+
+// abandonResume 服务端收到完整认证数据时放弃进行中的快速重连（调用方持有s.mu）
+func (s *AuthSession) abandonResume() {
+	if s.resume != nil {
+		log.Infof("[AUTH_RESUME] Resume abandoned by peer: authSeq=%d", s.AuthSeq)
+	}
+	s.resume = nil
+	s.State = StateSyncDeviceId
+}
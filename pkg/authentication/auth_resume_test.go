@@ -0,0 +1,142 @@
+package authentication
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth"
+)
+
+// 测试证明与密钥派生：双方结果一致，角色标签不同结果不同
+func TestResumeProofAndKey(t *testing.T) {
+	key := []byte("1234567890abcdef")
+	nc := bytes.Repeat([]byte{1}, AuthResumeNonceLen)
+	ns := bytes.Repeat([]byte{2}, AuthResumeNonceLen)
+
+	server := resumeProof(key, authResumeServerLabel, nc, ns, "client-udid", "server-udid")
+	client := resumeProof(key, authResumeClientLabel, nc, ns, "client-udid", "server-udid")
+	if bytes.Equal(server, client) {
+		t.Error("Expected different proofs for server and client")
+	}
+	if other := resumeProof(key, authResumeServerLabel, nc, ns, "other-udid", "server-udid"); bytes.Equal(server, other) {
+		t.Error("Expected proof to be bound to udids")
+	}
+
+	k1, err := deriveResumeKey(key, nc, ns)
+	if err != nil {
+		t.Fatalf("deriveResumeKey failed: %v", err)
+	}
+	k2, _ := deriveResumeKey(key, nc, ns)
+	if !bytes.Equal(k1, k2) || len(k1) != len(key) || bytes.Equal(k1, key) {
+		t.Error("Expected deterministic fresh 16-byte key")
+	}
+}
+
+// 测试重连凭据查找：按keyId匹配缓存的会话密钥
+func TestResumeKeyLookup(t *testing.T) {
+	key := []byte("fedcba0987654321")
+	device_auth.SaveDeviceSessionKey("resume-peer-A", key)
+
+	if keys := findResumeKeys("resume-peer-A"); len(keys) == 0 || !bytes.Equal(keys[0], key) {
+		t.Fatalf("Expected cached key, got %v", keys)
+	}
+	if got := lookupResumeKey("resume-peer-A", resumeKeyId(key)); !bytes.Equal(got, key) {
+		t.Error("Expected key to be found by keyId")
+	}
+	if got := lookupResumeKey("resume-peer-A", resumeKeyId([]byte("other-key-000000"))); got != nil {
+		t.Error("Expected nil for unknown keyId")
+	}
+	if keys := findResumeKeys("resume-peer-B"); len(keys) != 0 {
+		t.Error("Expected no keys for unknown peer")
+	}
+}
+
+// 测试服务端校验客户端证明：正确证明派生出与客户端一致的密钥，错误证明失败
+func TestHandleResumeFinish(t *testing.T) {
+	RegisterDeviceInfoProvider(&MockDeviceInfoProvider{
+		deviceInfo: &DeviceInfo{UDID: "resume-server"},
+		udid:       "resume-server",
+	})
+	defer UnregisterDeviceInfoProvider()
+
+	key := []byte("1234567890abcdef")
+	nc := bytes.Repeat([]byte{3}, AuthResumeNonceLen)
+	ns := bytes.Repeat([]byte{4}, AuthResumeNonceLen)
+
+	newSession := func() *AuthSession {
+		return &AuthSession{
+			IsServer:       true,
+			State:          StateResume,
+			LastUpdateTime: time.Now(),
+			resume: &resumeState{
+				peerUdid:   "resume-client",
+				key:        key,
+				localNonce: ns,
+				peerNonce:  nc,
+			},
+		}
+	}
+
+	// 错误证明
+	session := newSession()
+	passed, err := session.handleResumeFinish(&resumeMessage{
+		MsgType: resumeMsgFinish,
+		Proof:   hex.EncodeToString(resumeProof(key, authResumeServerLabel, nc, ns, "resume-client", "resume-server")),
+	})
+	if passed || err == nil || session.State != StateFailed {
+		t.Errorf("Expected failure for wrong proof, got passed=%v, err=%v, state=%d", passed, err, session.State)
+	}
+
+	// 正确证明
+	session = newSession()
+	passed, err = session.handleResumeFinish(&resumeMessage{
+		MsgType: resumeMsgFinish,
+		Proof:   hex.EncodeToString(resumeProof(key, authResumeClientLabel, nc, ns, "resume-client", "resume-server")),
+	})
+	if !passed || err != nil || session.State != StateAuthDone {
+		t.Fatalf("Expected success, got passed=%v, err=%v, state=%d", passed, err, session.State)
+	}
+
+	expected, _ := deriveResumeKey(key, nc, ns)
+	if !bytes.Equal(session.resume.newKey, expected) {
+		t.Error("Expected server key to match client derivation")
+	}
+}
+
+// 测试客户端只对声明支持快速重连的对端尝试重连
+func TestStartResumeRequiresPeerCapability(t *testing.T) {
+	peerUdid := "resume-peer-capability"
+	device_auth.SaveDeviceSessionKey(peerUdid, []byte("0987654321fedcba"))
+
+	manager := &AuthManager{
+		AuthId:        8101,
+		ConnInfo:      &AuthConnInfo{Udid: peerUdid},
+		SessionKeyMgr: NewSessionKeyManager(),
+	}
+	session := &AuthSession{AuthSeq: 8101, AuthManager: manager}
+
+	// 从未收到对端能力消息：有凭据也不尝试重连
+	if session.startResume() || session.State == StateResume {
+		t.Fatal("Expected resume to be skipped for peer without capability")
+	}
+
+	// 对端只声明支持密钥更新
+	manager.PeerUdid = peerUdid
+	handleCapabilityData(manager, []byte(`{"msgType":"CAPABILITY","capability":1}`))
+	if capability, ok := getPeerCapability(peerUdid); !ok || capability != AuthCapabilityRekey {
+		t.Fatalf("Unexpected cached capability: 0x%x, ok=%v", capability, ok)
+	}
+	if session.startResume() {
+		t.Error("Expected resume to be skipped for peer without resume capability")
+	}
+
+	handleCapabilityData(manager, []byte(`{"msgType":"CAPABILITY","capability":3}`))
+	if capability, ok := getPeerCapability(peerUdid); !ok || capability&AuthCapabilityResume == 0 {
+		t.Errorf("Expected resume capability to be cached, got 0x%x", capability)
+	}
+	if _, ok := getPeerCapability("resume-peer-unknown"); ok {
+		t.Error("Expected unknown peer to have no capability")
+	}
+}
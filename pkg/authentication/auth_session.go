@@ -12,6 +12,7 @@
 package authentication
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -31,6 +32,7 @@ const (
 	StateDeviceAuth   AuthSessionState = 2 // 设备认证（HiChain）
 	StateAuthDone     AuthSessionState = 3 // 认证完成
 	StateFailed       AuthSessionState = 4 // 认证失败
+	StateResume       AuthSessionState = 5 // 快速重连
 )
 
 // AuthSession 认证会话（对应C的AuthFsm）
@@ -44,6 +46,7 @@ type AuthSession struct {
 	AuthManager    *AuthManager     // 关联的AuthManager
 	CreateTime     time.Time        // 创建时间
 	LastUpdateTime time.Time        // 最后更新时间
+	resume         *resumeState     // 进行中的快速重连
 	mu             sync.RWMutex     // 保护状态
 }
 
@@ -139,6 +142,8 @@ func AuthSessionStartAuth(authSeq int64, requestId uint32, connId uint64,
 		CreateTime:     time.Now(),
 		LastUpdateTime: time.Now(),
 	}
+	// 启动状态机前关联AuthManager（快速重连需要立即发送数据）
+	session.AuthManager, _ = GetAuthManagerByConnId(connId)
 
 	mgr.sessions[authSeq] = session
 	mgr.connIdToSeq[connId] = authSeq
@@ -165,6 +170,11 @@ func (s *AuthSession) startStateMachine() error {
 		s.State = StateSyncDeviceId
 		log.Infof("[AUTH_SESSION] Client starting device auth: authSeq=%d", s.AuthSeq)
 
+		// 曾与对端认证成功时优先尝试快速重连，失败后回退到HiChain认证
+		if s.startResume() {
+			return nil
+		}
+
 		// 直接进入HiChain认证状态
 		s.State = StateDeviceAuth
 		return s.startHiChainAuth()
@@ -184,12 +194,15 @@ func (s *AuthSession) startHiChainAuth() error {
 	}
 
 	// 创建AuthSessionContext（客户端和服务端都需要）
+	// PIN码不在此预设：客户端由配对凭据提供者获取用户输入，服务端为每次请求生成随机PIN码
 	ctx := &context.AuthSessionContext{
 		ChannelID:     int(s.ConnId),
-		PinCode:       "888888",
 		RequestID:     s.AuthSeq,
 		LocalDeviceID: localDevInfo.UDID,
-		PeerDeviceID:  "",
+		PeerDeviceID:  s.peerUdid(),
+	}
+	if connInfo, err := GetConnInfo(s.ConnId); err == nil {
+		ctx.PeerIp = connInfo.Ip
 	}
 	context.SetAuthSessionContext(int(s.AuthSeq), ctx)
 	log.Infof("[AUTH_SESSION] Created AuthSessionContext: authSeq=%d, connId=%d, isServer=%v", s.AuthSeq, s.ConnId, s.IsServer)
@@ -203,7 +216,11 @@ func (s *AuthSession) startHiChainAuth() error {
 	}
 
 	// 构建认证参数
-	authParams := `{"peerUdid":"peer-device","serviceType":"softbus_auth"}`
+	params, _ := json.Marshal(map[string]string{
+		"peerUdid":    s.peerUdid(),
+		"serviceType": "softbus_auth",
+	})
+	authParams := string(params)
 
 	// 创建device_auth回调
 	callback := s.createDeviceAuthCallback()
@@ -266,9 +283,10 @@ func (s *AuthSession) createDeviceAuthCallback() *device_auth.DeviceAuthCallback
 
 			// 标记AuthManager认证成功
 			if s.AuthManager != nil {
-				s.AuthManager.mu.Lock()
-				s.AuthManager.HasAuthPassed = true
-				s.AuthManager.mu.Unlock()
+				if err := onAuthPassed(s.AuthManager, returnData); err != nil {
+					s.notifyAuthResult(AuthResultRejected)
+					return
+				}
 			}
 
 			// 通知应用层认证成功
@@ -285,7 +303,7 @@ func (s *AuthSession) createDeviceAuthCallback() *device_auth.DeviceAuthCallback
 				s.AuthSeq, errorCode)
 
 			// 通知应用层认证失败
-			s.notifyAuthResult(AuthResultFailed)
+			s.notifyAuthResult(authResultFromError(errorCode))
 		},
 
 		// OnRequest: HiChain请求参数（预留）
@@ -362,6 +380,10 @@ func AuthSessionProcessAuthData(authSeq int64, data []byte) error {
 
 	// 服务端首次收到认证数据时，需要先启动HiChain
 	session.mu.Lock()
+	if session.IsServer && session.State == StateResume {
+		// 客户端已放弃快速重连
+		session.abandonResume()
+	}
 	if session.IsServer && session.State == StateSyncDeviceId {
 		session.State = StateDeviceAuth
 		session.mu.Unlock()
@@ -420,6 +442,54 @@ func AuthSessionHandleAuthError(authSeq int64, reason int32) error {
 	return nil
 }
 
+// AuthSessionCancel 取消认证会话：取消进行中的HiChain认证（通知对端）并删除会话
+// 返回: 是否取消了进行中的HiChain认证（此时结果已通过OnError上报）
+func AuthSessionCancel(authSeq int64) bool {
+	mgr := getAuthSessionManager()
+	mgr.mu.Lock()
+	session, exists := mgr.sessions[authSeq]
+	if exists {
+		delete(mgr.sessions, authSeq)
+		if mgr.connIdToSeq[session.ConnId] == authSeq {
+			delete(mgr.connIdToSeq, session.ConnId)
+		}
+	}
+	mgr.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	session.mu.Lock()
+	state := session.State
+	if session.resume != nil && session.resume.timer != nil {
+		session.resume.timer.Stop()
+	}
+	session.resume = nil
+	session.mu.Unlock()
+
+	log.Infof("[AUTH_SESSION] Auth session cancelled: authSeq=%d, state=%d", authSeq, state)
+
+	// 未进入HiChain认证时没有需要通知的对端，结果由调用方上报
+	defer context.DeleteAuthSessionContextByRequestId(authSeq)
+	if state != StateDeviceAuth {
+		session.mu.Lock()
+		session.State = StateFailed
+		session.mu.Unlock()
+		return false
+	}
+
+	ga, err := device_auth.GetGaInstance()
+	if err != nil {
+		return false
+	}
+	ga.CancelRequest(authSeq, device_auth.AUTH_APPID)
+
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return session.State == StateFailed
+}
+
 // GetAuthSessionByConnId 根据ConnId获取会话
 func GetAuthSessionByConnId(connId uint64) (*AuthSession, error) {
 	mgr := getAuthSessionManager()
@@ -219,6 +219,12 @@ func onTcpDataReceived(fd int, connType tcp_connection.ConnectionType, buf []byt
 // - MODULE_META_AUTH(21) -> Meta Auth层（暂未实现）
 // - 其他模块 -> SocketCallback回调
 func processSocketData(fd int, pktHead *SocketPktHead, data []byte) {
+	// 加密数据先解密校验，认证后的明文数据直接丢弃
+	pktHead, data, ok := filterSocketData(fd, pktHead, data)
+	if !ok {
+		return
+	}
+
 	// 路由1: Auth Channel消息（MODULE_AUTH_CHANNEL或MODULE_AUTH_MSG）
 	if pktHead.Module == ModuleAuthChannel || pktHead.Module == ModuleAuthMsg {
 		log.Debugf("[AUTH_TCP] Routing to Auth Channel: fd=%d, module=%d", fd, pktHead.Module)
@@ -9,6 +9,9 @@ import (
 	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
 )
 
+// SessionKeyIndexLen 密文中密钥索引前缀的长度（字节）
+const SessionKeyIndexLen = 4
+
 // SessionKey 会话密钥（对应C的SessionKey）
 type SessionKey struct {
 	Index      int32     // 密钥索引
@@ -17,8 +20,8 @@ type SessionKey struct {
 	LastUsed   time.Time // 最后使用时间
 }
 
-// SessionKeyPersistor 会话密钥持久化接口（预留）
-// 外部可实现此接口以提供持久化能力
+// SessionKeyPersistor 会话密钥持久化接口
+// 内置实现见FileSessionKeyPersistor，外部也可实现此接口以提供持久化能力
 type SessionKeyPersistor interface {
 	// Save 保存authId对应的所有会话密钥
 	Save(authId int64, keys []*SessionKey) error
@@ -35,7 +38,7 @@ type SessionKeyPersistor interface {
 // 管理HiChain派生的会话密钥
 type SessionKeyManager struct {
 	keys      map[int64][]*SessionKey // authId -> SessionKey列表
-	persistor SessionKeyPersistor     // 持久化接口（预留）
+	persistor SessionKeyPersistor     // 持久化接口
 	mu        sync.RWMutex            // 读写锁
 }
 
@@ -46,7 +49,7 @@ func NewSessionKeyManager() *SessionKeyManager {
 	}
 }
 
-// RegisterPersistor 注册持久化接口（预留）
+// RegisterPersistor 注册持久化接口
 // 外部可通过此函数注入持久化实现
 func (m *SessionKeyManager) RegisterPersistor(persistor SessionKeyPersistor) {
 	m.mu.Lock()
@@ -89,6 +92,40 @@ func (m *SessionKeyManager) SetSessionKey(authId int64, sessionKey []byte) (int3
 		index = keyList[len(keyList)-1].Index + 1
 	}
 
+	m.addKeyLocked(authId, keyList, index, sessionKey)
+	return index, nil
+}
+
+// SetSessionKeyWithIndex 使用指定索引设置会话密钥
+// 用于双方协商出相同索引的场景（如密钥更新）
+//
+// 参数:
+//   - authId: 认证ID
+//   - index: 密钥索引（必须大于当前最新密钥的索引）
+//   - sessionKey: 会话密钥（16字节）
+//
+// 返回:
+//   - error: 错误信息
+func (m *SessionKeyManager) SetSessionKeyWithIndex(authId int64, index int32, sessionKey []byte) error {
+	if len(sessionKey) != 16 {
+		return fmt.Errorf("session key must be 16 bytes, got %d", len(sessionKey))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keyList := m.keys[authId]
+	if len(keyList) > 0 && index <= keyList[len(keyList)-1].Index {
+		return fmt.Errorf("session key index must increase: index=%d, latest=%d",
+			index, keyList[len(keyList)-1].Index)
+	}
+
+	m.addKeyLocked(authId, keyList, index, sessionKey)
+	return nil
+}
+
+// addKeyLocked 追加密钥记录并持久化（需要持有写锁）
+func (m *SessionKeyManager) addKeyLocked(authId int64, keyList []*SessionKey, index int32, sessionKey []byte) {
 	// 创建新密钥
 	keyCopy := make([]byte, 16)
 	copy(keyCopy, sessionKey)
@@ -114,8 +151,6 @@ func (m *SessionKeyManager) SetSessionKey(authId int64, sessionKey []byte) (int3
 			// 持久化失败不影响内存操作
 		}
 	}
-
-	return index, nil
 }
 
 // GetSessionKey 获取指定索引的会话密钥
@@ -269,6 +304,20 @@ var (
 //   - []byte: 密文数据
 //   - error: 错误信息
 func (m *SessionKeyManager) Encrypt(authId int64, plaintext []byte) ([]byte, error) {
+	return m.EncryptWithAAD(authId, plaintext, nil)
+}
+
+// EncryptWithAAD 使用最新会话密钥加密数据，并绑定附加认证数据
+//
+// 参数:
+//   - authId: 认证ID
+//   - plaintext: 明文数据
+//   - aad: 附加认证数据（如数据包头部字段），解密时必须提供相同的值
+//
+// 返回:
+//   - []byte: 密文数据，格式: [索引(4字节)] + [IV+密文+Tag]
+//   - error: 错误信息
+func (m *SessionKeyManager) EncryptWithAAD(authId int64, plaintext []byte, aad []byte) ([]byte, error) {
 	// 1. 获取最新的会话密钥
 	key, err := m.GetLatestSessionKey(authId)
 	if err != nil {
@@ -276,18 +325,18 @@ func (m *SessionKeyManager) Encrypt(authId int64, plaintext []byte) ([]byte, err
 	}
 
 	// 2. 使用AES-GCM加密（自动生成IV）
-	encrypted, err := crypto.EncryptAESGCM(key.Key, plaintext)
+	encrypted, err := crypto.EncryptAESGCMWithAAD(key.Key, plaintext, aad)
 	if err != nil {
 		return nil, fmt.Errorf("encryption failed: %w", err)
 	}
 
 	// 3. 添加密钥索引前缀: [索引(4字节)] + [IV+密文+Tag]
-	result := make([]byte, 4+len(encrypted))
+	result := make([]byte, SessionKeyIndexLen+len(encrypted))
 	result[0] = byte(key.Index >> 24)
 	result[1] = byte(key.Index >> 16)
 	result[2] = byte(key.Index >> 8)
 	result[3] = byte(key.Index)
-	copy(result[4:], encrypted)
+	copy(result[SessionKeyIndexLen:], encrypted)
 
 	return result, nil
 }
@@ -306,13 +355,27 @@ func (m *SessionKeyManager) Encrypt(authId int64, plaintext []byte) ([]byte, err
 //   - []byte: 明文数据
 //   - error: 错误信息
 func (m *SessionKeyManager) Decrypt(authId int64, ciphertext []byte) ([]byte, error) {
+	return m.DecryptWithAAD(authId, ciphertext, nil)
+}
+
+// DecryptWithAAD 根据密文中的密钥索引选择会话密钥解密数据，并校验附加认证数据
+//
+// 参数:
+//   - authId: 认证ID
+//   - ciphertext: 密文数据，格式: [索引(4字节)] + [IV+密文+Tag]
+//   - aad: 附加认证数据（必须与加密时一致）
+//
+// 返回:
+//   - []byte: 明文数据
+//   - error: 错误信息
+func (m *SessionKeyManager) DecryptWithAAD(authId int64, ciphertext []byte, aad []byte) ([]byte, error) {
 	// 1. 解析密钥索引: [索引(4字节)] + [IV+密文+Tag]
-	if len(ciphertext) < 4 {
+	if len(ciphertext) < SessionKeyIndexLen {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
 
-	keyIndex := int32(ciphertext[0])<<24 | int32(ciphertext[1])<<16 | int32(ciphertext[2])<<8 | int32(ciphertext[3])
-	encryptedData := ciphertext[4:]
+	keyIndex := ParseSessionKeyIndex(ciphertext)
+	encryptedData := ciphertext[SessionKeyIndexLen:]
 
 	// 2. 根据索引获取会话密钥
 	key, err := m.GetSessionKey(authId, keyIndex)
@@ -321,7 +384,7 @@ func (m *SessionKeyManager) Decrypt(authId int64, ciphertext []byte) ([]byte, er
 	}
 
 	// 3. 使用AES-GCM解密
-	plaintext, err := crypto.DecryptAESGCM(key.Key, encryptedData)
+	plaintext, err := crypto.DecryptAESGCMWithAAD(key.Key, encryptedData, aad)
 	if err != nil {
 		return nil, fmt.Errorf("decryption failed: %w", err)
 	}
@@ -329,11 +392,40 @@ func (m *SessionKeyManager) Decrypt(authId int64, ciphertext []byte) ([]byte, er
 	return plaintext, nil
 }
 
+// ParseSessionKeyIndex 从密文前缀中解析密钥索引（调用方需保证长度足够）
+func ParseSessionKeyIndex(ciphertext []byte) int32 {
+	return int32(ciphertext[0])<<24 | int32(ciphertext[1])<<16 | int32(ciphertext[2])<<8 | int32(ciphertext[3])
+}
+
 // ============================================================================
-// 持久化相关（预留）
+// 持久化相关
 // ============================================================================
 
-// LoadFromPersistor 从持久化存储加载所有会话密钥（预留）
+// Persist 将authId当前的所有会话密钥写入持久化存储
+// 用于对端身份（UDID）在密钥派生之后才确定的场景
+//
+// 参数:
+//   - authId: 认证ID
+//
+// 返回:
+//   - error: 错误信息
+func (m *SessionKeyManager) Persist(authId int64) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.persistor == nil {
+		return nil
+	}
+
+	keyList, exists := m.keys[authId]
+	if !exists {
+		return fmt.Errorf("no session keys for authId=%d", authId)
+	}
+
+	return m.persistor.Save(authId, keyList)
+}
+
+// LoadFromPersistor 从持久化存储加载所有会话密钥
 //
 // 参数:
 //   - authId: 认证ID
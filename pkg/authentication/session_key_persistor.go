@@ -0,0 +1,261 @@
+package authentication
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/storage"
+)
+
+// ============================================================================
+// 基于文件的会话密钥持久化
+// ============================================================================
+//
+// 会话密钥按对端UDID保存在 <dataDir>/session_keys/<sha256(udid)>.key，
+// 文件内容使用本地包装密钥加密（AES-256-GCM，AAD绑定UDID），原子写入，权限0600。
+// authId只在进程内有效，因此通过UdidResolver将authId映射到对端UDID。
+// 同一文件还记录对端声明的扩展能力，快速重连据此判断对端是否支持。
+
+const (
+	sessionKeyDirName     = "session_keys"
+	sessionKeyFileExt     = ".key"
+	sessionKeyFileVersion = 1
+	sessionKeyWrapPurpose = "session_key"
+)
+
+// UdidResolver 根据authId获取对端UDID
+type UdidResolver func(authId int64) (string, error)
+
+// sessionKeyRecord 会话密钥文件中的单条记录
+type sessionKeyRecord struct {
+	Index      int32     `json:"index"`
+	Key        string    `json:"key"`
+	CreateTime time.Time `json:"createTime"`
+}
+
+// sessionKeyFile 会话密钥文件内容
+type sessionKeyFile struct {
+	Version    int                `json:"version"`
+	Udid       string             `json:"udid"`
+	Keys       []sessionKeyRecord `json:"keys"`
+	Capability uint32             `json:"capability,omitempty"` // 对端扩展能力
+}
+
+// FileSessionKeyPersistor 基于文件的会话密钥持久化实现
+type FileSessionKeyPersistor struct {
+	dir      string       // 会话密钥目录
+	wrapKey  []byte       // 文件加密密钥
+	resolver UdidResolver // authId -> UDID
+	mu       sync.Mutex   // 串行化文件读写
+}
+
+// NewFileSessionKeyPersistor 创建基于文件的会话密钥持久化实现
+//
+// 参数:
+//   - dataDir: 数据目录
+//   - resolver: authId到对端UDID的映射（nil时使用AuthDeviceGetPeerUdid）
+//
+// 返回:
+//   - *FileSessionKeyPersistor: 持久化实现
+//   - error: 错误信息
+func NewFileSessionKeyPersistor(dataDir string, resolver UdidResolver) (*FileSessionKeyPersistor, error) {
+	if dataDir == "" {
+		return nil, fmt.Errorf("data dir is empty")
+	}
+	if resolver == nil {
+		resolver = AuthDeviceGetPeerUdid
+	}
+
+	wrapKey, err := storage.LoadOrCreateWrapKey(dataDir, sessionKeyWrapPurpose)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wrap key: %w", err)
+	}
+
+	dir := filepath.Join(dataDir, sessionKeyDirName)
+	if err := storage.EnsureDir(dir); err != nil {
+		return nil, err
+	}
+
+	return &FileSessionKeyPersistor{
+		dir:      dir,
+		wrapKey:  wrapKey,
+		resolver: resolver,
+	}, nil
+}
+
+// Save 保存authId对应的所有会话密钥
+func (p *FileSessionKeyPersistor) Save(authId int64, keys []*SessionKey) error {
+	udid, err := p.resolver(authId)
+	if err != nil {
+		return fmt.Errorf("failed to resolve peer udid: %w", err)
+	}
+	return p.SaveByUdid(udid, keys)
+}
+
+// Load 加载authId对应的所有会话密钥
+func (p *FileSessionKeyPersistor) Load(authId int64) ([]*SessionKey, error) {
+	udid, err := p.resolver(authId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve peer udid: %w", err)
+	}
+	return p.LoadByUdid(udid)
+}
+
+// Delete 删除authId对应的所有会话密钥
+func (p *FileSessionKeyPersistor) Delete(authId int64) error {
+	udid, err := p.resolver(authId)
+	if err != nil {
+		return fmt.Errorf("failed to resolve peer udid: %w", err)
+	}
+	return p.DeleteByUdid(udid)
+}
+
+// SaveByUdid 保存对端设备的会话密钥
+func (p *FileSessionKeyPersistor) SaveByUdid(udid string, keys []*SessionKey) error {
+	if udid == "" {
+		return fmt.Errorf("udid is empty")
+	}
+
+	content := &sessionKeyFile{
+		Version: sessionKeyFileVersion,
+		Udid:    udid,
+		Keys:    make([]sessionKeyRecord, 0, len(keys)),
+	}
+	for _, key := range keys {
+		content.Keys = append(content.Keys, sessionKeyRecord{
+			Index:      key.Index,
+			Key:        hex.EncodeToString(key.Key),
+			CreateTime: key.CreateTime,
+		})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 保留已记录的对端扩展能力
+	if old, err := p.readLocked(udid); err == nil {
+		content.Capability = old.Capability
+	}
+
+	if err := p.writeLocked(content); err != nil {
+		return err
+	}
+
+	log.Debugf("[SESSION_KEY] Session keys persisted: udid=%s, count=%d", udid, len(keys))
+	return nil
+}
+
+// SaveCapabilityByUdid 记录对端设备声明的扩展能力
+// 尚未保存会话密钥时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func (p *FileSessionKeyPersistor) SaveCapabilityByUdid(udid string, capability uint32) error {
+	if udid == "" {
+		return fmt.Errorf("udid is empty")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	content, err := p.readLocked(udid)
+	if err != nil {
+		return err
+	}
+	content.Capability = capability
+	return p.writeLocked(content)
+}
+
+// LoadCapabilityByUdid 获取对端设备声明的扩展能力，未记录时返回0
+func (p *FileSessionKeyPersistor) LoadCapabilityByUdid(udid string) (uint32, error) {
+	if udid == "" {
+		return 0, fmt.Errorf("udid is empty")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	content, err := p.readLocked(udid)
+	if err != nil {
+		return 0, err
+	}
+	return content.Capability, nil
+}
+
+// LoadByUdid 加载对端设备的会话密钥
+// 文件不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func (p *FileSessionKeyPersistor) LoadByUdid(udid string) ([]*SessionKey, error) {
+	if udid == "" {
+		return nil, fmt.Errorf("udid is empty")
+	}
+
+	p.mu.Lock()
+	content, err := p.readLocked(udid)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SessionKey, 0, len(content.Keys))
+	for _, record := range content.Keys {
+		key, err := hex.DecodeString(record.Key)
+		if err != nil || len(key) != 16 {
+			return nil, fmt.Errorf("invalid session key record: index=%d", record.Index)
+		}
+		keys = append(keys, &SessionKey{
+			Index:      record.Index,
+			Key:        key,
+			CreateTime: record.CreateTime,
+			LastUsed:   record.CreateTime,
+		})
+	}
+	return keys, nil
+}
+
+// DeleteByUdid 删除对端设备的会话密钥
+func (p *FileSessionKeyPersistor) DeleteByUdid(udid string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.Remove(p.path(udid)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// readLocked 读取并校验对端设备的会话密钥文件（调用方持有p.mu）
+func (p *FileSessionKeyPersistor) readLocked(udid string) (*sessionKeyFile, error) {
+	data, err := storage.ReadSealedFile(p.path(udid), p.wrapKey, []byte(udid))
+	if err != nil {
+		return nil, err
+	}
+
+	var content sessionKeyFile
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse session key file: %w", err)
+	}
+	if content.Version != sessionKeyFileVersion || content.Udid != udid {
+		return nil, fmt.Errorf("unexpected session key file: version=%d", content.Version)
+	}
+	return &content, nil
+}
+
+// writeLocked 加密写入对端设备的会话密钥文件（调用方持有p.mu）
+func (p *FileSessionKeyPersistor) writeLocked(content *sessionKeyFile) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session keys: %w", err)
+	}
+	return storage.WriteSealedFile(p.path(content.Udid), p.wrapKey, data, []byte(content.Udid))
+}
+
+// path 对端设备的会话密钥文件路径（UDID哈希后作为文件名，避免路径注入）
+func (p *FileSessionKeyPersistor) path(udid string) string {
+	sum := sha256.Sum256([]byte(udid))
+	return filepath.Join(p.dir, hex.EncodeToString(sum[:])+sessionKeyFileExt)
+}
@@ -0,0 +1,149 @@
+package authentication
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试文件持久化：保存、重新加载、删除
+func TestFileSessionKeyPersistor(t *testing.T) {
+	dataDir := t.TempDir()
+	udids := map[int64]string{9001: "peer-udid-A"}
+	resolver := func(authId int64) (string, error) {
+		if udid, ok := udids[authId]; ok {
+			return udid, nil
+		}
+		return "", fmt.Errorf("unknown authId=%d", authId)
+	}
+
+	persistor, err := NewFileSessionKeyPersistor(dataDir, resolver)
+	if err != nil {
+		t.Fatalf("NewFileSessionKeyPersistor failed: %v", err)
+	}
+
+	manager := NewSessionKeyManager()
+	manager.RegisterPersistor(persistor)
+	key := []byte("1234567890abcdef")
+	if _, err := manager.SetSessionKey(9001, key); err != nil {
+		t.Fatalf("SetSessionKey failed: %v", err)
+	}
+
+	// 文件权限0600，内容不包含明文密钥
+	files, _ := filepath.Glob(filepath.Join(dataDir, sessionKeyDirName, "*"+sessionKeyFileExt))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 key file, got %d", len(files))
+	}
+	info, _ := os.Stat(files[0])
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected 0600 permission, got %04o", info.Mode().Perm())
+	}
+	raw, _ := os.ReadFile(files[0])
+	if bytes.Contains(raw, key) || bytes.Contains(raw, []byte("peer-udid-A")) {
+		t.Error("Key file should be sealed")
+	}
+
+	// 模拟重启：新的持久化实例按UDID加载
+	reloaded, err := NewFileSessionKeyPersistor(dataDir, resolver)
+	if err != nil {
+		t.Fatalf("NewFileSessionKeyPersistor failed: %v", err)
+	}
+	keys, err := reloaded.LoadByUdid("peer-udid-A")
+	if err != nil || len(keys) != 1 || !bytes.Equal(keys[0].Key, key) {
+		t.Fatalf("LoadByUdid returned %v, %v", keys, err)
+	}
+
+	// 未知UDID
+	if _, err := reloaded.LoadByUdid("peer-udid-B"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist, got %v", err)
+	}
+
+	// 未知authId无法持久化
+	if err := persistor.Save(9002, keys); err == nil {
+		t.Error("Expected error for unresolved authId")
+	}
+
+	// 权限被放宽后拒绝加载
+	os.Chmod(files[0], 0644)
+	if _, err := reloaded.LoadByUdid("peer-udid-A"); err == nil {
+		t.Error("Expected error for insecure file permission")
+	}
+	os.Chmod(files[0], 0600)
+
+	if err := reloaded.Delete(9001); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := reloaded.LoadByUdid("peer-udid-A"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist after delete, got %v", err)
+	}
+}
+
+// 测试UDID确定后补充持久化
+func TestSessionKeyManager_Persist(t *testing.T) {
+	udid := ""
+	persistor, err := NewFileSessionKeyPersistor(t.TempDir(), func(authId int64) (string, error) {
+		if udid == "" {
+			return "", fmt.Errorf("udid not ready")
+		}
+		return udid, nil
+	})
+	if err != nil {
+		t.Fatalf("NewFileSessionKeyPersistor failed: %v", err)
+	}
+
+	manager := NewSessionKeyManager()
+	manager.RegisterPersistor(persistor)
+	manager.SetSessionKey(9101, []byte("1234567890abcdef"))
+
+	if err := manager.Persist(9101); err == nil {
+		t.Error("Expected error before udid is known")
+	}
+
+	udid = "peer-udid-C"
+	if err := manager.Persist(9101); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	if keys, err := persistor.LoadByUdid(udid); err != nil || len(keys) != 1 {
+		t.Errorf("LoadByUdid returned %v, %v", keys, err)
+	}
+}
+
+// 测试对端扩展能力随会话密钥文件保存，重写密钥时保留
+func TestFileSessionKeyPersistor_Capability(t *testing.T) {
+	persistor, err := NewFileSessionKeyPersistor(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("NewFileSessionKeyPersistor failed: %v", err)
+	}
+
+	// 尚未保存会话密钥
+	if err := persistor.SaveCapabilityByUdid("peer-udid-C", AuthCapabilityResume); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist, got %v", err)
+	}
+
+	key := &SessionKey{Index: 0, Key: []byte("1234567890abcdef")}
+	if err := persistor.SaveByUdid("peer-udid-C", []*SessionKey{key}); err != nil {
+		t.Fatalf("SaveByUdid failed: %v", err)
+	}
+	if capability, err := persistor.LoadCapabilityByUdid("peer-udid-C"); err != nil || capability != 0 {
+		t.Errorf("Expected no capability, got 0x%x (err=%v)", capability, err)
+	}
+
+	if err := persistor.SaveCapabilityByUdid("peer-udid-C", AuthCapabilityResume); err != nil {
+		t.Fatalf("SaveCapabilityByUdid failed: %v", err)
+	}
+
+	// 密钥更新后重写文件
+	key2 := &SessionKey{Index: 1, Key: []byte("abcdef1234567890")}
+	if err := persistor.SaveByUdid("peer-udid-C", []*SessionKey{key, key2}); err != nil {
+		t.Fatalf("SaveByUdid failed: %v", err)
+	}
+	if capability, err := persistor.LoadCapabilityByUdid("peer-udid-C"); err != nil || capability != AuthCapabilityResume {
+		t.Errorf("Expected capability to be kept, got 0x%x (err=%v)", capability, err)
+	}
+	if keys, err := persistor.LoadByUdid("peer-udid-C"); err != nil || len(keys) != 2 {
+		t.Errorf("LoadByUdid returned %v, %v", keys, err)
+	}
+}
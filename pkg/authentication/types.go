@@ -46,6 +46,8 @@ const (
 	ModuleAuthChannel   int32 = 8  // 认证通道
 	ModuleAuthMsg       int32 = 9  // 认证消息
 	ModuleMetaAuth      int32 = 21 // Meta认证
+	ModuleAuthRekey     int32 = 30 // 会话密钥更新（扩展模块）
+	ModuleAuthResume    int32 = 31 // 快速重连认证（扩展模块）
 )
 
 const (
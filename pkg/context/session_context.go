@@ -13,6 +13,7 @@ type AuthSessionContext struct {
 	RequestID     int64
 	LocalDeviceID string
 	PeerDeviceID  string
+	PeerIp        string // 对端IP（用于配对失败统计）
 }
 
 var (
@@ -57,3 +58,15 @@ func FindAuthSessionContextByRequestId(requestID int64) *AuthSessionContext {
 	}
 	return nil
 }
+
+// DeleteAuthSessionContextByRequestId 删除RequestID对应的所有会话上下文
+func DeleteAuthSessionContextByRequestId(requestID int64) {
+	contextMu.Lock()
+	defer contextMu.Unlock()
+
+	for channelID, ctx := range sessionContexts {
+		if ctx.RequestID == requestID {
+			delete(sessionContexts, channelID)
+		}
+	}
+}
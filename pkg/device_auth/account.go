@@ -0,0 +1,177 @@
+package device_auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 账户可信组
+// ============================================================================
+//
+// 除P2P绑定组外支持两种账户相关的可信组，设备之间无需逐一PIN码绑定:
+//   - 同账户组（IdenticalAccountGroup）: 同一用户ID下的设备，userId为组的OwnerUserID
+//   - 跨账户组（AcrossAccountAuthorizeGroup）: 本账户授权的其他账户（sharedUserId）的设备
+//
+// 账户凭据通过ProcessCredential按用户ID导入（见credential.go）:
+//   - 同账户: 用户ID为本账户，凭据由账户下发给同账户的所有设备
+//   - 跨账户: 用户ID为被授权的对端账户，凭据由双方账户共同持有
+// AuthDevice与账户组中的设备（或声明了用户ID的对端）认证时，以账户凭据代替PIN码执行PAKE，
+// 认证成功后双方自动将对端加入对应的账户组，并交换长期公钥，之后使用基于长期密钥的认证。
+
+// isAccountGroupType 是否为账户相关的组类型
+func isAccountGroupType(groupType GroupType) bool {
+	return groupType == IdenticalAccountGroup || groupType == AcrossAccountAuthorizeGroup
+}
+
+// accountGroupId 账户组的默认组ID（由用户ID派生，同账户的设备得到相同的组ID）
+func accountGroupId(userId string, sharedUserId string) string {
+	data := userId
+	if sharedUserId != "" {
+		data += "|" + sharedUserId
+	}
+	sum := sha256.Sum256([]byte(data))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// accountUserId 账户组中成员所属的用户ID（同账户组为本账户，跨账户组为被授权账户）
+func accountUserId(group *GroupInfo) string {
+	if GroupType(group.GroupType) == AcrossAccountAuthorizeGroup {
+		return group.SharedUserID
+	}
+	return group.OwnerUserID
+}
+
+// accountGroupAuthForm 账户组对应的认证形式
+func accountGroupAuthForm(group *GroupInfo) GroupAuthForm {
+	if GroupType(group.GroupType) == AcrossAccountAuthorizeGroup {
+		return AuthFormAcrossAccount
+	}
+	return AuthFormIdenticalAccount
+}
+
+// findAccountGroupLocked 查找成员用户ID为userId的账户组（调用方持有锁）
+// authForm为AuthFormInvalidType时依次查找同账户组和跨账户组
+func (d *deviceGroupManager) findAccountGroupLocked(userId string, authForm GroupAuthForm) *GroupInfo {
+	if userId == "" {
+		return nil
+	}
+	var across *GroupInfo
+	for _, group := range d.groups {
+		if !isAccountGroupType(GroupType(group.GroupType)) || accountUserId(group) != userId {
+			continue
+		}
+		form := accountGroupAuthForm(group)
+		if form == AuthFormIdenticalAccount && authForm != AuthFormAcrossAccount {
+			return group
+		}
+		if form == AuthFormAcrossAccount && authForm != AuthFormIdenticalAccount && across == nil {
+			across = group
+		}
+	}
+	return across
+}
+
+// sameAccountGroupLocked 查找同类型、同用户ID的已有账户组（调用方持有锁）
+func (d *deviceGroupManager) sameAccountGroupLocked(groupType GroupType, userId string, sharedUserId string) *GroupInfo {
+	if !isAccountGroupType(groupType) {
+		return nil
+	}
+	for _, group := range d.groups {
+		if GroupType(group.GroupType) == groupType && group.OwnerUserID == userId && group.SharedUserID == sharedUserId {
+			return group
+		}
+	}
+	return nil
+}
+
+// credentialAuth 一次认证选用的凭据，账户认证时还包含账户组（groupId为空表示对端凭据）
+type credentialAuth struct {
+	groupId    string
+	authForm   GroupAuthForm
+	selfUserId string // 本端用户ID（发送给对端）
+	peerUserId string // 对端用户ID
+	authCode   []byte
+	ownerAppId string
+}
+
+// selectAccountAuth 选择与对端认证使用的账户组和凭据
+// peerUserId: 对端用户ID（为空时根据对端所在的账户组确定）
+// authForm: 限定的认证形式（AuthFormInvalidType表示不限定）
+// 没有匹配的账户组或凭据时返回nil
+func (d *deviceGroupManager) selectAccountAuth(peerUdid string, peerUserId string, authForm GroupAuthForm) *credentialAuth {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if peerUserId == "" && peerUdid != "" {
+		for _, group := range d.groups {
+			if !isAccountGroupType(GroupType(group.GroupType)) {
+				continue
+			}
+			if _, exists := group.Members[peerUdid]; exists {
+				peerUserId = accountUserId(group)
+				break
+			}
+		}
+	}
+
+	group := d.findAccountGroupLocked(peerUserId, authForm)
+	if group == nil {
+		return nil
+	}
+	authCode := accountAuthCode(peerUserId)
+	if authCode == nil {
+		log.Warnf("[DEVICE_AUTH] Account credential missing: userId=%s, groupId=%s", peerUserId, group.GroupID)
+		return nil
+	}
+	return &credentialAuth{
+		groupId:    group.GroupID,
+		authForm:   accountGroupAuthForm(group),
+		selfUserId: group.OwnerUserID,
+		peerUserId: peerUserId,
+		authCode:   authCode,
+		ownerAppId: group.OwnerAppId,
+	}
+}
+
+// addAccountMember 账户认证成功后将对端加入账户组
+func (d *deviceGroupManager) addAccountMember(auth *credentialAuth, peerUdid string) error {
+	if auth.groupId == "" {
+		return nil
+	}
+	member := &DeviceMemberInfo{
+		DeviceID: peerUdid,
+		UDID:     peerUdid,
+		AuthID:   peerUdid,
+		UserID:   auth.peerUserId,
+		JoinTime: time.Now().Unix(),
+	}
+	_, err := d.addMember(auth.ownerAppId, auth.groupId, member)
+	return err
+}
+
+// keyAuthAccount 基于长期密钥认证的对端如果由带userId的非对称凭据预置，返回其账户组
+func (d *deviceGroupManager) keyAuthAccount(peerUdid string) *credentialAuth {
+	entry := peerPublicKeyCredential(peerUdid)
+	if entry == nil || entry.UserID == "" {
+		return nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	group := d.findAccountGroupLocked(entry.UserID, AuthFormInvalidType)
+	if group == nil {
+		return nil
+	}
+	return &credentialAuth{
+		groupId:    group.GroupID,
+		authForm:   accountGroupAuthForm(group),
+		selfUserId: group.OwnerUserID,
+		peerUserId: entry.UserID,
+		ownerAppId: group.OwnerAppId,
+	}
+}
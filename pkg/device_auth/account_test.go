@@ -0,0 +1,134 @@
+package device_auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+)
+
+const testAuthCode = "00112233445566778899aabbccddeeff"
+
+// importTestAccount 导入测试账户凭据，返回清理函数
+func importTestAccount(t *testing.T, userId string) func() {
+	if _, err := ProcessCredential(CredOpImport, `{"credentialType":1,"userId":"`+userId+`","authCode":"`+testAuthCode+`"}`); err != nil {
+		t.Fatalf("Import credential failed: %v", err)
+	}
+	return func() { ProcessCredential(CredOpDelete, `{"userId":"`+userId+`"}`) }
+}
+
+// 测试账户凭据的导入、查询和删除，凭据本身不返回
+func TestProcessCredential_Account(t *testing.T) {
+	if _, err := ProcessCredential(CredOpImport, `{"userId":"cred-user","authCode":"0011"}`); err == nil {
+		t.Error("Expected error for short authCode")
+	}
+
+	defer importTestAccount(t, "cred-user")()
+	result, err := ProcessCredential(CredOpQuery, `{"userId":"cred-user"}`)
+	if err != nil {
+		t.Fatalf("Query credential failed: %v", err)
+	}
+	if strings.Contains(result, testAuthCode) || !strings.Contains(result, "cred-user") {
+		t.Errorf("Unexpected query result: %s", result)
+	}
+
+	if _, err := ProcessCredential(CredOpDelete, `{"userId":"cred-user"}`); err != nil {
+		t.Fatalf("Delete credential failed: %v", err)
+	}
+	if _, err := ProcessCredential(CredOpQuery, `{"userId":"cred-user"}`); err == nil {
+		t.Error("Expected error after delete")
+	}
+}
+
+// 测试账户组的创建参数、批量添加成员的用户ID和凭据校验，以及认证时账户组的选择
+func TestAccountGroups(t *testing.T) {
+	gm := newDeviceGroupManager()
+
+	if err := gm.CreateGroup(AnyOsAccount, 1, "account_app", `{"groupType":1}`); err == nil {
+		t.Error("Expected error for identical account group without userId")
+	}
+	if err := gm.CreateGroup(AnyOsAccount, 2, "account_app", `{"groupType":1,"userId":"user-1"}`); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	identicalId := accountGroupId("user-1", "")
+	if gm.groups[identicalId] == nil || gm.groups[identicalId].OwnerUserID != "user-1" {
+		t.Fatalf("Expected identical account group with derived groupId")
+	}
+	if err := gm.CreateGroup(AnyOsAccount, 3, "account_app", `{"groupId":"OTHER","groupType":1,"userId":"user-1"}`); err == nil {
+		t.Error("Expected error for duplicate account group")
+	}
+	if err := gm.CreateGroup(AnyOsAccount, 4, "account_app", `{"groupId":"ACROSS_001","groupType":1282,"userId":"user-1","sharedUserId":"user-2"}`); err != nil {
+		t.Fatalf("CreateGroup across account failed: %v", err)
+	}
+	gm.CreateGroup(AnyOsAccount, 5, "account_app", `{"groupId":"P2P_001","groupType":256}`)
+
+	addParams := `{"groupId":"` + identicalId + `","deviceList":[{"deviceId":"udid-1"}]}`
+	if err := gm.AddMultiMembersToGroup(AnyOsAccount, "account_app", addParams); err == nil {
+		t.Error("Expected error without account credential")
+	}
+	defer importTestAccount(t, "user-1")()
+	defer importTestAccount(t, "user-2")()
+
+	if err := gm.AddMultiMembersToGroup(AnyOsAccount, "account_app", `{"groupId":"P2P_001","deviceList":[{"deviceId":"udid-1"}]}`); err == nil {
+		t.Error("Expected error for peer-to-peer group")
+	}
+	if err := gm.AddMultiMembersToGroup(AnyOsAccount, "account_app", `{"groupId":"`+identicalId+`","deviceList":[{"deviceId":"udid-1","userId":"user-2"}]}`); err == nil {
+		t.Error("Expected error for device of another user")
+	}
+	if err := gm.AddMultiMembersToGroup(AnyOsAccount, "account_app", addParams); err != nil {
+		t.Fatalf("AddMultiMembersToGroup failed: %v", err)
+	}
+	if member := gm.groups[identicalId].Members["udid-1"]; member == nil || member.UserID != "user-1" {
+		t.Errorf("Expected member with userId user-1, got %+v", member)
+	}
+
+	// 同账户成员使用同账户认证，被授权账户使用跨账户认证，其他设备不使用账户认证
+	if account := gm.selectAccountAuth("udid-1", "", AuthFormInvalidType); account == nil || account.authForm != AuthFormIdenticalAccount {
+		t.Errorf("Expected identical account auth for member, got %+v", account)
+	}
+	account := gm.selectAccountAuth("", "user-2", AuthFormInvalidType)
+	if account == nil || account.authForm != AuthFormAcrossAccount || account.groupId != "ACROSS_001" || account.selfUserId != "user-1" {
+		t.Errorf("Expected across account auth for user-2, got %+v", account)
+	}
+	if account := gm.selectAccountAuth("udid-unknown", "", AuthFormInvalidType); account != nil {
+		t.Errorf("Expected no account auth for unknown device, got %+v", account)
+	}
+}
+
+// 测试同账户设备无需PIN码即可完成认证，认证后双方互相加入同账户组并交换长期公钥
+func TestAccountAuth_IdenticalAccount(t *testing.T) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	defer DestroyDeviceAuthService()
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+	defer ResetPairingLockout("", "")
+	defer importTestAccount(t, "user-1")()
+
+	gm, _ := GetGmInstance()
+	if err := gm.CreateGroup(AnyOsAccount, 1, "account_app", `{"groupType":1,"userId":"user-1"}`); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	groupId := accountGroupId("user-1", "")
+
+	// 两端使用不同的authReqId，共享同一个DeviceGroupManager
+	pair := newGaTestPair(t, 4101, 4201, "acct-udid-a", "acct-udid-b")
+	if err := pair.authDevice(`{"peerUdid":"acct-udid-b","peerUserId":"user-1"}`); err != nil {
+		t.Fatalf("AuthDevice failed: %v", err)
+	}
+	if !pair.clientFinished || !pair.serverFinished {
+		t.Fatalf("Expected both sides finished, client=%v server=%v", pair.clientFinished, pair.serverFinished)
+	}
+	if pair.serverRequests != 0 {
+		t.Error("Expected no OnRequest confirmation for account auth")
+	}
+	for _, udid := range []string{"acct-udid-a", "acct-udid-b"} {
+		if !gm.IsDeviceInGroup(AnyOsAccount, "account_app", groupId, testAppDeviceId(t, "account_app", udid)) {
+			t.Errorf("Expected %s to join the account group", udid)
+		}
+		if hichain.GetDeviceAuthInfo(udid) == nil {
+			t.Errorf("Expected long-term key of %s saved", udid)
+		}
+	}
+}
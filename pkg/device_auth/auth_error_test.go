@@ -0,0 +1,191 @@
+package device_auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/context"
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+)
+
+// gaErrorPeer 认证错误码测试中的一端，发送的消息暂存在outbox中
+type gaErrorPeer struct {
+	ga       *realGroupAuthManager
+	reqId    int64
+	callback *DeviceAuthCallback
+	outbox   [][]byte
+	errors   chan int32
+	mu       sync.Mutex // 超时定时器在其他goroutine中发送消息
+}
+
+// messages 返回已发送的消息
+func (p *gaErrorPeer) messages() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]byte(nil), p.outbox...)
+}
+
+// take 取出最早一条未投递的消息
+func (p *gaErrorPeer) take() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.outbox) == 0 {
+		return nil
+	}
+	data := p.outbox[0]
+	p.outbox = p.outbox[1:]
+	return data
+}
+
+// newGaErrorPair 创建认证双方：客户端使用全局实例（PIN码clientPin），服务端通过OnRequest返回serverPin
+func newGaErrorPair(t *testing.T, clientReqId, serverReqId int64, clientPin, serverPin string) (*gaErrorPeer, *gaErrorPeer) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	context.SetAuthSessionContext(int(clientReqId), &context.AuthSessionContext{
+		RequestID: clientReqId, LocalDeviceID: "err-udid-a", PeerDeviceID: "err-udid-b",
+	})
+	context.SetAuthSessionContext(int(serverReqId), &context.AuthSessionContext{
+		RequestID: serverReqId, LocalDeviceID: "err-udid-b",
+	})
+	t.Cleanup(func() {
+		context.DeleteAuthSessionContext(int(clientReqId))
+		context.DeleteAuthSessionContext(int(serverReqId))
+		DestroyDeviceAuthService()
+	})
+
+	ga, _ := GetGaInstance()
+	client := &gaErrorPeer{ga: ga.(*realGroupAuthManager), reqId: clientReqId, errors: make(chan int32, 4)}
+	server := &gaErrorPeer{
+		ga:     newRealGroupAuthManager(),
+		reqId:  serverReqId,
+		errors: make(chan int32, 4),
+	}
+	for _, peer := range []*gaErrorPeer{client, server} {
+		peer := peer
+		peer.callback = &DeviceAuthCallback{
+			OnTransmit: func(requestId int64, data []byte) bool {
+				peer.mu.Lock()
+				peer.outbox = append(peer.outbox, data)
+				peer.mu.Unlock()
+				return true
+			},
+			OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
+				peer.errors <- errorCode
+			},
+			OnRequest: func(requestId int64, operationCode int32, reqParams string) string {
+				return fmt.Sprintf(`{"confirmation":%d,"pinCode":"%s"}`, RequestAccepted, serverPin)
+			},
+		}
+	}
+
+	if err := client.ga.AuthDevice(AnyOsAccount, clientReqId, `{"peerUdid":"err-udid-b","pinCode":"`+clientPin+`"}`, client.callback); err != nil {
+		t.Fatalf("AuthDevice failed: %v", err)
+	}
+	return client, server
+}
+
+// relay 交替投递双方暂存的消息，直到没有新消息
+func relay(client, server *gaErrorPeer) {
+	for {
+		clientData, serverData := client.take(), server.take()
+		if clientData == nil && serverData == nil {
+			return
+		}
+		if clientData != nil {
+			server.ga.ProcessData(server.reqId, clientData, server.callback)
+		}
+		if serverData != nil {
+			client.ga.ProcessData(client.reqId, serverData, client.callback)
+		}
+	}
+}
+
+// expectError 检查一端恰好上报一次指定错误码
+func expectError(t *testing.T, side string, peer *gaErrorPeer, want int32) {
+	t.Helper()
+	select {
+	case got := <-peer.errors:
+		if got != want {
+			t.Errorf("Expected %s OnError with %d, got %d", side, want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %s OnError with %d", side, want)
+	}
+	select {
+	case got := <-peer.errors:
+		t.Errorf("Expected %s OnError once, got another %d", side, got)
+	default:
+	}
+}
+
+// 测试PIN码错误：双方均上报HC_ERR_PIN_MISMATCH，服务端保留PIN码失败次数
+func TestAuthErrors_WrongPin(t *testing.T) {
+	defer ResetPairingLockout("", "")
+	client, server := newGaErrorPair(t, 6301, 6401, "111111", "222222")
+
+	relay(client, server)
+	expectError(t, "client", client, HC_ERR_PIN_MISMATCH)
+	expectError(t, "server", server, HC_ERR_PIN_MISMATCH)
+	if server.ga.hichainInstances[server.reqId] != nil || client.ga.hichainInstances[client.reqId] != nil {
+		t.Error("Expected HiChain instances destroyed")
+	}
+	if server.ga.pinFailures[server.reqId] != 1 {
+		t.Errorf("Expected 1 pin failure recorded, got %d", server.ga.pinFailures[server.reqId])
+	}
+}
+
+// 测试对端处于锁定期：被配对方不再展示PIN码，双方均上报HC_ERR_LOCKED_OUT
+func TestAuthErrors_LockedOut(t *testing.T) {
+	defer ResetPairingLockout("", "")
+	for checkPairingAllowed("err-udid-a", "") == nil {
+		recordPairingFailure("err-udid-a", "")
+	}
+	client, server := newGaErrorPair(t, 6302, 6402, "123456", "123456")
+
+	relay(client, server)
+	expectError(t, "client", client, HC_ERR_LOCKED_OUT)
+	expectError(t, "server", server, HC_ERR_LOCKED_OUT)
+}
+
+// 测试请求超时：超时方以HCTimeout通知对端并上报HC_ERR_TIMEOUT，对端同样上报HC_ERR_TIMEOUT
+func TestAuthErrors_Timeout(t *testing.T) {
+	defer ResetPairingLockout("", "")
+	defer func(timeout time.Duration) { AuthRequestTimeout = timeout }(AuthRequestTimeout)
+
+	AuthRequestTimeout = 20 * time.Millisecond
+	client, server := newGaErrorPair(t, 6303, 6403, "123456", "123456")
+	AuthRequestTimeout = time.Hour
+
+	// 服务端回复PAKE_RESPONSE，不再投递给客户端
+	server.ga.ProcessData(server.reqId, client.take(), server.callback)
+
+	expectError(t, "client", client, HC_ERR_TIMEOUT)
+	client.ga.mu.RLock()
+	destroyed := client.ga.hichainInstances[client.reqId] == nil
+	client.ga.mu.RUnlock()
+	if !destroyed {
+		t.Error("Expected HiChain instance destroyed on timeout")
+	}
+	sent := client.messages()
+	if len(sent) != 1 {
+		t.Fatalf("Expected timeout message sent to peer, got %d messages", len(sent))
+	}
+	var msg struct {
+		Message   int `json:"message"`
+		ErrorCode int `json:"errorCode"`
+	}
+	json.Unmarshal(sent[0], &msg)
+	if msg.Message != hichain.MsgTypeError || msg.ErrorCode != hichain.HCTimeout {
+		t.Errorf("Unexpected timeout message: %s", sent[0])
+	}
+
+	server.ga.ProcessData(server.reqId, sent[0], server.callback)
+	expectError(t, "server", server, HC_ERR_TIMEOUT)
+	if len(server.ga.timers) != 0 {
+		t.Error("Expected server timer stopped")
+	}
+}
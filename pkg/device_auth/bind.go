@@ -0,0 +1,685 @@
+package device_auth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 绑定/解绑协议
+// ============================================================================
+//
+// 绑定（AddMemberToGroup携带pinCode）:
+//   1. 发起方（HCController）通过HiChain PAKE与对端完成PIN码认证，并在EXCHANGE阶段交换长期公钥
+//   2. HiChain消息封装在bindMessage中，经DeviceAuthCallback.OnTransmit发送，对端通过ProcessData接收
+//   3. 认证成功后双方各自将对端添加为可信组成员（本地不存在该组时按消息中的组信息创建），通过OnFinish上报
+//   - 本地已有该组：MemberInvite（邀请对端加入本地组）
+//   - 本地没有该组：MemberJoin（加入对端的组，对端必须已存在该组）
+//
+// 解绑（DeleteMemberFromGroup，isForceDelete为false且注册了OnTransmit）:
+//   1. 发起方删除本地成员后，发送用本地长期私钥签名的解绑请求
+//   2. 对端用绑定时保存的公钥验签，删除发起方并回复确认，双方通过OnFinish上报
+//
+// 失败通过OnError上报（错误码见README），HiChain认证阶段的失败由HiChain错误消息通知对端，
+// 其他失败以bindMsgError通知对端；CancelRequest同样以bindMsgError通知对端，对端上报HC_ERR_PEER_CANCELLED。
+
+// 绑定消息类型
+const (
+	bindMsgAuth      = 1 // 承载HiChain认证消息
+	bindMsgUnbind    = 2 // 解绑请求
+	bindMsgUnbindAck = 3 // 解绑确认
+	bindMsgError     = 4 // 错误通知
+)
+
+// unbindValidWindow 解绑请求时间戳的有效范围（防重放）
+const unbindValidWindow = 5 * time.Minute
+
+// ErrBindRejected 对端拒绝或未通过绑定请求
+var ErrBindRejected = errors.New("bind request rejected")
+
+// ErrRequestCancelled 请求被本端取消
+var ErrRequestCancelled = errors.New("request cancelled")
+
+// bindMessage 绑定/解绑消息
+type bindMessage struct {
+	BindMsg    int             `json:"bindMsg"`
+	Operation  int32           `json:"operationCode"`
+	AppId      string          `json:"appId"`
+	GroupId    string          `json:"groupId"`
+	GroupName  string          `json:"groupName,omitempty"`
+	GroupType  int32           `json:"groupType,omitempty"`
+	Visibility int32           `json:"groupVisibility,omitempty"`
+	DeviceId   string          `json:"deviceId"`           // 发送方UDID
+	PeerUdid   string          `json:"peerUdid,omitempty"` // 接收方UDID
+	Data       json.RawMessage `json:"data,omitempty"`     // HiChain消息
+	Timestamp  int64           `json:"timestamp,omitempty"`
+	Signature  string          `json:"signature,omitempty"`
+	ErrorCode  int32           `json:"errorCode,omitempty"`
+}
+
+// bindSession 进行中的绑定/解绑请求
+type bindSession struct {
+	requestId  int64
+	appId      string
+	op         GroupOperationCode
+	isClient   bool
+	groupId    string
+	groupName  string
+	groupType  int32
+	visibility int32
+	peerUdid   string
+	pinCode    string
+	handle     *hichain.HiChainHandle
+	callback   *DeviceAuthCallback
+}
+
+// SetLocalDeviceUdid 设置本地设备UDID（绑定和解绑时作为本端身份）
+func SetLocalDeviceUdid(udid string) error {
+	gm, err := GetGmInstance()
+	if err != nil {
+		return err
+	}
+	d, ok := gm.(*deviceGroupManager)
+	if !ok {
+		return fmt.Errorf("group manager does not support binding")
+	}
+
+	d.mu.Lock()
+	d.localUdid = udid
+	d.mu.Unlock()
+	log.Infof("[DEVICE_AUTH] Local udid set: %s", udid)
+	return nil
+}
+
+// getLocalUdid 获取本地设备UDID
+func (d *deviceGroupManager) getLocalUdid() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.localUdid
+}
+
+// getCallback 获取应用注册的业务回调
+func (d *deviceGroupManager) getCallback(appId string) *DeviceAuthCallback {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.callbacks[appId]
+}
+
+// getBindSession 查找进行中的请求
+func (d *deviceGroupManager) getBindSession(requestId int64) *bindSession {
+	d.sessionMu.Lock()
+	defer d.sessionMu.Unlock()
+	return d.sessions[requestId]
+}
+
+// takeBindSession 结束请求（已结束时返回false，保证结果只上报一次）
+func (d *deviceGroupManager) takeBindSession(requestId int64, session *bindSession) bool {
+	d.sessionMu.Lock()
+	defer d.sessionMu.Unlock()
+
+	if current, exists := d.sessions[requestId]; !exists || current != session {
+		return false
+	}
+	delete(d.sessions, requestId)
+	if handle := session.handle; handle != nil {
+		hichain.Destroy(&handle)
+	}
+	return true
+}
+
+// startBind 发起绑定：与对端完成PIN码认证后互相添加为可信组成员
+func (d *deviceGroupManager) startBind(requestId int64, appId string, params map[string]interface{}) error {
+	groupId, _ := params["groupId"].(string)
+	pinCode, _ := params["pinCode"].(string)
+	if groupId == "" {
+		return fmt.Errorf("groupId is required")
+	}
+
+	callback := d.getCallback(appId)
+	if callback == nil || callback.OnTransmit == nil {
+		return fmt.Errorf("no transmit callback registered for appId: %s", appId)
+	}
+	localUdid := d.getLocalUdid()
+	if localUdid == "" {
+		return fmt.Errorf("local udid not set")
+	}
+
+	session := &bindSession{
+		requestId: requestId,
+		appId:     appId,
+		isClient:  true,
+		groupId:   groupId,
+		groupType: int32(PeerToPeerGroup),
+		pinCode:   pinCode,
+		callback:  callback,
+	}
+	// 对端UDID可选，给出时校验认证得到的对端身份
+	session.peerUdid, _ = params["deviceId"].(string)
+
+	d.mu.RLock()
+	group, exists := d.groups[groupId]
+	if exists {
+		if err := d.checkManageLocked(appId, group); err != nil {
+			d.mu.RUnlock()
+			return err
+		}
+		session.op = MemberInvite
+		session.groupName = group.GroupName
+		session.groupType = group.GroupType
+		session.visibility = group.Visibility
+	}
+	d.mu.RUnlock()
+	if !exists {
+		session.op = MemberJoin
+		session.groupName, _ = params["groupName"].(string)
+		if groupType, ok := params["groupType"].(float64); ok {
+			session.groupType = int32(groupType)
+		}
+	}
+
+	if err := d.addBindSession(session); err != nil {
+		return err
+	}
+
+	handle, err := hichain.GetInstance(d.bindIdentity(requestId), hichain.HCController, d.createBindCallBack(session))
+	if err != nil {
+		d.takeBindSession(requestId, session)
+		return fmt.Errorf("failed to create HiChain instance: %w", err)
+	}
+	session.handle = handle
+
+	log.Infof("[DEVICE_AUTH] Starting bind: requestId=%d, appId=%s, groupId=%s, op=%d", requestId, appId, groupId, session.op)
+	if err := handle.StartAuth(); err != nil {
+		// 已通过OnError上报的失败不再返回错误
+		if d.takeBindSession(requestId, session) {
+			return fmt.Errorf("failed to start bind: %w", err)
+		}
+	}
+	return nil
+}
+
+// addBindSession 登记进行中的请求
+func (d *deviceGroupManager) addBindSession(session *bindSession) error {
+	d.sessionMu.Lock()
+	defer d.sessionMu.Unlock()
+
+	if _, exists := d.sessions[session.requestId]; exists {
+		return fmt.Errorf("request already in progress: %d", session.requestId)
+	}
+	d.sessions[session.requestId] = session
+	return nil
+}
+
+// bindIdentity 绑定请求的HiChain会话标识
+func (d *deviceGroupManager) bindIdentity(requestId int64) *hichain.SessionIdentity {
+	return &hichain.SessionIdentity{
+		SessionID:     uint32(requestId),
+		PackageName:   AUTH_APPID,
+		ServiceType:   AUTH_APPID,
+		OperationCode: hichain.OpCodeAddMember,
+	}
+}
+
+// ProcessData 处理绑定或解绑设备的数据
+func (d *deviceGroupManager) ProcessData(requestId int64, data []byte) error {
+	log.Infof("[DEVICE_AUTH] ProcessData: requestId=%d, dataLen=%d", requestId, len(data))
+
+	var msg bindMessage
+	if err := json.Unmarshal(hichain.CleanJSONData(data), &msg); err != nil {
+		return fmt.Errorf("invalid bind message: %w", err)
+	}
+
+	switch msg.BindMsg {
+	case bindMsgAuth:
+		return d.processBindAuth(requestId, &msg)
+	case bindMsgUnbind:
+		return d.processUnbind(requestId, &msg)
+	case bindMsgUnbindAck:
+		session := d.getBindSession(requestId)
+		if session == nil || session.op != MemberDelete {
+			return fmt.Errorf("no unbind request in progress: %d", requestId)
+		}
+		if d.takeBindSession(requestId, session) {
+			log.Infof("[DEVICE_AUTH] Unbind acknowledged: requestId=%d, peer=%s", requestId, msg.DeviceId)
+			d.reportFinish(session, msg.DeviceId)
+		}
+		return nil
+	case bindMsgError:
+		session := d.getBindSession(requestId)
+		if session == nil {
+			return fmt.Errorf("no request in progress: %d", requestId)
+		}
+		log.Warnf("[DEVICE_AUTH] Peer reported error: requestId=%d, errorCode=%d", requestId, msg.ErrorCode)
+		errorCode := msg.ErrorCode
+		if errorCode == HC_ERR_REQUEST_CANCELLED {
+			errorCode = HC_ERR_PEER_CANCELLED
+		}
+		d.failBind(session, errorCode, fmt.Errorf("%w by peer", ErrBindRejected), false)
+		return nil
+	default:
+		return fmt.Errorf("unknown bind message: %d", msg.BindMsg)
+	}
+}
+
+// processBindAuth 处理承载HiChain消息的绑定数据（被绑定方首次收到时创建会话）
+func (d *deviceGroupManager) processBindAuth(requestId int64, msg *bindMessage) error {
+	session := d.getBindSession(requestId)
+	if session == nil || (!session.isClient && isPakeStart(msg.Data)) {
+		if session != nil {
+			d.takeBindSession(requestId, session)
+		}
+		var err error
+		if session, err = d.acceptBind(requestId, msg); err != nil {
+			d.sendBindError(d.getCallback(msg.AppId), requestId, msg, GetGroupErrorCode(err))
+			return err
+		}
+	}
+
+	if err := session.handle.ReceiveData(msg.Data); err != nil {
+		if errors.Is(err, hichain.ErrProofMismatch) {
+			recordPairingFailure(session.peerUdid, "")
+		}
+		d.failBind(session, hichainErrorCode(hichain.ErrorCodeOf(err)), err, true)
+		return err
+	}
+	return nil
+}
+
+// acceptBind 被绑定方创建会话
+func (d *deviceGroupManager) acceptBind(requestId int64, msg *bindMessage) (*bindSession, error) {
+	callback := d.getCallback(msg.AppId)
+	if callback == nil || callback.OnTransmit == nil {
+		return nil, fmt.Errorf("no transmit callback registered for appId: %s", msg.AppId)
+	}
+	if d.getLocalUdid() == "" {
+		return nil, fmt.Errorf("local udid not set")
+	}
+	if msg.GroupId == "" || msg.DeviceId == "" {
+		return nil, fmt.Errorf("groupId and deviceId are required")
+	}
+
+	session := &bindSession{
+		requestId:  requestId,
+		appId:      msg.AppId,
+		groupId:    msg.GroupId,
+		groupName:  msg.GroupName,
+		groupType:  msg.GroupType,
+		visibility: msg.Visibility,
+		peerUdid:   msg.DeviceId,
+		callback:   callback,
+	}
+
+	// 对端邀请本端加入其组，或请求加入本端已有的组
+	// 本端已有该组时要求对端声明的应用可以修改该组
+	d.mu.RLock()
+	group, err := d.manageableGroupLocked(msg.AppId, msg.GroupId)
+	if err == nil {
+		session.groupName = group.GroupName
+		session.groupType = group.GroupType
+		session.visibility = group.Visibility
+	}
+	d.mu.RUnlock()
+
+	switch GroupOperationCode(msg.Operation) {
+	case MemberInvite:
+		session.op = MemberJoin
+		if err != nil && !errors.Is(err, ErrGroupNotExist) {
+			return nil, err
+		}
+	case MemberJoin:
+		session.op = MemberInvite
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid bind operation: %d", msg.Operation)
+	}
+
+	if err := d.addBindSession(session); err != nil {
+		return nil, err
+	}
+	handle, err := hichain.GetInstance(d.bindIdentity(requestId), hichain.HCAccessory, d.createBindCallBack(session))
+	if err != nil {
+		d.takeBindSession(requestId, session)
+		return nil, fmt.Errorf("failed to create HiChain instance: %w", err)
+	}
+	session.handle = handle
+
+	log.Infof("[DEVICE_AUTH] Bind request accepted: requestId=%d, appId=%s, groupId=%s, peer=%s",
+		requestId, msg.AppId, msg.GroupId, msg.DeviceId)
+	return session, nil
+}
+
+// isPakeStart 判断HiChain消息是否为新一轮认证的第一条消息
+func isPakeStart(data []byte) bool {
+	var msg struct {
+		Message int `json:"message"`
+	}
+	return json.Unmarshal(hichain.CleanJSONData(data), &msg) == nil && msg.Message == hichain.MsgTypePakeRequest
+}
+
+// createBindCallBack 创建绑定会话的HiChain回调
+func (d *deviceGroupManager) createBindCallBack(session *bindSession) *hichain.HCCallBack {
+	return &hichain.HCCallBack{
+		OnTransmit: func(identity *hichain.SessionIdentity, data []byte) error {
+			msg := d.newBindMessage(session, bindMsgAuth)
+			msg.Data = json.RawMessage(hichain.CleanJSONData(data))
+			return d.sendBindMessage(session.callback, session.requestId, msg)
+		},
+
+		GetProtocolParams: func(identity *hichain.SessionIdentity, operationCode int32) (*hichain.ProtocolParams, error) {
+			params := &hichain.ProtocolParams{
+				KeyLength:  hichain.SessionKeyLength,
+				SelfAuthID: d.getLocalUdid(),
+			}
+			if session.isClient {
+				params.PeerAuthID = session.peerUdid
+			}
+
+			// 被绑定方首次使用时生成PIN码并交给应用层展示
+			if session.pinCode == "" && !session.isClient {
+				pinCode, err := GeneratePairingPin(&PairingRequest{
+					RequestId:     session.requestId,
+					PeerDeviceId:  session.peerUdid,
+					OperationCode: operationCode,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to generate pin code: %w", err)
+				}
+				session.pinCode = pinCode
+			}
+			params.PinCode = session.pinCode
+			return params, nil
+		},
+
+		SetSessionKey: func(identity *hichain.SessionIdentity, sessionKey *hichain.SessionKey) error {
+			if session.callback.OnSessionKeyReturned != nil {
+				session.callback.OnSessionKeyReturned(session.requestId, sessionKey.Key)
+			}
+			return nil
+		},
+
+		SetServiceResult: func(identity *hichain.SessionIdentity, result int32) error {
+			// HiChain已通过错误消息通知对端（或失败由对端报告），无需再发送bindMsgError
+			if result != hichain.HCOk {
+				d.failBind(session, hichainErrorCode(result), fmt.Errorf("auth failed: %d", result), false)
+				return nil
+			}
+			if peerUdid := session.handle.GetPeerAuthID(); peerUdid != "" {
+				session.peerUdid = peerUdid
+			}
+			if err := d.finishBind(session); err != nil {
+				d.failBind(session, GetGroupErrorCode(err), err, false)
+			}
+			return nil
+		},
+
+		ConfirmReceiveRequest: func(identity *hichain.SessionIdentity, operationCode int32) int32 {
+			// 锁定期内直接拒绝，不再生成和展示PIN码
+			if err := checkPairingAllowed(session.peerUdid, ""); err != nil {
+				log.Warnf("[DEVICE_AUTH] Bind rejected: requestId=%d, %v", session.requestId, err)
+				return hichain.HCLockedOut
+			}
+
+			// 绑定必须经过PIN码认证，不接受基于长期密钥的认证
+			if session.handle != nil && session.handle.UsesKeyAuth() {
+				log.Warnf("[DEVICE_AUTH] Bind rejected, key-based auth not allowed: requestId=%d", session.requestId)
+				return hichain.HCError
+			}
+
+			// 业务OnRequest回调明确给出结果时以其为准，响应中的pinCode作为本次PIN码
+			// 对端设备标识为应用可见的标识（AUTH_APPID以外的应用为假名ID）
+			if session.callback.OnRequest != nil {
+				peerDeviceId, _ := appVisibleUdid(session.appId, session.peerUdid)
+				reqParams, _ := json.Marshal(map[string]interface{}{
+					"peerDeviceId":  peerDeviceId,
+					"operationCode": session.op,
+					"groupId":       session.groupId,
+					"groupName":     session.groupName,
+				})
+				decided, accepted, pinCode := parseRequestResponse(session.callback.OnRequest(session.requestId, int32(session.op), string(reqParams)))
+				if pinCode != "" {
+					session.pinCode = pinCode
+				}
+				if decided {
+					if !accepted {
+						log.Warnf("[DEVICE_AUTH] Bind rejected by OnRequest: requestId=%d", session.requestId)
+						return hichain.HCError
+					}
+					return hichain.HCOk
+				}
+			}
+
+			req := &PairingRequest{RequestId: session.requestId, PeerDeviceId: session.peerUdid, OperationCode: int32(session.op)}
+			if !ConfirmPairingRequest(req) {
+				log.Warnf("[DEVICE_AUTH] Bind rejected by pairing provider: requestId=%d, peer=%s", session.requestId, session.peerUdid)
+				return hichain.HCError
+			}
+			return hichain.HCOk
+		},
+	}
+}
+
+// finishBind 认证成功：确保可信组存在并将对端添加为成员
+func (d *deviceGroupManager) finishBind(session *bindSession) error {
+	if session.peerUdid == "" {
+		return fmt.Errorf("peer udid unknown")
+	}
+
+	if err := d.ensureGroup(session); err != nil {
+		return err
+	}
+	member := &DeviceMemberInfo{
+		DeviceID: session.peerUdid,
+		UDID:     session.peerUdid,
+		AuthID:   session.peerUdid,
+		UserType: int32(DeviceTypeAccessory),
+		JoinTime: time.Now().Unix(),
+	}
+	if _, err := d.addMember(session.appId, session.groupId, member); err != nil {
+		return err
+	}
+	recordPairingSuccess(session.peerUdid, "")
+
+	if d.takeBindSession(session.requestId, session) {
+		log.Infof("[DEVICE_AUTH] Bind finished: requestId=%d, groupId=%s, peer=%s", session.requestId, session.groupId, session.peerUdid)
+		d.reportFinish(session, session.peerUdid)
+	}
+	return nil
+}
+
+// ensureGroup 本地不存在绑定的可信组时按会话中的组信息创建
+func (d *deviceGroupManager) ensureGroup(session *bindSession) error {
+	d.mu.Lock()
+	events := d.beginDataChangeLocked()
+	defer d.unlockAndNotify(events)
+
+	if _, exists := d.groups[session.groupId]; exists {
+		return nil
+	}
+	group := &GroupInfo{
+		GroupID:    session.groupId,
+		GroupName:  session.groupName,
+		GroupType:  session.groupType,
+		Visibility: session.visibility,
+		OwnerAppId: session.appId,
+		CreateTime: time.Now().Unix(),
+		Members:    make(map[string]*DeviceMemberInfo),
+	}
+	d.groups[session.groupId] = group
+	if err := d.saveLocked(); err != nil {
+		delete(d.groups, session.groupId)
+		return err
+	}
+	log.Infof("[DEVICE_AUTH] Group created by bind: groupId=%s, groupName=%s", session.groupId, session.groupName)
+	events.groupCreated(group)
+	return nil
+}
+
+// failBind 结束请求并通过OnError上报，notifyPeer为true时通知对端
+func (d *deviceGroupManager) failBind(session *bindSession, errorCode int32, err error, notifyPeer bool) {
+	if !d.takeBindSession(session.requestId, session) {
+		return
+	}
+	log.Errorf("[DEVICE_AUTH] Bind request failed: requestId=%d, op=%d, err=%v", session.requestId, session.op, err)
+
+	if notifyPeer {
+		msg := d.newBindMessage(session, bindMsgError)
+		msg.ErrorCode = errorCode
+		d.sendBindMessage(session.callback, session.requestId, msg)
+	}
+	if session.callback.OnError != nil {
+		session.callback.OnError(session.requestId, int32(session.op), errorCode, err.Error())
+	}
+}
+
+// reportFinish 通过OnFinish上报请求成功
+// peerUdid为应用可见的设备标识：AUTH_APPID为真实UDID，其他应用为该应用的假名ID
+func (d *deviceGroupManager) reportFinish(session *bindSession, peerUdid string) {
+	if session.callback.OnFinish == nil {
+		return
+	}
+	peerDeviceId, err := appVisibleUdid(session.appId, peerUdid)
+	if err != nil {
+		log.Errorf("[DEVICE_AUTH] Failed to get pseudonym for OnFinish: requestId=%d, err=%v", session.requestId, err)
+	}
+	returnData, _ := json.Marshal(map[string]string{
+		"groupId":  session.groupId,
+		"peerUdid": peerDeviceId,
+	})
+	session.callback.OnFinish(session.requestId, int32(session.op), string(returnData))
+}
+
+// newBindMessage 构造会话的绑定消息
+func (d *deviceGroupManager) newBindMessage(session *bindSession, bindMsg int) *bindMessage {
+	return &bindMessage{
+		BindMsg:    bindMsg,
+		Operation:  int32(session.op),
+		AppId:      session.appId,
+		GroupId:    session.groupId,
+		GroupName:  session.groupName,
+		GroupType:  session.groupType,
+		Visibility: session.visibility,
+		DeviceId:   d.getLocalUdid(),
+		PeerUdid:   session.peerUdid,
+	}
+}
+
+// sendBindMessage 通过业务回调发送绑定消息
+func (d *deviceGroupManager) sendBindMessage(callback *DeviceAuthCallback, requestId int64, msg *bindMessage) error {
+	if callback == nil || callback.OnTransmit == nil {
+		return fmt.Errorf("no transmit callback")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if !callback.OnTransmit(requestId, data) {
+		return fmt.Errorf("OnTransmit returned false")
+	}
+	return nil
+}
+
+// sendBindError 尚未建立会话时向对端回复错误
+func (d *deviceGroupManager) sendBindError(callback *DeviceAuthCallback, requestId int64, req *bindMessage, errorCode int32) {
+	msg := &bindMessage{
+		BindMsg:   bindMsgError,
+		Operation: req.Operation,
+		AppId:     req.AppId,
+		GroupId:   req.GroupId,
+		DeviceId:  d.getLocalUdid(),
+		PeerUdid:  req.DeviceId,
+		ErrorCode: errorCode,
+	}
+	if err := d.sendBindMessage(callback, requestId, msg); err != nil {
+		log.Warnf("[DEVICE_AUTH] Failed to send bind error: requestId=%d, %v", requestId, err)
+	}
+}
+
+// unbindSignData 解绑请求的签名内容
+func unbindSignData(msg *bindMessage) []byte {
+	return []byte(fmt.Sprintf("%d|%s|%s|%s|%s|%d",
+		msg.Operation, msg.AppId, msg.GroupId, msg.DeviceId, msg.PeerUdid, msg.Timestamp))
+}
+
+// notifyUnbind 本地删除成员后通知对端解绑（未注册OnTransmit时仅本地删除）
+func (d *deviceGroupManager) notifyUnbind(requestId int64, appId string, groupId string, member *DeviceMemberInfo) error {
+	callback := d.getCallback(appId)
+	localUdid := d.getLocalUdid()
+	if callback == nil || callback.OnTransmit == nil || localUdid == "" {
+		return nil
+	}
+
+	session := &bindSession{
+		requestId: requestId,
+		appId:     appId,
+		op:        MemberDelete,
+		isClient:  true,
+		groupId:   groupId,
+		peerUdid:  member.UDID,
+		callback:  callback,
+	}
+	msg := d.newBindMessage(session, bindMsgUnbind)
+	msg.Timestamp = time.Now().Unix()
+	signature, err := hichain.SignWithLocalKey(localUdid, unbindSignData(msg))
+	if err != nil {
+		return fmt.Errorf("failed to sign unbind request: %w", err)
+	}
+	msg.Signature = hex.EncodeToString(signature)
+
+	if err := d.addBindSession(session); err != nil {
+		return err
+	}
+	if err := d.sendBindMessage(callback, requestId, msg); err != nil {
+		d.takeBindSession(requestId, session)
+		return fmt.Errorf("failed to send unbind request: %w", err)
+	}
+	log.Infof("[DEVICE_AUTH] Unbind request sent: requestId=%d, groupId=%s, peer=%s", requestId, groupId, member.UDID)
+	return nil
+}
+
+// processUnbind 处理对端的解绑请求：验签后删除对端并回复确认
+func (d *deviceGroupManager) processUnbind(requestId int64, msg *bindMessage) error {
+	callback := d.getCallback(msg.AppId)
+	localUdid := d.getLocalUdid()
+
+	if msg.PeerUdid != localUdid {
+		d.sendBindError(callback, requestId, msg, HC_ERR_INVALID_PARAMS)
+		return fmt.Errorf("unbind request not for this device: %s", msg.PeerUdid)
+	}
+	if age := time.Since(time.Unix(msg.Timestamp, 0)); age > unbindValidWindow || age < -unbindValidWindow {
+		d.sendBindError(callback, requestId, msg, HC_ERR_INVALID_PARAMS)
+		return fmt.Errorf("unbind request expired: timestamp=%d", msg.Timestamp)
+	}
+	signature, err := hex.DecodeString(msg.Signature)
+	if err != nil || !hichain.VerifyPeerSignature(msg.DeviceId, unbindSignData(msg), signature) {
+		d.sendBindError(callback, requestId, msg, HC_ERR)
+		return fmt.Errorf("invalid unbind signature from %s", msg.DeviceId)
+	}
+
+	if _, err := d.deleteMember(msg.AppId, msg.GroupId, msg.DeviceId); err != nil {
+		d.sendBindError(callback, requestId, msg, GetGroupErrorCode(err))
+		return err
+	}
+	log.Infof("[DEVICE_AUTH] Unbound by peer: requestId=%d, groupId=%s, peer=%s", requestId, msg.GroupId, msg.DeviceId)
+
+	session := &bindSession{
+		requestId: requestId,
+		appId:     msg.AppId,
+		op:        MemberDelete,
+		groupId:   msg.GroupId,
+		peerUdid:  msg.DeviceId,
+		callback:  callback,
+	}
+	if callback != nil {
+		d.sendBindMessage(callback, requestId, d.newBindMessage(session, bindMsgUnbindAck))
+		d.reportFinish(session, msg.DeviceId)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package device_auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+)
+
+// bindTestPeer 绑定测试中的一端
+type bindTestPeer struct {
+	gm         *deviceGroupManager
+	remote     *bindTestPeer
+	pinCode    string // 被绑定方通过OnRequest返回的PIN码
+	finished   []int32
+	finishData []string
+	errors     []int32
+}
+
+// newBindTestPeer 创建测试端，发送的数据直接交给remote处理
+func newBindTestPeer(udid string) *bindTestPeer {
+	peer := &bindTestPeer{gm: newDeviceGroupManager()}
+	peer.gm.localUdid = udid
+	peer.gm.RegCallback("bind_app", &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool {
+			peer.remote.gm.ProcessData(requestId, data)
+			return true
+		},
+		OnFinish: func(requestId int64, operationCode int32, returnData string) {
+			peer.finished = append(peer.finished, operationCode)
+			peer.finishData = append(peer.finishData, returnData)
+		},
+		OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
+			peer.errors = append(peer.errors, operationCode)
+		},
+		OnRequest: func(requestId int64, operationCode int32, reqParams string) string {
+			return fmt.Sprintf(`{"confirmation":%d,"pinCode":"%s"}`, RequestAccepted, peer.pinCode)
+		},
+	})
+	return peer
+}
+
+// newBindTestPair 创建互相连接的两端，client本地已创建可信组
+func newBindTestPair(t *testing.T, serverPin string) (*bindTestPeer, *bindTestPeer) {
+	client := newBindTestPeer("bind-udid-a")
+	server := newBindTestPeer("bind-udid-b")
+	client.remote = server
+	server.remote = client
+	server.pinCode = serverPin
+
+	if err := client.gm.CreateGroup(AnyOsAccount, 1, "bind_app", `{"groupId":"BIND_001","groupName":"Bind","groupType":256}`); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	return client, server
+}
+
+// 测试绑定与解绑：PIN码正确时双方互相添加为成员并交换长期公钥，解绑后双方都删除对方
+func TestBindAndUnbind(t *testing.T) {
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+	defer ResetPairingLockout("", "")
+
+	client, server := newBindTestPair(t, "123456")
+	if err := client.gm.AddMemberToGroup(AnyOsAccount, 11, "bind_app", `{"groupId":"BIND_001","pinCode":"123456"}`); err != nil {
+		t.Fatalf("AddMemberToGroup failed: %v", err)
+	}
+
+	if len(client.finished) != 1 || client.finished[0] != int32(MemberInvite) {
+		t.Fatalf("Expected client OnFinish with MemberInvite, got %v (errors=%v)", client.finished, client.errors)
+	}
+	if len(server.finished) != 1 || server.finished[0] != int32(MemberJoin) {
+		t.Fatalf("Expected server OnFinish with MemberJoin, got %v (errors=%v)", server.finished, server.errors)
+	}
+	// 第三方应用的OnFinish中只有对端的假名ID
+	var finish map[string]string
+	json.Unmarshal([]byte(client.finishData[0]), &finish)
+	if pseudonym, _ := appVisibleUdid("bind_app", "bind-udid-b"); finish["peerUdid"] != pseudonym || pseudonym == "bind-udid-b" {
+		t.Errorf("Expected pseudonym in OnFinish, got %s", client.finishData[0])
+	}
+	if !client.gm.IsDeviceInGroup(AnyOsAccount, "bind_app", "BIND_001", testAppDeviceId(t, "bind_app", "bind-udid-b")) {
+		t.Error("Expected server to be a member on client side")
+	}
+	if !server.gm.IsDeviceInGroup(AnyOsAccount, "bind_app", "BIND_001", testAppDeviceId(t, "bind_app", "bind-udid-a")) {
+		t.Error("Expected client to be a member on server side")
+	}
+	if hichain.GetDeviceAuthInfo("bind-udid-a") == nil || hichain.GetDeviceAuthInfo("bind-udid-b") == nil {
+		t.Error("Expected long-term keys exchanged")
+	}
+
+	// 解绑：对端验签后删除发起方
+	if err := client.gm.DeleteMemberFromGroup(AnyOsAccount, 12, "bind_app", `{"groupId":"BIND_001","deviceId":"bind-udid-b"}`); err != nil {
+		t.Fatalf("DeleteMemberFromGroup failed: %v", err)
+	}
+	if len(client.finished) != 2 || client.finished[1] != int32(MemberDelete) {
+		t.Errorf("Expected client OnFinish with MemberDelete, got %v", client.finished)
+	}
+	if server.gm.IsDeviceInGroup(AnyOsAccount, "bind_app", "BIND_001", testAppDeviceId(t, "bind_app", "bind-udid-a")) {
+		t.Error("Expected client to be removed on server side")
+	}
+	if len(client.gm.sessions) != 0 || len(server.gm.sessions) != 0 {
+		t.Error("Expected no pending sessions")
+	}
+}
+
+// 测试PIN码错误时双方均上报失败且不添加成员
+func TestBind_WrongPin(t *testing.T) {
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+	defer ResetPairingLockout("", "")
+
+	client, server := newBindTestPair(t, "654321")
+	client.gm.AddMemberToGroup(AnyOsAccount, 21, "bind_app", `{"groupId":"BIND_001","pinCode":"123456"}`)
+
+	if len(client.errors) != 1 || len(server.errors) != 1 {
+		t.Errorf("Expected OnError on both sides, client=%v server=%v", client.errors, server.errors)
+	}
+	if len(client.finished) != 0 || len(server.finished) != 0 {
+		t.Error("Expected no OnFinish on wrong pin")
+	}
+	if client.gm.IsDeviceInGroup(AnyOsAccount, "bind_app", "BIND_001", testAppDeviceId(t, "bind_app", "bind-udid-b")) {
+		t.Error("Expected no member added on wrong pin")
+	}
+	if device, _ := GetPairingLockout("bind-udid-a", ""); device.Failures != 1 {
+		t.Errorf("Expected 1 pairing failure recorded, got %d", device.Failures)
+	}
+}
+
+// 测试伪造的解绑请求被拒绝
+func TestUnbind_InvalidSignature(t *testing.T) {
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+
+	server := newBindTestPeer("bind-udid-b")
+	server.remote = newBindTestPeer("bind-udid-a")
+	server.gm.CreateGroup(AnyOsAccount, 1, "bind_app", `{"groupId":"BIND_001","groupType":256}`)
+	server.gm.AddMemberToGroup(AnyOsAccount, 2, "bind_app", `{"groupId":"BIND_001","deviceId":"bind-udid-a"}`)
+	hichain.GetOrCreateLocalKeyPair("bind-udid-a")
+
+	data := fmt.Sprintf(`{"bindMsg":%d,"operationCode":%d,"appId":"bind_app","groupId":"BIND_001","deviceId":"bind-udid-a","peerUdid":"bind-udid-b","timestamp":1,"signature":"00"}`,
+		bindMsgUnbind, MemberDelete)
+	if err := server.gm.ProcessData(31, []byte(data)); err == nil {
+		t.Error("Expected forged unbind request to be rejected")
+	}
+	if !server.gm.IsDeviceInGroup(AnyOsAccount, "bind_app", "BIND_001", testAppDeviceId(t, "bind_app", "bind-udid-a")) {
+		t.Error("Expected member to be kept")
+	}
+}
+
+// 测试批量添加和删除成员
+func TestMultiMembers(t *testing.T) {
+	gm := newDeviceGroupManager()
+	defer importTestAccount(t, "multi-user")()
+	gm.CreateGroup(AnyOsAccount, 1, "test_app", `{"groupId":"MULTI_001","groupType":1,"userId":"multi-user"}`)
+
+	addParams := `{"groupId":"MULTI_001","deviceList":[{"deviceId":"dev-1","udid":"udid-1"},{"deviceId":"dev-2"}]}`
+	if err := gm.AddMultiMembersToGroup(AnyOsAccount, "test_app", addParams); err != nil {
+		t.Fatalf("AddMultiMembersToGroup failed: %v", err)
+	}
+	devices, _ := gm.GetTrustedDevices(AnyOsAccount, "test_app", "MULTI_001")
+	if len(devices) != 2 {
+		t.Errorf("Expected 2 members, got %d", len(devices))
+	}
+
+	// 任一设备不在组中时全部不生效
+	if err := gm.DelMultiMembersFromGroup(AnyOsAccount, "test_app", `{"groupId":"MULTI_001","deviceList":[{"deviceId":"dev-1"},{"deviceId":"dev-3"}]}`); err == nil {
+		t.Error("Expected error for unknown device")
+	}
+	if !gm.IsDeviceInGroup(AnyOsAccount, "test_app", "MULTI_001", testAppDeviceId(t, "test_app", "dev-1")) {
+		t.Error("Expected dev-1 to be kept after failed bulk delete")
+	}
+
+	if err := gm.DelMultiMembersFromGroup(AnyOsAccount, "test_app", `{"groupId":"MULTI_001","deviceList":[{"deviceId":"dev-1"},{"deviceId":"dev-2"}]}`); err != nil {
+		t.Fatalf("DelMultiMembersFromGroup failed: %v", err)
+	}
+	devices, _ = gm.GetTrustedDevices(AnyOsAccount, "test_app", "MULTI_001")
+	if len(devices) != 0 {
+		t.Errorf("Expected 0 members, got %d", len(devices))
+	}
+}
@@ -0,0 +1,160 @@
+package device_auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/context"
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+)
+
+// 测试取消进行中的认证：通知对端、销毁实例、删除AuthSessionContext，取消方上报取消、对端上报对端取消
+func TestCancelRequest_Auth(t *testing.T) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	defer DestroyDeviceAuthService()
+	defer ResetPairingLockout("", "")
+
+	const clientReqId, serverReqId = 6101, 6201
+	context.SetAuthSessionContext(clientReqId, &context.AuthSessionContext{
+		RequestID: clientReqId, LocalDeviceID: "cancel-udid-a", PeerDeviceID: "cancel-udid-b",
+	})
+	context.SetAuthSessionContext(serverReqId, &context.AuthSessionContext{
+		RequestID: serverReqId, LocalDeviceID: "cancel-udid-b",
+	})
+	defer context.DeleteAuthSessionContext(serverReqId)
+
+	client, _ := GetGaInstance()
+	server := newRealGroupAuthManager()
+
+	// 客户端发出的消息暂存，由测试决定何时交给服务端
+	var sent [][]byte
+	var clientErrors, serverErrors []int32
+	clientCb := &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool {
+			sent = append(sent, data)
+			return true
+		},
+		OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
+			clientErrors = append(clientErrors, errorCode)
+		},
+	}
+	serverCb := &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool { return true },
+		OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
+			serverErrors = append(serverErrors, errorCode)
+		},
+		OnRequest: func(requestId int64, operationCode int32, reqParams string) string {
+			return fmt.Sprintf(`{"confirmation":%d,"pinCode":"123456"}`, RequestAccepted)
+		},
+	}
+
+	if err := client.AuthDevice(AnyOsAccount, clientReqId, `{"peerUdid":"cancel-udid-b","pinCode":"123456"}`, clientCb); err != nil {
+		t.Fatalf("AuthDevice failed: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected PAKE_REQUEST sent, got %d messages", len(sent))
+	}
+	server.ProcessData(serverReqId, sent[0], serverCb)
+
+	client.CancelRequest(clientReqId, AUTH_APPID)
+	if len(clientErrors) != 1 || clientErrors[0] != HC_ERR_REQUEST_CANCELLED {
+		t.Errorf("Expected OnError with HC_ERR_REQUEST_CANCELLED, got %v", clientErrors)
+	}
+	if context.FindAuthSessionContextByRequestId(clientReqId) != nil {
+		t.Error("Expected AuthSessionContext deleted")
+	}
+	ga := client.(*realGroupAuthManager)
+	if ga.hichainInstances[clientReqId] != nil || ga.callbacks[clientReqId] != nil {
+		t.Error("Expected HiChain instance destroyed")
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("Expected error message sent to peer, got %d messages", len(sent))
+	}
+	var msg struct {
+		Message   int `json:"message"`
+		ErrorCode int `json:"errorCode"`
+	}
+	json.Unmarshal(sent[1], &msg)
+	if msg.Message != hichain.MsgTypeError || msg.ErrorCode != hichain.HCCancelled {
+		t.Errorf("Unexpected cancel message: %s", sent[1])
+	}
+
+	// 对端收到错误消息后结束认证
+	server.ProcessData(serverReqId, sent[1], serverCb)
+	if len(serverErrors) != 1 || serverErrors[0] != HC_ERR_PEER_CANCELLED {
+		t.Errorf("Expected peer OnError with HC_ERR_PEER_CANCELLED, got %v", serverErrors)
+	}
+	if server.hichainInstances[serverReqId] != nil {
+		t.Error("Expected peer HiChain instance destroyed")
+	}
+
+	// 再次取消不重复上报
+	client.CancelRequest(clientReqId, AUTH_APPID)
+	if len(clientErrors) != 1 {
+		t.Errorf("Expected no further OnError, got %v", clientErrors)
+	}
+}
+
+// 测试取消进行中的绑定：只有发起请求的应用可以取消，取消方上报取消、对端上报对端取消
+func TestCancelRequest_Bind(t *testing.T) {
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+	defer ResetPairingLockout("", "")
+
+	client, server := newBindTestPair(t, "123456")
+
+	// 客户端的消息暂存，不立即交给服务端
+	var pending [][]byte
+	var clientErrors, serverErrors []int32
+	client.gm.RegCallback("bind_app", &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool {
+			pending = append(pending, data)
+			return true
+		},
+		OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
+			clientErrors = append(clientErrors, errorCode)
+		},
+	})
+	server.gm.RegCallback("bind_app", &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool { return true },
+		OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
+			serverErrors = append(serverErrors, errorCode)
+		},
+		OnRequest: func(requestId int64, operationCode int32, reqParams string) string {
+			return fmt.Sprintf(`{"confirmation":%d,"pinCode":"123456"}`, RequestAccepted)
+		},
+	})
+
+	if err := client.gm.AddMemberToGroup(AnyOsAccount, 21, "bind_app", `{"groupId":"BIND_001","pinCode":"123456"}`); err != nil {
+		t.Fatalf("AddMemberToGroup failed: %v", err)
+	}
+	server.gm.ProcessData(21, pending[0])
+	if server.gm.getBindSession(21) == nil {
+		t.Fatal("Expected bind session on server side")
+	}
+
+	client.gm.CancelRequest(21, "other_app")
+	if client.gm.getBindSession(21) == nil {
+		t.Fatal("Expected cancel by another app to be ignored")
+	}
+
+	client.gm.CancelRequest(21, "bind_app")
+	if client.gm.getBindSession(21) != nil {
+		t.Error("Expected bind session removed")
+	}
+	if len(clientErrors) != 1 || clientErrors[0] != HC_ERR_REQUEST_CANCELLED {
+		t.Errorf("Expected OnError with HC_ERR_REQUEST_CANCELLED, got %v", clientErrors)
+	}
+
+	server.gm.ProcessData(21, pending[len(pending)-1])
+	if server.gm.getBindSession(21) != nil {
+		t.Error("Expected peer bind session removed")
+	}
+	if len(serverErrors) != 1 || serverErrors[0] != HC_ERR_PEER_CANCELLED {
+		t.Errorf("Expected peer OnError with HC_ERR_PEER_CANCELLED, got %v", serverErrors)
+	}
+}
@@ -0,0 +1,460 @@
+package device_auth
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/storage"
+)
+
+// ============================================================================
+// 凭据管理
+// ============================================================================
+//
+// ProcessCredential管理预置的信任关系，配网/开局系统可以预先导入凭据，无需交互式配对:
+//   - 账户凭据（userId）: 对称凭据，与账户组中的设备认证时代替PIN码（见account.go）
+//   - 对端对称凭据（deviceId）: 与该设备认证时代替PIN码执行PAKE
+//   - 对端非对称凭据（deviceId）: 对端ED25519长期公钥，直接使用基于长期密钥的认证；
+//     可以带userId，表示该设备属于某个账户，认证成功后加入对应的账户组
+//
+// 操作码:
+//   - CredOpImport: 导入凭据（已存在时覆盖）
+//   - CredOpQuery:  按credentialType/userId/deviceId过滤查询，不返回对称凭据内容
+//   - CredOpExport: 导出唯一匹配的凭据（导入格式，包含凭据内容）
+//   - CredOpDelete: 删除匹配的凭据
+//   - CredOpCreate: 获取（不存在时生成）本地长期密钥对，返回本地设备的非对称凭据供对端导入
+//
+// 调用EnableCredentialStore后凭据加密保存在 <dataDir>/device_credentials.json（AES-256-GCM，原子写入，权限0600）。
+
+const (
+	minAuthCodeLen          = 16 // 对称凭据最小长度（字节）
+	credentialStoreFileName = "device_credentials.json"
+	credentialStoreVersion  = 1
+	credentialWrapPurpose   = "device_credential"
+)
+
+// credentialEntry 凭据
+type credentialEntry struct {
+	CredentialType CredType `json:"credentialType"`
+	UserID         string   `json:"userId,omitempty"`
+	DeviceID       string   `json:"deviceId,omitempty"`
+	AuthCode       string   `json:"authCode,omitempty"`  // 对称凭据（十六进制）
+	PublicKey      string   `json:"publicKey,omitempty"` // 非对称凭据（十六进制ED25519公钥）
+	CreateTime     int64    `json:"createTime,omitempty"`
+}
+
+// key 凭据索引: 对端凭据按设备，账户凭据按用户
+func (c *credentialEntry) key() string {
+	if c.DeviceID != "" {
+		return fmt.Sprintf("%d/device/%s", c.CredentialType, c.DeviceID)
+	}
+	return fmt.Sprintf("%d/user/%s", c.CredentialType, c.UserID)
+}
+
+// publicView 查询结果（不含对称凭据内容）
+func (c *credentialEntry) publicView() *credentialEntry {
+	view := *c
+	view.AuthCode = ""
+	return &view
+}
+
+// matches 是否满足查询条件（条件为空时不过滤）
+func (c *credentialEntry) matches(filter *credentialEntry) bool {
+	return (filter.CredentialType == 0 || filter.CredentialType == c.CredentialType) &&
+		(filter.UserID == "" || filter.UserID == c.UserID) &&
+		(filter.DeviceID == "" || filter.DeviceID == c.DeviceID)
+}
+
+// validate 校验导入的凭据
+func (c *credentialEntry) validate() error {
+	switch c.CredentialType {
+	case SymmetricCred:
+		if (c.UserID == "") == (c.DeviceID == "") {
+			return fmt.Errorf("symmetric credential requires either userId or deviceId")
+		}
+		authCode, err := hex.DecodeString(c.AuthCode)
+		if err != nil || len(authCode) < minAuthCodeLen {
+			return fmt.Errorf("authCode must be at least %d bytes of hex", minAuthCodeLen)
+		}
+		c.PublicKey = ""
+	case AsymmetricCred:
+		if c.DeviceID == "" {
+			return fmt.Errorf("asymmetric credential requires deviceId")
+		}
+		publicKey, err := hex.DecodeString(c.PublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("publicKey must be a %d-byte ED25519 key in hex", ed25519.PublicKeySize)
+		}
+		c.AuthCode = ""
+	default:
+		return fmt.Errorf("unsupported credentialType: %d", c.CredentialType)
+	}
+	return nil
+}
+
+var (
+	g_credentials     = make(map[string]*credentialEntry) // key -> 凭据
+	g_credentialStore *credentialStore                    // 持久化存储（nil表示仅保存在内存）
+	g_credentialMu    sync.RWMutex
+)
+
+// ProcessCredential 处理凭证数据
+// 参数: {"credentialType":1|2,"userId":"...","deviceId":"...","authCode":"<hex>","publicKey":"<hex>"}
+func ProcessCredential(operationCode int32, requestParams string) (string, error) {
+	log.Infof("[DEVICE_AUTH] ProcessCredential: operationCode=%d", operationCode)
+
+	var params credentialEntry
+	if err := json.Unmarshal([]byte(requestParams), &params); err != nil {
+		return "", fmt.Errorf("invalid requestParams: %w", err)
+	}
+
+	switch operationCode {
+	case CredOpImport:
+		return importCredential(&params)
+	case CredOpQuery:
+		return queryCredentials(&params)
+	case CredOpExport:
+		return exportCredential(&params)
+	case CredOpDelete:
+		return deleteCredentials(&params)
+	case CredOpCreate:
+		return createLocalCredential(&params)
+	default:
+		return "", fmt.Errorf("unsupported operationCode: %d", operationCode)
+	}
+}
+
+// importCredential 导入凭据，非对称凭据同时作为对端长期公钥保存
+func importCredential(params *credentialEntry) (string, error) {
+	if params.CredentialType == 0 {
+		params.CredentialType = SymmetricCred
+	}
+	if err := params.validate(); err != nil {
+		return "", err
+	}
+	params.CreateTime = time.Now().Unix()
+
+	g_credentialMu.Lock()
+	key := params.key()
+	previous, existed := g_credentials[key]
+	g_credentials[key] = params
+	if err := saveCredentialsLocked(); err != nil {
+		if existed {
+			g_credentials[key] = previous
+		} else {
+			delete(g_credentials, key)
+		}
+		g_credentialMu.Unlock()
+		return "", err
+	}
+	g_credentialMu.Unlock()
+
+	if params.CredentialType == AsymmetricCred {
+		publicKey, _ := hex.DecodeString(params.PublicKey)
+		hichain.SaveDeviceAuthInfo(params.DeviceID, publicKey)
+	}
+	log.Infof("[DEVICE_AUTH] Credential imported: type=%d, userId=%s, deviceId=%s",
+		params.CredentialType, params.UserID, params.DeviceID)
+	return marshalCredential(params.publicView()), nil
+}
+
+// queryCredentials 查询凭据（不含对称凭据内容），有过滤条件但没有匹配时返回错误
+func queryCredentials(filter *credentialEntry) (string, error) {
+	matched := findCredentials(filter)
+	if len(matched) == 0 && (filter.UserID != "" || filter.DeviceID != "") {
+		return "", fmt.Errorf("credential not found")
+	}
+	credentials := make([]*credentialEntry, 0, len(matched))
+	for _, entry := range matched {
+		credentials = append(credentials, entry.publicView())
+	}
+	data, _ := json.Marshal(map[string]interface{}{"credentials": credentials})
+	return string(data), nil
+}
+
+// exportCredential 导出唯一匹配的凭据
+func exportCredential(filter *credentialEntry) (string, error) {
+	if filter.UserID == "" && filter.DeviceID == "" {
+		return "", fmt.Errorf("userId or deviceId is required")
+	}
+	matched := findCredentials(filter)
+	if len(matched) == 0 {
+		return "", fmt.Errorf("credential not found")
+	}
+	if len(matched) > 1 {
+		return "", fmt.Errorf("%d credentials matched, specify credentialType", len(matched))
+	}
+	return marshalCredential(matched[0]), nil
+}
+
+// deleteCredentials 删除匹配的凭据，对端公钥不再被任何可信组或凭据引用时一并删除
+func deleteCredentials(filter *credentialEntry) (string, error) {
+	if filter.UserID == "" && filter.DeviceID == "" {
+		return "", fmt.Errorf("userId or deviceId is required")
+	}
+
+	g_credentialMu.Lock()
+	removed := make(map[string]*credentialEntry)
+	for key, entry := range g_credentials {
+		if entry.matches(filter) {
+			removed[key] = entry
+			delete(g_credentials, key)
+		}
+	}
+	if len(removed) == 0 {
+		g_credentialMu.Unlock()
+		return "", fmt.Errorf("credential not found")
+	}
+	if err := saveCredentialsLocked(); err != nil {
+		for key, entry := range removed {
+			g_credentials[key] = entry
+		}
+		g_credentialMu.Unlock()
+		return "", err
+	}
+	g_credentialMu.Unlock()
+
+	for _, entry := range removed {
+		if entry.CredentialType == AsymmetricCred {
+			releaseCredentialKey(entry.DeviceID)
+		}
+	}
+	log.Infof("[DEVICE_AUTH] Credentials deleted: userId=%s, deviceId=%s, count=%d",
+		filter.UserID, filter.DeviceID, len(removed))
+	return "{}", nil
+}
+
+// createLocalCredential 返回本地设备的非对称凭据（长期公钥，不存在时生成）
+// params中的userId原样带回，对端导入后可将本设备关联到该账户
+func createLocalCredential(params *credentialEntry) (string, error) {
+	d := getDeviceGroupManager()
+	if d == nil {
+		return "", fmt.Errorf("device auth service not initialized")
+	}
+	localUdid := d.getLocalUdid()
+	if localUdid == "" {
+		return "", fmt.Errorf("local udid not set")
+	}
+	_, publicKey, err := hichain.GetOrCreateLocalKeyPair(localUdid)
+	if err != nil {
+		return "", fmt.Errorf("failed to create local key pair: %w", err)
+	}
+	return marshalCredential(&credentialEntry{
+		CredentialType: AsymmetricCred,
+		UserID:         params.UserID,
+		DeviceID:       localUdid,
+		PublicKey:      hex.EncodeToString(publicKey),
+	}), nil
+}
+
+// releaseCredentialKey 删除对端非对称凭据后，设备不在任何可信组中时删除其长期公钥
+func releaseCredentialKey(deviceId string) {
+	if d := getDeviceGroupManager(); d != nil {
+		d.mu.RLock()
+		inGroup := d.isDeviceTrustedLocked(deviceId)
+		d.mu.RUnlock()
+		if inGroup {
+			return
+		}
+	}
+	hichain.ClearDeviceAuthInfo(deviceId)
+}
+
+// findCredentials 查找匹配的凭据（按索引排序，保证输出稳定）
+func findCredentials(filter *credentialEntry) []*credentialEntry {
+	g_credentialMu.RLock()
+	defer g_credentialMu.RUnlock()
+
+	keys := make([]string, 0, len(g_credentials))
+	for key, entry := range g_credentials {
+		if entry.matches(filter) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := make([]*credentialEntry, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, g_credentials[key])
+	}
+	return result
+}
+
+// getCredential 按类型和主体获取凭据
+func getCredential(credType CredType, userId string, deviceId string) *credentialEntry {
+	key := (&credentialEntry{CredentialType: credType, UserID: userId, DeviceID: deviceId}).key()
+	g_credentialMu.RLock()
+	defer g_credentialMu.RUnlock()
+	return g_credentials[key]
+}
+
+// accountAuthCode 账户凭据（不存在时返回nil）
+func accountAuthCode(userId string) []byte {
+	if userId == "" {
+		return nil
+	}
+	if entry := getCredential(SymmetricCred, userId, ""); entry != nil {
+		authCode, _ := hex.DecodeString(entry.AuthCode)
+		return authCode
+	}
+	return nil
+}
+
+// peerAuthCode 对端设备的对称凭据（不存在时返回nil）
+func peerAuthCode(deviceId string) []byte {
+	if deviceId == "" {
+		return nil
+	}
+	if entry := getCredential(SymmetricCred, "", deviceId); entry != nil {
+		authCode, _ := hex.DecodeString(entry.AuthCode)
+		return authCode
+	}
+	return nil
+}
+
+// peerPublicKeyCredential 对端设备的非对称凭据（不存在时返回nil）
+func peerPublicKeyCredential(deviceId string) *credentialEntry {
+	if deviceId == "" {
+		return nil
+	}
+	return getCredential(AsymmetricCred, "", deviceId)
+}
+
+// marshalCredential 凭据JSON
+func marshalCredential(entry *credentialEntry) string {
+	data, _ := json.Marshal(entry)
+	return string(data)
+}
+
+// ============================================================================
+// 凭据持久化
+// ============================================================================
+
+// credentialStoreFile 凭据文件内容（加密前）
+type credentialStoreFile struct {
+	Version     int                `json:"version"`
+	Credentials []*credentialEntry `json:"credentials"`
+}
+
+// credentialStore 凭据文件存储
+type credentialStore struct {
+	path    string
+	wrapKey []byte
+}
+
+// EnableCredentialStore 启用凭据持久化
+// 从数据目录加载已保存的凭据（启用前导入的凭据合并保存），非对称凭据同步为对端长期公钥
+func EnableCredentialStore(dataDir string) error {
+	if dataDir == "" {
+		return fmt.Errorf("data dir is empty")
+	}
+	if err := storage.EnsureDir(dataDir); err != nil {
+		return err
+	}
+	wrapKey, err := storage.LoadOrCreateWrapKey(dataDir, credentialWrapPurpose)
+	if err != nil {
+		return fmt.Errorf("failed to load wrap key: %w", err)
+	}
+	store := &credentialStore{path: filepath.Join(dataDir, credentialStoreFileName), wrapKey: wrapKey}
+
+	credentials, err := store.load()
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	g_credentialMu.Lock()
+	dirty := false
+	for key, entry := range g_credentials {
+		if _, exists := credentials[key]; !exists {
+			credentials[key] = entry
+			dirty = true
+		}
+	}
+	if dirty {
+		if err := store.save(credentials); err != nil {
+			g_credentialMu.Unlock()
+			return err
+		}
+	}
+	g_credentials = credentials
+	g_credentialStore = store
+	g_credentialMu.Unlock()
+
+	for _, entry := range credentials {
+		if entry.CredentialType == AsymmetricCred {
+			publicKey, _ := hex.DecodeString(entry.PublicKey)
+			hichain.SaveDeviceAuthInfo(entry.DeviceID, publicKey)
+		}
+	}
+	log.Infof("[DEVICE_AUTH] Credential store enabled: path=%s, credentials=%d", store.path, len(credentials))
+	return nil
+}
+
+// saveCredentialsLocked 将凭据写入持久化存储（调用方持有写锁）
+func saveCredentialsLocked() error {
+	if g_credentialStore == nil {
+		return nil
+	}
+	if err := g_credentialStore.save(g_credentials); err != nil {
+		log.Errorf("[DEVICE_AUTH] Failed to persist credentials: %v", err)
+		return fmt.Errorf("failed to persist credentials: %w", err)
+	}
+	return nil
+}
+
+// load 加载并解密凭据文件（不存在时返回空集合）
+func (s *credentialStore) load() (map[string]*credentialEntry, error) {
+	credentials := make(map[string]*credentialEntry)
+
+	data, err := storage.ReadSealedFile(s.path, s.wrapKey, []byte(credentialWrapPurpose))
+	if errors.Is(err, os.ErrNotExist) {
+		return credentials, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content credentialStoreFile
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse credential store: %w", err)
+	}
+	if content.Version != credentialStoreVersion {
+		return nil, fmt.Errorf("unsupported credential store version: %d", content.Version)
+	}
+	for _, entry := range content.Credentials {
+		if entry == nil || entry.validate() != nil {
+			continue
+		}
+		credentials[entry.key()] = entry
+	}
+	return credentials, nil
+}
+
+// save 加密并原子写入凭据（按索引排序，保证输出稳定）
+func (s *credentialStore) save(credentials map[string]*credentialEntry) error {
+	keys := make([]string, 0, len(credentials))
+	for key := range credentials {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	content := &credentialStoreFile{Version: credentialStoreVersion, Credentials: make([]*credentialEntry, 0, len(keys))}
+	for _, key := range keys {
+		content.Credentials = append(content.Credentials, credentials[key])
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	return storage.WriteSealedFile(s.path, s.wrapKey, data, []byte(credentialWrapPurpose))
+}
@@ -0,0 +1,170 @@
+package device_auth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+)
+
+// resetCredentials 清空凭据并关闭持久化（模拟重启）
+func resetCredentials() {
+	g_credentialMu.Lock()
+	g_credentials = make(map[string]*credentialEntry)
+	g_credentialStore = nil
+	g_credentialMu.Unlock()
+}
+
+// 测试对端凭据的导入、查询、导出和删除：查询不返回对称凭据，非对称凭据同步为对端长期公钥
+func TestCredentialManager(t *testing.T) {
+	resetCredentials()
+	defer resetCredentials()
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+
+	publicKey := strings.Repeat("ab", 32)
+	invalid := []string{
+		`{"credentialType":1,"userId":"u","deviceId":"d","authCode":"` + testAuthCode + `"}`,
+		`{"credentialType":2,"userId":"u","publicKey":"` + publicKey + `"}`,
+		`{"credentialType":2,"deviceId":"d","publicKey":"abcd"}`,
+		`{"credentialType":3,"deviceId":"d"}`,
+	}
+	for _, params := range invalid {
+		if _, err := ProcessCredential(CredOpImport, params); err == nil {
+			t.Errorf("Expected import error for %s", params)
+		}
+	}
+
+	if _, err := ProcessCredential(CredOpImport, `{"credentialType":1,"deviceId":"cred-peer","authCode":"`+testAuthCode+`"}`); err != nil {
+		t.Fatalf("Import symmetric credential failed: %v", err)
+	}
+	if _, err := ProcessCredential(CredOpImport, `{"credentialType":2,"deviceId":"cred-peer","userId":"cred-user","publicKey":"`+publicKey+`"}`); err != nil {
+		t.Fatalf("Import asymmetric credential failed: %v", err)
+	}
+	if info := hichain.GetDeviceAuthInfo("cred-peer"); info == nil || hex.EncodeToString(info.PublicKey) != publicKey {
+		t.Error("Expected imported public key saved as peer long-term key")
+	}
+
+	result, err := ProcessCredential(CredOpQuery, `{"deviceId":"cred-peer"}`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var query struct {
+		Credentials []*credentialEntry `json:"credentials"`
+	}
+	json.Unmarshal([]byte(result), &query)
+	if len(query.Credentials) != 2 || strings.Contains(result, testAuthCode) {
+		t.Errorf("Unexpected query result: %s", result)
+	}
+
+	if _, err := ProcessCredential(CredOpExport, `{"deviceId":"cred-peer"}`); err == nil {
+		t.Error("Expected export error for ambiguous match")
+	}
+	exported, err := ProcessCredential(CredOpExport, `{"credentialType":1,"deviceId":"cred-peer"}`)
+	if err != nil || !strings.Contains(exported, testAuthCode) {
+		t.Errorf("Expected exported authCode, got %s (err=%v)", exported, err)
+	}
+
+	if _, err := ProcessCredential(CredOpDelete, `{"credentialType":2,"deviceId":"cred-peer"}`); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if hichain.GetDeviceAuthInfo("cred-peer") != nil {
+		t.Error("Expected peer long-term key removed with credential")
+	}
+	if peerAuthCode("cred-peer") == nil {
+		t.Error("Expected symmetric credential kept")
+	}
+}
+
+// 测试CredOpCreate返回本地长期公钥，可直接导入到对端
+func TestCredentialManager_CreateLocal(t *testing.T) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	defer DestroyDeviceAuthService()
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+
+	if _, err := ProcessCredential(CredOpCreate, `{}`); err == nil {
+		t.Error("Expected error without local udid")
+	}
+	SetLocalDeviceUdid("cred-local")
+	result, err := ProcessCredential(CredOpCreate, `{"userId":"cred-user"}`)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	var entry credentialEntry
+	json.Unmarshal([]byte(result), &entry)
+	_, publicKey := hichain.GetLocalPrivateKey("cred-local")
+	if entry.CredentialType != AsymmetricCred || entry.DeviceID != "cred-local" || entry.UserID != "cred-user" ||
+		entry.PublicKey != hex.EncodeToString(publicKey) {
+		t.Errorf("Unexpected local credential: %s", result)
+	}
+}
+
+// 测试凭据加密持久化，重启后可恢复
+func TestCredentialStore_Persistence(t *testing.T) {
+	resetCredentials()
+	defer resetCredentials()
+	dir := t.TempDir()
+
+	if err := EnableCredentialStore(dir); err != nil {
+		t.Fatalf("EnableCredentialStore failed: %v", err)
+	}
+	if _, err := ProcessCredential(CredOpImport, `{"credentialType":1,"userId":"store-user","authCode":"`+testAuthCode+`"}`); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, credentialStoreFileName))
+	if err != nil {
+		t.Fatalf("Credential file not written: %v", err)
+	}
+	if bytes.Contains(data, []byte(testAuthCode)) || bytes.Contains(data, []byte("store-user")) {
+		t.Error("Expected credential file to be encrypted")
+	}
+
+	resetCredentials()
+	if accountAuthCode("store-user") != nil {
+		t.Fatal("Expected credentials cleared")
+	}
+	if err := EnableCredentialStore(dir); err != nil {
+		t.Fatalf("EnableCredentialStore reload failed: %v", err)
+	}
+	if hex.EncodeToString(accountAuthCode("store-user")) != testAuthCode {
+		t.Error("Expected credential restored after restart")
+	}
+}
+
+// 测试导入对端对称凭据后，双方无需PIN码和业务确认即可完成认证
+func TestAuthDevice_PeerCredential(t *testing.T) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	defer DestroyDeviceAuthService()
+	resetCredentials()
+	defer resetCredentials()
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+	defer ResetPairingLockout("", "")
+
+	for _, udid := range []string{"token-udid-a", "token-udid-b"} {
+		if _, err := ProcessCredential(CredOpImport, `{"credentialType":1,"deviceId":"`+udid+`","authCode":"`+testAuthCode+`"}`); err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+	}
+
+	pair := newGaTestPair(t, 5101, 5201, "token-udid-a", "token-udid-b")
+	if err := pair.authDevice(`{"peerUdid":"token-udid-b"}`); err != nil {
+		t.Fatalf("AuthDevice failed: %v", err)
+	}
+	if !pair.clientFinished || !pair.serverFinished {
+		t.Fatalf("Expected both sides finished, client=%v server=%v", pair.clientFinished, pair.serverFinished)
+	}
+	if pair.serverRequests != 0 {
+		t.Error("Expected no OnRequest confirmation with peer credential")
+	}
+}
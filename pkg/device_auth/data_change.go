@@ -0,0 +1,227 @@
+package device_auth
+
+import (
+	"encoding/json"
+	"sort"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 数据变更通知
+// ============================================================================
+//
+// 每次可信组变更（创建/删除组、添加/删除成员、批量操作、绑定/解绑）在持有写锁期间记录事件，
+// 释放锁后再回调DataChangeListener，监听者可以在回调中查询DeviceGroupManager。
+// 只有通过CheckAccessToGroup的应用会收到该组的事件:
+//   - OnGroupCreated / OnGroupDeleted: 组创建/删除（删除组时先逐个上报成员的OnDeviceUnBound）
+//   - OnDeviceBound / OnDeviceUnBound: 组添加/删除可信设备
+//   - OnLastGroupDeleted: 设备已不在某种类型的任何组中
+//   - OnDeviceNotTrusted: 设备已不在任何组中
+//   - OnTrustedDeviceNumChanged: 可信设备（去重后的UDID）数量变化
+// 回调中的设备标识只有AUTH_APPID收到真实UDID，其他应用收到该应用的假名ID（见pseudonym.go）。
+
+// dataChangeEvents 一次变更产生的通知
+type dataChangeEvents struct {
+	d       *deviceGroupManager
+	before  map[string]map[int32]int // 变更前: udid -> groupType -> 所在组数量
+	touched map[string]*GroupInfo    // 本次变更涉及的组
+	removed map[string][]*GroupInfo  // udid -> 被移出的组
+	calls   []func()
+}
+
+// beginDataChangeLocked 记录变更前的可信关系（调用方持有写锁）
+func (d *deviceGroupManager) beginDataChangeLocked() *dataChangeEvents {
+	return &dataChangeEvents{
+		d:       d,
+		before:  d.trustSnapshotLocked(),
+		touched: make(map[string]*GroupInfo),
+		removed: make(map[string][]*GroupInfo),
+	}
+}
+
+// trustSnapshotLocked 统计每个设备在各类型组中的数量（调用方持有锁）
+func (d *deviceGroupManager) trustSnapshotLocked() map[string]map[int32]int {
+	snapshot := make(map[string]map[int32]int)
+	for _, group := range d.groups {
+		for _, member := range group.Members {
+			if snapshot[member.UDID] == nil {
+				snapshot[member.UDID] = make(map[int32]int)
+			}
+			snapshot[member.UDID][group.GroupType]++
+		}
+	}
+	return snapshot
+}
+
+// groupInfoString 回调中使用的组信息
+func groupInfoString(group *GroupInfo) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"groupId":         group.GroupID,
+		"groupName":       group.GroupName,
+		"groupType":       group.GroupType,
+		"groupVisibility": group.Visibility,
+	})
+	return string(data)
+}
+
+// dataChangeTarget 接收通知的应用及其监听者
+type dataChangeTarget struct {
+	appId    string
+	listener *DataChangeListener
+}
+
+// notifyDevice 回调时将UDID转换为应用可见的设备标识，转换失败时不回调，避免向应用泄露真实UDID
+func notifyDevice(appId string, udid string, fn func(deviceId string)) func() {
+	return func() {
+		deviceId, err := appVisibleUdid(appId, udid)
+		if err != nil {
+			log.Errorf("[DEVICE_AUTH] Drop data change notification: appId=%s, err=%v", appId, err)
+			return
+		}
+		fn(deviceId)
+	}
+}
+
+// listenersFor 可以访问任一指定组的应用的监听者（按appId排序，保证通知顺序稳定）
+func (e *dataChangeEvents) listenersFor(groups ...*GroupInfo) []dataChangeTarget {
+	appIds := make([]string, 0, len(e.d.listeners))
+	for appId := range e.d.listeners {
+		appIds = append(appIds, appId)
+	}
+	sort.Strings(appIds)
+
+	var result []dataChangeTarget
+	for _, appId := range appIds {
+		if e.d.listeners[appId] == nil {
+			continue
+		}
+		for _, group := range groups {
+			if e.d.checkAccessLocked(appId, group) == nil {
+				result = append(result, dataChangeTarget{appId: appId, listener: e.d.listeners[appId]})
+				break
+			}
+		}
+	}
+	return result
+}
+
+// groupCreated 记录组创建
+func (e *dataChangeEvents) groupCreated(group *GroupInfo) {
+	e.touched[group.GroupID] = group
+	groupInfo := groupInfoString(group)
+	for _, target := range e.listenersFor(group) {
+		if fn := target.listener.OnGroupCreated; fn != nil {
+			e.calls = append(e.calls, func() { fn(groupInfo) })
+		}
+	}
+}
+
+// groupDeleted 记录组删除，组内成员同时视为解绑
+func (e *dataChangeEvents) groupDeleted(group *GroupInfo) {
+	for _, member := range group.Members {
+		e.deviceUnbound(group, member)
+	}
+	e.touched[group.GroupID] = group
+	groupInfo := groupInfoString(group)
+	for _, target := range e.listenersFor(group) {
+		if fn := target.listener.OnGroupDeleted; fn != nil {
+			e.calls = append(e.calls, func() { fn(groupInfo) })
+		}
+	}
+}
+
+// deviceBound 记录组添加可信设备
+func (e *dataChangeEvents) deviceBound(group *GroupInfo, member *DeviceMemberInfo) {
+	e.touched[group.GroupID] = group
+	groupInfo := groupInfoString(group)
+	udid := member.UDID
+	for _, target := range e.listenersFor(group) {
+		if fn := target.listener.OnDeviceBound; fn != nil {
+			e.calls = append(e.calls, notifyDevice(target.appId, udid, func(deviceId string) { fn(deviceId, groupInfo) }))
+		}
+	}
+}
+
+// deviceUnbound 记录组删除可信设备
+func (e *dataChangeEvents) deviceUnbound(group *GroupInfo, member *DeviceMemberInfo) {
+	e.touched[group.GroupID] = group
+	e.removed[member.UDID] = append(e.removed[member.UDID], group)
+	groupInfo := groupInfoString(group)
+	udid := member.UDID
+	for _, target := range e.listenersFor(group) {
+		if fn := target.listener.OnDeviceUnBound; fn != nil {
+			e.calls = append(e.calls, notifyDevice(target.appId, udid, func(deviceId string) { fn(deviceId, groupInfo) }))
+		}
+	}
+}
+
+// commitLocked 比较变更前后的可信关系，生成设备级通知（调用方持有写锁，变更已生效）
+func (e *dataChangeEvents) commitLocked() {
+	if len(e.touched) == 0 {
+		return
+	}
+	after := e.d.trustSnapshotLocked()
+
+	udids := make([]string, 0, len(e.removed))
+	for udid := range e.removed {
+		udids = append(udids, udid)
+	}
+	sort.Strings(udids)
+
+	for _, udid := range udids {
+		groups := e.removed[udid]
+
+		// 按组类型上报OnLastGroupDeleted
+		notified := make(map[int32]bool)
+		for _, group := range groups {
+			groupType := group.GroupType
+			if notified[groupType] || e.before[udid][groupType] == 0 || after[udid][groupType] > 0 {
+				continue
+			}
+			notified[groupType] = true
+			var sameType []*GroupInfo
+			for _, g := range groups {
+				if g.GroupType == groupType {
+					sameType = append(sameType, g)
+				}
+			}
+			for _, target := range e.listenersFor(sameType...) {
+				if fn := target.listener.OnLastGroupDeleted; fn != nil {
+					e.calls = append(e.calls, notifyDevice(target.appId, udid, func(deviceId string) { fn(deviceId, groupType) }))
+				}
+			}
+		}
+
+		if len(e.before[udid]) > 0 && len(after[udid]) == 0 {
+			for _, target := range e.listenersFor(groups...) {
+				if fn := target.listener.OnDeviceNotTrusted; fn != nil {
+					e.calls = append(e.calls, notifyDevice(target.appId, udid, fn))
+				}
+			}
+		}
+	}
+
+	if len(e.before) != len(after) {
+		groups := make([]*GroupInfo, 0, len(e.touched))
+		for _, group := range e.touched {
+			groups = append(groups, group)
+		}
+		num := int32(len(after))
+		for _, target := range e.listenersFor(groups...) {
+			if fn := target.listener.OnTrustedDeviceNumChanged; fn != nil {
+				e.calls = append(e.calls, func() { fn(num) })
+			}
+		}
+	}
+}
+
+// unlockAndNotify 生成设备级通知，释放写锁后回调监听者
+// 用法: d.mu.Lock(); events := d.beginDataChangeLocked(); defer d.unlockAndNotify(events)
+func (d *deviceGroupManager) unlockAndNotify(events *dataChangeEvents) {
+	events.commitLocked()
+	d.mu.Unlock()
+	for _, call := range events.calls {
+		call()
+	}
+}
@@ -0,0 +1,98 @@
+package device_auth
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// newRecordingListener 创建记录所有回调的监听者
+func newRecordingListener(events *[]string) *DataChangeListener {
+	return &DataChangeListener{
+		OnGroupCreated: func(groupInfo string) {
+			*events = append(*events, "created")
+		},
+		OnGroupDeleted: func(groupInfo string) {
+			*events = append(*events, "deleted")
+		},
+		OnDeviceBound: func(peerUdid string, groupInfo string) {
+			*events = append(*events, "bound:"+peerUdid)
+		},
+		OnDeviceUnBound: func(peerUdid string, groupInfo string) {
+			*events = append(*events, "unbound:"+peerUdid)
+		},
+		OnDeviceNotTrusted: func(peerUdid string) {
+			*events = append(*events, "notTrusted:"+peerUdid)
+		},
+		OnLastGroupDeleted: func(peerUdid string, groupType int32) {
+			*events = append(*events, fmt.Sprintf("lastGroup:%s:%d", peerUdid, groupType))
+		},
+		OnTrustedDeviceNumChanged: func(curTrustedDeviceNum int32) {
+			*events = append(*events, fmt.Sprintf("num:%d", curTrustedDeviceNum))
+		},
+	}
+}
+
+// 测试所有可以访问该组（公开组）的应用都能收到组和可信关系变更通知，
+// AUTH_APPID收到真实UDID，其他应用收到各自的假名ID
+func TestDataChangeNotifications(t *testing.T) {
+	gm := newDeviceGroupManager()
+	defer importTestAccount(t, "notify-user")()
+	resetPseudonyms()
+	defer resetPseudonyms()
+
+	var events, otherEvents, authEvents []string
+	gm.RegDataChangeListener("app_a", newRecordingListener(&events))
+	gm.RegDataChangeListener("app_b", newRecordingListener(&otherEvents))
+	gm.RegDataChangeListener(AUTH_APPID, newRecordingListener(&authEvents))
+
+	gm.CreateGroup(AnyOsAccount, 1, "app_a", `{"groupId":"P2P_001","groupType":256,"groupVisibility":-1}`)
+	gm.CreateGroup(AnyOsAccount, 2, "app_a", `{"groupId":"ACCOUNT_001","groupType":1,"groupVisibility":-1,"userId":"notify-user"}`)
+	gm.AddMemberToGroup(AnyOsAccount, 3, "app_a", `{"groupId":"P2P_001","deviceId":"dev-1","udid":"udid-1"}`)
+	gm.AddMultiMembersToGroup(AnyOsAccount, "app_a", `{"groupId":"ACCOUNT_001","deviceList":[{"deviceId":"dev-1","udid":"udid-1"}]}`)
+	gm.DeleteMemberFromGroup(AnyOsAccount, 4, "app_a", `{"groupId":"P2P_001","deviceId":"dev-1"}`)
+	gm.DeleteGroup(AnyOsAccount, 5, "app_a", `{"groupId":"ACCOUNT_001"}`)
+
+	expected := func(deviceId string) []string {
+		return []string{
+			"created", "created",
+			"bound:" + deviceId, "num:1",
+			"bound:" + deviceId,
+			"unbound:" + deviceId, "lastGroup:" + deviceId + ":256",
+			"unbound:" + deviceId, "deleted", "lastGroup:" + deviceId + ":1", "notTrusted:" + deviceId, "num:0",
+		}
+	}
+	pseudoA, _ := appVisibleUdid("app_a", "udid-1")
+	pseudoB, _ := appVisibleUdid("app_b", "udid-1")
+	if pseudoA == "udid-1" || pseudoA == pseudoB {
+		t.Fatalf("Expected distinct pseudonyms per app, got %s %s", pseudoA, pseudoB)
+	}
+	if !reflect.DeepEqual(events, expected(pseudoA)) {
+		t.Errorf("Unexpected events:\n got  %v\n want %v", events, expected(pseudoA))
+	}
+	if !reflect.DeepEqual(otherEvents, expected(pseudoB)) {
+		t.Errorf("Expected other app to receive the same events with its pseudonym, got %v", otherEvents)
+	}
+	if !reflect.DeepEqual(authEvents, expected("udid-1")) {
+		t.Errorf("Expected %s to receive real UDIDs, got %v", AUTH_APPID, authEvents)
+	}
+}
+
+// 测试监听者可以在回调中查询DeviceGroupManager（回调时不持有锁）
+func TestDataChangeListenerReentrant(t *testing.T) {
+	gm := newDeviceGroupManager()
+
+	members := -1
+	gm.RegDataChangeListener("app_a", &DataChangeListener{
+		OnDeviceBound: func(peerUdid string, groupInfo string) {
+			devices, _ := gm.GetTrustedDevices(AnyOsAccount, "app_a", "P2P_002")
+			members = len(devices)
+		},
+	})
+
+	gm.CreateGroup(AnyOsAccount, 1, "app_a", `{"groupId":"P2P_002","groupType":256}`)
+	gm.AddMemberToGroup(AnyOsAccount, 2, "app_a", `{"groupId":"P2P_002","deviceId":"dev-2"}`)
+	if members != 1 {
+		t.Errorf("Expected listener to see 1 member, got %d", members)
+	}
+}
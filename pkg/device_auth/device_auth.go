@@ -1,10 +1,13 @@
 package device_auth
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/junbin-yang/dsoftbus-go/pkg/context"
 	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
@@ -21,22 +24,49 @@ type realGroupAuthManager struct {
 	callbacks        map[int64]*DeviceAuthCallback    // authReqId -> 回调
 	authMessages     map[int64]map[string]interface{} // authReqId -> 原始认证消息（用于提取authIdC等）
 	dmRequestIdMap   map[int64]int64                  // authReqId -> dmRequestId（用于查找AuthSessionContext）
+	pins             map[int64]string                 // authReqId -> 本次认证使用的PIN码
+	pinFailures      map[int64]int                    // authReqId -> 当前PIN码的PAKE失败次数
+	pinPrompts       map[int64]*pinPrompt             // authReqId -> 发起方等待用户输入的PIN码
+	credentials      map[int64]*credentialAuth        // authReqId -> 代替PIN码的凭据（对端凭据或账户凭据）
+	timers           map[int64]*time.Timer            // authReqId -> 请求超时定时器
 	mu               sync.RWMutex
 }
 
+// newRealGroupAuthManager 创建基于HiChain的GroupAuthManager
+func newRealGroupAuthManager() *realGroupAuthManager {
+	return &realGroupAuthManager{
+		hichainInstances: make(map[int64]*hichain.HiChainHandle),
+		callbacks:        make(map[int64]*DeviceAuthCallback),
+		authMessages:     make(map[int64]map[string]interface{}),
+		dmRequestIdMap:   make(map[int64]int64),
+		pins:             make(map[int64]string),
+		pinFailures:      make(map[int64]int),
+		pinPrompts:       make(map[int64]*pinPrompt),
+		credentials:      make(map[int64]*credentialAuth),
+		timers:           make(map[int64]*time.Timer),
+	}
+}
+
+// AuthRequestTimeout 认证请求超时时间，超时未完成时通知对端并以HC_ERR_TIMEOUT结束请求
+var AuthRequestTimeout = 60 * time.Second
+
 // ProcessData 处理认证数据（对应C的g_hichain->processData）
 func (g *realGroupAuthManager) ProcessData(authReqId int64, data []byte, gaCallback *DeviceAuthCallback) error {
 	// 解析消息，提取requestId（用于查找AuthSessionContext）
 	var authMsg map[string]interface{}
 	isPakeRequest := false
+	isErrorMsg := false
 	var dmRequestId int64 = authReqId // 默认使用authReqId
 
 	// 清理JSON数据（去除null字节等控制字符）
 	jsonData := hichain.CleanJSONData(data)
 
 	if err := json.Unmarshal(jsonData, &authMsg); err == nil {
-		if msgType, ok := authMsg["message"].(float64); ok && int(msgType) == 1 {
+		// PAKE_REQUEST和ISO_START都是新一轮认证的第一条消息
+		if msgType, ok := authMsg["message"].(float64); ok && (int(msgType) == 1 || int(msgType) == hichain.MsgTypeIsoStart) {
 			isPakeRequest = true
+		} else if ok && int(msgType) == hichain.MsgTypeError {
+			isErrorMsg = true
 		}
 		// 从HiChain消息中提取DM的requestId
 		if reqIdVal, ok := authMsg["requestId"]; ok {
@@ -68,10 +98,20 @@ func (g *realGroupAuthManager) ProcessData(authReqId int64, data []byte, gaCallb
 		delete(g.hichainInstances, authReqId)
 		delete(g.callbacks, authReqId)
 		delete(g.dmRequestIdMap, authReqId)
+		delete(g.pins, authReqId)
+		delete(g.pinPrompts, authReqId)
+		delete(g.credentials, authReqId)
+		g.stopRequestTimerLocked(authReqId)
 		g.mu.Unlock()
 		handle = nil
 	}
 
+	// 请求已结束（如本端先失败或已取消）后到达的错误消息不再创建实例
+	if handle == nil && isErrorMsg {
+		log.Warnf("[DEVICE_AUTH] Ignoring error message for finished request: authReqId=%d", authReqId)
+		return fmt.Errorf("no auth request in progress: %d", authReqId)
+	}
+
 	if handle == nil {
 		// 服务端首次收到数据，需要创建实例
 		log.Infof("[DEVICE_AUTH] Creating HiChain instance for server side: authReqId=%d, dmRequestId=%d", authReqId, dmRequestId)
@@ -103,6 +143,7 @@ func (g *realGroupAuthManager) ProcessData(authReqId int64, data []byte, gaCallb
 		g.mu.Lock()
 		g.hichainInstances[authReqId] = handle
 		g.callbacks[authReqId] = gaCallback
+		g.startRequestTimerLocked(authReqId, handle)
 		g.mu.Unlock()
 	}
 
@@ -118,9 +159,34 @@ func (g *realGroupAuthManager) ProcessData(authReqId int64, data []byte, gaCallb
 		g.mu.Unlock()
 	}
 
-	// 调用HiChain处理数据
+	// 发起方等待用户输入PIN码期间，后续认证消息在输入完成后再处理（错误消息立即处理）
+	if prompt := g.pendingPinPrompt(authReqId); prompt != nil && !isErrorMsg {
+		log.Infof("[DEVICE_AUTH] Waiting for pin input before processing data: authReqId=%d", authReqId)
+		pending := append([]byte(nil), data...)
+		go func() {
+			<-prompt.done
+			g.mu.RLock()
+			current := g.hichainInstances[authReqId]
+			g.mu.RUnlock()
+			if current != handle {
+				log.Warnf("[DEVICE_AUTH] Auth request finished while waiting for pin input: authReqId=%d", authReqId)
+				return
+			}
+			g.receiveData(authReqId, handle, pending)
+		}()
+		return nil
+	}
+
+	return g.receiveData(authReqId, handle, data)
+}
+
+// receiveData 调用HiChain处理数据
+func (g *realGroupAuthManager) receiveData(authReqId int64, handle *hichain.HiChainHandle, data []byte) error {
 	if err := handle.ReceiveData(data); err != nil {
 		log.Errorf("[DEVICE_AUTH] HiChain ReceiveData failed: %v", err)
+		if errors.Is(err, hichain.ErrProofMismatch) {
+			g.onPakeFailure(authReqId, handle)
+		}
 		return err
 	}
 
@@ -148,6 +214,13 @@ func (g *realGroupAuthManager) AuthDevice(osAccountId int32, authReqId int64, au
 	// 创建回调转换
 	hcCallback := g.createHCCallBack(authReqId, gaCallback)
 
+	// authParams中预先给出的对端PIN码，未给出时在协议需要时由用户输入
+	if pinCode, _ := params["pinCode"].(string); pinCode != "" {
+		g.mu.Lock()
+		g.pins[authReqId] = pinCode
+		g.mu.Unlock()
+	}
+
 	// 创建HiChain实例
 	handle, err := hichain.GetInstance(identity, hichain.HCController, hcCallback)
 	if err != nil {
@@ -158,16 +231,39 @@ func (g *realGroupAuthManager) AuthDevice(osAccountId int32, authReqId int64, au
 	g.mu.Lock()
 	g.hichainInstances[authReqId] = handle
 	g.callbacks[authReqId] = gaCallback
+	g.startRequestTimerLocked(authReqId, handle)
 	g.mu.Unlock()
 
-	log.Infof("[DEVICE_AUTH] Starting HiChain auth: authReqId=%d", authReqId)
-
-	// 启动认证（异步，通过OnTransmit回调发送数据）
-	if err := handle.StartAuth(); err != nil {
+	// 启动认证（异步，通过OnTransmit回调发送数据），按以下顺序选择认证方式:
+	//   1. 与对端已在同一可信组且保存了对端长期公钥，或导入了对端非对称凭据: 基于长期密钥的认证
+	//   2. 导入了对端对称凭据: 以对端凭据代替PIN码
+	//   3. 对端属于本地账户组（或authParams的peerUserId对应本地账户组）且已导入账户凭据: 以账户凭据代替PIN码
+	//   4. 其他: PIN码
+	peerUdid, _ := params["peerUdid"].(string)
+	peerUserId, _ := params["peerUserId"].(string)
+	if g.canUseKeyAuth(peerUdid) {
+		log.Infof("[DEVICE_AUTH] Starting key-based auth: authReqId=%d, peer=%s", authReqId, peerUdid)
+		err = handle.StartKeyAuth(peerUdid)
+	} else if credential := selectCredentialAuth(peerUdid, peerUserId); credential != nil {
+		log.Infof("[DEVICE_AUTH] Starting credential auth: authReqId=%d, peer=%s, authForm=%d, groupId=%s",
+			authReqId, peerUdid, credential.authForm, credential.groupId)
+		g.mu.Lock()
+		g.credentials[authReqId] = credential
+		g.mu.Unlock()
+		err = handle.StartAuth()
+	} else {
+		log.Infof("[DEVICE_AUTH] Starting HiChain auth: authReqId=%d", authReqId)
+		err = handle.StartAuth()
+	}
+	if err != nil {
 		// 启动失败，清理实例
 		g.mu.Lock()
 		delete(g.hichainInstances, authReqId)
 		delete(g.callbacks, authReqId)
+		delete(g.pins, authReqId)
+		delete(g.pinPrompts, authReqId)
+		delete(g.credentials, authReqId)
+		g.stopRequestTimerLocked(authReqId)
 		g.mu.Unlock()
 
 		return fmt.Errorf("failed to start auth: %w", err)
@@ -176,31 +272,354 @@ func (g *realGroupAuthManager) AuthDevice(osAccountId int32, authReqId int64, au
 	return nil
 }
 
+// canUseKeyAuth 判断与对端是否已绑定（同属可信组且保存了对端长期公钥），或已导入对端非对称凭据
+func (g *realGroupAuthManager) canUseKeyAuth(peerUdid string) bool {
+	if peerUdid == "" {
+		return false
+	}
+	if peerPublicKeyCredential(peerUdid) != nil {
+		return true
+	}
+	gm, err := GetGmInstance()
+	if err != nil {
+		return false
+	}
+	if groups, err := gm.GetRelatedGroups(AnyOsAccount, AUTH_APPID, peerUdid); err != nil || len(groups) == 0 {
+		return false
+	}
+	info := hichain.GetDeviceAuthInfo(peerUdid)
+	return info != nil && len(info.PublicKey) > 0
+}
+
+// getDeviceGroupManager 获取DeviceGroupManager实现（设备认证服务未初始化时返回nil）
+func getDeviceGroupManager() *deviceGroupManager {
+	gm, err := GetGmInstance()
+	if err != nil {
+		return nil
+	}
+	d, _ := gm.(*deviceGroupManager)
+	return d
+}
+
+// selectCredentialAuth 选择代替PIN码的凭据: 优先对端对称凭据，其次账户凭据
+func selectCredentialAuth(peerUdid string, peerUserId string) *credentialAuth {
+	if authCode := peerAuthCode(peerUdid); authCode != nil {
+		return &credentialAuth{authForm: AuthFormAccountUnrelated, authCode: authCode}
+	}
+	return selectAccountAuth(peerUdid, peerUserId, AuthFormInvalidType)
+}
+
+// selectAccountAuth 选择与对端认证使用的账户组和凭据（设备认证服务未初始化时返回nil）
+func selectAccountAuth(peerUdid string, peerUserId string, authForm GroupAuthForm) *credentialAuth {
+	if d := getDeviceGroupManager(); d != nil {
+		return d.selectAccountAuth(peerUdid, peerUserId, authForm)
+	}
+	return nil
+}
+
+// addAccountMember 账户认证成功后将对端加入账户组
+// 基于长期密钥认证时，对端如果由带userId的非对称凭据预置，同样加入对应的账户组
+func addAccountMember(credential *credentialAuth, peerUdid string, keyAuth bool) {
+	d := getDeviceGroupManager()
+	if d == nil || peerUdid == "" {
+		return
+	}
+	if credential == nil && keyAuth {
+		credential = d.keyAuthAccount(peerUdid)
+	}
+	if credential == nil {
+		return
+	}
+	if err := d.addAccountMember(credential, peerUdid); err != nil {
+		log.Errorf("[DEVICE_AUTH] Failed to add account member: groupId=%s, peer=%s, err=%v", credential.groupId, peerUdid, err)
+	}
+}
+
 // CancelRequest 取消认证过程（对应C的g_hichain->cancelRequest）
+// 以MsgTypeError通知对端后销毁HiChain实例，删除AuthSessionContext，并通过OnError上报HC_ERR_REQUEST_CANCELLED
 func (g *realGroupAuthManager) CancelRequest(requestId int64, appId string) {
 	log.Infof("[DEVICE_AUTH] CancelRequest: requestId=%d, appId=%s", requestId, appId)
 
+	ctx := g.sessionContext(requestId)
+
 	g.mu.Lock()
-	defer g.mu.Unlock()
+	handle := g.hichainInstances[requestId]
+	gaCallback := g.callbacks[requestId]
+	delete(g.hichainInstances, requestId)
+	delete(g.callbacks, requestId)
+	delete(g.authMessages, requestId)
+	delete(g.dmRequestIdMap, requestId)
+	delete(g.pins, requestId)
+	delete(g.pinPrompts, requestId)
+	delete(g.pinFailures, requestId)
+	delete(g.credentials, requestId)
+	g.stopRequestTimerLocked(requestId)
+	g.mu.Unlock()
 
-	// 销毁HiChain实例
-	if handle, exists := g.hichainInstances[requestId]; exists {
-		hichain.Destroy(&handle)
-		delete(g.hichainInstances, requestId)
-		delete(g.callbacks, requestId)
+	if ctx != nil {
+		context.DeleteAuthSessionContextByRequestId(ctx.RequestID)
+	}
+	if handle == nil {
+		log.Warnf("[DEVICE_AUTH] No auth request in progress: requestId=%d", requestId)
+		return
+	}
+
+	if err := handle.Cancel(); err != nil {
+		log.Warnf("[DEVICE_AUTH] Failed to notify peer of cancellation: requestId=%d, err=%v", requestId, err)
+	}
+	hichain.Destroy(&handle)
+
+	if gaCallback != nil && gaCallback.OnError != nil {
+		gaCallback.OnError(requestId, hichain.OpCodeAuthenticate, HC_ERR_REQUEST_CANCELLED, ErrRequestCancelled.Error())
 	}
 }
 
-// GetRealInfo 通过假名ID获取真实信息
+// GetRealInfo 通过假名ID获取真实信息（见pseudonym.go），调用方身份为AUTH_APPID
+// pseudonymId: {"pseudonymId":"..."}，返回 {"appId":"...","peerUdid":"..."}
 func (g *realGroupAuthManager) GetRealInfo(osAccountId int32, pseudonymId string) (string, error) {
-	log.Infof("[DEVICE_AUTH] GetRealInfo: osAccountId=%d, pseudonymId=%s", osAccountId, pseudonymId)
-	return "", fmt.Errorf("not implemented")
+	log.Infof("[DEVICE_AUTH] GetRealInfo: osAccountId=%d", osAccountId)
+	return getRealInfo(AUTH_APPID, pseudonymId)
 }
 
-// GetPseudonymId 通过索引获取假名ID
+// GetPseudonymId 通过索引获取假名ID（见pseudonym.go），调用方身份为AUTH_APPID
+// indexKey: {"appId":"...","peerUdid":"..."}
 func (g *realGroupAuthManager) GetPseudonymId(osAccountId int32, indexKey string) (string, error) {
-	log.Infof("[DEVICE_AUTH] GetPseudonymId: osAccountId=%d, indexKey=%s", osAccountId, indexKey)
-	return "", fmt.Errorf("not implemented")
+	log.Infof("[DEVICE_AUTH] GetPseudonymId: osAccountId=%d", osAccountId)
+	return getPseudonymId(AUTH_APPID, indexKey)
+}
+
+// appGroupAuthManager 应用使用的GroupAuthManager
+// 调用方身份在GetGaInstanceForApp时确定，假名接口按该身份授权，不使用请求参数中的appId
+type appGroupAuthManager struct {
+	*realGroupAuthManager
+	appId string
+}
+
+// GetRealInfo 通过假名ID获取真实信息，只有SetPseudonymResolvers授权的内部调用方可以反查
+func (a *appGroupAuthManager) GetRealInfo(osAccountId int32, pseudonymId string) (string, error) {
+	log.Infof("[DEVICE_AUTH] GetRealInfo: osAccountId=%d, appId=%s", osAccountId, a.appId)
+	return getRealInfo(a.appId, pseudonymId)
+}
+
+// GetPseudonymId 获取本应用看到的对端假名ID
+func (a *appGroupAuthManager) GetPseudonymId(osAccountId int32, indexKey string) (string, error) {
+	log.Infof("[DEVICE_AUTH] GetPseudonymId: osAccountId=%d, appId=%s", osAccountId, a.appId)
+	return getPseudonymId(a.appId, indexKey)
+}
+
+// resolvePin 获取本次认证的PIN码
+// 发起方由用户输入对端展示的PIN码，被配对方首次调用时生成随机PIN码并展示
+func (g *realGroupAuthManager) resolvePin(authReqId int64, operationCode int32) (string, error) {
+	g.mu.RLock()
+	pinCode, exists := g.pins[authReqId]
+	handle := g.hichainInstances[authReqId]
+	g.mu.RUnlock()
+	if exists {
+		return pinCode, nil
+	}
+
+	req := &PairingRequest{RequestId: authReqId, OperationCode: operationCode}
+	if handle != nil {
+		req.PeerDeviceId = handle.GetPeerAuthID()
+	}
+
+	if handle != nil && handle.GetDeviceType() == hichain.HCController {
+		// 发起方的PIN码输入可能需要等待用户操作，不阻塞HiChain消息处理:
+		// 后台等待输入，PIN码就绪前到达的认证消息由ProcessData暂存，此时返回空值，由协议层判断
+		g.startPinPrompt(authReqId, req)
+		return "", nil
+	}
+
+	pinCode, err := GeneratePairingPin(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate pin code: %w", err)
+	}
+
+	g.mu.Lock()
+	g.pins[authReqId] = pinCode
+	g.mu.Unlock()
+	return pinCode, nil
+}
+
+// pinPrompt 发起方一次PIN码输入
+type pinPrompt struct {
+	done chan struct{} // 输入完成（成功或失败）后关闭
+}
+
+// startPinPrompt 在后台获取用户输入的PIN码，同一请求只发起一次
+// 输入成功且请求仍在进行时保存为本次PIN码
+func (g *realGroupAuthManager) startPinPrompt(authReqId int64, req *PairingRequest) {
+	g.mu.Lock()
+	if _, exists := g.pinPrompts[authReqId]; exists {
+		g.mu.Unlock()
+		return
+	}
+	if g.pinPrompts == nil {
+		g.pinPrompts = make(map[int64]*pinPrompt)
+	}
+	prompt := &pinPrompt{done: make(chan struct{})}
+	g.pinPrompts[authReqId] = prompt
+	g.mu.Unlock()
+
+	go func() {
+		defer close(prompt.done)
+
+		pinCode, err := InputPairingPin(req)
+		if err != nil {
+			log.Warnf("[DEVICE_AUTH] Pin code not available: authReqId=%d, err=%v", authReqId, err)
+			return
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		// 等待输入期间请求已结束（取消、超时）或已重新开始
+		if g.pinPrompts[authReqId] != prompt {
+			return
+		}
+		g.pins[authReqId] = pinCode
+	}()
+}
+
+// pendingPinPrompt 返回请求尚未完成的PIN码输入，没有时返回nil
+func (g *realGroupAuthManager) pendingPinPrompt(authReqId int64) *pinPrompt {
+	g.mu.RLock()
+	prompt := g.pinPrompts[authReqId]
+	g.mu.RUnlock()
+	if prompt == nil {
+		return nil
+	}
+	select {
+	case <-prompt.done:
+		return nil
+	default:
+		return prompt
+	}
+}
+
+// sessionContext 查找认证请求对应的AuthSessionContext
+func (g *realGroupAuthManager) sessionContext(authReqId int64) *context.AuthSessionContext {
+	g.mu.RLock()
+	dmRequestId, hasDmRequestId := g.dmRequestIdMap[authReqId]
+	g.mu.RUnlock()
+
+	if !hasDmRequestId {
+		dmRequestId = authReqId // 回退到使用authReqId
+	}
+	return context.FindAuthSessionContextByRequestId(dmRequestId)
+}
+
+// peerInfo 获取认证请求的对端设备ID和来源IP
+func (g *realGroupAuthManager) peerInfo(authReqId int64, handle *hichain.HiChainHandle) (peerDeviceId string, ip string) {
+	peerDeviceId = handle.GetPeerAuthID()
+	if ctx := g.sessionContext(authReqId); ctx != nil {
+		if peerDeviceId == "" {
+			peerDeviceId = ctx.PeerDeviceID
+		}
+		ip = ctx.PeerIp
+	}
+	return peerDeviceId, ip
+}
+
+// onPakeFailure PAKE确认数据校验失败（PIN码错误）
+// 记录对端设备和IP的失败次数，当前PIN码失败次数达到上限时作废
+// 在SetServiceResult上报HCPinMismatch之后调用，此时实例已销毁但PIN码失败次数保留
+func (g *realGroupAuthManager) onPakeFailure(authReqId int64, handle *hichain.HiChainHandle) {
+	peerDeviceId, ip := g.peerInfo(authReqId, handle)
+	recordPairingFailure(peerDeviceId, ip)
+
+	g.mu.Lock()
+	g.pinFailures[authReqId]++
+	failures := g.pinFailures[authReqId]
+	if !pinAttemptsExceeded(failures) {
+		g.mu.Unlock()
+		return
+	}
+	delete(g.pins, authReqId)
+	delete(g.pinPrompts, authReqId)
+	delete(g.pinFailures, authReqId)
+	g.mu.Unlock()
+
+	if ctx := g.sessionContext(authReqId); ctx != nil {
+		ctx.PinCode = ""
+	}
+	log.Warnf("[DEVICE_AUTH] Pin code invalidated after %d failures: authReqId=%d, peer=%s, ip=%s",
+		failures, authReqId, peerDeviceId, ip)
+}
+
+// startRequestTimerLocked 启动认证请求超时定时器（调用方持有锁）
+func (g *realGroupAuthManager) startRequestTimerLocked(authReqId int64, handle *hichain.HiChainHandle) {
+	timeout := AuthRequestTimeout
+	if timeout <= 0 {
+		return
+	}
+	if g.timers == nil {
+		g.timers = make(map[int64]*time.Timer)
+	}
+	g.stopRequestTimerLocked(authReqId)
+	g.timers[authReqId] = time.AfterFunc(timeout, func() {
+		g.onRequestTimeout(authReqId, handle, timeout)
+	})
+}
+
+// stopRequestTimerLocked 停止认证请求超时定时器（调用方持有锁）
+func (g *realGroupAuthManager) stopRequestTimerLocked(authReqId int64) {
+	if timer, exists := g.timers[authReqId]; exists {
+		timer.Stop()
+		delete(g.timers, authReqId)
+	}
+}
+
+// onRequestTimeout 认证请求超时：以HCTimeout通知对端，销毁实例并通过OnError上报HC_ERR_TIMEOUT
+func (g *realGroupAuthManager) onRequestTimeout(authReqId int64, handle *hichain.HiChainHandle, timeout time.Duration) {
+	g.mu.Lock()
+	// 实例已结束或已被同一authReqId的新请求替换
+	if g.hichainInstances[authReqId] != handle {
+		g.mu.Unlock()
+		return
+	}
+	gaCallback := g.callbacks[authReqId]
+	delete(g.hichainInstances, authReqId)
+	delete(g.callbacks, authReqId)
+	delete(g.pins, authReqId)
+	delete(g.pinPrompts, authReqId)
+	delete(g.pinFailures, authReqId)
+	delete(g.credentials, authReqId)
+	delete(g.timers, authReqId)
+	g.mu.Unlock()
+
+	log.Warnf("[DEVICE_AUTH] Auth request timed out: authReqId=%d, timeout=%v", authReqId, timeout)
+	if err := handle.Abort(hichain.HCTimeout); err != nil {
+		log.Warnf("[DEVICE_AUTH] Failed to notify peer of timeout: authReqId=%d, err=%v", authReqId, err)
+	}
+	hichain.Destroy(&handle)
+
+	if gaCallback != nil && gaCallback.OnError != nil {
+		gaCallback.OnError(authReqId, hichain.OpCodeAuthenticate, HC_ERR_TIMEOUT, "auth timeout")
+	}
+}
+
+// hichainErrorCode 将HiChain错误码转换为OnError上报的HC_ERR_*错误码
+func hichainErrorCode(result int32) int32 {
+	switch result {
+	case hichain.HCPinMismatch:
+		return HC_ERR_PIN_MISMATCH
+	case hichain.HCVersionUnsupported:
+		return HC_ERR_VERSION_UNSUPPORTED
+	case hichain.HCPeerCancelled:
+		return HC_ERR_PEER_CANCELLED
+	case hichain.HCCancelled:
+		return HC_ERR_REQUEST_CANCELLED
+	case hichain.HCTimeout:
+		return HC_ERR_TIMEOUT
+	case hichain.HCLockedOut:
+		return HC_ERR_LOCKED_OUT
+	case hichain.HCRejected:
+		return HC_ERR_REQUEST_REJECTED
+	case hichain.HCInvalidParams:
+		return HC_ERR_INVALID_PARAMS
+	default:
+		return HC_ERR
+	}
 }
 
 // createHCCallBack 创建HiChain回调，转换为DeviceAuthCallback
@@ -225,20 +644,11 @@ func (g *realGroupAuthManager) createHCCallBack(authReqId int64, gaCallback *Dev
 			log.Infof("[DEVICE_AUTH] HiChain GetProtocolParams: sessionId=%d, authReqId=%d", identity.SessionID, authReqId)
 
 			// 从AuthSessionContext获取（客户端场景会预先设置）
-			pinCode := "888888" // 默认值
+			pinCode := ""
 			selfAuthID := ""
 			peerAuthID := "" // 服务端场景为空，由HiChainHandle从EXCHANGE消息解析
 
-			// 获取dmRequestId（用于查找AuthSessionContext）
-			g.mu.RLock()
-			dmRequestId, hasDmRequestId := g.dmRequestIdMap[authReqId]
-			g.mu.RUnlock()
-
-			if !hasDmRequestId {
-				dmRequestId = authReqId // 回退到使用authReqId
-			}
-
-			ctx := context.FindAuthSessionContextByRequestId(dmRequestId)
+			ctx := g.sessionContext(authReqId)
 			if ctx != nil {
 				if ctx.PinCode != "" {
 					pinCode = ctx.PinCode
@@ -256,6 +666,30 @@ func (g *realGroupAuthManager) createHCCallBack(authReqId int64, gaCallback *Dev
 				log.Warnf("[DEVICE_AUTH] ⚠️ AuthSessionContext not found for authReqId=%d", authReqId)
 			}
 
+			// 对端凭据或账户凭据代替PIN码
+			// 未预设PIN码时使用本次请求的PIN码（发起方为用户输入，被配对方首次调用时生成）
+			// 基于长期密钥的认证不需要PIN码
+			g.mu.RLock()
+			handle := g.hichainInstances[authReqId]
+			credential := g.credentials[authReqId]
+			g.mu.RUnlock()
+			if credential != nil {
+				return &hichain.ProtocolParams{
+					KeyLength:  hichain.SessionKeyLength,
+					SelfAuthID: selfAuthID,
+					PeerAuthID: peerAuthID,
+					PinCode:    hex.EncodeToString(credential.authCode),
+					AuthForm:   int(credential.authForm),
+					UserID:     credential.selfUserId,
+				}, nil
+			}
+			if pinCode == "" && !handle.UsesKeyAuth() {
+				var err error
+				if pinCode, err = g.resolvePin(authReqId, operationCode); err != nil {
+					return nil, err
+				}
+			}
+
 			return &hichain.ProtocolParams{
 				KeyLength:  hichain.SessionKeyLength,
 				SelfAuthID: selfAuthID,
@@ -281,24 +715,49 @@ func (g *realGroupAuthManager) createHCCallBack(authReqId int64, gaCallback *Dev
 				identity.SessionID, result)
 
 			if result == hichain.HCOk {
-				// 认证成功
+				// 认证成功，返回对端身份
+				returnData := "{}"
+				g.mu.RLock()
+				handle := g.hichainInstances[authReqId]
+				credential := g.credentials[authReqId]
+				g.mu.RUnlock()
+
+				// 清除该设备/IP的配对失败记录
+				recordPairingSuccess(g.peerInfo(authReqId, handle))
+
+				// 账户认证成功，对端自动成为账户组成员
+				addAccountMember(credential, handle.GetPeerAuthID(), handle.UsesKeyAuth())
+
+				if peerAuthID := handle.GetPeerAuthID(); peerAuthID != "" {
+					data, _ := json.Marshal(map[string]string{"peerUdid": peerAuthID})
+					returnData = string(data)
+				}
+
 				if gaCallback != nil && gaCallback.OnFinish != nil {
-					gaCallback.OnFinish(authReqId, int32(identity.OperationCode), "{}")
+					gaCallback.OnFinish(authReqId, int32(identity.OperationCode), returnData)
 				}
 			} else {
-				// 认证失败
+				// 认证失败，HiChain错误码转换为HC_ERR_*错误码上报
 				if gaCallback != nil && gaCallback.OnError != nil {
-					gaCallback.OnError(authReqId, int32(identity.OperationCode), result, "auth failed")
+					gaCallback.OnError(authReqId, int32(identity.OperationCode), hichainErrorCode(result), "auth failed")
 				}
 			}
 
-			// 认证完成后清理实例
+			// 认证完成后清理实例，PIN码随之失效
+			// PIN码错误时保留失败次数，对端以同一请求重试时继续累计
 			g.mu.Lock()
 			if handle, exists := g.hichainInstances[authReqId]; exists {
 				hichain.Destroy(&handle)
 				delete(g.hichainInstances, authReqId)
 				delete(g.callbacks, authReqId)
 			}
+			delete(g.pins, authReqId)
+			delete(g.pinPrompts, authReqId)
+			if result != hichain.HCPinMismatch {
+				delete(g.pinFailures, authReqId)
+			}
+			delete(g.credentials, authReqId)
+			g.stopRequestTimerLocked(authReqId)
 			g.mu.Unlock()
 
 			return nil
@@ -308,443 +767,80 @@ func (g *realGroupAuthManager) createHCCallBack(authReqId int64, gaCallback *Dev
 		ConfirmReceiveRequest: func(identity *hichain.SessionIdentity, operationCode int32) int32 {
 			log.Infof("[DEVICE_AUTH] HiChain ConfirmReceiveRequest: sessionId=%d", identity.SessionID)
 
-			// 如果有OnRequest回调，调用它
+			g.mu.RLock()
+			handle := g.hichainInstances[authReqId]
+			g.mu.RUnlock()
+			peerDeviceId, ip := g.peerInfo(authReqId, handle)
+			req := &PairingRequest{RequestId: authReqId, PeerDeviceId: peerDeviceId, OperationCode: operationCode}
+
+			// 锁定期内直接拒绝，不再生成和展示PIN码
+			if err := checkPairingAllowed(peerDeviceId, ip); err != nil {
+				log.Warnf("[DEVICE_AUTH] Request rejected: authReqId=%d, %v", authReqId, err)
+				return hichain.HCLockedOut
+			}
+
+			// 基于长期密钥的认证只接受与本端同属可信组或导入了非对称凭据的对端（与发起方的canUseKeyAuth一致）
+			// 仍需经过下方的业务确认
+			keyAuth := handle.UsesKeyAuth()
+			if keyAuth && !g.canUseKeyAuth(peerDeviceId) {
+				log.Warnf("[DEVICE_AUTH] Key-based auth rejected, peer not bound: authReqId=%d, peer=%s", authReqId, peerDeviceId)
+				return hichain.HCError
+			}
+
+			// 账户认证无需业务确认，由对端声明的用户ID选择本地账户组和凭据
+			if authForm := handle.GetPeerAuthForm(); !keyAuth && authForm != hichain.AuthFormAccountUnrelated {
+				account := selectAccountAuth("", handle.GetPeerUserID(), GroupAuthForm(authForm))
+				if account == nil || account.authForm != GroupAuthForm(authForm) {
+					log.Warnf("[DEVICE_AUTH] Account auth rejected: authReqId=%d, peer=%s, authForm=%d, userId=%s",
+						authReqId, peerDeviceId, authForm, handle.GetPeerUserID())
+					return hichain.HCError
+				}
+				g.mu.Lock()
+				g.credentials[authReqId] = account
+				g.mu.Unlock()
+				return hichain.HCOk
+			}
+
+			// 导入了对端对称凭据时无需业务确认，以凭据代替PIN码
+			if authCode := peerAuthCode(peerDeviceId); !keyAuth && authCode != nil {
+				g.mu.Lock()
+				g.credentials[authReqId] = &credentialAuth{authForm: AuthFormAccountUnrelated, authCode: authCode}
+				g.mu.Unlock()
+				return hichain.HCOk
+			}
+
+			// 业务OnRequest回调明确给出结果时以其为准，响应中的pinCode作为本次PIN码
 			if gaCallback != nil && gaCallback.OnRequest != nil {
-				response := gaCallback.OnRequest(authReqId, operationCode, "{}")
-				if response != "" {
+				reqParams, _ := json.Marshal(map[string]interface{}{
+					"peerDeviceId":  req.PeerDeviceId,
+					"operationCode": operationCode,
+				})
+				decided, accepted, pinCode := parseRequestResponse(gaCallback.OnRequest(authReqId, operationCode, string(reqParams)))
+				if pinCode != "" {
+					g.mu.Lock()
+					g.pins[authReqId] = pinCode
+					g.mu.Unlock()
+				}
+				if decided {
+					if !accepted {
+						log.Warnf("[DEVICE_AUTH] Request rejected by OnRequest: authReqId=%d", authReqId)
+						return hichain.HCError
+					}
 					return hichain.HCOk
 				}
 			}
 
-			// 默认接受请求
+			// 由配对凭据提供者确认
+			if !ConfirmPairingRequest(req) {
+				log.Warnf("[DEVICE_AUTH] Request rejected by pairing provider: authReqId=%d, peer=%s",
+					authReqId, req.PeerDeviceId)
+				return hichain.HCError
+			}
 			return hichain.HCOk
 		},
 	}
 }
 
-// ============================================================================
-// Stub实现 - DeviceGroupManager（保持不变）
-// ============================================================================
-
-// stubDeviceGroupManager 是DeviceGroupManager的stub实现
-type stubDeviceGroupManager struct {
-	callbacks map[string]*DeviceAuthCallback
-	listeners map[string]*DataChangeListener
-	groups    map[string]*GroupInfo // groupId -> GroupInfo
-	mu        sync.RWMutex
-}
-
-// GroupInfo 群组信息
-type GroupInfo struct {
-	GroupID     string
-	GroupName   string
-	GroupType   int32
-	Visibility  int32
-	OwnerUserID string
-	CreateTime  int64
-	Members     map[string]*DeviceMemberInfo // deviceId -> member info
-}
-
-// DeviceMemberInfo 设备成员信息
-type DeviceMemberInfo struct {
-	DeviceID   string
-	UDID       string
-	AuthID     string
-	UserType   int32
-	Credential string
-	JoinTime   int64
-}
-
-// RegCallback 注册业务回调（stub实现）
-func (d *stubDeviceGroupManager) RegCallback(appId string, callback *DeviceAuthCallback) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	log.Infof("[DEVICE_AUTH] RegCallback: appId=%s", appId)
-	if d.callbacks == nil {
-		d.callbacks = make(map[string]*DeviceAuthCallback)
-	}
-	d.callbacks[appId] = callback
-	return nil
-}
-
-// UnRegCallback 注销业务回调（stub实现）
-func (d *stubDeviceGroupManager) UnRegCallback(appId string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	log.Infof("[DEVICE_AUTH] UnRegCallback: appId=%s", appId)
-	delete(d.callbacks, appId)
-	return nil
-}
-
-// RegDataChangeListener 注册数据变更监听回调（stub实现）
-func (d *stubDeviceGroupManager) RegDataChangeListener(appId string, listener *DataChangeListener) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	log.Infof("[DEVICE_AUTH] RegDataChangeListener: appId=%s", appId)
-	if d.listeners == nil {
-		d.listeners = make(map[string]*DataChangeListener)
-	}
-	d.listeners[appId] = listener
-	return nil
-}
-
-// UnRegDataChangeListener 注销数据变更监听回调（stub实现）
-func (d *stubDeviceGroupManager) UnRegDataChangeListener(appId string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	log.Infof("[DEVICE_AUTH] UnRegDataChangeListener: appId=%s", appId)
-	delete(d.listeners, appId)
-	return nil
-}
-
-// CreateGroup 创建可信组
-func (d *stubDeviceGroupManager) CreateGroup(osAccountId int32, requestId int64, appId string, createParams string) error {
-	log.Infof("[DEVICE_AUTH] CreateGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
-
-	var params map[string]interface{}
-	if err := json.Unmarshal([]byte(createParams), &params); err != nil {
-		return fmt.Errorf("invalid createParams: %w", err)
-	}
-
-	groupId, _ := params["groupId"].(string)
-	groupName, _ := params["groupName"].(string)
-	groupType, _ := params["groupType"].(float64)
-	visibility, _ := params["groupVisibility"].(float64)
-
-	if groupId == "" {
-		return fmt.Errorf("groupId is required")
-	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	if d.groups == nil {
-		d.groups = make(map[string]*GroupInfo)
-	}
-
-	d.groups[groupId] = &GroupInfo{
-		GroupID:    groupId,
-		GroupName:  groupName,
-		GroupType:  int32(groupType),
-		Visibility: int32(visibility),
-		CreateTime: requestId,
-		Members:    make(map[string]*DeviceMemberInfo),
-	}
-
-	log.Infof("[DEVICE_AUTH] Group created: groupId=%s, groupName=%s", groupId, groupName)
-
-	// 触发回调
-	if listener, ok := d.listeners[appId]; ok && listener.OnGroupCreated != nil {
-		groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d}`, groupId, groupName, int32(groupType))
-		listener.OnGroupCreated(groupInfo)
-	}
-
-	return nil
-}
-
-// DeleteGroup 删除可信组
-func (d *stubDeviceGroupManager) DeleteGroup(osAccountId int32, requestId int64, appId string, disbandParams string) error {
-	log.Infof("[DEVICE_AUTH] DeleteGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
-
-	var params map[string]interface{}
-	if err := json.Unmarshal([]byte(disbandParams), &params); err != nil {
-		return fmt.Errorf("invalid disbandParams: %w", err)
-	}
-
-	groupId, _ := params["groupId"].(string)
-	if groupId == "" {
-		return fmt.Errorf("groupId is required")
-	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	group, exists := d.groups[groupId]
-	if !exists {
-		return fmt.Errorf("group not found: %s", groupId)
-	}
-
-	delete(d.groups, groupId)
-	log.Infof("[DEVICE_AUTH] Group deleted: groupId=%s", groupId)
-
-	// 触发回调
-	if listener, ok := d.listeners[appId]; ok && listener.OnGroupDeleted != nil {
-		groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s"}`, group.GroupID, group.GroupName)
-		listener.OnGroupDeleted(groupInfo)
-	}
-
-	return nil
-}
-
-// AddMemberToGroup 将可信设备添加到可信组
-func (d *stubDeviceGroupManager) AddMemberToGroup(osAccountId int32, requestId int64, appId string, addParams string) error {
-	log.Infof("[DEVICE_AUTH] AddMemberToGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
-
-	var params map[string]interface{}
-	if err := json.Unmarshal([]byte(addParams), &params); err != nil {
-		return fmt.Errorf("invalid addParams: %w", err)
-	}
-
-	groupId, _ := params["groupId"].(string)
-	deviceId, _ := params["deviceId"].(string)
-	if groupId == "" || deviceId == "" {
-		return fmt.Errorf("groupId and deviceId are required")
-	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	group, exists := d.groups[groupId]
-	if !exists {
-		return fmt.Errorf("group not found: %s", groupId)
-	}
-
-	if group.Members == nil {
-		group.Members = make(map[string]*DeviceMemberInfo)
-	}
-
-	member := &DeviceMemberInfo{
-		DeviceID: deviceId,
-		UDID:     deviceId,
-		JoinTime: requestId,
-	}
-	if udid, ok := params["udid"].(string); ok {
-		member.UDID = udid
-	}
-	if authId, ok := params["authId"].(string); ok {
-		member.AuthID = authId
-	}
-
-	group.Members[deviceId] = member
-	log.Infof("[DEVICE_AUTH] Member added: groupId=%s, deviceId=%s", groupId, deviceId)
-
-	// 触发回调
-	if listener, ok := d.listeners[appId]; ok && listener.OnDeviceBound != nil {
-		groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s"}`, group.GroupID, group.GroupName)
-		listener.OnDeviceBound(member.UDID, groupInfo)
-	}
-
-	return nil
-}
-
-// DeleteMemberFromGroup 从可信组删除可信设备
-func (d *stubDeviceGroupManager) DeleteMemberFromGroup(osAccountId int32, requestId int64, appId string, deleteParams string) error {
-	log.Infof("[DEVICE_AUTH] DeleteMemberFromGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
-
-	var params map[string]interface{}
-	if err := json.Unmarshal([]byte(deleteParams), &params); err != nil {
-		return fmt.Errorf("invalid deleteParams: %w", err)
-	}
-
-	groupId, _ := params["groupId"].(string)
-	deviceId, _ := params["deviceId"].(string)
-	if groupId == "" || deviceId == "" {
-		return fmt.Errorf("groupId and deviceId are required")
-	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	group, exists := d.groups[groupId]
-	if !exists {
-		return fmt.Errorf("group not found: %s", groupId)
-	}
-
-	member, exists := group.Members[deviceId]
-	if !exists {
-		return fmt.Errorf("device not found in group: %s", deviceId)
-	}
-
-	delete(group.Members, deviceId)
-	log.Infof("[DEVICE_AUTH] Member deleted: groupId=%s, deviceId=%s", groupId, deviceId)
-
-	// 触发回调
-	if listener, ok := d.listeners[appId]; ok && listener.OnDeviceUnBound != nil {
-		groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s"}`, group.GroupID, group.GroupName)
-		listener.OnDeviceUnBound(member.UDID, groupInfo)
-	}
-
-	return nil
-}
-
-// ProcessData 处理绑定或解绑设备的数据（stub实现）
-func (d *stubDeviceGroupManager) ProcessData(requestId int64, data []byte) error {
-	log.Infof("[DEVICE_AUTH] ProcessData: requestId=%d, dataLen=%d", requestId, len(data))
-	return fmt.Errorf("not implemented")
-}
-
-// AddMultiMembersToGroup 批量添加具有账户关系的可信设备（stub实现）
-func (d *stubDeviceGroupManager) AddMultiMembersToGroup(osAccountId int32, appId string, addParams string) error {
-	log.Infof("[DEVICE_AUTH] AddMultiMembersToGroup: osAccountId=%d, appId=%s", osAccountId, appId)
-	return fmt.Errorf("not implemented")
-}
-
-// DelMultiMembersFromGroup 批量删除具有账户关系的可信设备（stub实现）
-func (d *stubDeviceGroupManager) DelMultiMembersFromGroup(osAccountId int32, appId string, deleteParams string) error {
-	log.Infof("[DEVICE_AUTH] DelMultiMembersFromGroup: osAccountId=%d, appId=%s", osAccountId, appId)
-	return fmt.Errorf("not implemented")
-}
-
-// GetRegisterInfo 获取本地设备的注册信息（stub实现）
-func (d *stubDeviceGroupManager) GetRegisterInfo(reqJsonStr string) (string, error) {
-	log.Infof("[DEVICE_AUTH] GetRegisterInfo called")
-	return "{}", nil
-}
-
-// CheckAccessToGroup 检查指定应用是否具有组的访问权限（stub实现）
-func (d *stubDeviceGroupManager) CheckAccessToGroup(osAccountId int32, appId string, groupId string) error {
-	log.Infof("[DEVICE_AUTH] CheckAccessToGroup: osAccountId=%d, appId=%s, groupId=%s", osAccountId, appId, groupId)
-	// Stub实现：总是允许访问
-	return nil
-}
-
-// GetPkInfoList 获取与设备相关的所有公钥信息（stub实现）
-func (d *stubDeviceGroupManager) GetPkInfoList(osAccountId int32, appId string, queryParams string) ([]string, error) {
-	log.Infof("[DEVICE_AUTH] GetPkInfoList: osAccountId=%d, appId=%s", osAccountId, appId)
-	return []string{}, nil
-}
-
-// GetGroupInfoById 获取组的组信息
-func (d *stubDeviceGroupManager) GetGroupInfoById(osAccountId int32, appId string, groupId string) (string, error) {
-	log.Infof("[DEVICE_AUTH] GetGroupInfoById: osAccountId=%d, appId=%s, groupId=%s", osAccountId, appId, groupId)
-
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-
-	group, exists := d.groups[groupId]
-	if !exists {
-		return "", fmt.Errorf("group not found: %s", groupId)
-	}
-
-	result := fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d,"groupVisibility":%d}`,
-		group.GroupID, group.GroupName, group.GroupType, group.Visibility)
-	return result, nil
-}
-
-// GetGroupInfo 获取满足查询参数的组的组信息（stub实现）
-func (d *stubDeviceGroupManager) GetGroupInfo(osAccountId int32, appId string, queryParams string) ([]string, error) {
-	log.Infof("[DEVICE_AUTH] GetGroupInfo: osAccountId=%d, appId=%s", osAccountId, appId)
-	return []string{}, nil
-}
-
-// GetJoinedGroups 获取特定组类型的所有组信息
-func (d *stubDeviceGroupManager) GetJoinedGroups(osAccountId int32, appId string, groupType GroupType) ([]string, error) {
-	log.Infof("[DEVICE_AUTH] GetJoinedGroups: osAccountId=%d, appId=%s, groupType=%d", osAccountId, appId, groupType)
-
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-
-	var result []string
-	for _, group := range d.groups {
-		if groupType == AllGroup || GroupType(group.GroupType) == groupType {
-			groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d}`,
-				group.GroupID, group.GroupName, group.GroupType)
-			result = append(result, groupInfo)
-		}
-	}
-
-	return result, nil
-}
-
-// GetRelatedGroups 获取与某个设备相关的所有组信息
-func (d *stubDeviceGroupManager) GetRelatedGroups(osAccountId int32, appId string, peerDeviceId string) ([]string, error) {
-	log.Infof("[DEVICE_AUTH] GetRelatedGroups: osAccountId=%d, appId=%s, peerDeviceId=%s", osAccountId, appId, peerDeviceId)
-
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-
-	var result []string
-	for _, group := range d.groups {
-		if _, exists := group.Members[peerDeviceId]; exists {
-			groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d}`,
-				group.GroupID, group.GroupName, group.GroupType)
-			result = append(result, groupInfo)
-		}
-	}
-
-	return result, nil
-}
-
-// GetDeviceInfoById 获取可信设备的信息
-func (d *stubDeviceGroupManager) GetDeviceInfoById(osAccountId int32, appId string, deviceId string, groupId string) (string, error) {
-	log.Infof("[DEVICE_AUTH] GetDeviceInfoById: osAccountId=%d, appId=%s, deviceId=%s, groupId=%s",
-		osAccountId, appId, deviceId, groupId)
-
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-
-	group, exists := d.groups[groupId]
-	if !exists {
-		return "", fmt.Errorf("group not found: %s", groupId)
-	}
-
-	member, exists := group.Members[deviceId]
-	if !exists {
-		return "", fmt.Errorf("device not found: %s", deviceId)
-	}
-
-	result := fmt.Sprintf(`{"deviceId":"%s","udid":"%s","authId":"%s"}`,
-		member.DeviceID, member.UDID, member.AuthID)
-	return result, nil
-}
-
-// GetTrustedDevices 获取组中的所有可信设备信息
-func (d *stubDeviceGroupManager) GetTrustedDevices(osAccountId int32, appId string, groupId string) ([]string, error) {
-	log.Infof("[DEVICE_AUTH] GetTrustedDevices: osAccountId=%d, appId=%s, groupId=%s", osAccountId, appId, groupId)
-
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-
-	group, exists := d.groups[groupId]
-	if !exists {
-		return nil, fmt.Errorf("group not found: %s", groupId)
-	}
-
-	var result []string
-	for _, member := range group.Members {
-		deviceInfo := fmt.Sprintf(`{"deviceId":"%s","udid":"%s","authId":"%s"}`,
-			member.DeviceID, member.UDID, member.AuthID)
-		result = append(result, deviceInfo)
-	}
-
-	return result, nil
-}
-
-// IsDeviceInGroup 查询组中是否存在指定设备
-func (d *stubDeviceGroupManager) IsDeviceInGroup(osAccountId int32, appId string, groupId string, deviceId string) bool {
-	log.Infof("[DEVICE_AUTH] IsDeviceInGroup: osAccountId=%d, appId=%s, groupId=%s, deviceId=%s",
-		osAccountId, appId, groupId, deviceId)
-
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-
-	group, exists := d.groups[groupId]
-	if !exists {
-		return false
-	}
-
-	_, exists = group.Members[deviceId]
-	return exists
-}
-
-// CancelRequest 取消绑定或解绑过程（stub实现）
-func (d *stubDeviceGroupManager) CancelRequest(requestId int64, appId string) {
-	log.Infof("[DEVICE_AUTH] CancelRequest: requestId=%d, appId=%s", requestId, appId)
-	// Stub实现：什么也不做
-}
-
-// DestroyInfo 销毁内部分配的内存返回的信息（stub实现）
-func (d *stubDeviceGroupManager) DestroyInfo(returnInfo *string) {
-	// Stub实现：Go有垃圾回收，不需要手动释放
-	if returnInfo != nil {
-		*returnInfo = ""
-	}
-}
-
 // ============================================================================
 // 全局变量和服务管理
 // ============================================================================
@@ -769,17 +865,9 @@ func InitDeviceAuthService() error {
 	log.Info("[DEVICE_AUTH] Initializing device auth service with HiChain")
 
 	// 创建真实实现（使用hichain）
-	gaInstance = &realGroupAuthManager{
-		hichainInstances: make(map[int64]*hichain.HiChainHandle),
-		callbacks:        make(map[int64]*DeviceAuthCallback),
-		authMessages:     make(map[int64]map[string]interface{}),
-	}
+	gaInstance = newRealGroupAuthManager()
 
-	gmInstance = &stubDeviceGroupManager{
-		callbacks: make(map[string]*DeviceAuthCallback),
-		listeners: make(map[string]*DataChangeListener),
-		groups:    make(map[string]*GroupInfo),
-	}
+	gmInstance = newDeviceGroupManager()
 
 	serviceInitialized = true
 	log.Info("[DEVICE_AUTH] Device auth service initialized successfully")
@@ -806,6 +894,14 @@ func DestroyDeviceAuthService() {
 		}
 		ga.hichainInstances = make(map[int64]*hichain.HiChainHandle)
 		ga.callbacks = make(map[int64]*DeviceAuthCallback)
+		ga.pins = make(map[int64]string)
+		ga.pinPrompts = make(map[int64]*pinPrompt)
+		ga.pinFailures = make(map[int64]int)
+		ga.credentials = make(map[int64]*credentialAuth)
+		for _, timer := range ga.timers {
+			timer.Stop()
+		}
+		ga.timers = nil
 		ga.mu.Unlock()
 	}
 
@@ -818,6 +914,7 @@ func DestroyDeviceAuthService() {
 
 // GetGaInstance 获取组认证实例
 // 必须先调用InitDeviceAuthService
+// 软总线内部使用，调用方身份为AUTH_APPID；应用使用GetGaInstanceForApp
 func GetGaInstance() (GroupAuthManager, error) {
 	serviceMu.RLock()
 	defer serviceMu.RUnlock()
@@ -833,6 +930,50 @@ func GetGaInstance() (GroupAuthManager, error) {
 	return gaInstance, nil
 }
 
+// GetGaInstanceForApp 获取应用使用的组认证管理器实例，调用方身份固定为appId
+// AUTH_APPID保留给软总线内部（GetGaInstance），不能以应用身份获取
+func GetGaInstanceForApp(appId string) (GroupAuthManager, error) {
+	if appId == "" || appId == AUTH_APPID {
+		return nil, fmt.Errorf("invalid appId: %q", appId)
+	}
+	ga, err := GetGaInstance()
+	if err != nil {
+		return nil, err
+	}
+	instance, ok := ga.(*realGroupAuthManager)
+	if !ok {
+		return nil, fmt.Errorf("group auth manager does not support app instances")
+	}
+	return &appGroupAuthManager{realGroupAuthManager: instance, appId: appId}, nil
+}
+
+// SaveDeviceSessionKey 缓存与对端设备协商出的会话密钥，供后续快速重连使用
+// deviceId: 对端设备UDID
+func SaveDeviceSessionKey(deviceId string, sessionKey []byte) {
+	if deviceId == "" || len(sessionKey) == 0 {
+		return
+	}
+	hichain.SaveDeviceSessionKey(deviceId, sessionKey)
+}
+
+// GetDeviceSessionKey 获取缓存的对端设备会话密钥
+// 返回: 会话密钥（不存在时为nil）、缓存时间（Unix秒）
+func GetDeviceSessionKey(deviceId string) ([]byte, int64) {
+	return hichain.GetDeviceSessionKey(deviceId)
+}
+
+// EnableFileKeyStore 启用基于文件的HiChain长期密钥存储
+// 本地身份密钥对和对端公钥保存在数据目录中（私钥加密存储），重启后保持不变
+func EnableFileKeyStore(dataDir string) error {
+	store, err := hichain.NewFileKeyStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to create key store: %w", err)
+	}
+	hichain.SetKeyStore(store)
+	log.Infof("[DEVICE_AUTH] File key store enabled: dataDir=%s", dataDir)
+	return nil
+}
+
 // GetGmInstance 获取组管理实例
 // 必须先调用InitDeviceAuthService
 func GetGmInstance() (DeviceGroupManager, error) {
@@ -854,13 +995,6 @@ func GetGmInstance() (DeviceGroupManager, error) {
 // 辅助函数
 // ============================================================================
 
-// ProcessCredential 处理凭证数据
-func ProcessCredential(operationCode int32, requestParams string) (string, error) {
-	log.Infof("[DEVICE_AUTH] ProcessCredential: operationCode=%d", operationCode)
-	// Stub实现：返回空结果
-	return "{}", nil
-}
-
 // StartAuthDevice 开始设备认证
 func StartAuthDevice(requestId int64, authParams string, callback *DeviceAuthCallback) error {
 	log.Infof("[DEVICE_AUTH] StartAuthDevice: requestId=%d", requestId)
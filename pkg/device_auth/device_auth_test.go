@@ -2,8 +2,65 @@ package device_auth
 
 import (
 	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/context"
 )
 
+// gaTestPair 同一进程内的认证双方：客户端使用全局实例，服务端使用独立实例，OnTransmit直接投递给对端
+type gaTestPair struct {
+	client, server           GroupAuthManager
+	clientReqId, serverReqId int64
+	clientCb, serverCb       *DeviceAuthCallback
+	clientFinished           bool
+	serverFinished           bool
+	serverRequests           int // 服务端OnRequest调用次数（返回空确认）
+}
+
+// newGaTestPair 创建认证双方并设置AuthSessionContext（客户端clientUdid向服务端serverUdid发起），调用前需已初始化服务
+func newGaTestPair(t *testing.T, clientReqId, serverReqId int64, clientUdid, serverUdid string) *gaTestPair {
+	t.Helper()
+	context.SetAuthSessionContext(int(clientReqId), &context.AuthSessionContext{
+		RequestID: clientReqId, LocalDeviceID: clientUdid, PeerDeviceID: serverUdid,
+	})
+	context.SetAuthSessionContext(int(serverReqId), &context.AuthSessionContext{
+		RequestID: serverReqId, LocalDeviceID: serverUdid,
+	})
+	t.Cleanup(func() {
+		context.DeleteAuthSessionContext(int(clientReqId))
+		context.DeleteAuthSessionContext(int(serverReqId))
+	})
+
+	client, err := GetGaInstance()
+	if err != nil {
+		t.Fatalf("GetGaInstance failed: %v", err)
+	}
+	p := &gaTestPair{client: client, server: newRealGroupAuthManager(), clientReqId: clientReqId, serverReqId: serverReqId}
+	p.clientCb = &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool {
+			p.server.ProcessData(p.serverReqId, data, p.serverCb)
+			return true
+		},
+		OnFinish: func(requestId int64, operationCode int32, returnData string) { p.clientFinished = true },
+	}
+	p.serverCb = &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool {
+			p.client.ProcessData(p.clientReqId, data, p.clientCb)
+			return true
+		},
+		OnFinish: func(requestId int64, operationCode int32, returnData string) { p.serverFinished = true },
+		OnRequest: func(requestId int64, operationCode int32, reqParams string) string {
+			p.serverRequests++
+			return ""
+		},
+	}
+	return p
+}
+
+// authDevice 客户端发起认证，消息同步投递，返回时认证已结束
+func (p *gaTestPair) authDevice(authParams string) error {
+	return p.client.AuthDevice(AnyOsAccount, p.clientReqId, authParams, p.clientCb)
+}
+
 // TestInitDestroyDeviceAuthService 测试初始化和销毁服务
 func TestInitDestroyDeviceAuthService(t *testing.T) {
 	// 初始化服务
@@ -86,10 +143,10 @@ func TestGroupAuthManager(t *testing.T) {
 	ga.CancelRequest(1003, AUTH_APPID)
 	t.Log("CancelRequest succeeded")
 
-	// 测试GetRealInfo（应该返回not implemented）
+	// 测试GetRealInfo（未知假名应该返回错误）
 	_, err = ga.GetRealInfo(AnyOsAccount, "pseudo-123")
 	if err == nil {
-		t.Error("Expected not implemented error")
+		t.Error("Expected error for unknown pseudonym")
 	}
 	t.Logf("GetRealInfo error (expected): %v", err)
 }
@@ -283,7 +340,7 @@ func TestAddAndDeleteMember(t *testing.T) {
 		t.Fatalf("AddMemberToGroup failed: %v", err)
 	}
 
-	if !gm.IsDeviceInGroup(AnyOsAccount, "test_app", "TEST_003", "device001") {
+	if !gm.IsDeviceInGroup(AnyOsAccount, "test_app", "TEST_003", testAppDeviceId(t, "test_app", "device001")) {
 		t.Error("Device should be in group")
 	}
 
@@ -292,7 +349,7 @@ func TestAddAndDeleteMember(t *testing.T) {
 		t.Fatalf("DeleteMemberFromGroup failed: %v", err)
 	}
 
-	if gm.IsDeviceInGroup(AnyOsAccount, "test_app", "TEST_003", "device001") {
+	if gm.IsDeviceInGroup(AnyOsAccount, "test_app", "TEST_003", testAppDeviceId(t, "test_app", "device001")) {
 		t.Error("Device should not be in group")
 	}
 }
@@ -334,7 +391,7 @@ func TestGetRelatedGroupsForDevice(t *testing.T) {
 	addParams := `{"groupId":"TEST_007","deviceId":"device002"}`
 	gm.AddMemberToGroup(AnyOsAccount, 1011, "test_app", addParams)
 
-	groups, err := gm.GetRelatedGroups(AnyOsAccount, "test_app", "device002")
+	groups, err := gm.GetRelatedGroups(AnyOsAccount, "test_app", testAppDeviceId(t, "test_app", "device002"))
 	if err != nil {
 		t.Fatalf("GetRelatedGroups failed: %v", err)
 	}
@@ -384,7 +441,7 @@ func TestGetDeviceInfo(t *testing.T) {
 	addParams := `{"groupId":"TEST_009","deviceId":"device006","udid":"udid006","authId":"auth006"}`
 	gm.AddMemberToGroup(AnyOsAccount, 1017, "test_app", addParams)
 
-	deviceInfo, err := gm.GetDeviceInfoById(AnyOsAccount, "test_app", "device006", "TEST_009")
+	deviceInfo, err := gm.GetDeviceInfoById(AnyOsAccount, "test_app", testAppDeviceId(t, "test_app", "device006"), "TEST_009")
 	if err != nil {
 		t.Fatalf("GetDeviceInfoById failed: %v", err)
 	}
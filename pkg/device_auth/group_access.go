@@ -0,0 +1,218 @@
+package device_auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 可信组访问控制
+// ============================================================================
+//
+// 每个可信组记录创建它的应用（OwnerAppId），以及显式授权的管理者（Managers）和好友（Friends）列表:
+//   - 创建者和管理者: 可以读取和修改组（删除组、添加/删除成员、绑定/解绑、修改好友列表）
+//   - 好友: 只能读取
+//   - 公开组（GroupVisibilityPublic）: 所有应用可读，私有组只对上述应用可见
+//   - 只有创建者可以修改管理者列表
+// 软总线内部认证（AUTH_APPID）需要查询所有可信关系，对所有组可读。
+// 启用访问控制之前保存的组没有创建者，保持原有行为（所有应用均可读写）。
+
+// GroupError 带错误码的可信组错误
+type GroupError struct {
+	Code int32
+	Msg  string
+}
+
+// Error 实现error接口
+func (e *GroupError) Error() string {
+	return e.Msg
+}
+
+// Is 按错误码匹配，支持errors.Is
+func (e *GroupError) Is(target error) bool {
+	t, ok := target.(*GroupError)
+	return ok && t.Code == e.Code
+}
+
+var (
+	// ErrGroupNotExist 可信组不存在
+	ErrGroupNotExist = &GroupError{Code: HC_ERR_GROUP_NOT_EXIST, Msg: "group not exist"}
+	// ErrAccessDenied 应用无权读取该组
+	ErrAccessDenied = &GroupError{Code: HC_ERR_ACCESS_DENIED, Msg: "access denied"}
+	// ErrNotGroupManager 应用不是组的创建者或管理者，无权修改
+	ErrNotGroupManager = &GroupError{Code: HC_ERR_NOT_GROUP_MANAGER, Msg: "not group manager"}
+	// ErrNotGroupOwner 只有组的创建者可以执行该操作
+	ErrNotGroupOwner = &GroupError{Code: HC_ERR_NOT_GROUP_OWNER, Msg: "not group owner"}
+)
+
+// GetGroupErrorCode 获取错误对应的错误码（nil返回HC_SUCCESS，无错误码时返回HC_ERR）
+func GetGroupErrorCode(err error) int32 {
+	if err == nil {
+		return HC_SUCCESS
+	}
+	for _, target := range []*GroupError{ErrGroupNotExist, ErrAccessDenied, ErrNotGroupManager, ErrNotGroupOwner} {
+		if errors.Is(err, target) {
+			return target.Code
+		}
+	}
+	return HC_ERR
+}
+
+// isGroupManager 应用是否为组的创建者或管理者
+func isGroupManager(appId string, group *GroupInfo) bool {
+	if group.OwnerAppId == "" || group.OwnerAppId == appId {
+		return true
+	}
+	return containsAppId(group.Managers, appId)
+}
+
+// containsAppId 列表中是否包含appId
+func containsAppId(list []string, appId string) bool {
+	for _, item := range list {
+		if item == appId {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccessLocked 检查应用对组的读取权限（调用方持有锁）
+func (d *deviceGroupManager) checkAccessLocked(appId string, group *GroupInfo) error {
+	if isGroupManager(appId, group) || containsAppId(group.Friends, appId) ||
+		GroupVisibility(group.Visibility) == GroupVisibilityPublic || appId == AUTH_APPID {
+		return nil
+	}
+	return fmt.Errorf("%w: appId=%s, groupId=%s", ErrAccessDenied, appId, group.GroupID)
+}
+
+// checkManageLocked 检查应用对组的修改权限（调用方持有锁）
+func (d *deviceGroupManager) checkManageLocked(appId string, group *GroupInfo) error {
+	if isGroupManager(appId, group) {
+		return nil
+	}
+	return fmt.Errorf("%w: appId=%s, groupId=%s", ErrNotGroupManager, appId, group.GroupID)
+}
+
+// readableGroupLocked 查找应用可读取的组（调用方持有锁）
+func (d *deviceGroupManager) readableGroupLocked(appId string, groupId string) (*GroupInfo, error) {
+	group, exists := d.groups[groupId]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotExist, groupId)
+	}
+	if err := d.checkAccessLocked(appId, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// manageableGroupLocked 查找应用可修改的组（调用方持有锁）
+func (d *deviceGroupManager) manageableGroupLocked(appId string, groupId string) (*GroupInfo, error) {
+	group, exists := d.groups[groupId]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotExist, groupId)
+	}
+	if err := d.checkManageLocked(appId, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// AddGroupManager 添加组管理者（只有创建者可以操作）
+func (d *deviceGroupManager) AddGroupManager(osAccountId int32, appId string, groupId string, managerAppId string) error {
+	log.Infof("[DEVICE_AUTH] AddGroupManager: appId=%s, groupId=%s, manager=%s", appId, groupId, managerAppId)
+	return d.updateAccessList(appId, groupId, managerAppId, true, func(group *GroupInfo) *[]string {
+		return &group.Managers
+	})
+}
+
+// DeleteGroupManager 删除组管理者（只有创建者可以操作）
+func (d *deviceGroupManager) DeleteGroupManager(osAccountId int32, appId string, groupId string, managerAppId string) error {
+	log.Infof("[DEVICE_AUTH] DeleteGroupManager: appId=%s, groupId=%s, manager=%s", appId, groupId, managerAppId)
+	return d.updateAccessList(appId, groupId, managerAppId, false, func(group *GroupInfo) *[]string {
+		return &group.Managers
+	})
+}
+
+// AddGroupFriend 添加组好友（创建者和管理者可以操作）
+func (d *deviceGroupManager) AddGroupFriend(osAccountId int32, appId string, groupId string, friendAppId string) error {
+	log.Infof("[DEVICE_AUTH] AddGroupFriend: appId=%s, groupId=%s, friend=%s", appId, groupId, friendAppId)
+	return d.updateAccessList(appId, groupId, friendAppId, true, func(group *GroupInfo) *[]string {
+		return &group.Friends
+	})
+}
+
+// DeleteGroupFriend 删除组好友（创建者和管理者可以操作）
+func (d *deviceGroupManager) DeleteGroupFriend(osAccountId int32, appId string, groupId string, friendAppId string) error {
+	log.Infof("[DEVICE_AUTH] DeleteGroupFriend: appId=%s, groupId=%s, friend=%s", appId, groupId, friendAppId)
+	return d.updateAccessList(appId, groupId, friendAppId, false, func(group *GroupInfo) *[]string {
+		return &group.Friends
+	})
+}
+
+// GetGroupManagers 获取组管理者列表（含创建者）
+func (d *deviceGroupManager) GetGroupManagers(osAccountId int32, appId string, groupId string) ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	group, err := d.readableGroupLocked(appId, groupId)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	if group.OwnerAppId != "" {
+		result = append(result, group.OwnerAppId)
+	}
+	return append(result, group.Managers...), nil
+}
+
+// GetGroupFriends 获取组好友列表
+func (d *deviceGroupManager) GetGroupFriends(osAccountId int32, appId string, groupId string) ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	group, err := d.readableGroupLocked(appId, groupId)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, group.Friends...), nil
+}
+
+// updateAccessList 修改组的管理者或好友列表
+func (d *deviceGroupManager) updateAccessList(appId string, groupId string, target string, add bool, list func(group *GroupInfo) *[]string) error {
+	if target == "" {
+		return fmt.Errorf("appId is required")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	group, err := d.manageableGroupLocked(appId, groupId)
+	if err != nil {
+		return err
+	}
+	entries := list(group)
+	if entries == &group.Managers && group.OwnerAppId != "" && group.OwnerAppId != appId {
+		return fmt.Errorf("%w: appId=%s, groupId=%s", ErrNotGroupOwner, appId, groupId)
+	}
+
+	previous := *entries
+	updated := make([]string, 0, len(previous)+1)
+	for _, item := range previous {
+		if item != target {
+			updated = append(updated, item)
+		}
+	}
+	if add {
+		updated = append(updated, target)
+		sort.Strings(updated)
+	}
+	*entries = updated
+	if err := d.saveLocked(); err != nil {
+		*entries = previous
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package device_auth
+
+import (
+	"errors"
+	"testing"
+)
+
+// 测试私有组只对创建者、管理者和好友可见，公开组所有应用可读
+func TestGroupAccess_Visibility(t *testing.T) {
+	gm := newDeviceGroupManager()
+	gm.CreateGroup(AnyOsAccount, 1, "owner_app", `{"groupId":"PRIVATE_001","groupType":256}`)
+	gm.CreateGroup(AnyOsAccount, 2, "owner_app", `{"groupId":"PUBLIC_001","groupType":256,"groupVisibility":-1}`)
+	gm.AddMemberToGroup(AnyOsAccount, 3, "owner_app", `{"groupId":"PRIVATE_001","deviceId":"dev-1"}`)
+
+	if err := gm.CheckAccessToGroup(AnyOsAccount, "owner_app", "PRIVATE_001"); err != nil {
+		t.Errorf("Expected owner to access private group: %v", err)
+	}
+	if err := gm.CheckAccessToGroup(AnyOsAccount, "other_app", "PRIVATE_001"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied, got %v", err)
+	}
+	if err := gm.CheckAccessToGroup(AnyOsAccount, "other_app", "PUBLIC_001"); err != nil {
+		t.Errorf("Expected public group to be readable: %v", err)
+	}
+	if err := gm.CheckAccessToGroup(AnyOsAccount, "other_app", "MISSING"); GetGroupErrorCode(err) != HC_ERR_GROUP_NOT_EXIST {
+		t.Errorf("Expected HC_ERR_GROUP_NOT_EXIST, got %v", err)
+	}
+
+	// 查询只返回可读的组
+	groups, _ := gm.GetJoinedGroups(AnyOsAccount, "other_app", AllGroup)
+	if len(groups) != 1 {
+		t.Errorf("Expected 1 visible group, got %d", len(groups))
+	}
+	if gm.IsDeviceInGroup(AnyOsAccount, "other_app", "PRIVATE_001", "dev-1") {
+		t.Error("Expected private membership to be hidden")
+	}
+	if related, _ := gm.GetRelatedGroups(AnyOsAccount, AUTH_APPID, "dev-1"); len(related) != 1 {
+		t.Errorf("Expected internal auth app to see related group, got %d", len(related))
+	}
+	groups, _ = gm.GetGroupInfo(AnyOsAccount, "owner_app", `{"groupOwner":"owner_app","groupType":256}`)
+	if len(groups) != 2 {
+		t.Errorf("Expected 2 groups from GetGroupInfo, got %d", len(groups))
+	}
+
+	// 好友可读不可写
+	gm.AddGroupFriend(AnyOsAccount, "owner_app", "PRIVATE_001", "friend_app")
+	if err := gm.CheckAccessToGroup(AnyOsAccount, "friend_app", "PRIVATE_001"); err != nil {
+		t.Errorf("Expected friend to read group: %v", err)
+	}
+	err := gm.AddMemberToGroup(AnyOsAccount, 4, "friend_app", `{"groupId":"PRIVATE_001","deviceId":"dev-2"}`)
+	if GetGroupErrorCode(err) != HC_ERR_NOT_GROUP_MANAGER {
+		t.Errorf("Expected HC_ERR_NOT_GROUP_MANAGER, got %v", err)
+	}
+	if err := gm.DeleteGroup(AnyOsAccount, 5, "other_app", `{"groupId":"PUBLIC_001"}`); !errors.Is(err, ErrNotGroupManager) {
+		t.Errorf("Expected ErrNotGroupManager deleting public group, got %v", err)
+	}
+}
+
+// 测试管理者可以修改组，只有创建者可以修改管理者列表
+func TestGroupAccess_Managers(t *testing.T) {
+	gm := newDeviceGroupManager()
+	gm.CreateGroup(AnyOsAccount, 1, "owner_app", `{"groupId":"MANAGED_001","groupType":256}`)
+
+	if err := gm.AddGroupManager(AnyOsAccount, "owner_app", "MANAGED_001", "manager_app"); err != nil {
+		t.Fatalf("AddGroupManager failed: %v", err)
+	}
+	if err := gm.AddMemberToGroup(AnyOsAccount, 2, "manager_app", `{"groupId":"MANAGED_001","deviceId":"dev-1"}`); err != nil {
+		t.Errorf("Expected manager to add member: %v", err)
+	}
+	if err := gm.AddGroupFriend(AnyOsAccount, "manager_app", "MANAGED_001", "friend_app"); err != nil {
+		t.Errorf("Expected manager to add friend: %v", err)
+	}
+	if err := gm.AddGroupManager(AnyOsAccount, "manager_app", "MANAGED_001", "other_app"); GetGroupErrorCode(err) != HC_ERR_NOT_GROUP_OWNER {
+		t.Errorf("Expected HC_ERR_NOT_GROUP_OWNER, got %v", err)
+	}
+
+	managers, _ := gm.GetGroupManagers(AnyOsAccount, "friend_app", "MANAGED_001")
+	if len(managers) != 2 || managers[0] != "owner_app" || managers[1] != "manager_app" {
+		t.Errorf("Unexpected managers: %v", managers)
+	}
+
+	gm.DeleteGroupManager(AnyOsAccount, "owner_app", "MANAGED_001", "manager_app")
+	if err := gm.DeleteMemberFromGroup(AnyOsAccount, 3, "manager_app", `{"groupId":"MANAGED_001","deviceId":"dev-1"}`); !errors.Is(err, ErrNotGroupManager) {
+		t.Errorf("Expected removed manager to be rejected, got %v", err)
+	}
+}
+
+// 测试变更通知只发送给可以访问该组的应用
+func TestGroupAccess_Notifications(t *testing.T) {
+	gm := newDeviceGroupManager()
+
+	var ownerEvents, otherEvents []string
+	gm.RegDataChangeListener("owner_app", newRecordingListener(&ownerEvents))
+	gm.RegDataChangeListener("other_app", newRecordingListener(&otherEvents))
+
+	gm.CreateGroup(AnyOsAccount, 1, "owner_app", `{"groupId":"PRIVATE_002","groupType":256}`)
+	gm.AddMemberToGroup(AnyOsAccount, 2, "owner_app", `{"groupId":"PRIVATE_002","deviceId":"dev-1"}`)
+
+	if len(ownerEvents) != 3 {
+		t.Errorf("Expected owner to receive 3 events, got %v", ownerEvents)
+	}
+	if len(otherEvents) != 0 {
+		t.Errorf("Expected no events for other app, got %v", otherEvents)
+	}
+}
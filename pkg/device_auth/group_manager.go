@@ -0,0 +1,820 @@
+package device_auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// DeviceGroupManager
+// ============================================================================
+
+// deviceGroupManager DeviceGroupManager实现
+// 可信组保存在内存中，调用EnableGroupStore后每次变更同步写入数据目录，重启后可恢复
+type deviceGroupManager struct {
+	callbacks map[string]*DeviceAuthCallback
+	listeners map[string]*DataChangeListener
+	groups    map[string]*GroupInfo // groupId -> GroupInfo
+	store     *groupStore           // 持久化存储（nil表示仅保存在内存）
+	localUdid string                // 本地设备UDID（绑定/解绑时的本端身份）
+	mu        sync.RWMutex
+
+	sessions  map[int64]*bindSession // requestId -> 进行中的绑定/解绑请求
+	sessionMu sync.Mutex             // 保护sessions，调用HiChain和业务回调时不持有
+}
+
+// newDeviceGroupManager 创建DeviceGroupManager
+func newDeviceGroupManager() *deviceGroupManager {
+	return &deviceGroupManager{
+		callbacks: make(map[string]*DeviceAuthCallback),
+		listeners: make(map[string]*DataChangeListener),
+		groups:    make(map[string]*GroupInfo),
+		sessions:  make(map[int64]*bindSession),
+	}
+}
+
+// GroupInfo 群组信息
+type GroupInfo struct {
+	GroupID      string                       `json:"groupId"`
+	GroupName    string                       `json:"groupName"`
+	GroupType    int32                        `json:"groupType"`
+	Visibility   int32                        `json:"groupVisibility"`
+	OwnerUserID  string                       `json:"ownerUserId,omitempty"`
+	SharedUserID string                       `json:"sharedUserId,omitempty"` // 跨账户组授权的对端用户ID
+	OwnerAppId   string                       `json:"ownerAppId,omitempty"`   // 创建组的应用
+	Managers     []string                     `json:"managers,omitempty"`     // 可以修改组的应用
+	Friends      []string                     `json:"friends,omitempty"`      // 可以读取组的应用
+	CreateTime   int64                        `json:"createTime"`
+	Members      map[string]*DeviceMemberInfo `json:"members"` // deviceId -> member info
+}
+
+// DeviceMemberInfo 设备成员信息
+type DeviceMemberInfo struct {
+	DeviceID   string `json:"deviceId"`
+	UDID       string `json:"udid"`
+	AuthID     string `json:"authId,omitempty"`
+	UserType   int32  `json:"userType"`
+	UserID     string `json:"userId,omitempty"` // 账户组成员所属的用户ID
+	Credential string `json:"credential,omitempty"`
+	JoinTime   int64  `json:"joinTime"`
+}
+
+// EnableGroupStore 启用可信组持久化
+// 从数据目录加载已保存的可信组，之后每次变更都会原子写入磁盘
+// 启用前已在内存中创建的可信组会合并保存
+func EnableGroupStore(dataDir string) error {
+	gm, err := GetGmInstance()
+	if err != nil {
+		return err
+	}
+	d, ok := gm.(*deviceGroupManager)
+	if !ok {
+		return fmt.Errorf("group manager does not support persistence")
+	}
+
+	store, err := newGroupStore(dataDir)
+	if err != nil {
+		return err
+	}
+	groups, migrated, err := store.load()
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dirty := migrated
+	for groupId, group := range d.groups {
+		if _, exists := groups[groupId]; !exists {
+			groups[groupId] = group
+			dirty = true
+		}
+	}
+	if dirty {
+		if err := store.save(groups); err != nil {
+			return err
+		}
+	}
+	d.groups = groups
+	d.store = store
+
+	log.Infof("[DEVICE_AUTH] Group store enabled: path=%s, groups=%d", store.path, len(groups))
+	return nil
+}
+
+// releaseMemberKeysLocked 设备已不在任何可信组中时删除其长期公钥（调用方持有锁）
+// 由非对称凭据预置的公钥保留，随凭据删除
+func (d *deviceGroupManager) releaseMemberKeysLocked(member *DeviceMemberInfo) {
+	for _, group := range d.groups {
+		if _, exists := group.Members[member.DeviceID]; exists {
+			return
+		}
+	}
+	for _, deviceId := range []string{member.UDID, member.DeviceID} {
+		if peerPublicKeyCredential(deviceId) == nil {
+			hichain.ClearDeviceAuthInfo(deviceId)
+		}
+		if member.DeviceID == member.UDID {
+			break
+		}
+	}
+}
+
+// isDeviceTrustedLocked 设备（deviceId或UDID）是否在任一可信组中（调用方持有锁）
+func (d *deviceGroupManager) isDeviceTrustedLocked(deviceId string) bool {
+	for _, group := range d.groups {
+		for _, member := range group.Members {
+			if member.DeviceID == deviceId || member.UDID == deviceId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// saveLocked 将可信组写入持久化存储（调用方持有写锁）
+func (d *deviceGroupManager) saveLocked() error {
+	if d.store == nil {
+		return nil
+	}
+	if err := d.store.save(d.groups); err != nil {
+		log.Errorf("[DEVICE_AUTH] Failed to persist groups: %v", err)
+		return fmt.Errorf("failed to persist groups: %w", err)
+	}
+	return nil
+}
+
+// RegCallback 注册业务回调
+func (d *deviceGroupManager) RegCallback(appId string, callback *DeviceAuthCallback) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	log.Infof("[DEVICE_AUTH] RegCallback: appId=%s", appId)
+	if d.callbacks == nil {
+		d.callbacks = make(map[string]*DeviceAuthCallback)
+	}
+	d.callbacks[appId] = callback
+	return nil
+}
+
+// UnRegCallback 注销业务回调
+func (d *deviceGroupManager) UnRegCallback(appId string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	log.Infof("[DEVICE_AUTH] UnRegCallback: appId=%s", appId)
+	delete(d.callbacks, appId)
+	return nil
+}
+
+// RegDataChangeListener 注册数据变更监听回调
+func (d *deviceGroupManager) RegDataChangeListener(appId string, listener *DataChangeListener) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	log.Infof("[DEVICE_AUTH] RegDataChangeListener: appId=%s", appId)
+	if d.listeners == nil {
+		d.listeners = make(map[string]*DataChangeListener)
+	}
+	d.listeners[appId] = listener
+	return nil
+}
+
+// UnRegDataChangeListener 注销数据变更监听回调
+func (d *deviceGroupManager) UnRegDataChangeListener(appId string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	log.Infof("[DEVICE_AUTH] UnRegDataChangeListener: appId=%s", appId)
+	delete(d.listeners, appId)
+	return nil
+}
+
+// CreateGroup 创建可信组
+func (d *deviceGroupManager) CreateGroup(osAccountId int32, requestId int64, appId string, createParams string) error {
+	log.Infof("[DEVICE_AUTH] CreateGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(createParams), &params); err != nil {
+		return fmt.Errorf("invalid createParams: %w", err)
+	}
+
+	groupId, _ := params["groupId"].(string)
+	groupName, _ := params["groupName"].(string)
+	groupType, _ := params["groupType"].(float64)
+	visibility, _ := params["groupVisibility"].(float64)
+	userId, _ := params["userId"].(string)
+	sharedUserId, _ := params["sharedUserId"].(string)
+
+	// 账户组需要用户ID，未指定groupId时由用户ID派生
+	switch GroupType(groupType) {
+	case IdenticalAccountGroup:
+		if userId == "" {
+			return fmt.Errorf("userId is required for identical account group")
+		}
+		sharedUserId = ""
+	case AcrossAccountAuthorizeGroup:
+		if userId == "" || sharedUserId == "" || userId == sharedUserId {
+			return fmt.Errorf("userId and a different sharedUserId are required for across account group")
+		}
+	default:
+		userId, sharedUserId = "", ""
+	}
+	if groupId == "" && userId != "" {
+		groupId = accountGroupId(userId, sharedUserId)
+	}
+	if groupId == "" {
+		return fmt.Errorf("groupId is required")
+	}
+
+	d.mu.Lock()
+	events := d.beginDataChangeLocked()
+	defer d.unlockAndNotify(events)
+
+	if d.groups == nil {
+		d.groups = make(map[string]*GroupInfo)
+	}
+	if _, exists := d.groups[groupId]; exists {
+		return fmt.Errorf("group already exists: %s", groupId)
+	}
+	if existing := d.sameAccountGroupLocked(GroupType(groupType), userId, sharedUserId); existing != nil {
+		return fmt.Errorf("account group already exists: %s", existing.GroupID)
+	}
+
+	group := &GroupInfo{
+		GroupID:      groupId,
+		GroupName:    groupName,
+		GroupType:    int32(groupType),
+		Visibility:   int32(visibility),
+		OwnerUserID:  userId,
+		SharedUserID: sharedUserId,
+		OwnerAppId:   appId,
+		CreateTime:   time.Now().Unix(),
+		Members:      make(map[string]*DeviceMemberInfo),
+	}
+	d.groups[groupId] = group
+	if err := d.saveLocked(); err != nil {
+		delete(d.groups, groupId)
+		return err
+	}
+
+	log.Infof("[DEVICE_AUTH] Group created: groupId=%s, groupName=%s", groupId, groupName)
+	events.groupCreated(group)
+
+	return nil
+}
+
+// DeleteGroup 删除可信组
+func (d *deviceGroupManager) DeleteGroup(osAccountId int32, requestId int64, appId string, disbandParams string) error {
+	log.Infof("[DEVICE_AUTH] DeleteGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(disbandParams), &params); err != nil {
+		return fmt.Errorf("invalid disbandParams: %w", err)
+	}
+
+	groupId, _ := params["groupId"].(string)
+	if groupId == "" {
+		return fmt.Errorf("groupId is required")
+	}
+
+	d.mu.Lock()
+	events := d.beginDataChangeLocked()
+	defer d.unlockAndNotify(events)
+
+	group, err := d.manageableGroupLocked(appId, groupId)
+	if err != nil {
+		return err
+	}
+
+	delete(d.groups, groupId)
+	if err := d.saveLocked(); err != nil {
+		d.groups[groupId] = group
+		return err
+	}
+	for _, member := range group.Members {
+		d.releaseMemberKeysLocked(member)
+	}
+	log.Infof("[DEVICE_AUTH] Group deleted: groupId=%s", groupId)
+	events.groupDeleted(group)
+
+	return nil
+}
+
+// AddMemberToGroup 将可信设备添加到可信组
+// addParams携带pinCode时与对端执行绑定协议，结果通过OnFinish/OnError上报；否则直接添加到本地可信组
+func (d *deviceGroupManager) AddMemberToGroup(osAccountId int32, requestId int64, appId string, addParams string) error {
+	log.Infof("[DEVICE_AUTH] AddMemberToGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(addParams), &params); err != nil {
+		return fmt.Errorf("invalid addParams: %w", err)
+	}
+	if pinCode, _ := params["pinCode"].(string); pinCode != "" {
+		return d.startBind(requestId, appId, params)
+	}
+
+	groupId, _ := params["groupId"].(string)
+	deviceId, _ := params["deviceId"].(string)
+	if groupId == "" || deviceId == "" {
+		return fmt.Errorf("groupId and deviceId are required")
+	}
+
+	_, err := d.addMember(appId, groupId, parseMemberInfo(params))
+	return err
+}
+
+// parseMemberInfo 从参数中解析成员信息（udid缺省时与deviceId相同）
+func parseMemberInfo(params map[string]interface{}) *DeviceMemberInfo {
+	deviceId, _ := params["deviceId"].(string)
+	member := &DeviceMemberInfo{
+		DeviceID: deviceId,
+		UDID:     deviceId,
+		JoinTime: time.Now().Unix(),
+	}
+	if udid, ok := params["udid"].(string); ok && udid != "" {
+		member.UDID = udid
+	}
+	if authId, ok := params["authId"].(string); ok {
+		member.AuthID = authId
+	}
+	return member
+}
+
+// addMember 将成员添加到本地可信组（已存在时覆盖）
+func (d *deviceGroupManager) addMember(appId string, groupId string, member *DeviceMemberInfo) (*GroupInfo, error) {
+	d.mu.Lock()
+	events := d.beginDataChangeLocked()
+	defer d.unlockAndNotify(events)
+
+	group, err := d.manageableGroupLocked(appId, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.Members == nil {
+		group.Members = make(map[string]*DeviceMemberInfo)
+	}
+
+	previous, existed := group.Members[member.DeviceID]
+	group.Members[member.DeviceID] = member
+	if err := d.saveLocked(); err != nil {
+		if existed {
+			group.Members[member.DeviceID] = previous
+		} else {
+			delete(group.Members, member.DeviceID)
+		}
+		return nil, err
+	}
+	log.Infof("[DEVICE_AUTH] Member added: groupId=%s, deviceId=%s", groupId, member.DeviceID)
+	if existed && previous.UDID != member.UDID {
+		events.deviceUnbound(group, previous)
+	}
+	events.deviceBound(group, member)
+
+	return group, nil
+}
+
+// DeleteMemberFromGroup 从可信组删除可信设备
+// 删除本地成员后，注册了OnTransmit且isForceDelete不为true时通知对端解绑，结果通过OnFinish/OnError上报
+func (d *deviceGroupManager) DeleteMemberFromGroup(osAccountId int32, requestId int64, appId string, deleteParams string) error {
+	log.Infof("[DEVICE_AUTH] DeleteMemberFromGroup: osAccountId=%d, requestId=%d, appId=%s", osAccountId, requestId, appId)
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(deleteParams), &params); err != nil {
+		return fmt.Errorf("invalid deleteParams: %w", err)
+	}
+
+	groupId, _ := params["groupId"].(string)
+	deviceId, _ := params["deviceId"].(string)
+	if groupId == "" || deviceId == "" {
+		return fmt.Errorf("groupId and deviceId are required")
+	}
+
+	member, err := d.deleteMember(appId, groupId, deviceId)
+	if err != nil {
+		return err
+	}
+	if force, _ := params["isForceDelete"].(bool); force {
+		return nil
+	}
+	return d.notifyUnbind(requestId, appId, groupId, member)
+}
+
+// deleteMember 从本地可信组删除成员，设备不在任何组中时删除其长期公钥
+func (d *deviceGroupManager) deleteMember(appId string, groupId string, deviceId string) (*DeviceMemberInfo, error) {
+	d.mu.Lock()
+	events := d.beginDataChangeLocked()
+	defer d.unlockAndNotify(events)
+
+	group, err := d.manageableGroupLocked(appId, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	member, exists := group.Members[deviceId]
+	if !exists {
+		return nil, fmt.Errorf("device not found in group: %s", deviceId)
+	}
+
+	delete(group.Members, deviceId)
+	if err := d.saveLocked(); err != nil {
+		group.Members[deviceId] = member
+		return nil, err
+	}
+	d.releaseMemberKeysLocked(member)
+	log.Infof("[DEVICE_AUTH] Member deleted: groupId=%s, deviceId=%s", groupId, deviceId)
+	events.deviceUnbound(group, member)
+
+	return member, nil
+}
+
+// multiMembersParams 批量添加/删除成员的参数
+type multiMembersParams struct {
+	GroupId    string `json:"groupId"`
+	DeviceList []struct {
+		DeviceId string `json:"deviceId"`
+		Udid     string `json:"udid"`
+		AuthId   string `json:"authId"`
+		UserId   string `json:"userId"`
+	} `json:"deviceList"`
+}
+
+// parseMultiMembersParams 解析批量操作参数
+func parseMultiMembersParams(jsonStr string) (*multiMembersParams, error) {
+	var params multiMembersParams
+	if err := json.Unmarshal([]byte(jsonStr), &params); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if params.GroupId == "" || len(params.DeviceList) == 0 {
+		return nil, fmt.Errorf("groupId and deviceList are required")
+	}
+	for _, device := range params.DeviceList {
+		if device.DeviceId == "" {
+			return nil, fmt.Errorf("deviceId is required in deviceList")
+		}
+	}
+	return &params, nil
+}
+
+// AddMultiMembersToGroup 批量添加具有账户关系的可信设备
+// 参数: {"groupId":"...","deviceList":[{"deviceId":"...","udid":"...","authId":"...","userId":"..."}]}，全部成功或全部不生效
+// 只能添加到账户组，设备的userId缺省为组成员的用户ID，不一致时拒绝；本地需已导入该用户ID的账户凭据
+func (d *deviceGroupManager) AddMultiMembersToGroup(osAccountId int32, appId string, addParams string) error {
+	log.Infof("[DEVICE_AUTH] AddMultiMembersToGroup: osAccountId=%d, appId=%s", osAccountId, appId)
+
+	params, err := parseMultiMembersParams(addParams)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	events := d.beginDataChangeLocked()
+	defer d.unlockAndNotify(events)
+
+	group, err := d.manageableGroupLocked(appId, params.GroupId)
+	if err != nil {
+		return err
+	}
+	if !isAccountGroupType(GroupType(group.GroupType)) {
+		return fmt.Errorf("group is not an account group: %s", params.GroupId)
+	}
+	userId := accountUserId(group)
+	for _, device := range params.DeviceList {
+		if device.UserId != "" && device.UserId != userId {
+			return fmt.Errorf("device %s belongs to user %s, not %s", device.DeviceId, device.UserId, userId)
+		}
+	}
+	if accountAuthCode(userId) == nil {
+		return fmt.Errorf("account credential not found: %s", userId)
+	}
+	if group.Members == nil {
+		group.Members = make(map[string]*DeviceMemberInfo)
+	}
+
+	previous := make(map[string]*DeviceMemberInfo)
+	var added []*DeviceMemberInfo
+	for _, device := range params.DeviceList {
+		member := &DeviceMemberInfo{
+			DeviceID: device.DeviceId,
+			UDID:     device.Udid,
+			AuthID:   device.AuthId,
+			UserID:   userId,
+			JoinTime: time.Now().Unix(),
+		}
+		if member.UDID == "" {
+			member.UDID = member.DeviceID
+		}
+		if _, saved := previous[member.DeviceID]; !saved {
+			previous[member.DeviceID] = group.Members[member.DeviceID]
+		}
+		group.Members[member.DeviceID] = member
+		added = append(added, member)
+	}
+	if err := d.saveLocked(); err != nil {
+		for deviceId, member := range previous {
+			if member != nil {
+				group.Members[deviceId] = member
+			} else {
+				delete(group.Members, deviceId)
+			}
+		}
+		return err
+	}
+	log.Infof("[DEVICE_AUTH] Members added: groupId=%s, count=%d", params.GroupId, len(added))
+	for deviceId, member := range previous {
+		if member != nil && member.UDID != group.Members[deviceId].UDID {
+			events.deviceUnbound(group, member)
+		}
+	}
+	for _, member := range added {
+		events.deviceBound(group, member)
+	}
+
+	return nil
+}
+
+// DelMultiMembersFromGroup 批量删除具有账户关系的可信设备
+// 参数格式同AddMultiMembersToGroup，任一设备不在组中时全部不生效
+func (d *deviceGroupManager) DelMultiMembersFromGroup(osAccountId int32, appId string, deleteParams string) error {
+	log.Infof("[DEVICE_AUTH] DelMultiMembersFromGroup: osAccountId=%d, appId=%s", osAccountId, appId)
+
+	params, err := parseMultiMembersParams(deleteParams)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	events := d.beginDataChangeLocked()
+	defer d.unlockAndNotify(events)
+
+	group, err := d.manageableGroupLocked(appId, params.GroupId)
+	if err != nil {
+		return err
+	}
+
+	removed := make(map[string]*DeviceMemberInfo)
+	for _, device := range params.DeviceList {
+		member, exists := group.Members[device.DeviceId]
+		if !exists {
+			if _, done := removed[device.DeviceId]; done {
+				continue
+			}
+			for deviceId, member := range removed {
+				group.Members[deviceId] = member
+			}
+			return fmt.Errorf("device not found in group: %s", device.DeviceId)
+		}
+		removed[device.DeviceId] = member
+		delete(group.Members, device.DeviceId)
+	}
+	if err := d.saveLocked(); err != nil {
+		for deviceId, member := range removed {
+			group.Members[deviceId] = member
+		}
+		return err
+	}
+	for _, member := range removed {
+		d.releaseMemberKeysLocked(member)
+	}
+	log.Infof("[DEVICE_AUTH] Members deleted: groupId=%s, count=%d", params.GroupId, len(removed))
+	for _, member := range removed {
+		events.deviceUnbound(group, member)
+	}
+
+	return nil
+}
+
+// GetRegisterInfo 获取本地设备的注册信息（stub实现）
+func (d *deviceGroupManager) GetRegisterInfo(reqJsonStr string) (string, error) {
+	log.Infof("[DEVICE_AUTH] GetRegisterInfo called")
+	return "{}", nil
+}
+
+// CheckAccessToGroup 检查指定应用是否具有组的访问权限
+func (d *deviceGroupManager) CheckAccessToGroup(osAccountId int32, appId string, groupId string) error {
+	log.Infof("[DEVICE_AUTH] CheckAccessToGroup: osAccountId=%d, appId=%s, groupId=%s", osAccountId, appId, groupId)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, err := d.readableGroupLocked(appId, groupId)
+	return err
+}
+
+// GetPkInfoList 获取与设备相关的所有公钥信息（stub实现）
+func (d *deviceGroupManager) GetPkInfoList(osAccountId int32, appId string, queryParams string) ([]string, error) {
+	log.Infof("[DEVICE_AUTH] GetPkInfoList: osAccountId=%d, appId=%s", osAccountId, appId)
+	return []string{}, nil
+}
+
+// GetGroupInfoById 获取组的组信息
+func (d *deviceGroupManager) GetGroupInfoById(osAccountId int32, appId string, groupId string) (string, error) {
+	log.Infof("[DEVICE_AUTH] GetGroupInfoById: osAccountId=%d, appId=%s, groupId=%s", osAccountId, appId, groupId)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	group, err := d.readableGroupLocked(appId, groupId)
+	if err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d,"groupVisibility":%d}`,
+		group.GroupID, group.GroupName, group.GroupType, group.Visibility)
+	return result, nil
+}
+
+// GetGroupInfo 获取满足查询参数的组的组信息
+// 查询参数（均可选）: groupId、groupName、groupType、groupOwner（创建组的appId），只返回应用可读取的组
+func (d *deviceGroupManager) GetGroupInfo(osAccountId int32, appId string, queryParams string) ([]string, error) {
+	log.Infof("[DEVICE_AUTH] GetGroupInfo: osAccountId=%d, appId=%s", osAccountId, appId)
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(queryParams), &params); err != nil {
+		return nil, fmt.Errorf("invalid queryParams: %w", err)
+	}
+	groupId, _ := params["groupId"].(string)
+	groupName, _ := params["groupName"].(string)
+	groupOwner, _ := params["groupOwner"].(string)
+	groupType, hasType := params["groupType"].(float64)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	result := []string{}
+	for _, group := range d.groups {
+		if (groupId != "" && group.GroupID != groupId) || (groupName != "" && group.GroupName != groupName) ||
+			(groupOwner != "" && group.OwnerAppId != groupOwner) || (hasType && group.GroupType != int32(groupType)) {
+			continue
+		}
+		if d.checkAccessLocked(appId, group) != nil {
+			continue
+		}
+		result = append(result, fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d,"groupVisibility":%d}`,
+			group.GroupID, group.GroupName, group.GroupType, group.Visibility))
+	}
+	return result, nil
+}
+
+// GetJoinedGroups 获取特定组类型的所有组信息
+func (d *deviceGroupManager) GetJoinedGroups(osAccountId int32, appId string, groupType GroupType) ([]string, error) {
+	log.Infof("[DEVICE_AUTH] GetJoinedGroups: osAccountId=%d, appId=%s, groupType=%d", osAccountId, appId, groupType)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var result []string
+	for _, group := range d.groups {
+		if d.checkAccessLocked(appId, group) != nil {
+			continue
+		}
+		if groupType == AllGroup || GroupType(group.GroupType) == groupType {
+			groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d}`,
+				group.GroupID, group.GroupName, group.GroupType)
+			result = append(result, groupInfo)
+		}
+	}
+
+	return result, nil
+}
+
+// GetRelatedGroups 获取与某个设备相关的所有组信息
+func (d *deviceGroupManager) GetRelatedGroups(osAccountId int32, appId string, peerDeviceId string) ([]string, error) {
+	log.Infof("[DEVICE_AUTH] GetRelatedGroups: osAccountId=%d, appId=%s, peerDeviceId=%s", osAccountId, appId, peerDeviceId)
+
+	peerUdid, ok := appDeviceUdid(appId, peerDeviceId)
+	if !ok {
+		return nil, nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var result []string
+	for _, group := range d.groups {
+		if d.checkAccessLocked(appId, group) != nil {
+			continue
+		}
+		if _, exists := group.Members[peerUdid]; exists {
+			groupInfo := fmt.Sprintf(`{"groupId":"%s","groupName":"%s","groupType":%d}`,
+				group.GroupID, group.GroupName, group.GroupType)
+			result = append(result, groupInfo)
+		}
+	}
+
+	return result, nil
+}
+
+// GetDeviceInfoById 获取可信设备的信息
+func (d *deviceGroupManager) GetDeviceInfoById(osAccountId int32, appId string, deviceId string, groupId string) (string, error) {
+	log.Infof("[DEVICE_AUTH] GetDeviceInfoById: osAccountId=%d, appId=%s, deviceId=%s, groupId=%s",
+		osAccountId, appId, deviceId, groupId)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	group, err := d.readableGroupLocked(appId, groupId)
+	if err != nil {
+		return "", err
+	}
+
+	peerUdid, ok := appDeviceUdid(appId, deviceId)
+	member, exists := group.Members[peerUdid]
+	if !ok || !exists {
+		return "", fmt.Errorf("device not found: %s", deviceId)
+	}
+	return memberInfoForApp(appId, member)
+}
+
+// GetTrustedDevices 获取组中的所有可信设备信息
+func (d *deviceGroupManager) GetTrustedDevices(osAccountId int32, appId string, groupId string) ([]string, error) {
+	log.Infof("[DEVICE_AUTH] GetTrustedDevices: osAccountId=%d, appId=%s, groupId=%s", osAccountId, appId, groupId)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	group, err := d.readableGroupLocked(appId, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, member := range group.Members {
+		deviceInfo, err := memberInfoForApp(appId, member)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, deviceInfo)
+	}
+
+	return result, nil
+}
+
+// IsDeviceInGroup 查询组中是否存在指定设备
+func (d *deviceGroupManager) IsDeviceInGroup(osAccountId int32, appId string, groupId string, deviceId string) bool {
+	log.Infof("[DEVICE_AUTH] IsDeviceInGroup: osAccountId=%d, appId=%s, groupId=%s, deviceId=%s",
+		osAccountId, appId, groupId, deviceId)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	group, err := d.readableGroupLocked(appId, groupId)
+	if err != nil {
+		return false
+	}
+
+	peerUdid, ok := appDeviceUdid(appId, deviceId)
+	if !ok {
+		return false
+	}
+	_, exists := group.Members[peerUdid]
+	return exists
+}
+
+// memberInfoForApp 生成应用可见的设备信息，AUTH_APPID以外的应用看到的设备标识都是该应用的假名ID
+func memberInfoForApp(appId string, member *DeviceMemberInfo) (string, error) {
+	ids := []string{member.DeviceID, member.UDID, member.AuthID}
+	for i, id := range ids {
+		if id == "" {
+			continue
+		}
+		visible, err := appVisibleUdid(appId, id)
+		if err != nil {
+			return "", err
+		}
+		ids[i] = visible
+	}
+	return fmt.Sprintf(`{"deviceId":"%s","udid":"%s","authId":"%s"}`, ids[0], ids[1], ids[2]), nil
+}
+
+// CancelRequest 取消绑定或解绑过程
+// 以bindMsgError通知对端后结束请求，并通过OnError上报HC_ERR_REQUEST_CANCELLED
+func (d *deviceGroupManager) CancelRequest(requestId int64, appId string) {
+	log.Infof("[DEVICE_AUTH] CancelRequest: requestId=%d, appId=%s", requestId, appId)
+
+	session := d.getBindSession(requestId)
+	if session == nil {
+		log.Warnf("[DEVICE_AUTH] No bind request in progress: requestId=%d", requestId)
+		return
+	}
+	if session.appId != appId {
+		log.Warnf("[DEVICE_AUTH] Cancel denied: requestId=%d, appId=%s, owner=%s", requestId, appId, session.appId)
+		return
+	}
+	d.failBind(session, HC_ERR_REQUEST_CANCELLED, ErrRequestCancelled, true)
+}
+
+// DestroyInfo 销毁内部分配的内存返回的信息（stub实现）
+func (d *deviceGroupManager) DestroyInfo(returnInfo *string) {
+	// Stub实现：Go有垃圾回收，不需要手动释放
+	if returnInfo != nil {
+		*returnInfo = ""
+	}
+}
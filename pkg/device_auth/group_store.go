@@ -0,0 +1,162 @@
+package device_auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/storage"
+)
+
+// ============================================================================
+// 可信组持久化存储
+// ============================================================================
+//
+// 可信组保存在 <dataDir>/device_groups.json，原子写入，权限0600。
+// 文件带有version字段，加载时按groupStoreMigrations逐级升级到当前版本，
+// 升级后立即以当前版本重写；高于当前版本的文件拒绝加载，避免旧程序破坏新格式。
+
+const (
+	groupStoreFileName = "device_groups.json"
+	groupStoreVersion  = 1 // 当前存储格式版本
+)
+
+// groupStoreFile 可信组文件内容
+type groupStoreFile struct {
+	Version int          `json:"version"`
+	Groups  []*GroupInfo `json:"groups"`
+}
+
+// groupStoreMigrations 存储格式升级函数，key为源版本，升级到key+1
+var groupStoreMigrations = map[int]func(data []byte) ([]byte, error){
+	0: migrateGroupStoreV0,
+}
+
+// groupStore 可信组文件存储
+type groupStore struct {
+	path string
+}
+
+// newGroupStore 创建可信组文件存储
+func newGroupStore(dataDir string) (*groupStore, error) {
+	if dataDir == "" {
+		return nil, fmt.Errorf("data dir is empty")
+	}
+	if err := storage.EnsureDir(dataDir); err != nil {
+		return nil, err
+	}
+	return &groupStore{path: filepath.Join(dataDir, groupStoreFileName)}, nil
+}
+
+// load 加载可信组
+// 返回: 可信组、是否经过格式升级（需要重写文件）、错误信息
+func (s *groupStore) load() (map[string]*GroupInfo, bool, error) {
+	groups := make(map[string]*GroupInfo)
+
+	data, err := storage.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return groups, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, migrated, err := migrateGroupStore(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var content groupStoreFile
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, false, fmt.Errorf("failed to parse group store: %w", err)
+	}
+	for _, group := range content.Groups {
+		if group == nil || group.GroupID == "" {
+			continue
+		}
+		if group.Members == nil {
+			group.Members = make(map[string]*DeviceMemberInfo)
+		}
+		groups[group.GroupID] = group
+	}
+	return groups, migrated, nil
+}
+
+// save 原子写入可信组（按groupId排序，保证输出稳定）
+func (s *groupStore) save(groups map[string]*GroupInfo) error {
+	content := &groupStoreFile{
+		Version: groupStoreVersion,
+		Groups:  make([]*GroupInfo, 0, len(groups)),
+	}
+	for _, group := range groups {
+		content.Groups = append(content.Groups, group)
+	}
+	sort.Slice(content.Groups, func(i, j int) bool {
+		return content.Groups[i].GroupID < content.Groups[j].GroupID
+	})
+
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal groups: %w", err)
+	}
+	return storage.WriteFileAtomic(s.path, data)
+}
+
+// migrateGroupStore 将文件内容逐级升级到当前版本
+func migrateGroupStore(data []byte) ([]byte, bool, error) {
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, false, fmt.Errorf("failed to parse group store: %w", err)
+	}
+
+	// 没有version字段的是版本0
+	version := 0
+	if header.Version != nil {
+		version = *header.Version
+	}
+	if version > groupStoreVersion {
+		return nil, false, fmt.Errorf("unsupported group store version: %d (max %d)", version, groupStoreVersion)
+	}
+
+	migrated := false
+	for version < groupStoreVersion {
+		migrate, ok := groupStoreMigrations[version]
+		if !ok {
+			return nil, false, fmt.Errorf("no migration from group store version %d", version)
+		}
+		var err error
+		if data, err = migrate(data); err != nil {
+			return nil, false, fmt.Errorf("failed to migrate group store from version %d: %w", version, err)
+		}
+		log.Infof("[DEVICE_AUTH] Group store migrated: version %d -> %d", version, version+1)
+		version++
+		migrated = true
+	}
+	return data, migrated, nil
+}
+
+// migrateGroupStoreV0 版本0为不带版本号的groupId -> GroupInfo映射
+func migrateGroupStoreV0(data []byte) ([]byte, error) {
+	var legacy map[string]*GroupInfo
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+
+	content := &groupStoreFile{Version: 1, Groups: make([]*GroupInfo, 0, len(legacy))}
+	for groupId, group := range legacy {
+		if group == nil {
+			continue
+		}
+		if group.GroupID == "" {
+			group.GroupID = groupId
+		}
+		content.Groups = append(content.Groups, group)
+	}
+	return json.Marshal(content)
+}
@@ -0,0 +1,112 @@
+package device_auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/storage"
+)
+
+// 测试可信组持久化：重启（重新初始化服务）后可信组和成员仍然存在
+func TestGroupStore_Persistence(t *testing.T) {
+	dir := t.TempDir()
+
+	InitDeviceAuthService()
+	if err := EnableGroupStore(dir); err != nil {
+		t.Fatalf("EnableGroupStore failed: %v", err)
+	}
+	gm, _ := GetGmInstance()
+	if err := gm.CreateGroup(AnyOsAccount, 1, "test_app", `{"groupId":"PERSIST_001","groupName":"Persist","groupType":256}`); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	if err := gm.CreateGroup(AnyOsAccount, 2, "test_app", `{"groupId":"PERSIST_001","groupName":"Persist","groupType":256}`); err == nil {
+		t.Error("Expected error for duplicate group")
+	}
+	gm.AddMemberToGroup(AnyOsAccount, 3, "test_app", `{"groupId":"PERSIST_001","deviceId":"peer-001","udid":"udid-001"}`)
+	DestroyDeviceAuthService()
+
+	if err := storage.CheckPermission(filepath.Join(dir, groupStoreFileName), storage.FilePerm); err != nil {
+		t.Errorf("Unexpected group store permission: %v", err)
+	}
+
+	InitDeviceAuthService()
+	defer DestroyDeviceAuthService()
+	if err := EnableGroupStore(dir); err != nil {
+		t.Fatalf("EnableGroupStore after restart failed: %v", err)
+	}
+	gm, _ = GetGmInstance()
+	if !gm.IsDeviceInGroup(AnyOsAccount, "test_app", "PERSIST_001", testAppDeviceId(t, "test_app", "peer-001")) {
+		t.Fatal("Expected member to survive restart")
+	}
+	groups, _ := gm.GetRelatedGroups(AnyOsAccount, "test_app", testAppDeviceId(t, "test_app", "peer-001"))
+	if len(groups) != 1 {
+		t.Errorf("Expected 1 related group, got %d", len(groups))
+	}
+
+	// 删除成员同样落盘
+	gm.DeleteMemberFromGroup(AnyOsAccount, 4, "test_app", `{"groupId":"PERSIST_001","deviceId":"peer-001"}`)
+	store, _ := newGroupStore(dir)
+	loaded, _, err := store.load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(loaded["PERSIST_001"].Members) != 0 {
+		t.Error("Expected member deletion to be persisted")
+	}
+}
+
+// 测试存储格式升级：版本0（无版本号的映射）升级到当前版本，未知的新版本拒绝加载
+func TestGroupStore_Migration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, groupStoreFileName)
+
+	legacy := `{"LEGACY_001":{"groupName":"Legacy","groupType":256,"members":{"peer-002":{"deviceId":"peer-002","udid":"udid-002"}}}}`
+	if err := os.WriteFile(path, []byte(legacy), storage.FilePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	store, _ := newGroupStore(dir)
+	groups, migrated, err := store.load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if !migrated {
+		t.Error("Expected legacy store to be migrated")
+	}
+	group := groups["LEGACY_001"]
+	if group == nil || group.GroupID != "LEGACY_001" || group.Members["peer-002"] == nil {
+		t.Fatalf("Unexpected migrated group: %+v", group)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version":99,"groups":[]}`), storage.FilePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.load(); err == nil {
+		t.Error("Expected error for unsupported version")
+	}
+}
+
+// 测试解绑：设备不再属于任何可信组时删除其长期公钥
+func TestDeleteMemberReleasesPeerKey(t *testing.T) {
+	InitDeviceAuthService()
+	defer DestroyDeviceAuthService()
+
+	gm, _ := GetGmInstance()
+	gm.CreateGroup(AnyOsAccount, 1, "test_app", `{"groupId":"UNBIND_001","groupType":256}`)
+	gm.CreateGroup(AnyOsAccount, 2, "test_app", `{"groupId":"UNBIND_002","groupType":256}`)
+	gm.AddMemberToGroup(AnyOsAccount, 3, "test_app", `{"groupId":"UNBIND_001","deviceId":"unbind-peer"}`)
+	gm.AddMemberToGroup(AnyOsAccount, 4, "test_app", `{"groupId":"UNBIND_002","deviceId":"unbind-peer"}`)
+	hichain.SaveDeviceAuthInfo("unbind-peer", make([]byte, 32))
+
+	gm.DeleteMemberFromGroup(AnyOsAccount, 5, "test_app", `{"groupId":"UNBIND_001","deviceId":"unbind-peer"}`)
+	if hichain.GetDeviceAuthInfo("unbind-peer") == nil {
+		t.Error("Expected peer key kept while still in another group")
+	}
+
+	gm.DeleteGroup(AnyOsAccount, 6, "test_app", `{"groupId":"UNBIND_002"}`)
+	if hichain.GetDeviceAuthInfo("unbind-peer") != nil {
+		t.Error("Expected peer key removed after unbind")
+	}
+}
@@ -0,0 +1,104 @@
+package hichain
+
+import (
+	"errors"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 错误码
+// ============================================================================
+//
+// 认证失败时，失败方以MsgTypeError（errorCode为本端错误码）通知对端，并通过SetServiceResult上报同一错误码；
+// 对端收到MsgTypeError后不再回复，按peerErrorCode转换后通过SetServiceResult上报：
+//   - 本端校验对端确认数据失败（PIN码错误）: HCPinMismatch，双方均上报HCPinMismatch
+//   - 版本不兼容: HCVersionUnsupported
+//   - 被配对方处于锁定期: HCLockedOut；业务拒绝配对: HCRejected
+//   - 取消方不上报（由取消方自行处理），对端上报HCPeerCancelled
+//   - 超时方上报HCTimeout，对端同样上报HCTimeout
+//   - 其他错误: HCAuthFailed
+
+var (
+	// ErrVersionUnsupported 对端要求的最低版本高于本端版本
+	ErrVersionUnsupported = errors.New("协议版本不兼容")
+	// ErrRequestRejected 业务拒绝配对请求
+	ErrRequestRejected = errors.New("配对请求被拒绝")
+	// ErrLockedOut 配对失败次数过多，处于锁定期
+	ErrLockedOut = errors.New("配对请求处于锁定期")
+
+	// errUnknownMessage 未知消息类型（不结束认证）
+	errUnknownMessage = errors.New("未知消息类型")
+)
+
+// ErrorCodeOf 本端处理失败时发送给对端并上报的错误码
+func ErrorCodeOf(err error) int32 {
+	switch {
+	case errors.Is(err, ErrProofMismatch):
+		return HCPinMismatch
+	case errors.Is(err, ErrVersionUnsupported):
+		return HCVersionUnsupported
+	case errors.Is(err, ErrLockedOut):
+		return HCLockedOut
+	case errors.Is(err, ErrRequestRejected):
+		return HCRejected
+	default:
+		return HCAuthFailed
+	}
+}
+
+// peerErrorCode 对端通过MsgTypeError报告的错误码转换为本端上报的错误码
+func peerErrorCode(code int) int32 {
+	switch code {
+	case HCCancelled:
+		return HCPeerCancelled
+	case HCPinMismatch, HCVersionUnsupported, HCTimeout, HCLockedOut, HCRejected:
+		return int32(code)
+	default:
+		return HCAuthFailed
+	}
+}
+
+// fail 认证失败：以MsgTypeError通知对端，并通过SetServiceResult上报错误码
+// 认证已结束时不重复通知和上报
+func (h *HiChainHandle) fail(code int32, err error) error {
+	if h.state == StateCompleted || h.state == StateFailed {
+		return err
+	}
+	log.Errorf("[HICHAIN] ✗ 认证失败：会话=%d，errorCode=%d，%v", h.identity.SessionID, code, err)
+	h.notifyPeerError(code)
+	h.callback.SetServiceResult(h.identity, code)
+	return err
+}
+
+// notifyPeerError 实例进入失败状态，并以MsgTypeError通知对端
+// 发起方尚未发出请求时对端不存在该认证，不发送消息
+func (h *HiChainHandle) notifyPeerError(code int32) error {
+	notifyPeer := h.state != StateInit || h.deviceType == HCAccessory
+	h.state = StateFailed
+	if !notifyPeer {
+		return nil
+	}
+	return h.sendMessage(&AuthMessage{
+		MessageType: MsgTypeError,
+		RequestID:   h.requestID,
+		ErrorCode:   int(code),
+	})
+}
+
+// handlePeerError 处理对端的错误消息：结束认证并上报对端的错误码，不回复
+func (h *HiChainHandle) handlePeerError(msg *AuthMessage) error {
+	code := msg.ErrorCode
+	if code == 0 && msg.Payload != nil {
+		code = msg.Payload.ErrorCode
+	}
+	if h.state == StateCompleted || h.state == StateFailed {
+		log.Warnf("[HICHAIN] 认证已结束，忽略错误消息：会话=%d，errorCode=%d", h.identity.SessionID, code)
+		return nil
+	}
+
+	log.Errorf("[HICHAIN] ✗ 对端报告错误：会话=%d，errorCode=%d", h.identity.SessionID, code)
+	h.state = StateFailed
+	h.callback.SetServiceResult(h.identity, peerErrorCode(code))
+	return nil
+}
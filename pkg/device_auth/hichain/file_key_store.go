@@ -0,0 +1,183 @@
+package hichain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/storage"
+)
+
+// ============================================================================
+// 基于文件的长期密钥存储
+// ============================================================================
+//
+// 密钥保存在 <dataDir>/hichain_keys 目录:
+//   - 本地身份: local_<sha256(deviceID)>.key
+//   - 对端公钥: peer_<sha256(deviceID)>.key
+// 文件内容使用本地包装密钥加密（AES-256-GCM，AAD绑定记录类型和设备ID），原子写入，权限0600。
+
+const (
+	keyStoreDirName     = "hichain_keys"
+	keyStoreFileVersion = 1
+	keyStoreWrapPurpose = "hichain_key"
+	localKeyPrefix      = "local_"
+	peerKeyPrefix       = "peer_"
+	keyFileExt          = ".key"
+)
+
+// keyFile 密钥文件内容
+type keyFile struct {
+	Version    int    `json:"version"`
+	DeviceID   string `json:"deviceId"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	PublicKey  string `json:"publicKey"`
+}
+
+// FileKeyStore 基于文件的长期密钥存储
+type FileKeyStore struct {
+	dir     string     // 密钥目录
+	wrapKey []byte     // 文件加密密钥
+	mu      sync.Mutex // 串行化文件读写
+}
+
+// NewFileKeyStore 创建基于文件的长期密钥存储
+// 参数：
+//   - dataDir：数据目录
+//
+// 返回：
+//   - 密钥存储
+//   - 错误信息
+func NewFileKeyStore(dataDir string) (*FileKeyStore, error) {
+	if dataDir == "" {
+		return nil, fmt.Errorf("data dir is empty")
+	}
+
+	wrapKey, err := storage.LoadOrCreateWrapKey(dataDir, keyStoreWrapPurpose)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wrap key: %w", err)
+	}
+
+	dir := filepath.Join(dataDir, keyStoreDirName)
+	if err := storage.EnsureDir(dir); err != nil {
+		return nil, err
+	}
+
+	return &FileKeyStore{dir: dir, wrapKey: wrapKey}, nil
+}
+
+// LoadLocalKeyPair 加载本地长期密钥对
+func (s *FileKeyStore) LoadLocalKeyPair(deviceID string) ([]byte, []byte, error) {
+	content, err := s.read(localKeyPrefix, deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := hex.DecodeString(content.PrivateKey)
+	if err != nil || len(privateKey) != ed25519.PrivateKeySize {
+		return nil, nil, fmt.Errorf("invalid local private key: deviceID=%s", deviceID)
+	}
+	publicKey, err := hex.DecodeString(content.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, nil, fmt.Errorf("invalid local public key: deviceID=%s", deviceID)
+	}
+	return privateKey, publicKey, nil
+}
+
+// SaveLocalKeyPair 保存本地长期密钥对
+func (s *FileKeyStore) SaveLocalKeyPair(deviceID string, privateKey []byte, publicKey []byte) error {
+	return s.write(localKeyPrefix, &keyFile{
+		Version:    keyStoreFileVersion,
+		DeviceID:   deviceID,
+		PrivateKey: hex.EncodeToString(privateKey),
+		PublicKey:  hex.EncodeToString(publicKey),
+	})
+}
+
+// LoadPeerPublicKey 加载对端公钥
+func (s *FileKeyStore) LoadPeerPublicKey(deviceID string) ([]byte, error) {
+	content, err := s.read(peerKeyPrefix, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := hex.DecodeString(content.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid peer public key: deviceID=%s", deviceID)
+	}
+	return publicKey, nil
+}
+
+// SavePeerPublicKey 保存对端公钥
+func (s *FileKeyStore) SavePeerPublicKey(deviceID string, publicKey []byte) error {
+	return s.write(peerKeyPrefix, &keyFile{
+		Version:   keyStoreFileVersion,
+		DeviceID:  deviceID,
+		PublicKey: hex.EncodeToString(publicKey),
+	})
+}
+
+// DeletePeer 删除对端公钥
+func (s *FileKeyStore) DeletePeer(deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(peerKeyPrefix, deviceID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// read 读取并解密密钥文件，不存在时返回ErrKeyNotFound
+func (s *FileKeyStore) read(prefix string, deviceID string) (*keyFile, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("device id is empty")
+	}
+
+	s.mu.Lock()
+	data, err := storage.ReadSealedFile(s.path(prefix, deviceID), s.wrapKey, []byte(prefix+deviceID))
+	s.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content keyFile
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+	if content.Version != keyStoreFileVersion || content.DeviceID != deviceID {
+		return nil, fmt.Errorf("unexpected key file: version=%d", content.Version)
+	}
+	return &content, nil
+}
+
+// write 加密并原子写入密钥文件
+func (s *FileKeyStore) write(prefix string, content *keyFile) error {
+	if content.DeviceID == "" {
+		return fmt.Errorf("device id is empty")
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return storage.WriteSealedFile(s.path(prefix, content.DeviceID), s.wrapKey, data, []byte(prefix+content.DeviceID))
+}
+
+// path 密钥文件路径（设备ID哈希后作为文件名，避免路径注入）
+func (s *FileKeyStore) path(prefix string, deviceID string) string {
+	sum := sha256.Sum256([]byte(deviceID))
+	return filepath.Join(s.dir, prefix+hex.EncodeToString(sum[:])+keyFileExt)
+}
@@ -1,6 +1,7 @@
 package hichain
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -90,8 +91,19 @@ func (h *HiChainHandle) ReceiveData(data []byte) error {
 		return err
 	}
 
-	// 根据消息类型处理
-	// 注意：MsgTypePakeRequest==MsgTypeAuthStart==1, MsgTypePakeClientConfirm==MsgTypeAuthChallenge==2
+	// 处理失败时通知对端并上报错误码（未知消息不影响进行中的认证）
+	if err := h.handleMessage(msg, cleanedData); err != nil {
+		if errors.Is(err, errUnknownMessage) {
+			return err
+		}
+		return h.fail(ErrorCodeOf(err), err)
+	}
+	return nil
+}
+
+// handleMessage 根据消息类型分发处理
+// 注意：MsgTypePakeRequest==MsgTypeAuthStart==1, MsgTypePakeClientConfirm==MsgTypeAuthChallenge==2
+func (h *HiChainHandle) handleMessage(msg *AuthMessage, cleanedData []byte) error {
 	switch msg.MessageType {
 	case 1: // MsgTypePakeRequest / MsgTypeAuthStart
 		log.Infof("[HICHAIN] 收到认证请求消息（type=%d）", msg.MessageType)
@@ -116,21 +128,45 @@ func (h *HiChainHandle) ReceiveData(data []byte) error {
 
 	case MsgTypePakeResponse:
 		log.Infof("[HICHAIN] 收到PAKE响应消息（PAKE_RESPONSE）")
+		if h.deviceType == HCController {
+			return h.handlePakeResponse(msg)
+		}
 		return h.handleAuthChallenge(msg)
 
 	case MsgTypePakeServerConfirm:
 		log.Infof("[HICHAIN] 收到PAKE服务端确认消息（PAKE_SERVER_CONFIRM）")
+		if h.deviceType == HCController {
+			return h.handlePakeServerConfirm(msg)
+		}
 		return h.handleAuthConfirm(msg)
 
+	case MsgTypePakeExchangeResp:
+		log.Infof("[HICHAIN] 收到PAKE EXCHANGE响应（PAKE_EXCHANGE_RESPONSE）")
+		return h.handleExchangeResponse(msg)
+
+	case MsgTypeIsoStart:
+		log.Infof("[HICHAIN] 收到基于长期密钥的认证请求（ISO_START）")
+		return h.handleIsoStart(msg)
+
+	case MsgTypeIsoResponse:
+		log.Infof("[HICHAIN] 收到基于长期密钥的认证响应（ISO_RESPONSE）")
+		return h.handleIsoResponse(msg)
+
+	case MsgTypeIsoClientConfirm:
+		log.Infof("[HICHAIN] 收到客户端确认（ISO_CLIENT_CONFIRM）")
+		return h.handleIsoClientConfirm(msg)
+
+	case MsgTypeIsoServerConfirm:
+		log.Infof("[HICHAIN] 收到服务端确认（ISO_SERVER_CONFIRM）")
+		return h.handleIsoServerConfirm(msg)
+
 	case MsgTypeError:
-		log.Errorf("[HICHAIN] ✗ 收到错误消息：errorCode=%d", msg.ErrorCode)
-		h.state = StateFailed
-		h.callback.SetServiceResult(h.identity, HCAuthFailed)
-		return fmt.Errorf("HiChain认证错误：errorCode=%d", msg.ErrorCode)
+		log.Infof("[HICHAIN] 收到错误消息（ERROR）")
+		return h.handlePeerError(msg)
 
 	default:
 		log.Errorf("[HICHAIN] ✗ 未知消息类型：%d (0x%x)", msg.MessageType, msg.MessageType)
-		return fmt.Errorf("未知消息类型：%d", msg.MessageType)
+		return fmt.Errorf("%w：%d", errUnknownMessage, msg.MessageType)
 	}
 }
 
@@ -153,6 +189,30 @@ func (h *HiChainHandle) StartAuth() error {
 	return h.startAuthentication()
 }
 
+// Cancel 取消进行中的认证：以MsgTypeError（HCCancelled）通知对端，实例进入失败状态
+// 不调用SetServiceResult，由取消方自行上报结果
+// 返回：
+//   - 错误（若通知对端失败；认证已结束或发起方尚未发出请求时不发送消息）
+func (h *HiChainHandle) Cancel() error {
+	return h.Abort(HCCancelled)
+}
+
+// Abort 以指定错误码结束进行中的认证（如上层超时）：通知对端，实例进入失败状态
+// 与Cancel相同，不调用SetServiceResult，由调用方自行上报结果
+// 返回：
+//   - 错误（若通知对端失败；认证已结束或发起方尚未发出请求时不发送消息）
+func (h *HiChainHandle) Abort(code int32) error {
+	if h == nil {
+		return fmt.Errorf("无效的句柄")
+	}
+	if h.state == StateCompleted || h.state == StateFailed {
+		return nil
+	}
+
+	log.Infof("[HICHAIN] 结束会话 %d 的认证：errorCode=%d", h.identity.SessionID, code)
+	return h.notifyPeerError(code)
+}
+
 // GetState 返回当前认证状态
 // 返回：
 //   - 当前状态（StateInit/StateStarted等，若句柄无效则返回StateFailed）
@@ -172,3 +232,43 @@ func (h *HiChainHandle) GetSessionKey() []byte {
 	}
 	return h.sessionKey
 }
+
+// GetDeviceType 返回设备类型
+// 返回：
+//   - HCAccessory或HCController（若句柄无效则返回-1）
+func (h *HiChainHandle) GetDeviceType() int {
+	if h == nil {
+		return -1
+	}
+	return h.deviceType
+}
+
+// GetPeerAuthID 返回对端认证ID（对端设备UDID）
+// 返回：
+//   - 对端认证ID（若句柄无效或尚未确定则返回空字符串）
+func (h *HiChainHandle) GetPeerAuthID() string {
+	if h == nil {
+		return ""
+	}
+	return h.peerAuthID
+}
+
+// GetPeerAuthForm 返回对端在PAKE_REQUEST中请求的认证形式（服务端）
+// 返回：
+//   - AuthFormXXX（若句柄无效则返回AuthFormInvalid）
+func (h *HiChainHandle) GetPeerAuthForm() int {
+	if h == nil {
+		return AuthFormInvalid
+	}
+	return h.peerAuthForm
+}
+
+// GetPeerUserID 返回对端在PAKE_REQUEST中声明的用户ID（服务端）
+// 返回：
+//   - 对端用户ID（若句柄无效或非账户认证则返回空字符串）
+func (h *HiChainHandle) GetPeerUserID() string {
+	if h == nil {
+		return ""
+	}
+	return h.peerUserID
+}
@@ -1,9 +1,72 @@
 package hichain
 
 import (
+	"encoding/json"
 	"testing"
 )
 
+// testAuthProtocol 认证测试使用的协议
+type testAuthProtocol int
+
+const (
+	testProtocolIso  testAuthProtocol = iota // 基于长期密钥的ISO认证（StartKeyAuth）
+	testProtocolPake                         // 基于PIN码的PAKE认证（StartPake）
+)
+
+// hcTestPeer 认证测试中的一端
+type hcTestPeer struct {
+	handle     *HiChainHandle
+	remote     *HiChainHandle
+	rewrite    func(msg *AuthMessage) // 发送前修改消息（模拟旧版本对端）
+	confirm    int32                  // ConfirmReceiveRequest的返回值
+	sessionKey []byte
+	result     int32
+}
+
+// newTestPeer 创建使用protocol认证的测试端，发送的数据直接交给remote处理
+// pinCode仅用于PAKE
+func newTestPeer(t *testing.T, protocol testAuthProtocol, deviceType int, selfID string, peerID string, pinCode string) *hcTestPeer {
+	t.Helper()
+	params := &ProtocolParams{KeyLength: SessionKeyLength, SelfAuthID: selfID, PeerAuthID: peerID}
+	if protocol == testProtocolPake {
+		params.PinCode = pinCode
+	}
+
+	peer := &hcTestPeer{confirm: HCOk, result: -1}
+	callback := &HCCallBack{
+		OnTransmit: func(identity *SessionIdentity, data []byte) error {
+			if peer.rewrite != nil {
+				msg, _ := unpackMessage(data)
+				peer.rewrite(msg)
+				data, _ = json.Marshal(msg)
+			}
+			return peer.remote.ReceiveData(data)
+		},
+		GetProtocolParams: func(identity *SessionIdentity, operationCode int32) (*ProtocolParams, error) {
+			return params, nil
+		},
+		SetSessionKey: func(identity *SessionIdentity, sessionKey *SessionKey) error {
+			peer.sessionKey = sessionKey.Key
+			return nil
+		},
+		SetServiceResult: func(identity *SessionIdentity, result int32) error {
+			peer.result = result
+			return nil
+		},
+		ConfirmReceiveRequest: func(identity *SessionIdentity, operationCode int32) int32 {
+			return peer.confirm
+		},
+	}
+
+	identity := &SessionIdentity{SessionID: uint32(protocol) + 1, PackageName: "test", ServiceType: "test", OperationCode: OpCodeAuthenticate}
+	handle, err := GetInstance(identity, deviceType, callback)
+	if err != nil {
+		t.Fatalf("GetInstance failed: %v", err)
+	}
+	peer.handle = handle
+	return peer
+}
+
 // TestSessionIdentity 测试SessionIdentity结构体初始化
 func TestSessionIdentity(t *testing.T) {
 	identity := &SessionIdentity{
@@ -0,0 +1,383 @@
+package hichain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/crypto"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 基于长期密钥的认证（标准绑定交换/ISO风格）
+// ============================================================================
+//
+// 已绑定的设备（EXCHANGE阶段交换过ED25519长期公钥）之间不再使用PIN码:
+//   1. ISO_START          (C→S): authId_C, challenge_C, epk_C
+//   2. ISO_RESPONSE       (S→C): authId_S, challenge_S, epk_S, Sign_S(transcript)
+//   3. ISO_CLIENT_CONFIRM (C→S): Sign_C(transcript)
+//   4. ISO_SERVER_CONFIRM (S→C): 认证结果
+// transcript = 角色标签 || challenge_C || challenge_S || epk_C || epk_S || authId_C || authId_S（每个字段前加4字节大端长度）
+// 会话密钥 = HKDF(X25519(esk, epk_peer), challenge_C || challenge_S, "hichain_iso_session_key")
+// 长期密钥只用于签名，会话密钥由临时X25519密钥协商，保证前向安全。
+// 服务端只接受保存了长期公钥的对端（解绑时公钥被删除），并在应答前经ConfirmReceiveRequest由业务确认。
+
+// 基于长期密钥认证的消息类型
+const (
+	MsgTypeIsoStart         = 0x0011 // 客户端发起 (17)
+	MsgTypeIsoResponse      = 0x8011 // 服务端响应 (32785)
+	MsgTypeIsoClientConfirm = 0x0012 // 客户端确认 (18)
+	MsgTypeIsoServerConfirm = 0x8012 // 服务端确认 (32786)
+)
+
+const (
+	isoChallengeLen    = 16
+	isoServerSignLabel = "hichain_iso_server"
+	isoClientSignLabel = "hichain_iso_client"
+	isoSessionKeyInfo  = "hichain_iso_session_key"
+)
+
+var (
+	// ErrPeerKeyNotFound 对端长期公钥不存在（设备未绑定）
+	ErrPeerKeyNotFound = errors.New("对端长期公钥不存在")
+
+	// ErrSignatureInvalid 对端长期密钥签名校验失败
+	ErrSignatureInvalid = errors.New("长期密钥签名校验失败")
+)
+
+// StartKeyAuth 使用长期密钥发起认证（作为发起方）
+// 参数：
+//   - peerAuthID：对端认证ID（GetProtocolParams未提供时使用）
+//
+// 返回：
+//   - 错误（未保存对端长期公钥时返回ErrPeerKeyNotFound）
+func (h *HiChainHandle) StartKeyAuth(peerAuthID string) error {
+	if h == nil {
+		return fmt.Errorf("无效的句柄")
+	}
+	if h.state != StateInit {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	h.keyAuth = true
+
+	params, err := h.callback.GetProtocolParams(h.identity, OpCodeAuthenticate)
+	if err != nil {
+		return err
+	}
+	h.selfAuthID = params.SelfAuthID
+	h.peerAuthID = params.PeerAuthID
+	if h.peerAuthID == "" {
+		h.peerAuthID = peerAuthID
+	}
+	if h.selfAuthID == "" || h.peerAuthID == "" {
+		return fmt.Errorf("设备ID未设置：self=%s, peer=%s", h.selfAuthID, h.peerAuthID)
+	}
+
+	if err := h.loadIsoKeys(); err != nil {
+		return err
+	}
+	if err := h.generateIsoEphemeral(); err != nil {
+		return err
+	}
+
+	log.Infof("[HICHAIN] 发起基于长期密钥的认证：对端=%s", h.peerAuthID)
+
+	msg := &AuthMessage{
+		MessageType: MsgTypeIsoStart,
+		SessionID:   h.identity.SessionID,
+		Payload: &PakePayload{
+			AuthID:        bytesToHex([]byte(h.selfAuthID)),
+			Challenge:     bytesToHex(h.ourChallenge),
+			Epk:           bytesToHex(h.pakeEpk),
+			OperationCode: OpCodeAuthenticate,
+		},
+	}
+	h.state = StateStarted
+	if err := h.sendMessage(msg); err != nil {
+		h.state = StateFailed
+		return err
+	}
+	return nil
+}
+
+// UsesKeyAuth 是否使用基于长期密钥的认证
+func (h *HiChainHandle) UsesKeyAuth() bool {
+	if h == nil {
+		return false
+	}
+	return h.keyAuth
+}
+
+// handleIsoStart 处理ISO_START（服务端）
+func (h *HiChainHandle) handleIsoStart(msg *AuthMessage) error {
+	log.Infof("[HICHAIN] 处理ISO_START")
+
+	// 只接受新会话的ISO_START，进行中或已结束的会话不能被重新开始
+	if h.state != StateInit {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	h.keyAuth = true
+	h.requestID = msg.RequestID
+
+	if msg.Payload == nil {
+		return h.failKeyAuth(fmt.Errorf("payload为空"))
+	}
+	peerID, err := hexToBytes(msg.Payload.AuthID)
+	if err != nil || len(peerID) == 0 {
+		return h.failKeyAuth(fmt.Errorf("解析客户端authId失败"))
+	}
+	h.peerAuthID = string(peerID)
+	if h.peerChallenge, err = hexToBytes(msg.Payload.Challenge); err != nil || len(h.peerChallenge) != isoChallengeLen {
+		return h.failKeyAuth(fmt.Errorf("解析客户端challenge失败"))
+	}
+	if h.pakePeerEpk, err = hexToBytes(msg.Payload.Epk); err != nil || len(h.pakePeerEpk) != 32 {
+		return h.failKeyAuth(fmt.Errorf("解析客户端epk失败"))
+	}
+
+	// 由业务确认是否接受请求：锁定期、对端是否仍为可信设备、业务审批
+	// 保存了对端长期公钥不代表仍然绑定，是否接受以业务的可信关系为准
+	if h.callback.ConfirmReceiveRequest != nil {
+		switch ret := h.callback.ConfirmReceiveRequest(h.identity, OpCodeAuthenticate); ret {
+		case HCOk:
+		case HCLockedOut:
+			return h.failKeyAuth(fmt.Errorf("%w：对端=%s", ErrLockedOut, h.peerAuthID))
+		default:
+			return h.failKeyAuth(fmt.Errorf("%w：对端=%s", ErrRequestRejected, h.peerAuthID))
+		}
+	}
+
+	params, err := h.callback.GetProtocolParams(h.identity, OpCodeAuthenticate)
+	if err != nil {
+		return h.failKeyAuth(err)
+	}
+	h.selfAuthID = params.SelfAuthID
+	if h.selfAuthID == "" {
+		return h.failKeyAuth(fmt.Errorf("服务器设备ID未设置，请在AuthSessionContext中设置LocalDeviceID"))
+	}
+
+	if err := h.loadIsoKeys(); err != nil {
+		return h.failKeyAuth(err)
+	}
+	if err := h.generateIsoEphemeral(); err != nil {
+		return h.failKeyAuth(err)
+	}
+	if err := h.deriveIsoSessionKey(); err != nil {
+		return h.failKeyAuth(err)
+	}
+
+	signature, err := signED25519(h.longTermPrivateKey, h.isoTranscript(isoServerSignLabel))
+	if err != nil {
+		return h.failKeyAuth(fmt.Errorf("ED25519签名失败: %w", err))
+	}
+
+	respMsg := &AuthMessage{
+		MessageType: MsgTypeIsoResponse,
+		RequestID:   h.requestID,
+		Payload: &PakePayload{
+			AuthID:    bytesToHex([]byte(h.selfAuthID)),
+			Challenge: bytesToHex(h.ourChallenge),
+			Epk:       bytesToHex(h.pakeEpk),
+			Signature: bytesToHex(signature),
+		},
+	}
+	h.state = StateAuthenticating
+	return h.sendMessage(respMsg)
+}
+
+// handleIsoResponse 处理ISO_RESPONSE（客户端）
+func (h *HiChainHandle) handleIsoResponse(msg *AuthMessage) error {
+	log.Infof("[HICHAIN] 处理ISO_RESPONSE")
+
+	if !h.keyAuth || h.state != StateStarted {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	if msg.Payload == nil {
+		return h.failKeyAuth(fmt.Errorf("payload为空"))
+	}
+
+	peerID, err := hexToBytes(msg.Payload.AuthID)
+	if err != nil || string(peerID) != h.peerAuthID {
+		return h.failKeyAuth(fmt.Errorf("服务端authId不匹配：期望=%s", h.peerAuthID))
+	}
+	if h.peerChallenge, err = hexToBytes(msg.Payload.Challenge); err != nil || len(h.peerChallenge) != isoChallengeLen {
+		return h.failKeyAuth(fmt.Errorf("解析服务端challenge失败"))
+	}
+	if h.pakePeerEpk, err = hexToBytes(msg.Payload.Epk); err != nil || len(h.pakePeerEpk) != 32 {
+		return h.failKeyAuth(fmt.Errorf("解析服务端epk失败"))
+	}
+
+	signature, err := hexToBytes(msg.Payload.Signature)
+	if err != nil || !verifyED25519Signature(h.peerLongTermKey, h.isoTranscript(isoServerSignLabel), signature) {
+		log.Errorf("[HICHAIN] ✗ 服务端签名验证失败")
+		return h.failKeyAuth(fmt.Errorf("服务端签名验证失败: %w", ErrSignatureInvalid))
+	}
+	log.Infof("[HICHAIN] ✓ 服务端签名验证成功")
+
+	if err := h.deriveIsoSessionKey(); err != nil {
+		return h.failKeyAuth(err)
+	}
+
+	signature, err = signED25519(h.longTermPrivateKey, h.isoTranscript(isoClientSignLabel))
+	if err != nil {
+		return h.failKeyAuth(fmt.Errorf("ED25519签名失败: %w", err))
+	}
+
+	confirmMsg := &AuthMessage{
+		MessageType: MsgTypeIsoClientConfirm,
+		RequestID:   msg.RequestID,
+		Payload: &PakePayload{
+			Signature: bytesToHex(signature),
+		},
+	}
+	h.state = StateAuthenticating
+	return h.sendMessage(confirmMsg)
+}
+
+// handleIsoClientConfirm 处理ISO_CLIENT_CONFIRM（服务端）
+func (h *HiChainHandle) handleIsoClientConfirm(msg *AuthMessage) error {
+	log.Infof("[HICHAIN] 处理ISO_CLIENT_CONFIRM")
+
+	if !h.keyAuth || h.state != StateAuthenticating {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	if msg.Payload == nil {
+		return h.failKeyAuth(fmt.Errorf("payload为空"))
+	}
+
+	signature, err := hexToBytes(msg.Payload.Signature)
+	if err != nil || !verifyED25519Signature(h.peerLongTermKey, h.isoTranscript(isoClientSignLabel), signature) {
+		log.Errorf("[HICHAIN] ✗ 客户端签名验证失败")
+		return h.failKeyAuth(fmt.Errorf("客户端签名验证失败: %w", ErrSignatureInvalid))
+	}
+	log.Infof("[HICHAIN] ✓ 客户端签名验证成功")
+
+	h.callback.SetSessionKey(h.identity, &SessionKey{
+		Key:    h.sessionKey,
+		Length: int32(len(h.sessionKey)),
+	})
+
+	confirmMsg := &AuthMessage{
+		MessageType: MsgTypeIsoServerConfirm,
+		RequestID:   h.requestID,
+		Result:      HCOk,
+	}
+	if err := h.sendMessage(confirmMsg); err != nil {
+		return err
+	}
+
+	h.state = StateCompleted
+	log.Infof("[HICHAIN] ✓ 基于长期密钥的认证完成：对端=%s", h.peerAuthID)
+	h.callback.SetServiceResult(h.identity, HCOk)
+	return nil
+}
+
+// handleIsoServerConfirm 处理ISO_SERVER_CONFIRM（客户端）
+func (h *HiChainHandle) handleIsoServerConfirm(msg *AuthMessage) error {
+	log.Infof("[HICHAIN] 处理ISO_SERVER_CONFIRM")
+
+	if !h.keyAuth || h.state != StateAuthenticating {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	if msg.Result != HCOk {
+		h.state = StateFailed
+		h.callback.SetServiceResult(h.identity, HCAuthFailed)
+		return fmt.Errorf("服务端认证失败 (result=%d)", msg.Result)
+	}
+
+	h.callback.SetSessionKey(h.identity, &SessionKey{
+		Key:    h.sessionKey,
+		Length: int32(len(h.sessionKey)),
+	})
+
+	h.state = StateCompleted
+	log.Infof("[HICHAIN] ✓ 基于长期密钥的认证完成：对端=%s", h.peerAuthID)
+	h.callback.SetServiceResult(h.identity, HCOk)
+	return nil
+}
+
+// loadIsoKeys 加载本地长期密钥对和对端长期公钥
+func (h *HiChainHandle) loadIsoKeys() error {
+	info := GetDeviceAuthInfo(h.peerAuthID)
+	if info == nil || len(info.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: %s", ErrPeerKeyNotFound, h.peerAuthID)
+	}
+	h.peerLongTermKey = info.PublicKey
+
+	privateKey, publicKey, err := GetOrCreateLocalKeyPair(h.selfAuthID)
+	if err != nil {
+		return fmt.Errorf("加载本地长期密钥失败: %w", err)
+	}
+	h.longTermPrivateKey = privateKey
+	h.longTermPublicKey = publicKey
+	return nil
+}
+
+// generateIsoEphemeral 生成本端challenge和临时X25519密钥对
+func (h *HiChainHandle) generateIsoEphemeral() error {
+	challenge, err := generateRandomBytes(isoChallengeLen)
+	if err != nil {
+		return fmt.Errorf("生成challenge失败: %w", err)
+	}
+	esk, epk, err := generateX25519KeyPair()
+	if err != nil {
+		return err
+	}
+	h.ourChallenge = challenge
+	h.pakeEsk = esk
+	h.pakeEpk = epk
+	return nil
+}
+
+// deriveIsoSessionKey 由临时密钥协商会话密钥
+func (h *HiChainHandle) deriveIsoSessionKey() error {
+	shared, err := computeX25519SharedSecret(h.pakeEsk, h.pakePeerEpk)
+	if err != nil {
+		return err
+	}
+	challengeC, challengeS, _, _, _, _ := h.isoRoles()
+	salt := append(append([]byte{}, challengeC...), challengeS...)
+	key, err := crypto.DeriveKeyHKDF(shared, salt, []byte(isoSessionKeyInfo), SessionKeyLength)
+	if err != nil {
+		return fmt.Errorf("派生会话密钥失败: %w", err)
+	}
+	h.sessionKey = key
+	return nil
+}
+
+// isoRoles 按客户端/服务端角色整理双方的challenge、epk和authId
+func (h *HiChainHandle) isoRoles() (challengeC, challengeS, epkC, epkS []byte, idC, idS string) {
+	if h.deviceType == HCController {
+		return h.ourChallenge, h.peerChallenge, h.pakeEpk, h.pakePeerEpk, h.selfAuthID, h.peerAuthID
+	}
+	return h.peerChallenge, h.ourChallenge, h.pakePeerEpk, h.pakeEpk, h.peerAuthID, h.selfAuthID
+}
+
+// isoTranscript 构造签名消息，每个字段前加长度，不同字段划分不会得到相同的消息
+func (h *HiChainHandle) isoTranscript(label string) []byte {
+	challengeC, challengeS, epkC, epkS, idC, idS := h.isoRoles()
+
+	var buf bytes.Buffer
+	for _, field := range [][]byte{[]byte(label), challengeC, challengeS, epkC, epkS, []byte(idC), []byte(idS)} {
+		binary.Write(&buf, binary.BigEndian, uint32(len(field)))
+		buf.Write(field)
+	}
+	return buf.Bytes()
+}
+
+// failKeyAuth 认证失败：通知对端和上层
+func (h *HiChainHandle) failKeyAuth(err error) error {
+	log.Errorf("[HICHAIN] ✗ 基于长期密钥的认证失败：%v", err)
+	return h.fail(ErrorCodeOf(err), err)
+}
+
+// sendMessage 打包并发送消息
+func (h *HiChainHandle) sendMessage(msg *AuthMessage) error {
+	data, err := packMessage(msg)
+	if err != nil {
+		return err
+	}
+	return h.callback.OnTransmit(h.identity, data)
+}
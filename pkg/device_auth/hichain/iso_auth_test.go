@@ -0,0 +1,132 @@
+package hichain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// 测试已绑定设备之间基于长期密钥的认证：双方协商出相同的会话密钥
+func TestKeyAuth_BoundDevices(t *testing.T) {
+	SetKeyStore(nil)
+	defer SetKeyStore(nil)
+
+	// 模拟绑定：双方各自的长期身份，并互相保存对端公钥
+	// 同一进程内两端共享密钥缓存，GetOrCreateLocalKeyPair保存的公钥即对端看到的公钥
+	if _, _, err := GetOrCreateLocalKeyPair("iso-client"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GetOrCreateLocalKeyPair("iso-server"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newTestPeer(t, testProtocolIso, HCController, "iso-client", "iso-server", "")
+	server := newTestPeer(t, testProtocolIso, HCAccessory, "iso-server", "", "")
+	client.remote = server.handle
+	server.remote = client.handle
+
+	if err := client.handle.StartKeyAuth("iso-server"); err != nil {
+		t.Fatalf("StartKeyAuth failed: %v", err)
+	}
+
+	if client.result != HCOk || server.result != HCOk {
+		t.Fatalf("Expected both sides to succeed, client=%d server=%d", client.result, server.result)
+	}
+	if len(client.sessionKey) != SessionKeyLength || !bytes.Equal(client.sessionKey, server.sessionKey) {
+		t.Error("Expected both sides to derive the same session key")
+	}
+	if !server.handle.UsesKeyAuth() || server.handle.GetPeerAuthID() != "iso-client" {
+		t.Errorf("Unexpected server state: keyAuth=%v peer=%s", server.handle.UsesKeyAuth(), server.handle.GetPeerAuthID())
+	}
+}
+
+// 测试未绑定或身份不匹配的设备无法通过基于长期密钥的认证
+func TestKeyAuth_Rejected(t *testing.T) {
+	SetKeyStore(nil)
+	defer SetKeyStore(nil)
+
+	// 发起方没有对端公钥
+	GetOrCreateLocalKeyPair("iso-lonely")
+	client := newTestPeer(t, testProtocolIso, HCController, "iso-lonely", "iso-unknown", "")
+	if err := client.handle.StartKeyAuth("iso-unknown"); !errors.Is(err, ErrPeerKeyNotFound) {
+		t.Errorf("Expected ErrPeerKeyNotFound, got %v", err)
+	}
+
+	// 服务端保存的客户端公钥与客户端实际身份不符（如解绑后重新生成身份）
+	GetOrCreateLocalKeyPair("iso-client2")
+	GetOrCreateLocalKeyPair("iso-server2")
+	client = newTestPeer(t, testProtocolIso, HCController, "iso-client2", "iso-server2", "")
+	server := newTestPeer(t, testProtocolIso, HCAccessory, "iso-server2", "", "")
+	client.remote = server.handle
+	server.remote = client.handle
+
+	_, forged, _ := generateED25519KeyPair()
+	SaveDeviceAuthInfo("iso-client2", forged)
+
+	client.handle.StartKeyAuth("iso-server2")
+	if server.result != HCAuthFailed || client.result != HCAuthFailed {
+		t.Errorf("Expected both sides to fail, client=%d server=%d", client.result, server.result)
+	}
+	if server.sessionKey != nil || client.sessionKey != nil {
+		t.Error("Expected no session key on failure")
+	}
+}
+
+// 测试服务端只在初始状态接受ISO_START，已完成的会话不能被重新开始
+func TestKeyAuth_RestartRejected(t *testing.T) {
+	SetKeyStore(nil)
+	defer SetKeyStore(nil)
+
+	GetOrCreateLocalKeyPair("iso-client3")
+	GetOrCreateLocalKeyPair("iso-server3")
+	client := newTestPeer(t, testProtocolIso, HCController, "iso-client3", "iso-server3", "")
+	server := newTestPeer(t, testProtocolIso, HCAccessory, "iso-server3", "", "")
+	client.remote = server.handle
+	server.remote = client.handle
+
+	var start *AuthMessage
+	client.rewrite = func(msg *AuthMessage) {
+		if msg.MessageType == MsgTypeIsoStart {
+			copied := *msg
+			start = &copied
+		}
+	}
+	if err := client.handle.StartKeyAuth("iso-server3"); err != nil || server.result != HCOk || start == nil {
+		t.Fatalf("Expected key auth to succeed: err=%v, server=%d", err, server.result)
+	}
+
+	sessionKey := server.handle.sessionKey
+	server.result = -1
+	if err := server.handle.handleIsoStart(start); err == nil {
+		t.Error("Expected replayed ISO_START to be rejected")
+	}
+	if server.handle.state != StateCompleted || !bytes.Equal(server.handle.sessionKey, sessionKey) || server.result != -1 {
+		t.Errorf("Expected completed session unchanged: state=%d, result=%d", server.handle.state, server.result)
+	}
+}
+
+// 测试签名消息的字段带长度，不同的authId划分得到不同的消息
+func TestIsoTranscript_LengthPrefixed(t *testing.T) {
+	challenge := bytes.Repeat([]byte{1}, isoChallengeLen)
+	epk := bytes.Repeat([]byte{2}, 32)
+	newHandle := func(selfID string, peerID string) *HiChainHandle {
+		return &HiChainHandle{
+			deviceType:    HCController,
+			selfAuthID:    selfID,
+			peerAuthID:    peerID,
+			ourChallenge:  challenge,
+			peerChallenge: challenge,
+			pakeEpk:       epk,
+			pakePeerEpk:   epk,
+		}
+	}
+
+	a := newHandle("ab", "c").isoTranscript(isoServerSignLabel)
+	b := newHandle("a", "bc").isoTranscript(isoServerSignLabel)
+	if bytes.Equal(a, b) {
+		t.Error("Expected different transcripts for different authId splits")
+	}
+	if !bytes.Equal(a, newHandle("ab", "c").isoTranscript(isoServerSignLabel)) {
+		t.Error("Expected deterministic transcript")
+	}
+}
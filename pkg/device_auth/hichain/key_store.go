@@ -1,25 +1,64 @@
 package hichain
 
 import (
+	"errors"
 	"sync"
+	"time"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
 )
 
+// ErrKeyNotFound 密钥不存在
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyStore 长期密钥存储
+// 保存本地ED25519长期身份密钥对和EXCHANGE阶段获得的对端公钥
+type KeyStore interface {
+	// LoadLocalKeyPair 加载本地长期密钥对，不存在时返回ErrKeyNotFound
+	LoadLocalKeyPair(deviceID string) (privateKey []byte, publicKey []byte, err error)
+
+	// SaveLocalKeyPair 保存本地长期密钥对
+	SaveLocalKeyPair(deviceID string, privateKey []byte, publicKey []byte) error
+
+	// LoadPeerPublicKey 加载对端公钥，不存在时返回ErrKeyNotFound
+	LoadPeerPublicKey(deviceID string) ([]byte, error)
+
+	// SavePeerPublicKey 保存对端公钥
+	SavePeerPublicKey(deviceID string, publicKey []byte) error
+
+	// DeletePeer 删除对端的公钥（解绑时调用）
+	DeletePeer(deviceID string) error
+}
+
 // DeviceAuthInfo 设备认证信息（内存缓存）
 type DeviceAuthInfo struct {
-	DeviceID       string // 设备ID
-	PublicKey      []byte // ED25519公钥（32字节）
-	PrivateKey     []byte // 本地ED25519私钥（64字节，仅保存自己的）
-	SessionKey     []byte // 最后一次会话密钥（可选，用于快速重连）
-	LastAuthTime   int64  // 最后认证时间戳
+	DeviceID     string // 设备ID
+	PublicKey    []byte // ED25519公钥（32字节）
+	PrivateKey   []byte // 本地ED25519私钥（64字节，仅保存自己的）
+	SessionKey   []byte // 最后一次会话密钥（可选，用于快速重连）
+	LastAuthTime int64  // 最后认证时间戳
 }
 
 var (
-	// 内存中的设备认证信息缓存
+	// 内存中的设备认证信息缓存（配置了KeyStore时作为其前置缓存）
 	deviceAuthStore = make(map[string]*DeviceAuthInfo)
 	authStoreMu     sync.RWMutex
+
+	// 长期密钥持久化存储（nil表示仅保存在内存）
+	g_keyStore KeyStore
 )
 
-// SaveDeviceAuthInfo 保存设备认证信息到内存
+// SetKeyStore 设置长期密钥存储，nil表示仅保存在内存
+// 切换存储时清空内存缓存，之后按需从新存储加载
+func SetKeyStore(store KeyStore) {
+	authStoreMu.Lock()
+	defer authStoreMu.Unlock()
+
+	g_keyStore = store
+	deviceAuthStore = make(map[string]*DeviceAuthInfo)
+}
+
+// SaveDeviceAuthInfo 保存对端设备公钥（写入内存缓存和KeyStore）
 func SaveDeviceAuthInfo(deviceID string, publicKey []byte) {
 	authStoreMu.Lock()
 	defer authStoreMu.Unlock()
@@ -32,17 +71,45 @@ func SaveDeviceAuthInfo(deviceID string, publicKey []byte) {
 			PublicKey: publicKey,
 		}
 	}
+
+	if g_keyStore != nil {
+		if err := g_keyStore.SavePeerPublicKey(deviceID, publicKey); err != nil {
+			log.Errorf("[HICHAIN] 保存对端公钥失败: deviceID=%s, err=%v", deviceID, err)
+		}
+	}
 }
 
-// GetDeviceAuthInfo 获取设备认证信息
+// GetDeviceAuthInfo 获取设备认证信息（内存中不存在时从KeyStore加载对端公钥）
 func GetDeviceAuthInfo(deviceID string) *DeviceAuthInfo {
-	authStoreMu.RLock()
-	defer authStoreMu.RUnlock()
-	return deviceAuthStore[deviceID]
+	authStoreMu.Lock()
+	defer authStoreMu.Unlock()
+
+	if info, exists := deviceAuthStore[deviceID]; exists && len(info.PublicKey) > 0 {
+		return info
+	}
+	if g_keyStore == nil {
+		return deviceAuthStore[deviceID]
+	}
+
+	publicKey, err := g_keyStore.LoadPeerPublicKey(deviceID)
+	if err != nil {
+		if !errors.Is(err, ErrKeyNotFound) {
+			log.Errorf("[HICHAIN] 加载对端公钥失败: deviceID=%s, err=%v", deviceID, err)
+		}
+		return deviceAuthStore[deviceID]
+	}
+
+	info, exists := deviceAuthStore[deviceID]
+	if !exists {
+		info = &DeviceAuthInfo{DeviceID: deviceID}
+		deviceAuthStore[deviceID] = info
+	}
+	info.PublicKey = publicKey
+	return info
 }
 
-// SaveLocalPrivateKey 保存本地长期私钥
-func SaveLocalPrivateKey(deviceID string, privateKey, publicKey []byte) {
+// SaveLocalPrivateKey 保存本地长期私钥（写入内存缓存和KeyStore）
+func SaveLocalPrivateKey(deviceID string, privateKey, publicKey []byte) error {
 	authStoreMu.Lock()
 	defer authStoreMu.Unlock()
 
@@ -56,22 +123,118 @@ func SaveLocalPrivateKey(deviceID string, privateKey, publicKey []byte) {
 			PublicKey:  publicKey,
 		}
 	}
+
+	if g_keyStore != nil {
+		return g_keyStore.SaveLocalKeyPair(deviceID, privateKey, publicKey)
+	}
+	return nil
 }
 
-// GetLocalPrivateKey 获取本地长期私钥（如果存在）
+// GetLocalPrivateKey 获取本地长期私钥（内存中不存在时从KeyStore加载）
 func GetLocalPrivateKey(deviceID string) ([]byte, []byte) {
+	authStoreMu.Lock()
+	defer authStoreMu.Unlock()
+
+	if info, exists := deviceAuthStore[deviceID]; exists && len(info.PrivateKey) > 0 {
+		return info.PrivateKey, info.PublicKey
+	}
+	if g_keyStore == nil {
+		return nil, nil
+	}
+
+	privateKey, publicKey, err := g_keyStore.LoadLocalKeyPair(deviceID)
+	if err != nil {
+		if !errors.Is(err, ErrKeyNotFound) {
+			log.Errorf("[HICHAIN] 加载本地长期密钥失败: deviceID=%s, err=%v", deviceID, err)
+		}
+		return nil, nil
+	}
+
+	info, exists := deviceAuthStore[deviceID]
+	if !exists {
+		info = &DeviceAuthInfo{DeviceID: deviceID}
+		deviceAuthStore[deviceID] = info
+	}
+	info.PrivateKey = privateKey
+	info.PublicKey = publicKey
+	return privateKey, publicKey
+}
+
+// GetOrCreateLocalKeyPair 获取本地长期身份密钥对，不存在时生成并保存
+// 本地身份只生成一次，配置了KeyStore时重启后保持不变
+func GetOrCreateLocalKeyPair(deviceID string) ([]byte, []byte, error) {
+	if privateKey, publicKey := GetLocalPrivateKey(deviceID); privateKey != nil {
+		return privateKey, publicKey, nil
+	}
+
+	privateKey, publicKey, err := generateED25519KeyPair()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := SaveLocalPrivateKey(deviceID, privateKey, publicKey); err != nil {
+		// 保存失败不影响本次认证，但重启后身份会变化
+		log.Errorf("[HICHAIN] 保存本地长期密钥失败: deviceID=%s, err=%v", deviceID, err)
+	}
+	log.Infof("[HICHAIN] 生成本地长期身份密钥对: pubKey=%s", bytesToHex(publicKey))
+	return privateKey, publicKey, nil
+}
+
+// SaveDeviceSessionKey 缓存与设备最后一次协商的会话密钥（用于快速重连）
+func SaveDeviceSessionKey(deviceID string, sessionKey []byte) {
+	authStoreMu.Lock()
+	defer authStoreMu.Unlock()
+
+	key := append([]byte(nil), sessionKey...)
+	if info, exists := deviceAuthStore[deviceID]; exists {
+		info.SessionKey = key
+		info.LastAuthTime = time.Now().Unix()
+	} else {
+		deviceAuthStore[deviceID] = &DeviceAuthInfo{
+			DeviceID:     deviceID,
+			SessionKey:   key,
+			LastAuthTime: time.Now().Unix(),
+		}
+	}
+}
+
+// GetDeviceSessionKey 获取缓存的会话密钥及缓存时间戳（不存在时返回nil）
+func GetDeviceSessionKey(deviceID string) ([]byte, int64) {
 	authStoreMu.RLock()
 	defer authStoreMu.RUnlock()
 
-	if info, exists := deviceAuthStore[deviceID]; exists {
-		return info.PrivateKey, info.PublicKey
+	if info, exists := deviceAuthStore[deviceID]; exists && len(info.SessionKey) > 0 {
+		return append([]byte(nil), info.SessionKey...), info.LastAuthTime
 	}
-	return nil, nil
+	return nil, 0
 }
 
-// ClearDeviceAuthInfo 清除设备认证信息
+// ClearDeviceAuthInfo 清除对端设备认证信息（内存缓存和KeyStore中的公钥）
 func ClearDeviceAuthInfo(deviceID string) {
 	authStoreMu.Lock()
 	defer authStoreMu.Unlock()
 	delete(deviceAuthStore, deviceID)
+
+	if g_keyStore != nil {
+		if err := g_keyStore.DeletePeer(deviceID); err != nil {
+			log.Errorf("[HICHAIN] 删除对端公钥失败: deviceID=%s, err=%v", deviceID, err)
+		}
+	}
+}
+
+// SignWithLocalKey 使用本地长期私钥签名（本地身份不存在时生成）
+func SignWithLocalKey(deviceID string, message []byte) ([]byte, error) {
+	privateKey, _, err := GetOrCreateLocalKeyPair(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return signED25519(privateKey, message)
+}
+
+// VerifyPeerSignature 使用保存的对端长期公钥验证签名（未保存对端公钥时返回false）
+func VerifyPeerSignature(deviceID string, message []byte, signature []byte) bool {
+	info := GetDeviceAuthInfo(deviceID)
+	if info == nil || len(info.PublicKey) == 0 {
+		return false
+	}
+	return verifyED25519Signature(info.PublicKey, message, signature)
 }
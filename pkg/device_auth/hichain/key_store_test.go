@@ -0,0 +1,70 @@
+package hichain
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试文件密钥存储：本地身份只生成一次，重启后保持不变，私钥不以明文落盘
+func TestFileKeyStore_LocalIdentity(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileKeyStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore failed: %v", err)
+	}
+	SetKeyStore(store)
+	defer SetKeyStore(nil)
+
+	priv1, pub1, err := GetOrCreateLocalKeyPair("local-device")
+	if err != nil {
+		t.Fatalf("GetOrCreateLocalKeyPair failed: %v", err)
+	}
+
+	// 模拟重启：重新打开存储并清空内存缓存
+	store, _ = NewFileKeyStore(dir)
+	SetKeyStore(store)
+	priv2, pub2, _ := GetOrCreateLocalKeyPair("local-device")
+	if !bytes.Equal(priv1, priv2) || !bytes.Equal(pub1, pub2) {
+		t.Error("Expected local identity to survive restart")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, keyStoreDirName, localKeyPrefix+"*"))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 local key file, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if bytes.Contains(data, []byte(bytesToHex(priv1))) || bytes.Contains(data, priv1) {
+		t.Error("Private key stored in plaintext")
+	}
+}
+
+// 测试对端公钥持久化与删除
+func TestFileKeyStore_PeerKeys(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileKeyStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore failed: %v", err)
+	}
+	SetKeyStore(store)
+	defer SetKeyStore(nil)
+
+	_, peerPub, _ := generateED25519KeyPair()
+	SaveDeviceAuthInfo("peer-device", peerPub)
+
+	SetKeyStore(store) // 清空内存缓存
+	info := GetDeviceAuthInfo("peer-device")
+	if info == nil || !bytes.Equal(info.PublicKey, peerPub) {
+		t.Fatal("Expected peer public key to be loaded from store")
+	}
+
+	ClearDeviceAuthInfo("peer-device")
+	if _, err := store.LoadPeerPublicKey("peer-device"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after delete, got %v", err)
+	}
+	if info := GetDeviceAuthInfo("peer-device"); info != nil {
+		t.Error("Expected no cached info after delete")
+	}
+}
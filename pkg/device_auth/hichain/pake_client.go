@@ -0,0 +1,263 @@
+package hichain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// PAKE 客户端（发起方）
+// ============================================================================
+//
+// 客户端流程:
+//   1. PAKE_REQUEST         (C→S): 本端版本范围、support256mod
+//   2. PAKE_RESPONSE        (S→C): 协商后的版本、salt、epk_S、challenge_S
+//   3. PAKE_CLIENT_CONFIRM  (C→S): epk_C、challenge_C、kcfData_C
+//   4. PAKE_SERVER_CONFIRM  (S→C): kcfData_S
+//   5. EXCHANGE_REQUEST     (C→S): 加密的客户端长期公钥和签名
+//   6. EXCHANGE_RESPONSE    (S→C): 加密的服务端长期公钥和签名
+// 双方在EXCHANGE阶段保存对端长期公钥，之后可使用基于长期密钥的认证（见iso_auth.go）。
+
+// startAuthentication 启动认证流程（作为发起方），发送PAKE_REQUEST
+func (h *HiChainHandle) startAuthentication() error {
+	// 获取协议参数（包含自身和对端的认证ID等）
+	params, err := h.callback.GetProtocolParams(h.identity, OpCodeAuthenticate)
+	if err != nil {
+		return err
+	}
+	h.selfAuthID = params.SelfAuthID
+	h.peerAuthID = params.PeerAuthID
+
+	// ⚠️ HarmonyOS要求携带authForm字段；peerDeviceId和connDeviceId均为客户端设备ID
+	// 账户认证时携带本端用户ID，服务端据此选择账户凭据
+	msg := &AuthMessage{
+		MessageType:  MsgTypePakeRequest,
+		SessionID:    h.identity.SessionID,
+		AuthForm:     params.AuthForm,
+		UserID:       params.UserID,
+		PeerDeviceID: h.selfAuthID,
+		ConnDeviceID: h.selfAuthID,
+		Payload: &PakePayload{
+			Version:       localPakeVersion(),
+			Support256Mod: true,
+			OperationCode: OpCodeAuthenticate,
+		},
+	}
+
+	data, err := packMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	h.state = StateStarted
+	return h.callback.OnTransmit(h.identity, data)
+}
+
+// handlePakeResponse 处理PAKE_RESPONSE（客户端）
+func (h *HiChainHandle) handlePakeResponse(msg *AuthMessage) error {
+	log.Infof("[HICHAIN] 处理PAKE_RESPONSE")
+
+	if h.state != StateStarted {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	if msg.Payload == nil {
+		return h.failPake(fmt.Errorf("payload为空"))
+	}
+	h.requestID = msg.RequestID
+
+	// 1. 服务端返回协商后的版本，按同样规则确定PAKE版本和曲线
+	pakeVersion, pakeCurve, _, err := negotiatePakeVersion(msg.Payload.Version, msg.Payload.Support256Mod)
+	if err != nil {
+		return h.failPake(err)
+	}
+	h.pakeVersion = pakeVersion
+	h.pakeCurve = pakeCurve
+	log.Infof("[HICHAIN] PAKE版本：version=%d, curve=%d", pakeVersion, pakeCurve)
+
+	// 2. 解析salt、服务端epk和challenge
+	if h.pakeSalt, err = hexToBytes(msg.Payload.Salt); err != nil || len(h.pakeSalt) == 0 {
+		return h.failPake(fmt.Errorf("解析服务端salt失败"))
+	}
+	if h.pakePeerEpk, err = hexToBytes(msg.Payload.Epk); err != nil || len(h.pakePeerEpk) == 0 {
+		return h.failPake(fmt.Errorf("解析服务端epk失败"))
+	}
+	if h.peerChallenge, err = hexToBytes(msg.Payload.Challenge); err != nil || len(h.peerChallenge) == 0 {
+		return h.failPake(fmt.Errorf("解析服务端challenge失败"))
+	}
+
+	// 3. 获取PIN码，计算基点和临时密钥对
+	params, err := h.callback.GetProtocolParams(h.identity, OpCodeAuthenticate)
+	if err != nil {
+		return h.failPake(err)
+	}
+	if params.PinCode == "" {
+		return h.failPake(fmt.Errorf("PIN码未设置"))
+	}
+	if err := h.preparePake(params.PinCode, h.pakeSalt); err != nil {
+		return h.failPake(err)
+	}
+	if h.ourChallenge, err = generateRandomBytes(16); err != nil {
+		return h.failPake(fmt.Errorf("生成challenge失败: %w", err))
+	}
+
+	// 4. 派生会话密钥并生成确认数据
+	if err := h.derivePakeKeys(); err != nil {
+		return h.failPake(err)
+	}
+
+	confirmMsg := &AuthMessage{
+		MessageType: MsgTypePakeClientConfirm,
+		RequestID:   h.requestID,
+		Payload: &PakePayload{
+			Epk:       bytesToHex(h.pakeEpk),
+			Challenge: bytesToHex(h.ourChallenge),
+			KcfData:   bytesToHex(h.pakeProof()),
+		},
+	}
+
+	data, err := packMessage(confirmMsg)
+	if err != nil {
+		return err
+	}
+
+	h.state = StateAuthenticating
+	return h.callback.OnTransmit(h.identity, data)
+}
+
+// handlePakeServerConfirm 处理PAKE_SERVER_CONFIRM（客户端），校验通过后发送EXCHANGE请求
+func (h *HiChainHandle) handlePakeServerConfirm(msg *AuthMessage) error {
+	log.Infof("[HICHAIN] 处理PAKE_SERVER_CONFIRM")
+
+	if h.state != StateAuthenticating {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	if msg.Payload == nil {
+		return h.failPake(fmt.Errorf("payload为空"))
+	}
+
+	serverKcfData, err := hexToBytes(msg.Payload.KcfData)
+	if err != nil || !h.verifyPakeProof(serverKcfData) {
+		log.Errorf("[HICHAIN] ✗ 服务端kcfData验证失败")
+		return h.failPake(fmt.Errorf("服务端kcfData验证失败: %w", ErrProofMismatch))
+	}
+	log.Infof("[HICHAIN] ✓ 服务端kcfData验证成功")
+
+	h.callback.SetSessionKey(h.identity, &SessionKey{
+		Key:    h.sessionKey,
+		Length: int32(len(h.sessionKey)),
+	})
+
+	// EXCHANGE：用会话密钥加密发送本端长期公钥及签名
+	privateKey, publicKey, err := GetOrCreateLocalKeyPair(h.selfAuthID)
+	if err != nil {
+		return h.failPake(fmt.Errorf("加载本地长期密钥失败: %w", err))
+	}
+	h.longTermPrivateKey = privateKey
+	h.longTermPublicKey = publicKey
+
+	authInfo, _ := json.Marshal(map[string]interface{}{
+		"authId": bytesToHex([]byte(h.selfAuthID)),
+		"authPk": bytesToHex(publicKey),
+	})
+
+	// 签名消息: challengeSelf + challengePeer + authInfo
+	signMessage := append(append(append([]byte{}, h.ourChallenge...), h.peerChallenge...), authInfo...)
+	signature, err := signED25519(privateKey, signMessage)
+	if err != nil {
+		return h.failPake(fmt.Errorf("ED25519签名失败: %w", err))
+	}
+
+	nonce, err := generateRandomBytes(12)
+	if err != nil {
+		return h.failPake(err)
+	}
+	cipherData, err := encryptAesGcm(h.sessionKey, nonce, append(authInfo, signature...), []byte("hichain_exchange_request"))
+	if err != nil {
+		return h.failPake(fmt.Errorf("加密客户端数据失败: %w", err))
+	}
+
+	exchangeMsg := &AuthMessage{
+		MessageType:  MsgTypePakeExchangeReq,
+		RequestID:    h.requestID,
+		PeerDeviceID: h.selfAuthID,
+		ConnDeviceID: h.selfAuthID,
+		Payload: &PakePayload{
+			ExAuthInfo: bytesToHex(append(nonce, cipherData...)),
+		},
+	}
+
+	data, err := packMessage(exchangeMsg)
+	if err != nil {
+		return err
+	}
+	return h.callback.OnTransmit(h.identity, data)
+}
+
+// handleExchangeResponse 处理EXCHANGE_RESPONSE（客户端），保存服务端长期公钥
+func (h *HiChainHandle) handleExchangeResponse(msg *AuthMessage) error {
+	log.Infof("[HICHAIN] 处理PAKE_EXCHANGE_RESPONSE")
+
+	if h.state != StateAuthenticating || len(h.sessionKey) == 0 {
+		return fmt.Errorf("无效状态: %d", h.state)
+	}
+	if msg.Payload == nil || msg.Payload.ExAuthInfo == "" {
+		return h.failPake(fmt.Errorf("EXCHANGE响应缺少exAuthInfo"))
+	}
+
+	exAuthInfo, err := hexToBytes(msg.Payload.ExAuthInfo)
+	if err != nil || len(exAuthInfo) < 12 {
+		return h.failPake(fmt.Errorf("解析exAuthInfo失败"))
+	}
+	plaintext, err := decryptAesGcm(h.sessionKey, exAuthInfo[:12], exAuthInfo[12:], []byte("hichain_exchange_response"))
+	if err != nil {
+		return h.failPake(fmt.Errorf("解密服务端数据失败: %w", err))
+	}
+	if len(plaintext) < 64 {
+		return h.failPake(fmt.Errorf("明文长度不足"))
+	}
+
+	authInfoJSON := plaintext[:len(plaintext)-64]
+	signature := plaintext[len(plaintext)-64:]
+
+	var authInfo map[string]interface{}
+	if err := json.Unmarshal(CleanJSONData(authInfoJSON), &authInfo); err != nil {
+		return h.failPake(fmt.Errorf("解析服务端authInfo失败: %w", err))
+	}
+	authPkHex, _ := authInfo["authPk"].(string)
+	serverPublicKey, err := hexToBytes(authPkHex)
+	if err != nil || len(serverPublicKey) != 32 {
+		return h.failPake(fmt.Errorf("服务端公钥格式错误"))
+	}
+	authIdHex, _ := authInfo["authId"].(string)
+	serverID, err := hexToBytes(authIdHex)
+	if err != nil || len(serverID) == 0 {
+		return h.failPake(fmt.Errorf("服务端authId格式错误"))
+	}
+	if h.peerAuthID != "" && h.peerAuthID != string(serverID) {
+		return h.failPake(fmt.Errorf("服务端authId不匹配：期望=%s，实际=%s", h.peerAuthID, serverID))
+	}
+	h.peerAuthID = string(serverID)
+
+	// 服务端签名消息: challengeServer + challengeClient + authInfo
+	verifyMsg := append(append(append([]byte{}, h.peerChallenge...), h.ourChallenge...), authInfoJSON...)
+	if !verifyED25519Signature(serverPublicKey, verifyMsg, signature) {
+		log.Errorf("[HICHAIN] ✗ 服务端签名验证失败")
+		return h.failPake(fmt.Errorf("服务端签名验证失败"))
+	}
+	log.Infof("[HICHAIN] ✓ 服务端签名验证成功")
+
+	SaveDeviceAuthInfo(h.peerAuthID, serverPublicKey)
+
+	h.state = StateCompleted
+	log.Infof("[HICHAIN] ✓ 认证成功完成(含EXCHANGE)！对端=%s", h.peerAuthID)
+	h.callback.SetServiceResult(h.identity, HCOk)
+	return nil
+}
+
+// failPake 客户端认证失败：通知对端和上层
+func (h *HiChainHandle) failPake(err error) error {
+	log.Errorf("[HICHAIN] ✗ PAKE认证失败：%v", err)
+	return h.fail(ErrorCodeOf(err), err)
+}
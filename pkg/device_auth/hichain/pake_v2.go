@@ -0,0 +1,366 @@
+package hichain
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ============================================================================
+// PAKE V2 EC-SPEKE 与版本协商
+// ============================================================================
+//
+// 版本号格式为 "major.minor.capabilities"，第三段为能力位图。
+// 双方取较低的major.minor和能力位交集，交集包含PakeCapV2时使用PAKE V2，否则使用PAKE V1。
+// PAKE V2支持两种曲线：双方都声明support256mod时使用P-256，否则使用X25519。
+//
+// PAKE V2 密钥计算:
+//   base      = HashToCurve(HKDF(PIN, salt, "hichain_speke_base_info"))
+//   epk       = esk * base
+//   sid       = SHA256(challenge_C || challenge_S)
+//   shared    = SHA256(sid || X(esk * epk_peer))
+//   sessionKey= HKDF(shared, salt, "hichain_speke_sessionkey_info", 16)
+//   kcfData   = SHA256(epk_self || epk_peer || shared || base)
+
+// PAKE协议版本
+const (
+	PakeV1 = 1 // EC-SPEKE X25519，HMAC确认
+	PakeV2 = 2 // EC-SPEKE X25519/P-256，哈希确认
+)
+
+// PAKE使用的曲线
+const (
+	PakeCurveX25519 = 0
+	PakeCurveP256   = 1
+)
+
+const (
+	PakeCapV2 = 0x20 // 版本号能力位：支持PAKE V2
+
+	pakeVersionMin     = "1.0.0"
+	pakeVersionCurrent = "2.0.58" // 2.0.26（PAKE V1时期的能力位） | PakeCapV2
+
+	p256PointLen = 64 // P-256点编码：X || Y
+)
+
+// pakeVersionParts 解析版本号 "major.minor.capabilities"
+func pakeVersionParts(version string) ([3]int, error) {
+	var parts [3]int
+	fields := strings.Split(version, ".")
+	if len(fields) != 3 {
+		return parts, fmt.Errorf("无效的版本号: %s", version)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, fmt.Errorf("无效的版本号: %s", version)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// compareVersionPrefix 比较major.minor
+func compareVersionPrefix(a, b [3]int) int {
+	for i := 0; i < 2; i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// localPakeVersion 本端支持的版本范围
+func localPakeVersion() *VersionInfo {
+	return &VersionInfo{MinVersion: pakeVersionMin, CurrentVersion: pakeVersionCurrent}
+}
+
+// negotiatePakeVersion 根据对端声明的版本选择双方都支持的PAKE版本和曲线
+// 参数：
+//   - peer：对端版本信息（为nil或无法解析时按旧设备处理，使用PAKE V1）
+//   - peerSupport256：对端是否支持P-256
+//
+// 返回：
+//   - PAKE版本（PakeV1/PakeV2）
+//   - 曲线（PakeCurveX25519/PakeCurveP256）
+//   - 协商后的版本信息（服务端在PAKE_RESPONSE中返回）
+//   - 错误（对端最低版本高于本端时）
+func negotiatePakeVersion(peer *VersionInfo, peerSupport256 bool) (int, int, *VersionInfo, error) {
+	local, _ := pakeVersionParts(pakeVersionCurrent)
+	if peer == nil {
+		return PakeV1, PakeCurveX25519, localPakeVersion(), nil
+	}
+
+	peerCurrent, err := pakeVersionParts(peer.CurrentVersion)
+	if err != nil {
+		return PakeV1, PakeCurveX25519, peer, nil
+	}
+	if peerMin, err := pakeVersionParts(peer.MinVersion); err == nil && compareVersionPrefix(peerMin, local) > 0 {
+		return 0, 0, nil, fmt.Errorf("%w：对端最低版本=%s，本端版本=%s", ErrVersionUnsupported, peer.MinVersion, pakeVersionCurrent)
+	}
+
+	common := local
+	if compareVersionPrefix(peerCurrent, local) < 0 {
+		common = peerCurrent
+	}
+	common[2] = local[2] & peerCurrent[2]
+
+	negotiated := &VersionInfo{
+		MinVersion:     pakeVersionMin,
+		CurrentVersion: fmt.Sprintf("%d.%d.%d", common[0], common[1], common[2]),
+	}
+	if common[2]&PakeCapV2 == 0 {
+		return PakeV1, PakeCurveX25519, negotiated, nil
+	}
+	if peerSupport256 {
+		return PakeV2, PakeCurveP256, negotiated, nil
+	}
+	return PakeV2, PakeCurveX25519, negotiated, nil
+}
+
+// preparePake 根据PIN码和salt计算SPEKE基点并生成临时密钥对
+func (h *HiChainHandle) preparePake(pinCode string, salt []byte) error {
+	psk := []byte(pinCode)
+
+	if h.pakeCurve == PakeCurveP256 {
+		base, err := computeP256BasePoint(psk, salt)
+		if err != nil {
+			return fmt.Errorf("计算基点失败: %w", err)
+		}
+		esk, epk, err := generateP256KeyPair(base)
+		if err != nil {
+			return err
+		}
+		h.pakeBase, h.pakeEsk, h.pakeEpk = base, esk, epk
+		return nil
+	}
+
+	base, err := computeX25519BasePoint(psk, salt)
+	if err != nil {
+		return fmt.Errorf("计算基点失败: %w", err)
+	}
+	// 必须使用generateX25519KeyPair生成私钥（clamping），公钥 = esk * base
+	esk, _, err := generateX25519KeyPair()
+	if err != nil {
+		return fmt.Errorf("生成X25519私钥失败: %w", err)
+	}
+	epk, err := computeX25519PublicKey(esk, base)
+	if err != nil {
+		return fmt.Errorf("计算X25519临时公钥失败: %w", err)
+	}
+	h.pakeBase, h.pakeEsk, h.pakeEpk = base, esk, epk
+	return nil
+}
+
+// derivePakeKeys 计算共享密钥并派生会话密钥（需先设置对端epk和challenge）
+func (h *HiChainHandle) derivePakeKeys() error {
+	if h.pakeVersion != PakeV2 {
+		sharedSecret, err := computeX25519SharedSecret(h.pakeEsk, h.pakePeerEpk)
+		if err != nil {
+			return fmt.Errorf("计算共享密钥失败: %w", err)
+		}
+		// PAKE V1 unionKey: sessionKey[0:16] + hmacKey[16:48]
+		unionKey, err := deriveSessionKey(sharedSecret, h.pakeSalt, 48)
+		if err != nil {
+			return fmt.Errorf("派生unionKey失败: %w", err)
+		}
+		h.pakeSharedSec = sharedSecret
+		h.sessionKey = unionKey[0:16]
+		h.pakeHmacKey = unionKey[16:48]
+		return nil
+	}
+
+	var point []byte
+	var err error
+	if h.pakeCurve == PakeCurveP256 {
+		point, err = computeP256SharedSecret(h.pakeEsk, h.pakePeerEpk)
+	} else {
+		point, err = computeX25519SharedSecret(h.pakeEsk, h.pakePeerEpk)
+	}
+	if err != nil {
+		return fmt.Errorf("计算共享密钥失败: %w", err)
+	}
+
+	challengeC, challengeS := h.ourChallenge, h.peerChallenge
+	if h.deviceType != HCController {
+		challengeC, challengeS = h.peerChallenge, h.ourChallenge
+	}
+	sid := sha256.Sum256(append(append([]byte{}, challengeC...), challengeS...))
+	shared := sha256.Sum256(append(sid[:], point...))
+
+	sessionKey := make([]byte, SessionKeyLength)
+	if _, err := hkdf.New(sha256.New, shared[:], h.pakeSalt, []byte(HiChainSpekeSessionKeyInfo)).Read(sessionKey); err != nil {
+		return fmt.Errorf("HKDF派生密钥失败: %w", err)
+	}
+	h.pakeSharedSec = shared[:]
+	h.sessionKey = sessionKey
+	return nil
+}
+
+// pakeProof 生成本端密钥确认数据
+func (h *HiChainHandle) pakeProof() []byte {
+	if h.pakeVersion != PakeV2 {
+		return computeKcfDataV1(h.pakeHmacKey, h.ourChallenge, h.peerChallenge, true)
+	}
+	return computeKcfDataV2(h.pakeEpk, h.pakePeerEpk, h.pakeSharedSec, h.pakeBase)
+}
+
+// verifyPakeProof 校验对端密钥确认数据
+func (h *HiChainHandle) verifyPakeProof(kcfData []byte) bool {
+	var expected []byte
+	if h.pakeVersion != PakeV2 {
+		expected = computeKcfDataV1(h.pakeHmacKey, h.ourChallenge, h.peerChallenge, false)
+	} else {
+		expected = computeKcfDataV2(h.pakePeerEpk, h.pakeEpk, h.pakeSharedSec, h.pakeBase)
+	}
+	return hmac.Equal(expected, kcfData)
+}
+
+// computeKcfDataV2 计算PAKE V2密钥确认数据: SHA256(epkSelf || epkPeer || sharedSecret || base)
+func computeKcfDataV2(epkSelf, epkPeer, sharedSecret, base []byte) []byte {
+	h := sha256.New()
+	h.Write(epkSelf)
+	h.Write(epkPeer)
+	h.Write(sharedSecret)
+	h.Write(base)
+	return h.Sum(nil)
+}
+
+// ============================================================================
+// P-256 EC-SPEKE
+// ============================================================================
+
+// computeP256BasePoint 计算P-256 SPEKE基点
+// base = SSWU(HKDF(PSK, salt, info))，编码为 X || Y
+func computeP256BasePoint(psk []byte, salt []byte) ([]byte, error) {
+	if len(psk) == 0 {
+		return nil, fmt.Errorf("PSK不能为空")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := hkdf.New(sha256.New, psk, salt, []byte(HiChainSpekeBaseInfo)).Read(secret); err != nil {
+		return nil, fmt.Errorf("HKDF派生secret失败: %w", err)
+	}
+
+	x, y := hashToCurveP256(secret)
+	return encodeP256Point(x, y), nil
+}
+
+// hashToCurveP256 将32字节secret映射到P-256上的点（RFC 9380 Simplified SWU，Z=-10）
+func hashToCurveP256(secret []byte) (*big.Int, *big.Int) {
+	params := elliptic.P256().Params()
+	p := params.P
+
+	u := new(big.Int).Mod(new(big.Int).SetBytes(secret), p)
+	a := new(big.Int).Sub(p, big.NewInt(3))
+	b := params.B
+	z := new(big.Int).Sub(p, big.NewInt(10))
+
+	mod := func(v *big.Int) *big.Int { return v.Mod(v, p) }
+	inv := func(v *big.Int) *big.Int { return new(big.Int).ModInverse(v, p) }
+	gx := func(x *big.Int) *big.Int {
+		v := new(big.Int).Exp(x, big.NewInt(3), p)
+		v.Add(v, new(big.Int).Mul(a, x))
+		v.Add(v, b)
+		return mod(v)
+	}
+
+	// tv1 = 1 / (Z^2 * u^4 + Z * u^2)
+	zu2 := mod(new(big.Int).Mul(z, new(big.Int).Mul(u, u)))
+	den := mod(new(big.Int).Add(new(big.Int).Mul(zu2, zu2), zu2))
+
+	var x1 *big.Int
+	if den.Sign() == 0 {
+		// x1 = B / (Z * A)
+		x1 = mod(new(big.Int).Mul(b, inv(mod(new(big.Int).Mul(z, a)))))
+	} else {
+		// x1 = (-B / A) * (1 + tv1)
+		negB := new(big.Int).Sub(p, b)
+		x1 = mod(new(big.Int).Mul(negB, inv(a)))
+		x1 = mod(x1.Mul(x1, new(big.Int).Add(big.NewInt(1), inv(den))))
+	}
+
+	x := x1
+	y := new(big.Int).ModSqrt(gx(x1), p)
+	if y == nil {
+		x = mod(new(big.Int).Mul(zu2, x1))
+		y = new(big.Int).ModSqrt(gx(x), p)
+	}
+
+	// sgn0(y) 与 sgn0(u) 一致
+	if u.Bit(0) != y.Bit(0) {
+		y.Sub(p, y)
+	}
+	return x, y
+}
+
+// generateP256KeyPair 生成P-256 SPEKE临时密钥对：epk = esk * base
+func generateP256KeyPair(base []byte) ([]byte, []byte, error) {
+	curve := elliptic.P256()
+	bx, by, err := decodeP256Point(base)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// esk ∈ [1, n-1]
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(curve.Params().N, big.NewInt(1)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成P-256私钥失败: %w", err)
+	}
+	k.Add(k, big.NewInt(1))
+	esk := k.FillBytes(make([]byte, 32))
+
+	x, y := curve.ScalarMult(bx, by, esk)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, nil, fmt.Errorf("计算P-256临时公钥失败")
+	}
+	return esk, encodeP256Point(x, y), nil
+}
+
+// computeP256SharedSecret 计算P-256共享密钥（返回共享点的X坐标）
+func computeP256SharedSecret(privateKey, peerPublicKey []byte) ([]byte, error) {
+	if len(privateKey) != 32 {
+		return nil, fmt.Errorf("私钥长度错误: 期望32字节，实际%d", len(privateKey))
+	}
+	px, py, err := decodeP256Point(peerPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	x, y := elliptic.P256().ScalarMult(px, py, privateKey)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, fmt.Errorf("P-256共享点为无穷远点")
+	}
+	return x.FillBytes(make([]byte, 32)), nil
+}
+
+// encodeP256Point 编码P-256点为 X || Y（各32字节）
+func encodeP256Point(x, y *big.Int) []byte {
+	buf := make([]byte, p256PointLen)
+	x.FillBytes(buf[:32])
+	y.FillBytes(buf[32:])
+	return buf
+}
+
+// decodeP256Point 解码并校验P-256点
+func decodeP256Point(data []byte) (*big.Int, *big.Int, error) {
+	if len(data) != p256PointLen {
+		return nil, nil, fmt.Errorf("P-256点长度错误: 期望%d字节，实际%d", p256PointLen, len(data))
+	}
+	x := new(big.Int).SetBytes(data[:32])
+	y := new(big.Int).SetBytes(data[32:])
+	if !elliptic.P256().IsOnCurve(x, y) {
+		return nil, nil, fmt.Errorf("P-256点不在曲线上")
+	}
+	return x, y, nil
+}
@@ -0,0 +1,177 @@
+package hichain
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"errors"
+	"testing"
+)
+
+// 测试版本协商：取能力位交集，双方支持PAKE V2时优先使用，support256mod决定曲线
+func TestNegotiatePakeVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		peer       *VersionInfo
+		support256 bool
+		version    int
+		curve      int
+		current    string
+		wantErr    bool
+	}{
+		{"no version", nil, true, PakeV1, PakeCurveX25519, pakeVersionCurrent, false},
+		{"legacy peer", &VersionInfo{MinVersion: "1.0.0", CurrentVersion: "2.0.26"}, true, PakeV1, PakeCurveX25519, "2.0.26", false},
+		{"v2 x25519", &VersionInfo{MinVersion: "1.0.0", CurrentVersion: "2.0.58"}, false, PakeV2, PakeCurveX25519, "2.0.58", false},
+		{"v2 p256", &VersionInfo{MinVersion: "2.0.0", CurrentVersion: "2.1.63"}, true, PakeV2, PakeCurveP256, "2.0.58", false},
+		{"peer too new", &VersionInfo{MinVersion: "3.0.0", CurrentVersion: "3.0.32"}, true, 0, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		version, curve, negotiated, err := negotiatePakeVersion(tt.peer, tt.support256)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if version != tt.version || curve != tt.curve || negotiated.CurrentVersion != tt.current {
+			t.Errorf("%s: got version=%d curve=%d current=%s, want %d %d %s",
+				tt.name, version, curve, negotiated.CurrentVersion, tt.version, tt.curve, tt.current)
+		}
+	}
+}
+
+// 测试P-256基点映射：结果在曲线上且确定
+func TestComputeP256BasePoint(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	base1, err := computeP256BasePoint([]byte("123456"), salt)
+	if err != nil {
+		t.Fatalf("computeP256BasePoint failed: %v", err)
+	}
+	base2, _ := computeP256BasePoint([]byte("123456"), salt)
+	if !bytes.Equal(base1, base2) {
+		t.Error("Expected deterministic base point")
+	}
+	base3, _ := computeP256BasePoint([]byte("654321"), salt)
+	if bytes.Equal(base1, base3) {
+		t.Error("Expected different PINs to map to different points")
+	}
+
+	x, y, err := decodeP256Point(base1)
+	if err != nil || !elliptic.P256().IsOnCurve(x, y) {
+		t.Errorf("Base point not on curve: %v", err)
+	}
+}
+
+// 测试客户端与服务端完整PAKE流程：各协商结果下双方得到相同会话密钥并交换长期公钥
+func TestPakeFlow_Variants(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite func(msg *AuthMessage)
+		version int
+		curve   int
+	}{
+		{"v2 p256", nil, PakeV2, PakeCurveP256},
+		{"v2 x25519", func(msg *AuthMessage) {
+			if msg.MessageType == MsgTypePakeRequest {
+				msg.Payload.Support256Mod = false
+			}
+		}, PakeV2, PakeCurveX25519},
+		{"v1 legacy", func(msg *AuthMessage) {
+			if msg.MessageType == MsgTypePakeRequest {
+				msg.Payload.Version = &VersionInfo{MinVersion: "1.0.0", CurrentVersion: "2.0.26"}
+			}
+		}, PakeV1, PakeCurveX25519},
+	}
+
+	for _, tt := range tests {
+		SetKeyStore(nil)
+		client := newTestPeer(t, testProtocolPake, HCController, "pake-client", "pake-server", "123456")
+		server := newTestPeer(t, testProtocolPake, HCAccessory, "pake-server", "", "123456")
+		client.remote = server.handle
+		server.remote = client.handle
+		client.rewrite = tt.rewrite
+
+		if err := client.handle.StartAuth(); err != nil {
+			t.Fatalf("%s: StartAuth failed: %v", tt.name, err)
+		}
+		if client.result != HCOk || server.result != HCOk {
+			t.Errorf("%s: expected both sides to succeed, client=%d server=%d", tt.name, client.result, server.result)
+			continue
+		}
+		if server.handle.pakeVersion != tt.version || server.handle.pakeCurve != tt.curve ||
+			client.handle.pakeVersion != tt.version || client.handle.pakeCurve != tt.curve {
+			t.Errorf("%s: unexpected variant server=%d/%d client=%d/%d", tt.name,
+				server.handle.pakeVersion, server.handle.pakeCurve, client.handle.pakeVersion, client.handle.pakeCurve)
+		}
+		if len(client.sessionKey) != SessionKeyLength || !bytes.Equal(client.sessionKey, server.sessionKey) {
+			t.Errorf("%s: expected both sides to derive the same session key", tt.name)
+		}
+		if GetDeviceAuthInfo("pake-server") == nil || GetDeviceAuthInfo("pake-client") == nil {
+			t.Errorf("%s: expected long-term keys exchanged", tt.name)
+		}
+	}
+	SetKeyStore(nil)
+}
+
+// 测试PIN码不一致时服务端校验失败
+func TestPakeFlow_WrongPin(t *testing.T) {
+	SetKeyStore(nil)
+	defer SetKeyStore(nil)
+
+	client := newTestPeer(t, testProtocolPake, HCController, "pake-client", "pake-server", "111111")
+	server := newTestPeer(t, testProtocolPake, HCAccessory, "pake-server", "", "222222")
+	client.remote = server.handle
+	server.remote = client.handle
+
+	err := client.handle.StartAuth()
+	if !errors.Is(err, ErrProofMismatch) {
+		t.Errorf("Expected ErrProofMismatch, got %v", err)
+	}
+	if server.sessionKey != nil || client.sessionKey != nil {
+		t.Error("Expected no session key on wrong PIN")
+	}
+	if client.result != HCPinMismatch || server.result != HCPinMismatch {
+		t.Errorf("Expected HCPinMismatch on both sides, client=%d server=%d", client.result, server.result)
+	}
+}
+
+// 测试服务端拒绝时以错误消息通知客户端，双方上报相同的错误码
+func TestPakeFlow_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm int32
+		rewrite func(msg *AuthMessage)
+		want    int32
+	}{
+		{"version unsupported", HCOk, func(msg *AuthMessage) {
+			if msg.MessageType == MsgTypePakeRequest {
+				msg.Payload.Version = &VersionInfo{MinVersion: "3.0.0", CurrentVersion: "3.0.32"}
+			}
+		}, HCVersionUnsupported},
+		{"locked out", HCLockedOut, nil, HCLockedOut},
+		{"rejected", HCError, nil, HCRejected},
+	}
+
+	for _, tt := range tests {
+		SetKeyStore(nil)
+		client := newTestPeer(t, testProtocolPake, HCController, "pake-client", "pake-server", "123456")
+		server := newTestPeer(t, testProtocolPake, HCAccessory, "pake-server", "", "123456")
+		client.remote = server.handle
+		server.remote = client.handle
+		client.rewrite = tt.rewrite
+		server.confirm = tt.confirm
+
+		client.handle.StartAuth()
+		if client.result != tt.want || server.result != tt.want {
+			t.Errorf("%s: expected %d on both sides, client=%d server=%d", tt.name, tt.want, client.result, server.result)
+		}
+		if client.handle.GetState() != StateFailed || server.handle.GetState() != StateFailed {
+			t.Errorf("%s: expected both sides failed", tt.name)
+		}
+	}
+	SetKeyStore(nil)
+}
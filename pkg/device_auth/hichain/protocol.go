@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
@@ -27,6 +28,9 @@ const (
 	MsgTypeAuthResult    = 5
 )
 
+// ErrProofMismatch PAKE确认数据校验失败（通常是PIN码错误）
+var ErrProofMismatch = errors.New("PAKE证明校验失败")
+
 // 认证形式（authForm）
 const (
 	AuthFormInvalid          = -1 // 无效类型
@@ -54,6 +58,8 @@ type PakePayload struct {
 	ExAuthInfo    string       `json:"exAuthInfo,omitempty"`    // 扩展认证信息 (EXCHANGE阶段)
 	PeerAuthID    string       `json:"peerAuthId,omitempty"`    // 对端认证ID (EXCHANGE阶段，hex编码)
 	PeerUserType  int          `json:"peerUserType,omitempty"`  // 对端用户类型 (EXCHANGE阶段)
+	AuthID        string       `json:"authId,omitempty"`        // 认证ID (ISO认证，hex编码)
+	Signature     string       `json:"signature,omitempty"`     // ED25519签名 (ISO认证，hex编码)
 }
 
 // AuthMessage 表示HiChain认证消息结构
@@ -71,6 +77,7 @@ type AuthMessage struct {
 
 	// 旧协议字段（兼容）
 	AuthForm  int    `json:"authForm,omitempty"`  // 认证形式
+	UserID    string `json:"userId,omitempty"`    // 发起方用户ID（账户认证）
 	Challenge string `json:"challenge,omitempty"` // 挑战值
 	Response  string `json:"response,omitempty"`  // 响应值
 	AuthID    string `json:"authId,omitempty"`    // 认证ID
@@ -111,52 +118,6 @@ func unpackMessage(data []byte) (*AuthMessage, error) {
 	return &msg, nil
 }
 
-// startAuthentication 启动认证流程（作为发起方）
-func (h *HiChainHandle) startAuthentication() error {
-	// 获取协议参数（包含自身和对端的认证ID等）
-	params, err := h.callback.GetProtocolParams(h.identity, OpCodeAuthenticate)
-	if err != nil {
-		return err
-	}
-
-	h.selfAuthID = params.SelfAuthID // 记录自身认证ID
-	h.peerAuthID = params.PeerAuthID // 记录对端认证ID
-
-	// 生成随机挑战值
-	challenge, err := generateChallenge()
-	if err != nil {
-		return err
-	}
-
-	// 保存本地挑战值（后续用于密钥派生）
-	h.ourChallenge = challenge
-
-	// 创建认证开始消息
-	// ⚠️ 关键修复：HarmonyOS v4.1.4要求必须包含authForm字段
-	// 否则会报错：Failed to get auth form! (HC_ERR_JSON_GET = 8195)
-	msg := &AuthMessage{
-		MessageType: MsgTypeAuthStart,
-		SessionID:   h.identity.SessionID,
-		AuthForm:    AuthFormAccountUnrelated,     // ⚠️ 设置认证形式为"无账户关联"
-		Challenge:   fmt.Sprintf("%x", challenge), // 挑战值转为十六进制字符串
-		AuthID:      h.selfAuthID,                 // 携带自身认证ID
-	}
-
-	// 打包并发送消息
-	data, err := packMessage(msg)
-	if err != nil {
-		return err
-	}
-
-	err = h.callback.OnTransmit(h.identity, data)
-	if err != nil {
-		return err
-	}
-
-	h.state = StateStarted // 更新状态为"已启动"
-	return nil
-}
-
 // handleAuthStart 处理PAKE_REQUEST（作为服务器接收方）
 func (h *HiChainHandle) handleAuthStart(msg *AuthMessage, rawData []byte) error {
 	log.Infof("[HICHAIN] 处理PAKE_REQUEST")
@@ -169,6 +130,20 @@ func (h *HiChainHandle) handleAuthStart(msg *AuthMessage, rawData []byte) error
 	} else if msg.PeerDeviceID != "" {
 		h.peerAuthID = msg.PeerDeviceID
 	}
+	h.peerAuthForm = msg.AuthForm
+	h.peerUserID = msg.UserID
+
+	// 由业务确认是否接受配对请求（需在生成PIN码之前）
+	if h.callback.ConfirmReceiveRequest != nil {
+		// 返回HCLockedOut表示对端处于锁定期，其他非HCOk结果均视为拒绝
+		switch ret := h.callback.ConfirmReceiveRequest(h.identity, OpCodeAuthenticate); ret {
+		case HCOk:
+		case HCLockedOut:
+			return fmt.Errorf("%w：对端=%s", ErrLockedOut, h.peerAuthID)
+		default:
+			return fmt.Errorf("%w：对端=%s", ErrRequestRejected, h.peerAuthID)
+		}
+	}
 
 	// 获取PIN码和服务器设备ID（从AuthSessionContext）
 	params, err := h.callback.GetProtocolParams(h.identity, OpCodeAuthenticate)
@@ -177,7 +152,7 @@ func (h *HiChainHandle) handleAuthStart(msg *AuthMessage, rawData []byte) error
 	}
 	pinCode := params.PinCode
 	if pinCode == "" {
-		pinCode = "888888" // 默认PIN
+		return fmt.Errorf("PIN码未设置")
 	}
 
 	// ⚠️ 关键：服务器设备ID必须从params获取，不能从消息中提取
@@ -189,37 +164,32 @@ func (h *HiChainHandle) handleAuthStart(msg *AuthMessage, rawData []byte) error
 
 	//log.Infof("[HICHAIN] 设备ID信息: selfAuthID=%s, peerAuthID=%s", h.selfAuthID, h.peerAuthID)
 
-	// PAKE V1 EC-SPEKE 协议实现
-	// 1. 生成服务器salt (16字节，符合HarmonyOS标准实现)
-	salt, err := generateRandomBytes(16)
-	if err != nil {
-		return fmt.Errorf("生成salt失败: %w", err)
+	// 1. 版本协商：选择双方都支持的PAKE版本和曲线
+	var peerVersion *VersionInfo
+	peerSupport256 := false
+	if msg.Payload != nil {
+		peerVersion = msg.Payload.Version
+		peerSupport256 = msg.Payload.Support256Mod
 	}
-	h.pakeSalt = salt
-
-	// 2. 从PIN派生PSK并计算SPEKE基点
-	psk := []byte(pinCode)
-	base, err := computeX25519BasePoint(psk, salt)
+	pakeVersion, pakeCurve, version, err := negotiatePakeVersion(peerVersion, peerSupport256)
 	if err != nil {
-		return fmt.Errorf("计算基点失败: %w", err)
+		return err
 	}
-	h.pakeBase = base
+	h.pakeVersion = pakeVersion
+	h.pakeCurve = pakeCurve
+	log.Infof("[HICHAIN] PAKE版本协商：version=%d, curve=%d, negotiated=%s", pakeVersion, pakeCurve, version.CurrentVersion)
 
-	// 3. 生成临时密钥对
-	// EC-SPEKE-X25519: epk = esk * base
-	// 注意：必须使用GenerateX25519KeyPair生成私钥，它会进行X25519标准的clamping操作
-	// 然后重新计算公钥 = esk * base（SPEKE使用自定义base而不是标准基点G）
-	eskSelf, _, err := generateX25519KeyPair() // 生成私钥（已clamped），忽略基于G的公钥
+	// 2. 生成服务器salt (16字节，符合HarmonyOS标准实现)
+	salt, err := generateRandomBytes(16)
 	if err != nil {
-		return fmt.Errorf("生成X25519私钥失败: %w", err)
+		return fmt.Errorf("生成salt失败: %w", err)
 	}
-	// 计算临时公钥：epkSelf = eskSelf * base（SPEKE）
-	epkSelf, err := computeX25519PublicKey(eskSelf, base)
-	if err != nil {
-		return fmt.Errorf("计算X25519临时公钥失败: %w", err)
+	h.pakeSalt = salt
+
+	// 3. 从PIN派生SPEKE基点并生成临时密钥对: epk = esk * base
+	if err := h.preparePake(pinCode, salt); err != nil {
+		return err
 	}
-	h.pakeEsk = eskSelf
-	h.pakeEpk = epkSelf
 
 	// 4. 生成challenge (16字节)
 	challenge, err := generateRandomBytes(16)
@@ -228,29 +198,16 @@ func (h *HiChainHandle) handleAuthStart(msg *AuthMessage, rawData []byte) error
 	}
 	h.ourChallenge = challenge
 
-	//log.Infof("[HICHAIN] === 生成PAKE_RESPONSE ===")
-	//log.Infof("[HICHAIN]   - salt (16字节): %s", bytesToHex(salt))
-	//log.Infof("[HICHAIN]   - epk (32字节): %s", bytesToHex(epkSelf))
-	//log.Infof("[HICHAIN]   - challenge (16字节): %s", bytesToHex(challenge))
-
-	// 5. 构建PAKE_RESPONSE
-	// 使用客户端请求中的版本信息（如果有），否则使用默认值
-	version := &VersionInfo{
-		MinVersion:     "1.0.0",
-		CurrentVersion: "2.0.26",
-	}
-	if msg.Payload != nil && msg.Payload.Version != nil {
-		version = msg.Payload.Version
-	}
-
+	// 5. 构建PAKE_RESPONSE（携带协商后的版本）
 	respMsg := &AuthMessage{
 		MessageType: MsgTypePakeResponse,
 		RequestID:   h.requestID,
 		Payload: &PakePayload{
-			Salt:      bytesToHex(salt),
-			Epk:       bytesToHex(epkSelf),
-			Challenge: bytesToHex(challenge),
-			Version:   version,
+			Salt:          bytesToHex(salt),
+			Epk:           bytesToHex(h.pakeEpk),
+			Challenge:     bytesToHex(challenge),
+			Version:       version,
+			Support256Mod: pakeCurve == PakeCurveP256,
 		},
 	}
 
@@ -259,13 +216,9 @@ func (h *HiChainHandle) handleAuthStart(msg *AuthMessage, rawData []byte) error
 		return err
 	}
 
-	err = h.callback.OnTransmit(h.identity, data)
-	if err != nil {
-		return err
-	}
-
+	// 先更新状态再发送，对端的后续消息可能在发送返回前到达
 	h.state = StateAuthenticating
-	return nil
+	return h.callback.OnTransmit(h.identity, data)
 }
 
 // handleAuthChallenge 处理PAKE_CLIENT_CONFIRM（服务端收到客户端确认）
@@ -290,89 +243,37 @@ func (h *HiChainHandle) handleAuthChallenge(msg *AuthMessage) error {
 	}
 	h.peerChallenge = clientChallenge
 
-	// 3. 计算共享密钥: sharedSecret = eskSelf * epkPeer
-	sharedSecret, err := computeX25519SharedSecret(h.pakeEsk, clientEpk)
-	if err != nil {
-		return fmt.Errorf("计算共享密钥失败: %w", err)
-	}
-	h.pakeSharedSec = sharedSecret
-
-	log.Infof("[HICHAIN] === 计算共享密钥 ===")
-	log.Infof("[HICHAIN]   - 服务器esk: %s", bytesToHex(h.pakeEsk))
-	log.Infof("[HICHAIN]   - 客户端epk: %s", bytesToHex(clientEpk))
-	log.Infof("[HICHAIN]   - sharedSecret: %s", bytesToHex(sharedSecret))
-	log.Infof("[HICHAIN]   - salt: %s", bytesToHex(h.pakeSalt))
-
-	// 4. 派生unionKey (PAKE V1需要48字节: sessionKey[0:16] + hmacKey[16:48])
-	unionKey, err := deriveSessionKey(sharedSecret, h.pakeSalt, 48)
-	if err != nil {
-		return fmt.Errorf("派生unionKey失败: %w", err)
+	// 3. 计算共享密钥并派生会话密钥
+	if err := h.derivePakeKeys(); err != nil {
+		return err
 	}
 
-	// 分割unionKey
-	sessionKey := unionKey[0:16]
-	hmacKey := unionKey[16:48]
-	h.sessionKey = sessionKey
-
-	log.Infof("[HICHAIN] === 验证客户端kcfData ===")
-	log.Infof("[HICHAIN] 输入参数:")
-	log.Infof("[HICHAIN]   - unionKey (48字节): %s", bytesToHex(unionKey))
-	log.Infof("[HICHAIN]   - sessionKey [0:16]: %s", bytesToHex(sessionKey))
-	log.Infof("[HICHAIN]   - hmacKey [16:48]: %s", bytesToHex(hmacKey))
-	log.Infof("[HICHAIN]   - serverChallenge (我们的, 16字节): %s", bytesToHex(h.ourChallenge))
-	log.Infof("[HICHAIN]   - clientChallenge (对端的, 16字节): %s", bytesToHex(clientChallenge))
-
-	// 5. 解析并验证客户端的kcfData
+	// 4. 解析并验证客户端的kcfData (VerifyProof)
 	clientKcfData, err := hexToBytes(msg.Payload.KcfData)
 	if err != nil {
 		return fmt.Errorf("解析客户端kcfData失败: %w", err)
 	}
-
-	// 验证客户端的kcfData (VerifyProof)
-	// 服务器验证客户端: message = challengePeer + challengeSelf = challengeClient + challengeServer
-	expectedClientKcf := computeKcfDataV1(hmacKey, h.ourChallenge, clientChallenge, false)
-
-	log.Infof("[HICHAIN] 验证公式: HMAC-SHA256(hmacKey, challengeClient + challengeServer)")
-	log.Infof("[HICHAIN]   - 拼接消息 (32字节): %s", bytesToHex(append(clientChallenge, h.ourChallenge...)))
-
-	// 直接逐字节比较
-	if len(expectedClientKcf) != len(clientKcfData) {
-		log.Errorf("[HICHAIN] ✗ 客户端kcfData长度不匹配: 期望%d, 实际%d", len(expectedClientKcf), len(clientKcfData))
-		return fmt.Errorf("客户端kcfData验证失败")
-	}
-
-	match := true
-	for i := 0; i < len(expectedClientKcf); i++ {
-		if expectedClientKcf[i] != clientKcfData[i] {
-			match = false
-			break
-		}
-	}
-
-	if !match {
+	if !h.verifyPakeProof(clientKcfData) {
 		log.Errorf("[HICHAIN] ✗ 客户端kcfData验证失败")
-		log.Errorf("[HICHAIN]   期望: %s", bytesToHex(expectedClientKcf))
-		log.Errorf("[HICHAIN]   实际: %s", bytesToHex(clientKcfData))
-		return fmt.Errorf("客户端kcfData验证失败")
+		return fmt.Errorf("客户端kcfData验证失败: %w", ErrProofMismatch)
 	}
 	log.Infof("[HICHAIN] ✓ 客户端kcfData验证成功")
 
-	// 6. 通知上层会话密钥
+	// 5. 通知上层会话密钥
 	h.callback.SetSessionKey(h.identity, &SessionKey{
-		Key:    sessionKey,
-		Length: int32(len(sessionKey)),
+		Key:    h.sessionKey,
+		Length: int32(len(h.sessionKey)),
 	})
 
-	// 7. 生成服务器的kcfData (GenerateProof)
-	serverKcfData := computeKcfDataV1(hmacKey, h.ourChallenge, clientChallenge, true)
+	// 6. 生成服务器的kcfData (GenerateProof)
+	serverKcfData := h.pakeProof()
 
-	// 8. 发送PAKE_SERVER_CONFIRM (PAKE V1不包含challenge字段)
+	// 7. 发送PAKE_SERVER_CONFIRM (不包含challenge字段)
 	confirmMsg := &AuthMessage{
 		MessageType: MsgTypePakeServerConfirm,
 		RequestID:   h.requestID,
 		Payload: &PakePayload{
 			KcfData: bytesToHex(serverKcfData),
-			// PAKE V1协议不在PAKE_SERVER_CONFIRM中发送challenge
 		},
 	}
 
@@ -381,16 +282,11 @@ func (h *HiChainHandle) handleAuthChallenge(msg *AuthMessage) error {
 		return err
 	}
 
-	err = h.callback.OnTransmit(h.identity, data)
-	if err != nil {
-		return err
-	}
-
-	// PAKE V1: 发送PAKE_SERVER_CONFIRM后等待客户端确认或EXCHANGE请求
+	// 发送PAKE_SERVER_CONFIRM后等待客户端确认或EXCHANGE请求
 	// 暂不立即设置为完成状态,等待后续消息
 	h.state = StateAuthenticating
-	log.Infof("[HICHAIN] PAKE_SERVER_CONFIRM已发送,等待客户端响应")
-	return nil
+	log.Infof("[HICHAIN] 发送PAKE_SERVER_CONFIRM,等待客户端响应")
+	return h.callback.OnTransmit(h.identity, data)
 }
 
 // handleAuthResponse 处理旧协议响应（兼容）
@@ -499,26 +395,19 @@ func (h *HiChainHandle) handleExchangeRequest(msg *AuthMessage) error {
 	}
 	log.Infof("[HICHAIN] ✓ 客户端签名验证成功")
 
-	// 5. 生成或加载服务器的ED25519密钥对
+	// 保存对端长期公钥（签名已验证）
+	if clientDeviceID != "" {
+		SaveDeviceAuthInfo(clientDeviceID, clientPublicKey)
+	}
+
+	// 5. 加载服务器的ED25519长期身份密钥对（首次使用时生成）
 	if len(h.longTermPrivateKey) == 0 {
-		// 尝试从内存缓存加载
-		privateKey, publicKey := GetLocalPrivateKey(h.selfAuthID)
-		if privateKey != nil {
-			h.longTermPrivateKey = privateKey
-			h.longTermPublicKey = publicKey
-			log.Infof("[HICHAIN] 使用缓存的ED25519密钥对")
-		} else {
-			// 生成新密钥对
-			privateKey, publicKey, err := generateED25519KeyPair()
-			if err != nil {
-				return fmt.Errorf("生成ED25519密钥对失败: %w", err)
-			}
-			h.longTermPrivateKey = privateKey
-			h.longTermPublicKey = publicKey
-			// 保存到内存缓存
-			SaveLocalPrivateKey(h.selfAuthID, privateKey, publicKey)
-			log.Infof("[HICHAIN] 生成并缓存ED25519密钥对: pubKey=%s", bytesToHex(publicKey))
+		privateKey, publicKey, err := GetOrCreateLocalKeyPair(h.selfAuthID)
+		if err != nil {
+			return fmt.Errorf("生成ED25519密钥对失败: %w", err)
 		}
+		h.longTermPrivateKey = privateKey
+		h.longTermPublicKey = publicKey
 	}
 
 	// 5. 生成服务器的authInfo和签名
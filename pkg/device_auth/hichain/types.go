@@ -1,10 +1,17 @@
 package hichain
 
 const (
-	HCOk            = 0  // 操作成功
-	HCError         = -1 // 通用错误
-	HCInvalidParams = -2 // 无效参数错误
-	HCAuthFailed    = -3 // 认证失败错误
+	HCOk                 = 0   // 操作成功
+	HCError              = -1  // 通用错误
+	HCInvalidParams      = -2  // 无效参数错误
+	HCAuthFailed         = -3  // 认证失败错误
+	HCCancelled          = -4  // 请求被取消
+	HCPinMismatch        = -5  // PIN码错误（PAKE确认数据校验失败）
+	HCVersionUnsupported = -6  // 协议版本不兼容
+	HCPeerCancelled      = -7  // 对端取消了请求
+	HCTimeout            = -8  // 请求超时
+	HCLockedOut          = -9  // 配对失败次数过多，处于锁定期
+	HCRejected           = -10 // 对端拒绝了配对请求
 
 	// 操作码
 	OpCodeAuthenticate = 1 // 认证操作
@@ -42,6 +49,8 @@ type ProtocolParams struct {
 	SelfAuthID string // 自身认证ID
 	PeerAuthID string // 对端认证ID
 	PinCode    string // PIN码（用于PAKE认证）
+	AuthForm   int    // 认证形式（AuthFormXXX，发起方使用；账户认证时PinCode为账户凭据）
+	UserID     string // 本端用户ID（账户认证时发送给对端）
 }
 
 // SessionKey 表示会话密钥
@@ -104,8 +113,19 @@ type HiChainHandle struct {
 	pakeEpk        []byte // 临时公钥
 	pakePeerEpk    []byte // 对端临时公钥
 	pakeSharedSec  []byte // PAKE共享密钥
+	pakeHmacKey    []byte // PAKE V1密钥确认HMAC密钥
+	pakeVersion    int    // 协商的PAKE版本（PakeV1/PakeV2）
+	pakeCurve      int    // 协商的曲线（PakeCurveX25519/PakeCurveP256）
 
 	// EXCHANGE阶段长期密钥（ED25519）
 	longTermPrivateKey []byte // ED25519私钥（64字节）
 	longTermPublicKey  []byte // ED25519公钥（32字节）
+
+	// 基于长期密钥的认证（已绑定设备）
+	keyAuth         bool   // 是否使用基于长期密钥的认证
+	peerLongTermKey []byte // 对端ED25519长期公钥（32字节）
+
+	// 账户认证（PAKE_REQUEST中对端声明的认证形式和用户ID）
+	peerAuthForm int    // 对端请求的认证形式（AuthFormXXX）
+	peerUserID   string // 对端用户ID
 }
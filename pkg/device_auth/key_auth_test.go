@@ -0,0 +1,112 @@
+package device_auth
+
+import (
+	"testing"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/context"
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+)
+
+// keyAuthTestResult 基于长期密钥认证测试中双方的结果
+type keyAuthTestResult struct {
+	clientResult   int32
+	serverFinished bool
+	serverErrors   []int32
+	serverRequests int
+}
+
+// runKeyAuth 客户端直接发起基于长期密钥的认证（绕过发起方的canUseKeyAuth），由服务端决定是否接受
+func runKeyAuth(t *testing.T, serverReqId int64) *keyAuthTestResult {
+	context.SetAuthSessionContext(int(serverReqId), &context.AuthSessionContext{
+		RequestID: serverReqId, LocalDeviceID: "key-udid-b",
+	})
+	t.Cleanup(func() { context.DeleteAuthSessionContext(int(serverReqId)) })
+
+	server := newRealGroupAuthManager()
+	result := &keyAuthTestResult{clientResult: -1}
+
+	var client *hichain.HiChainHandle
+	serverCb := &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool {
+			client.ReceiveData(data)
+			return true
+		},
+		OnFinish: func(requestId int64, operationCode int32, returnData string) { result.serverFinished = true },
+		OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
+			result.serverErrors = append(result.serverErrors, errorCode)
+		},
+		OnRequest: func(requestId int64, operationCode int32, reqParams string) string {
+			result.serverRequests++
+			return ""
+		},
+	}
+	clientCb := &hichain.HCCallBack{
+		OnTransmit: func(identity *hichain.SessionIdentity, data []byte) error {
+			server.ProcessData(serverReqId, data, serverCb)
+			return nil
+		},
+		GetProtocolParams: func(identity *hichain.SessionIdentity, operationCode int32) (*hichain.ProtocolParams, error) {
+			return &hichain.ProtocolParams{KeyLength: hichain.SessionKeyLength, SelfAuthID: "key-udid-a", PeerAuthID: "key-udid-b"}, nil
+		},
+		SetSessionKey: func(identity *hichain.SessionIdentity, sessionKey *hichain.SessionKey) error { return nil },
+		SetServiceResult: func(identity *hichain.SessionIdentity, res int32) error {
+			result.clientResult = res
+			return nil
+		},
+	}
+
+	identity := &hichain.SessionIdentity{SessionID: uint32(serverReqId), OperationCode: hichain.OpCodeAuthenticate}
+	var err error
+	if client, err = hichain.GetInstance(identity, hichain.HCController, clientCb); err != nil {
+		t.Fatalf("GetInstance failed: %v", err)
+	}
+	if err := client.StartKeyAuth("key-udid-b"); err != nil {
+		t.Fatalf("StartKeyAuth failed: %v", err)
+	}
+	return result
+}
+
+// 测试服务端只对同属可信组的对端完成基于长期密钥的认证：仅保存了对端公钥不足以通过认证
+func TestKeyAuth_RequiresGroup(t *testing.T) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	defer DestroyDeviceAuthService()
+	hichain.SetKeyStore(nil)
+	defer hichain.SetKeyStore(nil)
+	defer ResetPairingLockout("", "")
+
+	// 双方互相保存了长期公钥（同一进程内共享密钥缓存），但不在同一可信组
+	for _, udid := range []string{"key-udid-a", "key-udid-b"} {
+		if _, _, err := hichain.GetOrCreateLocalKeyPair(udid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := runKeyAuth(t, 7201)
+	if result.serverFinished || len(result.serverErrors) != 1 || result.clientResult == hichain.HCOk {
+		t.Fatalf("Expected key auth rejected without group, finished=%v errors=%v client=%d",
+			result.serverFinished, result.serverErrors, result.clientResult)
+	}
+	if result.serverRequests != 0 {
+		t.Error("Expected no OnRequest for peer that is not bound")
+	}
+
+	// 对端加入可信组后认证成功，且经过业务确认
+	gm, _ := GetGmInstance()
+	if err := gm.CreateGroup(AnyOsAccount, 7202, "key_app", `{"groupId":"KEY_001","groupName":"Key","groupType":256}`); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	if err := gm.AddMemberToGroup(AnyOsAccount, 7203, "key_app", `{"groupId":"KEY_001","deviceId":"key-udid-a"}`); err != nil {
+		t.Fatalf("AddMemberToGroup failed: %v", err)
+	}
+
+	result = runKeyAuth(t, 7204)
+	if !result.serverFinished || len(result.serverErrors) != 0 || result.clientResult != hichain.HCOk {
+		t.Fatalf("Expected key auth to succeed with group, finished=%v errors=%v client=%d",
+			result.serverFinished, result.serverErrors, result.clientResult)
+	}
+	if result.serverRequests != 1 {
+		t.Errorf("Expected OnRequest consulted once, got %d", result.serverRequests)
+	}
+}
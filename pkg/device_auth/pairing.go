@@ -0,0 +1,185 @@
+package device_auth
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 配对凭据（PIN码）
+// ============================================================================
+//
+// PIN码不再使用固定值，每次配对请求由PairingCredentialProvider提供:
+//   - 被配对方（服务端）: ConfirmRequest确认是否接受请求，GeneratePin生成随机PIN码并交给应用层展示
+//   - 发起方（客户端）: InputPin获取用户输入的对端PIN码（也可以在authParams的pinCode中直接传入）
+// 业务回调DeviceAuthCallback.OnRequest返回的confirmation（RequestAccepted/RequestRejected）和pinCode优先于Provider。
+
+// DefaultPinLength 默认PIN码位数
+const DefaultPinLength = 6
+
+// PairingRequest 配对请求信息
+type PairingRequest struct {
+	RequestId     int64  // 认证请求ID
+	PeerDeviceId  string // 对端设备ID（可能为空）
+	OperationCode int32  // 操作码
+}
+
+// PairingCredentialProvider 配对凭据提供者
+type PairingCredentialProvider interface {
+	// ConfirmRequest 被配对方确认是否接受对端的配对请求
+	ConfirmRequest(req *PairingRequest) bool
+
+	// GeneratePin 被配对方为本次请求生成PIN码，并交给应用层展示
+	GeneratePin(req *PairingRequest) (string, error)
+
+	// InputPin 发起方获取用户输入的对端PIN码
+	// 在独立的goroutine中调用，可以阻塞等待用户输入，等待期间认证请求超时仍然生效
+	InputPin(req *PairingRequest) (string, error)
+}
+
+// DefaultPairingCredentialProvider 默认配对凭据提供者：生成随机数字PIN码
+type DefaultPairingCredentialProvider struct {
+	PinLength    int                                       // PIN码位数（0表示DefaultPinLength）
+	OnConfirm    func(req *PairingRequest) bool            // 确认配对请求（nil表示接受）
+	OnDisplayPin func(req *PairingRequest, pin string)     // 展示PIN码（nil时写入日志）
+	OnInputPin   func(req *PairingRequest) (string, error) // 获取用户输入的PIN码（nil时无法发起配对）
+}
+
+// ConfirmRequest 确认配对请求
+func (p *DefaultPairingCredentialProvider) ConfirmRequest(req *PairingRequest) bool {
+	if p.OnConfirm == nil {
+		return true
+	}
+	return p.OnConfirm(req)
+}
+
+// GeneratePin 生成随机PIN码并展示
+func (p *DefaultPairingCredentialProvider) GeneratePin(req *PairingRequest) (string, error) {
+	length := p.PinLength
+	if length <= 0 {
+		length = DefaultPinLength
+	}
+
+	pin, err := generateRandomPin(length)
+	if err != nil {
+		return "", err
+	}
+
+	if p.OnDisplayPin != nil {
+		p.OnDisplayPin(req, pin)
+	} else {
+		log.Infof("[DEVICE_AUTH] ┌─────────────────────────────────┐")
+		log.Infof("[DEVICE_AUTH] │   请在对端设备上输入PIN码：       │")
+		log.Infof("[DEVICE_AUTH] │         %s                    │", pin)
+		log.Infof("[DEVICE_AUTH] └─────────────────────────────────┘")
+	}
+	return pin, nil
+}
+
+// InputPin 获取用户输入的PIN码
+func (p *DefaultPairingCredentialProvider) InputPin(req *PairingRequest) (string, error) {
+	if p.OnInputPin == nil {
+		return "", fmt.Errorf("no pin input handler")
+	}
+	return p.OnInputPin(req)
+}
+
+// generateRandomPin 生成指定位数的随机数字PIN码
+// 首位不为0（与DM一致），旧版本DM以整数形式传递PIN码时不会丢失位数
+func generateRandomPin(length int) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < length; i++ {
+		max, base := int64(10), int64(0)
+		if i == 0 && length > 1 {
+			max, base = 9, 1
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(max))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate pin: %w", err)
+		}
+		sb.WriteByte(byte('0' + base + n.Int64()))
+	}
+	return sb.String(), nil
+}
+
+var (
+	g_pairingProvider   PairingCredentialProvider = &DefaultPairingCredentialProvider{}
+	g_pairingProviderMu sync.RWMutex
+)
+
+// SetPairingCredentialProvider 设置配对凭据提供者，nil表示恢复默认实现
+func SetPairingCredentialProvider(provider PairingCredentialProvider) {
+	g_pairingProviderMu.Lock()
+	defer g_pairingProviderMu.Unlock()
+
+	if provider == nil {
+		provider = &DefaultPairingCredentialProvider{}
+	}
+	g_pairingProvider = provider
+}
+
+// GetPairingCredentialProvider 获取当前配对凭据提供者
+func GetPairingCredentialProvider() PairingCredentialProvider {
+	g_pairingProviderMu.RLock()
+	defer g_pairingProviderMu.RUnlock()
+	return g_pairingProvider
+}
+
+// ConfirmPairingRequest 确认是否接受对端的配对请求
+func ConfirmPairingRequest(req *PairingRequest) bool {
+	return GetPairingCredentialProvider().ConfirmRequest(req)
+}
+
+// GeneratePairingPin 为配对请求生成PIN码并交给应用层展示
+func GeneratePairingPin(req *PairingRequest) (string, error) {
+	pin, err := GetPairingCredentialProvider().GeneratePin(req)
+	if err != nil {
+		return "", err
+	}
+	if pin == "" {
+		return "", fmt.Errorf("empty pin from provider")
+	}
+	return pin, nil
+}
+
+// InputPairingPin 获取发起方用户输入的PIN码
+func InputPairingPin(req *PairingRequest) (string, error) {
+	pin, err := GetPairingCredentialProvider().InputPin(req)
+	if err != nil {
+		return "", err
+	}
+	if pin == "" {
+		return "", fmt.Errorf("empty pin from provider")
+	}
+	return pin, nil
+}
+
+// requestResponse OnRequest回调的响应
+type requestResponse struct {
+	Confirmation *uint32 `json:"confirmation"`
+	PinCode      string  `json:"pinCode"`
+}
+
+// parseRequestResponse 解析OnRequest响应
+// 返回: 是否明确给出了确认结果、是否接受、响应中的PIN码
+func parseRequestResponse(response string) (decided bool, accepted bool, pinCode string) {
+	if response == "" {
+		return false, false, ""
+	}
+
+	var resp requestResponse
+	if err := json.Unmarshal([]byte(response), &resp); err != nil {
+		log.Warnf("[DEVICE_AUTH] Invalid OnRequest response: %v", err)
+		return true, false, ""
+	}
+	if resp.Confirmation == nil {
+		return false, false, resp.PinCode
+	}
+	return true, RequestResponse(*resp.Confirmation) == RequestAccepted, resp.PinCode
+}
@@ -0,0 +1,193 @@
+package device_auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// PIN码防暴力破解
+// ============================================================================
+//
+// 6位PIN码熵很低，若PAKE失败后可无限重试，攻击者可以在线猜测PIN码。
+// 这里按对端设备ID和来源IP分别统计PAKE失败次数:
+//   - 连续失败达到LockoutThreshold次后锁定，锁定时长按BaseLockout指数增长（上限MaxLockout）
+//   - 锁定期间拒绝该设备/IP的配对请求（在生成PIN码之前拒绝）
+//   - 同一个PIN码累计失败MaxAttemptsPerPin次后作废，下次请求重新生成
+//   - 认证成功后清除该设备/IP的失败记录
+// 锁定状态可通过GetPairingLockout查询，通过ResetPairingLockout清除。
+
+// ErrPairingLocked 对端处于配对锁定期
+var ErrPairingLocked = errors.New("pairing locked")
+
+// PairingGuardPolicy PIN码防暴力破解策略
+type PairingGuardPolicy struct {
+	MaxAttemptsPerPin int           // 单个PIN码允许的失败次数，达到后作废，0表示不限制
+	LockoutThreshold  int           // 连续失败多少次后锁定，0表示不锁定
+	BaseLockout       time.Duration // 首次锁定时长，之后每次锁定翻倍
+	MaxLockout        time.Duration // 锁定时长上限
+}
+
+// DefaultPairingGuardPolicy 默认防暴力破解策略
+func DefaultPairingGuardPolicy() PairingGuardPolicy {
+	return PairingGuardPolicy{
+		MaxAttemptsPerPin: 3,
+		LockoutThreshold:  5,
+		BaseLockout:       30 * time.Second,
+		MaxLockout:        time.Hour,
+	}
+}
+
+// PairingLockout 设备或IP的配对锁定状态
+type PairingLockout struct {
+	Failures    int       // 当前连续失败次数
+	Lockouts    int       // 累计锁定次数（决定下次锁定时长）
+	LockedUntil time.Time // 锁定截止时间，零值表示未锁定
+}
+
+// Locked 是否处于锁定期
+func (l PairingLockout) Locked() bool {
+	return time.Now().Before(l.LockedUntil)
+}
+
+// pairingGuard 配对失败统计
+type pairingGuard struct {
+	policy  PairingGuardPolicy
+	devices map[string]*PairingLockout // 对端设备ID -> 锁定状态
+	ips     map[string]*PairingLockout // 来源IP -> 锁定状态
+	mu      sync.Mutex
+}
+
+var g_pairingGuard = &pairingGuard{
+	policy:  DefaultPairingGuardPolicy(),
+	devices: make(map[string]*PairingLockout),
+	ips:     make(map[string]*PairingLockout),
+}
+
+// SetPairingGuardPolicy 设置PIN码防暴力破解策略
+func SetPairingGuardPolicy(policy PairingGuardPolicy) {
+	g_pairingGuard.mu.Lock()
+	defer g_pairingGuard.mu.Unlock()
+	g_pairingGuard.policy = policy
+
+	log.Infof("[DEVICE_AUTH] Pairing guard policy set: maxAttemptsPerPin=%d, threshold=%d, lockout=%v~%v",
+		policy.MaxAttemptsPerPin, policy.LockoutThreshold, policy.BaseLockout, policy.MaxLockout)
+}
+
+// GetPairingGuardPolicy 获取当前PIN码防暴力破解策略
+func GetPairingGuardPolicy() PairingGuardPolicy {
+	g_pairingGuard.mu.Lock()
+	defer g_pairingGuard.mu.Unlock()
+	return g_pairingGuard.policy
+}
+
+// GetPairingLockout 查询对端设备和来源IP的锁定状态
+// 参数为空时对应的返回值为零值
+func GetPairingLockout(peerDeviceId string, ip string) (device PairingLockout, addr PairingLockout) {
+	g_pairingGuard.mu.Lock()
+	defer g_pairingGuard.mu.Unlock()
+
+	if record, exists := g_pairingGuard.devices[peerDeviceId]; exists && peerDeviceId != "" {
+		device = *record
+	}
+	if record, exists := g_pairingGuard.ips[ip]; exists && ip != "" {
+		addr = *record
+	}
+	return device, addr
+}
+
+// ResetPairingLockout 清除对端设备和来源IP的失败记录与锁定状态
+// 参数为空时忽略对应项，两者都为空时清除全部记录
+func ResetPairingLockout(peerDeviceId string, ip string) {
+	g_pairingGuard.mu.Lock()
+	defer g_pairingGuard.mu.Unlock()
+
+	if peerDeviceId == "" && ip == "" {
+		g_pairingGuard.devices = make(map[string]*PairingLockout)
+		g_pairingGuard.ips = make(map[string]*PairingLockout)
+		log.Infof("[DEVICE_AUTH] All pairing lockouts reset")
+		return
+	}
+	if peerDeviceId != "" {
+		delete(g_pairingGuard.devices, peerDeviceId)
+	}
+	if ip != "" {
+		delete(g_pairingGuard.ips, ip)
+	}
+	log.Infof("[DEVICE_AUTH] Pairing lockout reset: peer=%s, ip=%s", peerDeviceId, ip)
+}
+
+// checkPairingAllowed 检查对端设备和来源IP是否处于锁定期
+func checkPairingAllowed(peerDeviceId string, ip string) error {
+	g_pairingGuard.mu.Lock()
+	defer g_pairingGuard.mu.Unlock()
+
+	now := time.Now()
+	if record, exists := g_pairingGuard.devices[peerDeviceId]; exists && peerDeviceId != "" && now.Before(record.LockedUntil) {
+		return fmt.Errorf("%w: peer %s until %s", ErrPairingLocked, peerDeviceId, record.LockedUntil.Format(time.RFC3339))
+	}
+	if record, exists := g_pairingGuard.ips[ip]; exists && ip != "" && now.Before(record.LockedUntil) {
+		return fmt.Errorf("%w: ip %s until %s", ErrPairingLocked, ip, record.LockedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// recordPairingFailure 记录一次PAKE失败，达到阈值时锁定
+func recordPairingFailure(peerDeviceId string, ip string) {
+	g_pairingGuard.mu.Lock()
+	defer g_pairingGuard.mu.Unlock()
+
+	if peerDeviceId != "" {
+		g_pairingGuard.addFailure(g_pairingGuard.devices, peerDeviceId)
+	}
+	if ip != "" {
+		g_pairingGuard.addFailure(g_pairingGuard.ips, ip)
+	}
+}
+
+// addFailure 累加失败次数，达到阈值时按指数退避锁定（调用方持有锁）
+func (p *pairingGuard) addFailure(records map[string]*PairingLockout, key string) {
+	record, exists := records[key]
+	if !exists {
+		record = &PairingLockout{}
+		records[key] = record
+	}
+	record.Failures++
+
+	if p.policy.LockoutThreshold <= 0 || record.Failures < p.policy.LockoutThreshold {
+		log.Warnf("[DEVICE_AUTH] Pairing failure recorded: key=%s, failures=%d", key, record.Failures)
+		return
+	}
+
+	duration := p.policy.BaseLockout << uint(record.Lockouts)
+	if duration <= 0 || (p.policy.MaxLockout > 0 && duration > p.policy.MaxLockout) {
+		duration = p.policy.MaxLockout
+	}
+	record.Lockouts++
+	record.Failures = 0
+	record.LockedUntil = time.Now().Add(duration)
+	log.Warnf("[DEVICE_AUTH] Pairing locked: key=%s, lockouts=%d, duration=%v", key, record.Lockouts, duration)
+}
+
+// recordPairingSuccess 认证成功，清除失败记录
+func recordPairingSuccess(peerDeviceId string, ip string) {
+	g_pairingGuard.mu.Lock()
+	defer g_pairingGuard.mu.Unlock()
+
+	if peerDeviceId != "" {
+		delete(g_pairingGuard.devices, peerDeviceId)
+	}
+	if ip != "" {
+		delete(g_pairingGuard.ips, ip)
+	}
+}
+
+// pinAttemptsExceeded 判断单个PIN码的失败次数是否已达上限
+func pinAttemptsExceeded(failures int) bool {
+	policy := GetPairingGuardPolicy()
+	return policy.MaxAttemptsPerPin > 0 && failures >= policy.MaxAttemptsPerPin
+}
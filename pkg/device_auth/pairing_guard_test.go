@@ -0,0 +1,112 @@
+package device_auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/context"
+	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth/hichain"
+)
+
+// 测试连续失败后按指数退避锁定
+func TestPairingGuard_Lockout(t *testing.T) {
+	defer SetPairingGuardPolicy(DefaultPairingGuardPolicy())
+	defer ResetPairingLockout("", "")
+
+	SetPairingGuardPolicy(PairingGuardPolicy{
+		LockoutThreshold: 2,
+		BaseLockout:      time.Minute,
+		MaxLockout:       3 * time.Minute,
+	})
+
+	recordPairingFailure("guard-peer", "10.0.0.1")
+	if err := checkPairingAllowed("guard-peer", "10.0.0.1"); err != nil {
+		t.Fatalf("Expected no lockout below threshold, got %v", err)
+	}
+
+	recordPairingFailure("guard-peer", "10.0.0.1")
+	if err := checkPairingAllowed("guard-peer", ""); !errors.Is(err, ErrPairingLocked) {
+		t.Errorf("Expected peer to be locked, got %v", err)
+	}
+	if err := checkPairingAllowed("", "10.0.0.1"); !errors.Is(err, ErrPairingLocked) {
+		t.Errorf("Expected ip to be locked, got %v", err)
+	}
+	if err := checkPairingAllowed("other-peer", "10.0.0.2"); err != nil {
+		t.Errorf("Expected other peer to pass, got %v", err)
+	}
+
+	// 第二次锁定时长翻倍，第三次受上限约束
+	device, _ := GetPairingLockout("guard-peer", "")
+	first := time.Until(device.LockedUntil)
+	recordPairingFailure("guard-peer", "")
+	recordPairingFailure("guard-peer", "")
+	device, _ = GetPairingLockout("guard-peer", "")
+	if second := time.Until(device.LockedUntil); device.Lockouts != 2 || second < first+50*time.Second {
+		t.Errorf("Expected doubled lockout, first=%v, second=%v, lockouts=%d", first, second, device.Lockouts)
+	}
+	recordPairingFailure("guard-peer", "")
+	recordPairingFailure("guard-peer", "")
+	device, _ = GetPairingLockout("guard-peer", "")
+	if third := time.Until(device.LockedUntil); third > 3*time.Minute {
+		t.Errorf("Expected lockout to be capped, got %v", third)
+	}
+
+	// 重置后解除锁定
+	ResetPairingLockout("guard-peer", "10.0.0.1")
+	if err := checkPairingAllowed("guard-peer", "10.0.0.1"); err != nil {
+		t.Errorf("Expected lockout cleared after reset, got %v", err)
+	}
+}
+
+// 测试认证成功清除失败记录
+func TestPairingGuard_Success(t *testing.T) {
+	defer ResetPairingLockout("", "")
+
+	recordPairingFailure("success-peer", "10.0.0.3")
+	recordPairingSuccess("success-peer", "10.0.0.3")
+	device, addr := GetPairingLockout("success-peer", "10.0.0.3")
+	if device.Failures != 0 || addr.Failures != 0 {
+		t.Errorf("Expected failures cleared, got device=%+v, ip=%+v", device, addr)
+	}
+}
+
+// 测试同一PIN码失败次数达到上限后作废
+func TestPairingGuard_PinInvalidation(t *testing.T) {
+	InitDeviceAuthService()
+	defer DestroyDeviceAuthService()
+	defer ResetPairingLockout("", "")
+
+	const authReqId = 9301
+	context.SetAuthSessionContext(authReqId, &context.AuthSessionContext{
+		RequestID:    authReqId,
+		PinCode:      "123456",
+		PeerDeviceID: "pin-peer",
+		PeerIp:       "10.0.0.4",
+	})
+	defer context.DeleteAuthSessionContext(authReqId)
+
+	ga := gaInstance.(*realGroupAuthManager)
+	ga.pins[authReqId] = "123456"
+
+	var handle *hichain.HiChainHandle
+	for i := 0; i < DefaultPairingGuardPolicy().MaxAttemptsPerPin-1; i++ {
+		ga.onPakeFailure(authReqId, handle)
+	}
+	if _, exists := ga.pins[authReqId]; !exists {
+		t.Fatal("Expected pin to remain valid below limit")
+	}
+
+	ga.onPakeFailure(authReqId, handle)
+	if _, exists := ga.pins[authReqId]; exists {
+		t.Error("Expected pin to be invalidated")
+	}
+	if ctx, _ := context.GetAuthSessionContext(authReqId); ctx.PinCode != "" {
+		t.Errorf("Expected context pin cleared, got %q", ctx.PinCode)
+	}
+
+	device, addr := GetPairingLockout("pin-peer", "10.0.0.4")
+	if device.Failures != 3 || addr.Failures != 3 {
+		t.Errorf("Expected failures recorded for peer and ip, got device=%+v, ip=%+v", device, addr)
+	}
+}
@@ -0,0 +1,193 @@
+package device_auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/context"
+)
+
+// 测试默认提供者生成随机数字PIN码并交给展示回调
+func TestDefaultPairingCredentialProvider(t *testing.T) {
+	var displayed string
+	provider := &DefaultPairingCredentialProvider{
+		PinLength:    8,
+		OnDisplayPin: func(req *PairingRequest, pin string) { displayed = pin },
+	}
+
+	req := &PairingRequest{RequestId: 1, PeerDeviceId: "peer"}
+	pin, err := provider.GeneratePin(req)
+	if err != nil {
+		t.Fatalf("GeneratePin failed: %v", err)
+	}
+	if len(pin) != 8 {
+		t.Errorf("Expected 8-digit pin, got %q", pin)
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			t.Errorf("Expected digits only, got %q", pin)
+			break
+		}
+	}
+	if pin[0] == '0' {
+		t.Errorf("Expected non-zero leading digit, got %q", pin)
+	}
+	if displayed != pin {
+		t.Errorf("Expected displayed pin %q, got %q", pin, displayed)
+	}
+
+	// 默认接受请求，未设置输入回调时无法获取PIN码
+	if !provider.ConfirmRequest(req) {
+		t.Error("Expected request to be accepted by default")
+	}
+	if _, err := provider.InputPin(req); err == nil {
+		t.Error("Expected error without input handler")
+	}
+}
+
+// 测试设置/恢复配对凭据提供者
+func TestSetPairingCredentialProvider(t *testing.T) {
+	defer SetPairingCredentialProvider(nil)
+
+	SetPairingCredentialProvider(&DefaultPairingCredentialProvider{
+		OnConfirm:  func(req *PairingRequest) bool { return req.PeerDeviceId == "trusted" },
+		OnInputPin: func(req *PairingRequest) (string, error) { return "", nil },
+	})
+	if !ConfirmPairingRequest(&PairingRequest{PeerDeviceId: "trusted"}) {
+		t.Error("Expected trusted peer to be accepted")
+	}
+	if ConfirmPairingRequest(&PairingRequest{PeerDeviceId: "unknown"}) {
+		t.Error("Expected unknown peer to be rejected")
+	}
+	if _, err := InputPairingPin(&PairingRequest{}); err == nil {
+		t.Error("Expected error for empty pin")
+	}
+
+	SetPairingCredentialProvider(nil)
+	if _, ok := GetPairingCredentialProvider().(*DefaultPairingCredentialProvider); !ok {
+		t.Error("Expected default provider after reset")
+	}
+	if pin, err := GeneratePairingPin(&PairingRequest{}); err != nil || len(pin) != DefaultPinLength {
+		t.Errorf("Expected default pin, got %q, %v", pin, err)
+	}
+}
+
+// 测试解析OnRequest响应
+func TestParseRequestResponse(t *testing.T) {
+	tests := []struct {
+		response string
+		decided  bool
+		accepted bool
+		pinCode  string
+	}{
+		{"", false, false, ""},
+		{"{}", false, false, ""},
+		{`{"pinCode":"123456"}`, false, false, "123456"},
+		{`{"confirmation":2147483654,"pinCode":"654321"}`, true, true, "654321"},
+		{`{"confirmation":2147483653}`, true, false, ""},
+		{"not-json", true, false, ""},
+	}
+
+	for _, tt := range tests {
+		decided, accepted, pinCode := parseRequestResponse(tt.response)
+		if decided != tt.decided || accepted != tt.accepted || pinCode != tt.pinCode {
+			t.Errorf("parseRequestResponse(%q) = %v, %v, %q; want %v, %v, %q",
+				tt.response, decided, accepted, pinCode, tt.decided, tt.accepted, tt.pinCode)
+		}
+	}
+}
+
+// 测试发起方PIN码输入不阻塞认证消息处理：输入完成前收到的消息暂存，输入后继续完成认证
+func TestInputPinAsync(t *testing.T) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	defer DestroyDeviceAuthService()
+	defer ResetPairingLockout("", "")
+
+	const clientReqId, serverReqId = 6301, 6401
+
+	// 只响应本测试的请求（其他测试遗留的输入goroutine可能稍后才调用Provider）
+	pinInput := make(chan string)
+	SetPairingCredentialProvider(&DefaultPairingCredentialProvider{
+		OnInputPin: func(req *PairingRequest) (string, error) {
+			if req.RequestId != clientReqId {
+				return "", fmt.Errorf("unexpected request: %d", req.RequestId)
+			}
+			return <-pinInput, nil
+		},
+	})
+	defer SetPairingCredentialProvider(nil)
+
+	context.SetAuthSessionContext(clientReqId, &context.AuthSessionContext{
+		RequestID: clientReqId, LocalDeviceID: "async-pin-a", PeerDeviceID: "async-pin-b",
+	})
+	defer context.DeleteAuthSessionContext(clientReqId)
+	context.SetAuthSessionContext(serverReqId, &context.AuthSessionContext{
+		RequestID: serverReqId, LocalDeviceID: "async-pin-b",
+	})
+	defer context.DeleteAuthSessionContext(serverReqId)
+
+	client, _ := GetGaInstance()
+	server := newRealGroupAuthManager()
+
+	toServer := make(chan []byte, 4)
+	toClient := make(chan []byte, 4)
+	finished := make(chan struct{}, 1)
+	clientCb := &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool { toServer <- data; return true },
+		OnFinish:   func(requestId int64, operationCode int32, returnData string) { finished <- struct{}{} },
+	}
+	serverCb := &DeviceAuthCallback{
+		OnTransmit: func(requestId int64, data []byte) bool { toClient <- data; return true },
+		OnRequest: func(requestId int64, operationCode int32, reqParams string) string {
+			return fmt.Sprintf(`{"confirmation":%d,"pinCode":"135790"}`, RequestAccepted)
+		},
+	}
+
+	// 发起认证和处理PAKE_RESPONSE都不等待用户输入
+	done := make(chan error, 1)
+	go func() {
+		done <- client.AuthDevice(AnyOsAccount, clientReqId, `{"peerUdid":"async-pin-b"}`, clientCb)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AuthDevice failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AuthDevice blocked on pin input")
+	}
+
+	server.ProcessData(serverReqId, <-toServer, serverCb)
+	go func() { done <- client.ProcessData(clientReqId, <-toClient, clientCb) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ProcessData failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ProcessData blocked on pin input")
+	}
+	select {
+	case data := <-toServer:
+		t.Fatalf("Unexpected message before pin input: %s", data)
+	default:
+	}
+
+	// 输入PIN码后继续处理暂存的PAKE_RESPONSE并完成认证
+	pinInput <- "135790"
+	for {
+		select {
+		case data := <-toServer:
+			server.ProcessData(serverReqId, data, serverCb)
+		case data := <-toClient:
+			client.ProcessData(clientReqId, data, clientCb)
+		case <-finished:
+			return
+		case <-time.After(3 * time.Second):
+			t.Fatal("Auth not finished after pin input")
+		}
+	}
+}
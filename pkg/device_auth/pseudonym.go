@@ -0,0 +1,355 @@
+package device_auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/storage"
+)
+
+// ============================================================================
+// 假名ID
+// ============================================================================
+//
+// 第三方应用只能看到对端设备的假名ID，不能看到真实UDID:
+//   pseudonymId = HMAC-SHA256(本地密钥, appId || 0x00 || peerUdid)（十六进制大写）
+// 同一设备对不同应用的假名不同，不同设备对同一应用的假名不同。
+// 本地密钥随机生成，RotatePseudonymSecret轮换后生成新的假名；上一代假名仍可反查，再次轮换后失效。
+// 反查表记录GetPseudonymId生成过的假名，GetRealInfo只对软总线内部（AUTH_APPID和SetPseudonymResolvers授权的调用方）返回真实UDID，
+// 假名的所属应用也不能反查。调用方身份由获取GroupAuthManager实例的方式确定（GetGaInstance为AUTH_APPID，
+// GetGaInstanceForApp为注册的appId），不使用请求参数中自行声明的appId；应用只能获取自己的假名。
+// DataChangeListener、绑定/解绑的OnFinish和DeviceGroupManager的设备查询中，AUTH_APPID以外的应用收到的设备标识
+// 同样是该应用的假名ID，查询参数中的设备标识也只接受该应用的假名ID。
+// 调用EnablePseudonymStore后密钥和反查表加密保存在 <dataDir>/pseudonyms.json，重启后假名保持不变。
+
+const (
+	pseudonymSecretLen     = 32
+	pseudonymStoreFileName = "pseudonyms.json"
+	pseudonymStoreVersion  = 1
+	pseudonymWrapPurpose   = "pseudonym"
+)
+
+var (
+	// ErrPseudonymNotFound 假名不存在或已随密钥轮换失效
+	ErrPseudonymNotFound = errors.New("pseudonym not found")
+	// ErrPseudonymAccessDenied 调用方无权反查该假名
+	ErrPseudonymAccessDenied = errors.New("pseudonym access denied")
+)
+
+// pseudonymEntry 反查表项
+type pseudonymEntry struct {
+	AppID      string `json:"appId"`
+	PeerUdid   string `json:"peerUdid"`
+	Generation int    `json:"generation"` // 生成时的密钥代数
+}
+
+// pseudonymState 假名密钥和反查表（同时是持久化文件内容）
+type pseudonymState struct {
+	Version    int                        `json:"version"`
+	Generation int                        `json:"generation"` // 当前密钥代数
+	Secret     string                     `json:"secret"`     // 当前密钥（十六进制）
+	Table      map[string]*pseudonymEntry `json:"table"`      // pseudonymId -> 真实信息
+}
+
+var (
+	g_pseudonyms         *pseudonymState // 首次使用时生成
+	g_pseudonymStore     *pseudonymStore // 持久化存储（nil表示仅保存在内存）
+	g_pseudonymResolvers map[string]bool // 除AUTH_APPID外可以反查真实UDID的内部调用方
+	g_pseudonymMu        sync.Mutex
+)
+
+// pseudonymIndexKey GetPseudonymId的参数
+type pseudonymIndexKey struct {
+	AppID    string `json:"appId"`
+	PeerUdid string `json:"peerUdid"`
+}
+
+// pseudonymQuery GetRealInfo的参数
+type pseudonymQuery struct {
+	PseudonymID string `json:"pseudonymId"`
+}
+
+// getPseudonymId 获取应用看到的对端假名ID，并记录到反查表
+// caller: 调用方身份；indexKey: {"appId":"...","peerUdid":"..."}，appId为空时为调用方自己
+// 只有软总线内部可以获取其他应用的假名
+func getPseudonymId(caller string, indexKey string) (string, error) {
+	var params pseudonymIndexKey
+	if err := json.Unmarshal([]byte(indexKey), &params); err != nil {
+		return "", fmt.Errorf("invalid indexKey: %w", err)
+	}
+	if params.AppID == "" {
+		params.AppID = caller
+	}
+	if params.AppID == "" || params.PeerUdid == "" {
+		return "", fmt.Errorf("appId and peerUdid are required")
+	}
+	if params.AppID != caller && caller != AUTH_APPID {
+		log.Warnf("[DEVICE_AUTH] Pseudonym access denied: caller=%s, appId=%s", caller, params.AppID)
+		return "", ErrPseudonymAccessDenied
+	}
+	return pseudonymFor(params.AppID, params.PeerUdid)
+}
+
+// appVisibleUdid 应用可见的对端设备标识：AUTH_APPID为真实UDID，其他应用为该应用的假名ID
+func appVisibleUdid(appId string, peerUdid string) (string, error) {
+	if appId == AUTH_APPID {
+		return peerUdid, nil
+	}
+	return pseudonymFor(appId, peerUdid)
+}
+
+// appDeviceUdid 将应用传入的对端设备标识还原为真实UDID：AUTH_APPID直接使用，
+// 其他应用只接受该应用自己的假名ID（其他应用的假名和真实UDID视为不存在）
+func appDeviceUdid(appId string, deviceId string) (string, bool) {
+	if appId == AUTH_APPID {
+		return deviceId, true
+	}
+
+	g_pseudonymMu.Lock()
+	defer g_pseudonymMu.Unlock()
+
+	if g_pseudonyms == nil {
+		return "", false
+	}
+	entry, exists := g_pseudonyms.Table[strings.ToUpper(deviceId)]
+	if !exists || entry.AppID != appId {
+		return "", false
+	}
+	return entry.PeerUdid, true
+}
+
+// pseudonymFor 获取应用看到的对端假名ID，并记录到反查表
+func pseudonymFor(appId string, peerUdid string) (string, error) {
+	g_pseudonymMu.Lock()
+	defer g_pseudonymMu.Unlock()
+
+	state, err := pseudonymStateLocked()
+	if err != nil {
+		return "", err
+	}
+	secret, _ := hex.DecodeString(state.Secret)
+	pseudonymId := derivePseudonym(secret, appId, peerUdid)
+	if _, exists := state.Table[pseudonymId]; !exists {
+		state.Table[pseudonymId] = &pseudonymEntry{AppID: appId, PeerUdid: peerUdid, Generation: state.Generation}
+		if err := savePseudonymsLocked(); err != nil {
+			delete(state.Table, pseudonymId)
+			return "", err
+		}
+	}
+	return pseudonymId, nil
+}
+
+// getRealInfo 通过假名反查真实UDID（仅限软总线内部调用方）
+// caller: 调用方身份；query: {"pseudonymId":"..."}，返回 {"appId":"...","peerUdid":"..."}
+func getRealInfo(caller string, query string) (string, error) {
+	var params pseudonymQuery
+	if err := json.Unmarshal([]byte(query), &params); err != nil {
+		return "", fmt.Errorf("invalid pseudonymId: %w", err)
+	}
+	if params.PseudonymID == "" {
+		return "", fmt.Errorf("pseudonymId is required")
+	}
+
+	g_pseudonymMu.Lock()
+	defer g_pseudonymMu.Unlock()
+
+	state, err := pseudonymStateLocked()
+	if err != nil {
+		return "", err
+	}
+	entry, exists := state.Table[strings.ToUpper(params.PseudonymID)]
+	if !exists {
+		return "", ErrPseudonymNotFound
+	}
+	if caller != AUTH_APPID && !g_pseudonymResolvers[caller] {
+		log.Warnf("[DEVICE_AUTH] Pseudonym access denied: caller=%s, owner=%s", caller, entry.AppID)
+		return "", ErrPseudonymAccessDenied
+	}
+	data, _ := json.Marshal(map[string]string{"appId": entry.AppID, "peerUdid": entry.PeerUdid})
+	return string(data), nil
+}
+
+// SetPseudonymResolvers 设置除AUTH_APPID外可以通过GetRealInfo反查真实UDID的软总线内部调用方（替换原有列表）
+// 不应授权第三方应用
+func SetPseudonymResolvers(appIds ...string) {
+	resolvers := make(map[string]bool, len(appIds))
+	for _, appId := range appIds {
+		resolvers[appId] = true
+	}
+
+	g_pseudonymMu.Lock()
+	g_pseudonymResolvers = resolvers
+	g_pseudonymMu.Unlock()
+}
+
+// RotatePseudonymSecret 轮换假名密钥
+// 之后生成的假名使用新密钥；上一代假名仍可反查，更早的假名从反查表中删除
+func RotatePseudonymSecret() error {
+	g_pseudonymMu.Lock()
+	defer g_pseudonymMu.Unlock()
+
+	state, err := pseudonymStateLocked()
+	if err != nil {
+		return err
+	}
+	secret, err := newPseudonymSecret()
+	if err != nil {
+		return err
+	}
+
+	previous := *state
+	rotated := &pseudonymState{
+		Version:    pseudonymStoreVersion,
+		Generation: state.Generation + 1,
+		Secret:     secret,
+		Table:      make(map[string]*pseudonymEntry),
+	}
+	for pseudonymId, entry := range state.Table {
+		if entry.Generation >= state.Generation {
+			rotated.Table[pseudonymId] = entry
+		}
+	}
+	g_pseudonyms = rotated
+	if err := savePseudonymsLocked(); err != nil {
+		g_pseudonyms = &previous
+		return err
+	}
+	log.Infof("[DEVICE_AUTH] Pseudonym secret rotated: generation=%d", rotated.Generation)
+	return nil
+}
+
+// EnablePseudonymStore 启用假名密钥和反查表持久化
+// 文件存在时以文件为准（启用前生成的假名失效），否则保存当前状态
+func EnablePseudonymStore(dataDir string) error {
+	if dataDir == "" {
+		return fmt.Errorf("data dir is empty")
+	}
+	if err := storage.EnsureDir(dataDir); err != nil {
+		return err
+	}
+	wrapKey, err := storage.LoadOrCreateWrapKey(dataDir, pseudonymWrapPurpose)
+	if err != nil {
+		return fmt.Errorf("failed to load wrap key: %w", err)
+	}
+	store := &pseudonymStore{path: filepath.Join(dataDir, pseudonymStoreFileName), wrapKey: wrapKey}
+
+	state, err := store.load()
+	if err != nil {
+		return fmt.Errorf("failed to load pseudonyms: %w", err)
+	}
+
+	g_pseudonymMu.Lock()
+	defer g_pseudonymMu.Unlock()
+
+	if state == nil {
+		if state, err = pseudonymStateLocked(); err != nil {
+			return err
+		}
+		if err := store.save(state); err != nil {
+			return err
+		}
+	}
+	g_pseudonyms = state
+	g_pseudonymStore = store
+	log.Infof("[DEVICE_AUTH] Pseudonym store enabled: path=%s, generation=%d, entries=%d",
+		store.path, state.Generation, len(state.Table))
+	return nil
+}
+
+// pseudonymStateLocked 获取假名状态，首次使用时生成密钥（调用方持有锁）
+func pseudonymStateLocked() (*pseudonymState, error) {
+	if g_pseudonyms != nil {
+		return g_pseudonyms, nil
+	}
+	secret, err := newPseudonymSecret()
+	if err != nil {
+		return nil, err
+	}
+	g_pseudonyms = &pseudonymState{
+		Version: pseudonymStoreVersion,
+		Secret:  secret,
+		Table:   make(map[string]*pseudonymEntry),
+	}
+	return g_pseudonyms, nil
+}
+
+// savePseudonymsLocked 写入持久化存储（调用方持有锁）
+func savePseudonymsLocked() error {
+	if g_pseudonymStore == nil {
+		return nil
+	}
+	if err := g_pseudonymStore.save(g_pseudonyms); err != nil {
+		log.Errorf("[DEVICE_AUTH] Failed to persist pseudonyms: %v", err)
+		return fmt.Errorf("failed to persist pseudonyms: %w", err)
+	}
+	return nil
+}
+
+// newPseudonymSecret 生成随机假名密钥（十六进制）
+func newPseudonymSecret() (string, error) {
+	secret := make([]byte, pseudonymSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate pseudonym secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// derivePseudonym 计算假名: HMAC-SHA256(secret, appId || 0x00 || peerUdid)
+func derivePseudonym(secret []byte, appId string, peerUdid string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(appId))
+	mac.Write([]byte{0})
+	mac.Write([]byte(peerUdid))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// pseudonymStore 假名文件存储
+type pseudonymStore struct {
+	path    string
+	wrapKey []byte
+}
+
+// load 加载并解密假名文件（不存在时返回nil）
+func (s *pseudonymStore) load() (*pseudonymState, error) {
+	data, err := storage.ReadSealedFile(s.path, s.wrapKey, []byte(pseudonymWrapPurpose))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state pseudonymState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse pseudonym store: %w", err)
+	}
+	if state.Version != pseudonymStoreVersion {
+		return nil, fmt.Errorf("unsupported pseudonym store version: %d", state.Version)
+	}
+	if secret, err := hex.DecodeString(state.Secret); err != nil || len(secret) != pseudonymSecretLen {
+		return nil, fmt.Errorf("invalid pseudonym secret")
+	}
+	if state.Table == nil {
+		state.Table = make(map[string]*pseudonymEntry)
+	}
+	return &state, nil
+}
+
+// save 加密并原子写入假名文件
+func (s *pseudonymStore) save(state *pseudonymState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pseudonyms: %w", err)
+	}
+	return storage.WriteSealedFile(s.path, s.wrapKey, data, []byte(pseudonymWrapPurpose))
+}
@@ -0,0 +1,223 @@
+package device_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// resetPseudonyms 清空假名密钥和反查表并关闭持久化（模拟重启）
+func resetPseudonyms() {
+	g_pseudonymMu.Lock()
+	g_pseudonyms = nil
+	g_pseudonymStore = nil
+	g_pseudonymMu.Unlock()
+}
+
+// testAppDeviceId 应用查询时使用的对端设备标识（该应用的假名ID）
+func testAppDeviceId(t *testing.T, appId string, udid string) string {
+	t.Helper()
+	deviceId, err := appVisibleUdid(appId, udid)
+	if err != nil {
+		t.Fatalf("appVisibleUdid failed: %v", err)
+	}
+	return deviceId
+}
+
+// 测试假名按应用和设备区分，只有AUTH_APPID和授权的内部调用方可以反查；
+// 调用方身份由实例确定，应用在请求中声明AUTH_APPID无效
+func TestPseudonym_Lookup(t *testing.T) {
+	if err := InitDeviceAuthService(); err != nil {
+		t.Fatalf("InitDeviceAuthService failed: %v", err)
+	}
+	defer DestroyDeviceAuthService()
+	resetPseudonyms()
+	defer resetPseudonyms()
+
+	if _, err := GetGaInstanceForApp(AUTH_APPID); err == nil {
+		t.Error("Expected AUTH_APPID to be reserved for the internal instance")
+	}
+	ga, _ := GetGaInstance()
+	appA, _ := GetGaInstanceForApp("app-a")
+	appB, _ := GetGaInstanceForApp("app-b")
+	if _, err := appA.GetPseudonymId(AnyOsAccount, `{"appId":"app-a"}`); err == nil {
+		t.Error("Expected error without peerUdid")
+	}
+
+	pseudoA, err := appA.GetPseudonymId(AnyOsAccount, `{"peerUdid":"pseudo-udid-1"}`)
+	if err != nil {
+		t.Fatalf("GetPseudonymId failed: %v", err)
+	}
+	again, _ := ga.GetPseudonymId(AnyOsAccount, `{"appId":"app-a","peerUdid":"pseudo-udid-1"}`)
+	pseudoB, _ := appB.GetPseudonymId(AnyOsAccount, `{"appId":"app-b","peerUdid":"pseudo-udid-1"}`)
+	other, _ := appA.GetPseudonymId(AnyOsAccount, `{"appId":"app-a","peerUdid":"pseudo-udid-2"}`)
+	if pseudoA != again {
+		t.Error("Expected stable pseudonym for the same app and device")
+	}
+	if pseudoA == pseudoB || pseudoA == other || pseudoA == "pseudo-udid-1" {
+		t.Errorf("Expected distinct pseudonyms, got %s %s %s", pseudoA, pseudoB, other)
+	}
+	// 应用不能获取其他应用的假名
+	if _, err := appA.GetPseudonymId(AnyOsAccount, `{"appId":"app-b","peerUdid":"pseudo-udid-1"}`); !errors.Is(err, ErrPseudonymAccessDenied) {
+		t.Errorf("Expected access denied for another app's pseudonym, got %v", err)
+	}
+
+	// 只有软总线内部可以反查，假名的所属应用和其他应用都不能
+	defer SetPseudonymResolvers()
+	SetPseudonymResolvers("internal-app")
+	internal, _ := GetGaInstanceForApp("internal-app")
+	for _, caller := range []GroupAuthManager{ga, internal} {
+		result, err := caller.GetRealInfo(AnyOsAccount, `{"pseudonymId":"`+pseudoA+`"}`)
+		if err != nil {
+			t.Fatalf("GetRealInfo failed: %v", err)
+		}
+		var info map[string]string
+		json.Unmarshal([]byte(result), &info)
+		if info["peerUdid"] != "pseudo-udid-1" || info["appId"] != "app-a" {
+			t.Errorf("Unexpected real info: %s", result)
+		}
+	}
+	for _, caller := range []GroupAuthManager{appA, appB} {
+		// 请求中声明的appId不影响调用方身份
+		query := `{"appId":"` + AUTH_APPID + `","pseudonymId":"` + pseudoA + `"}`
+		if _, err := caller.GetRealInfo(AnyOsAccount, query); !errors.Is(err, ErrPseudonymAccessDenied) {
+			t.Errorf("Expected access denied for app claiming %s, got %v", AUTH_APPID, err)
+		}
+	}
+	if _, err := ga.GetRealInfo(AnyOsAccount, `{"pseudonymId":"UNKNOWN"}`); !errors.Is(err, ErrPseudonymNotFound) {
+		t.Errorf("Expected not found for unknown pseudonym, got %v", err)
+	}
+}
+
+// 测试DeviceGroupManager的设备查询对AUTH_APPID以外的应用只返回和接受该应用的假名ID
+func TestPseudonym_GroupQueries(t *testing.T) {
+	gm := newDeviceGroupManager()
+	gm.CreateGroup(AnyOsAccount, 1, "app_a", `{"groupId":"PSEUDO_001","groupType":256}`)
+	gm.AddGroupFriend(AnyOsAccount, "app_a", "PSEUDO_001", "app_b")
+	gm.AddMemberToGroup(AnyOsAccount, 2, "app_a", `{"groupId":"PSEUDO_001","deviceId":"pseudo-dev","udid":"pseudo-udid","authId":"pseudo-auth"}`)
+
+	raw := []string{"pseudo-dev", "pseudo-udid", "pseudo-auth"}
+	assertNoRawIds := func(appId string, info string) {
+		t.Helper()
+		for _, id := range raw {
+			if bytes.Contains([]byte(info), []byte(id)) {
+				t.Errorf("Expected no real device id for %s, got %s", appId, info)
+			}
+		}
+	}
+
+	for _, appId := range []string{"app_a", "app_b"} {
+		devices, err := gm.GetTrustedDevices(AnyOsAccount, appId, "PSEUDO_001")
+		if err != nil || len(devices) != 1 {
+			t.Fatalf("GetTrustedDevices failed: %v, %v", devices, err)
+		}
+		assertNoRawIds(appId, devices[0])
+		var device map[string]string
+		json.Unmarshal([]byte(devices[0]), &device)
+		if device["deviceId"] != testAppDeviceId(t, appId, "pseudo-dev") || device["udid"] != testAppDeviceId(t, appId, "pseudo-udid") {
+			t.Errorf("Expected %s pseudonyms, got %s", appId, devices[0])
+		}
+
+		// 查询参数使用返回的假名
+		info, err := gm.GetDeviceInfoById(AnyOsAccount, appId, device["deviceId"], "PSEUDO_001")
+		if err != nil {
+			t.Fatalf("GetDeviceInfoById failed: %v", err)
+		}
+		assertNoRawIds(appId, info)
+		if !gm.IsDeviceInGroup(AnyOsAccount, appId, "PSEUDO_001", device["deviceId"]) {
+			t.Errorf("Expected %s to find the device by pseudonym", appId)
+		}
+		if groups, _ := gm.GetRelatedGroups(AnyOsAccount, appId, device["deviceId"]); len(groups) != 1 {
+			t.Errorf("Expected 1 related group for %s, got %d", appId, len(groups))
+		}
+
+		// 真实设备标识不能用于查询
+		if gm.IsDeviceInGroup(AnyOsAccount, appId, "PSEUDO_001", "pseudo-dev") {
+			t.Errorf("Expected real device id to be refused for %s", appId)
+		}
+		if _, err := gm.GetDeviceInfoById(AnyOsAccount, appId, "pseudo-dev", "PSEUDO_001"); err == nil {
+			t.Errorf("Expected GetDeviceInfoById by real device id to fail for %s", appId)
+		}
+		if groups, _ := gm.GetRelatedGroups(AnyOsAccount, appId, "pseudo-dev"); len(groups) != 0 {
+			t.Errorf("Expected no related group by real device id for %s, got %d", appId, len(groups))
+		}
+	}
+
+	// 其他应用的假名不能用于查询
+	if gm.IsDeviceInGroup(AnyOsAccount, "app_b", "PSEUDO_001", testAppDeviceId(t, "app_a", "pseudo-dev")) {
+		t.Error("Expected another app's pseudonym to be refused")
+	}
+
+	// 软总线内部看到和使用真实设备标识
+	devices, _ := gm.GetTrustedDevices(AnyOsAccount, AUTH_APPID, "PSEUDO_001")
+	if len(devices) != 1 || devices[0] != `{"deviceId":"pseudo-dev","udid":"pseudo-udid","authId":"pseudo-auth"}` {
+		t.Errorf("Expected real device ids for %s, got %v", AUTH_APPID, devices)
+	}
+	if !gm.IsDeviceInGroup(AnyOsAccount, AUTH_APPID, "PSEUDO_001", "pseudo-dev") {
+		t.Errorf("Expected %s to find the device by real id", AUTH_APPID)
+	}
+}
+
+// 测试密钥轮换：新假名不同，上一代假名仍可反查，再次轮换后失效
+func TestPseudonym_Rotation(t *testing.T) {
+	resetPseudonyms()
+	defer resetPseudonyms()
+
+	indexKey := `{"appId":"app-a","peerUdid":"pseudo-udid-1"}`
+	gen0, _ := getPseudonymId(AUTH_APPID, indexKey)
+	if err := RotatePseudonymSecret(); err != nil {
+		t.Fatalf("RotatePseudonymSecret failed: %v", err)
+	}
+	gen1, _ := getPseudonymId(AUTH_APPID, indexKey)
+	if gen0 == gen1 {
+		t.Fatal("Expected new pseudonym after rotation")
+	}
+	if _, err := getRealInfo(AUTH_APPID, `{"pseudonymId":"`+gen0+`"}`); err != nil {
+		t.Errorf("Expected previous generation resolvable, got %v", err)
+	}
+
+	RotatePseudonymSecret()
+	if _, err := getRealInfo(AUTH_APPID, `{"pseudonymId":"`+gen0+`"}`); !errors.Is(err, ErrPseudonymNotFound) {
+		t.Errorf("Expected expired pseudonym after second rotation, got %v", err)
+	}
+	if _, err := getRealInfo(AUTH_APPID, `{"pseudonymId":"`+gen1+`"}`); err != nil {
+		t.Errorf("Expected previous generation resolvable, got %v", err)
+	}
+}
+
+// 测试假名密钥和反查表加密持久化，重启后假名不变
+func TestPseudonymStore_Persistence(t *testing.T) {
+	resetPseudonyms()
+	defer resetPseudonyms()
+	dir := t.TempDir()
+
+	if err := EnablePseudonymStore(dir); err != nil {
+		t.Fatalf("EnablePseudonymStore failed: %v", err)
+	}
+	indexKey := `{"appId":"app-a","peerUdid":"pseudo-udid-1"}`
+	before, err := getPseudonymId(AUTH_APPID, indexKey)
+	if err != nil {
+		t.Fatalf("GetPseudonymId failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, pseudonymStoreFileName))
+	if err != nil {
+		t.Fatalf("Pseudonym file not written: %v", err)
+	}
+	if bytes.Contains(data, []byte("pseudo-udid-1")) {
+		t.Error("Expected pseudonym file to be encrypted")
+	}
+
+	resetPseudonyms()
+	if err := EnablePseudonymStore(dir); err != nil {
+		t.Fatalf("EnablePseudonymStore reload failed: %v", err)
+	}
+	if _, err := getRealInfo(AUTH_APPID, `{"pseudonymId":"`+before+`"}`); err != nil {
+		t.Errorf("Expected pseudonym resolvable after restart, got %v", err)
+	}
+	if after, _ := getPseudonymId(AUTH_APPID, indexKey); after != before {
+		t.Error("Expected same pseudonym after restart")
+	}
+}
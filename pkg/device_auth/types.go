@@ -81,6 +81,7 @@ const (
 	CredOpCreate  int32 = 1  // ProcessCredential的创建凭证操作码
 	CredOpImport  int32 = 2  // ProcessCredential的导入凭证操作码
 	CredOpDelete  int32 = 3  // ProcessCredential的删除凭证操作码
+	CredOpExport  int32 = 4  // ProcessCredential的导出凭证操作码
 )
 
 // ReturnFlag 返回标志
@@ -113,6 +114,17 @@ const (
 	HC_SUCCESS           int32 = 0
 	HC_ERR               int32 = -1
 	HC_ERR_INVALID_PARAMS int32 = -2
+	HC_ERR_GROUP_NOT_EXIST   int32 = -3 // 可信组不存在
+	HC_ERR_ACCESS_DENIED     int32 = -4 // 应用无权读取可信组
+	HC_ERR_NOT_GROUP_MANAGER int32 = -5 // 应用不是可信组的创建者或管理者
+	HC_ERR_NOT_GROUP_OWNER   int32 = -6 // 应用不是可信组的创建者
+	HC_ERR_REQUEST_CANCELLED int32 = -7 // 请求已被取消
+	HC_ERR_PIN_MISMATCH        int32 = -8  // PIN码错误（PAKE确认数据校验失败）
+	HC_ERR_VERSION_UNSUPPORTED int32 = -9  // 与对端协议版本不兼容
+	HC_ERR_PEER_CANCELLED      int32 = -10 // 对端取消了请求
+	HC_ERR_TIMEOUT             int32 = -11 // 请求超时
+	HC_ERR_LOCKED_OUT          int32 = -12 // 配对失败次数过多，处于锁定期
+	HC_ERR_REQUEST_REJECTED    int32 = -13 // 对端拒绝了配对请求
 )
 
 // ============================================================================
@@ -250,6 +262,24 @@ type DeviceGroupManager interface {
 	// IsDeviceInGroup 查询组中是否存在指定设备
 	IsDeviceInGroup(osAccountId int32, appId string, groupId string, deviceId string) bool
 
+	// AddGroupManager 添加组管理者（只有创建者可以操作）
+	AddGroupManager(osAccountId int32, appId string, groupId string, managerAppId string) error
+
+	// DeleteGroupManager 删除组管理者（只有创建者可以操作）
+	DeleteGroupManager(osAccountId int32, appId string, groupId string, managerAppId string) error
+
+	// AddGroupFriend 添加组好友（好友只能读取组）
+	AddGroupFriend(osAccountId int32, appId string, groupId string, friendAppId string) error
+
+	// DeleteGroupFriend 删除组好友
+	DeleteGroupFriend(osAccountId int32, appId string, groupId string, friendAppId string) error
+
+	// GetGroupManagers 获取组管理者列表
+	GetGroupManagers(osAccountId int32, appId string, groupId string) ([]string, error)
+
+	// GetGroupFriends 获取组好友列表
+	GetGroupFriends(osAccountId int32, appId string, groupId string) ([]string, error)
+
 	// CancelRequest 取消绑定或解绑过程
 	CancelRequest(requestId int64, appId string)
 
@@ -3,12 +3,14 @@ package frame
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/junbin-yang/dsoftbus-go/pkg/authentication"
 	"github.com/junbin-yang/dsoftbus-go/pkg/bus_center"
 	"github.com/junbin-yang/dsoftbus-go/pkg/device_auth"
 	"github.com/junbin-yang/dsoftbus-go/pkg/discovery/service"
 	"github.com/junbin-yang/dsoftbus-go/pkg/transmission"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/config"
 	"github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
 )
 
@@ -123,6 +125,34 @@ func discServerInit() error {
 	return nil
 }
 
+// admissionPolicyFromConfig 将配置文件中的准入策略转换为authentication策略
+// 数值项为0时使用默认值，负数表示不限制
+func admissionPolicyFromConfig(conf *config.Config) authentication.AdmissionPolicy {
+	policy := authentication.DefaultAdmissionPolicy()
+	c := conf.Admission
+
+	policy.UdidAllowList = c.UdidAllowList
+	policy.UdidDenyList = c.UdidDenyList
+	policy.IpAllowList = c.IpAllowList
+	policy.IpDenyList = c.IpDenyList
+
+	pick := func(value int, def int) int {
+		switch {
+		case value < 0:
+			return 0
+		case value == 0:
+			return def
+		default:
+			return value
+		}
+	}
+	policy.MaxUnauthConns = pick(c.MaxUnauthConns, policy.MaxUnauthConns)
+	policy.RateLimit = pick(c.RateLimit, policy.RateLimit)
+	policy.RateWindow = time.Duration(pick(c.RateWindow, int(policy.RateWindow/time.Second))) * time.Second
+	policy.AuthTimeout = time.Duration(pick(c.AuthTimeout, int(policy.AuthTimeout/time.Second))) * time.Second
+	return policy
+}
+
 // authInit 初始化Authentication服务
 func authInit() error {
 	// 初始化DeviceAuth服务
@@ -131,6 +161,30 @@ func authInit() error {
 	}
 	logger.Info("[Frame] DeviceAuth服务已初始化")
 
+	// 绑定/解绑协议使用本地UDID作为本端身份
+	if udid, err := authentication.GetLocalUDID(); err == nil {
+		device_auth.SetLocalDeviceUdid(udid)
+	} else {
+		logger.Warnf("[Frame] 获取本地UDID失败: %v", err)
+	}
+
+	// 启用可信组、长期密钥、凭据和假名持久化（重启后保留已配对设备、本地身份、预置凭据和假名ID）
+	if conf := config.Get(); conf != nil && conf.DataDir != "" {
+		if err := device_auth.EnableGroupStore(conf.DataDir); err != nil {
+			logger.Warnf("[Frame] 可信组持久化启用失败: %v", err)
+		}
+		if err := device_auth.EnableFileKeyStore(conf.DataDir); err != nil {
+			logger.Warnf("[Frame] 长期密钥持久化启用失败: %v", err)
+		}
+		// 凭据中的对端公钥需要写入长期密钥存储，在其之后启用
+		if err := device_auth.EnableCredentialStore(conf.DataDir); err != nil {
+			logger.Warnf("[Frame] 凭据持久化启用失败: %v", err)
+		}
+		if err := device_auth.EnablePseudonymStore(conf.DataDir); err != nil {
+			logger.Warnf("[Frame] 假名持久化启用失败: %v", err)
+		}
+	}
+
 	// 初始化AuthDevice（认证管理器）
 	// 创建默认回调，转发认证事件到Bus Center
 	bc := bus_center.GetInstance()
@@ -154,6 +208,20 @@ func authInit() error {
 	}
 	logger.Info("[Frame] AuthDevice已初始化")
 
+	// 启用会话密钥持久化（守护进程重启后可复用已有密钥）
+	if conf := config.Get(); conf != nil && conf.DataDir != "" {
+		if _, err := authentication.EnableFileSessionKeyPersistor(conf.DataDir); err != nil {
+			logger.Warnf("[Frame] 会话密钥持久化启用失败: %v", err)
+		}
+	}
+
+	// 应用认证准入策略（需在开始监听之前生效）
+	if conf := config.Get(); conf != nil {
+		if err := authentication.SetAdmissionPolicy(admissionPolicyFromConfig(conf)); err != nil {
+			return fmt.Errorf("认证准入策略配置错误: %v", err)
+		}
+	}
+
 	// 启动认证TCP监听
 	authPort, err := authentication.StartSocketListening(authentication.Auth, "0.0.0.0", 0)
 	if err != nil {
@@ -1,6 +1,8 @@
 package transmission
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 
@@ -68,13 +70,27 @@ func onAuthChannelDataRecv(channelId int, data *authentication.AuthChannelData)
 	log.Infof("[TRANS_AUTH] Received AUTH_CHANNEL: channelId=%d, flag=%d, len=%d",
 		channelId, data.Flag, data.Len)
 
+	cleanData := cleanJSONData(data.Data)
+
+	// flag=1 表示回复，仅处理打开会话回复
+	if data.Flag == 1 {
+		var reply AuthChannelReplyMsg
+		if err := json.Unmarshal(cleanData, &reply); err != nil {
+			log.Errorf("[TRANS_AUTH] Failed to parse reply: %v", err)
+			return
+		}
+		if reply.PeerSessionID != 0 {
+			handleOpenSessionReply(channelId, &reply)
+		}
+		return
+	}
+
 	// flag=0 表示请求
 	if data.Flag != 0 {
 		return
 	}
 
-	// 清理并解析请求
-	cleanData := cleanJSONData(data.Data)
+	// 解析请求
 	log.Infof("[TRANS_AUTH] AUTH_CHANNEL request: %s", string(cleanData))
 
 	var req AuthChannelRequestMsg
@@ -83,6 +99,16 @@ func onAuthChannelDataRecv(channelId int, data *authentication.AuthChannelData)
 		return
 	}
 
+	// 携带SESSION_ID的请求为打开/关闭会话请求
+	if req.SessionID != 0 {
+		if req.Code == authChannelCodeCloseSession {
+			handleCloseSessionRequest(channelId, &req)
+		} else {
+			handleOpenSessionRequest(channelId, &req)
+		}
+		return
+	}
+
 	// 获取本地设备信息
 	localDevInfo, err := authentication.GetLocalDeviceInfo()
 	if err != nil {
@@ -167,6 +193,7 @@ func onAuthSdkDataRecv(channelId int, data *authentication.AuthChannelData) {
 		},
 		OnFinish: func(requestId int64, operationCode int32, returnData string) {
 			log.Infof("[TRANS_AUTH] HiChain auth finished: requestId=%d, opCode=%d", requestId, operationCode)
+			onAuthSdkFinish(channelId, returnData)
 		},
 		OnError: func(requestId int64, operationCode int32, errorCode int32, errorReturn string) {
 			log.Errorf("[TRANS_AUTH] HiChain auth error: requestId=%d, errorCode=%d", requestId, errorCode)
@@ -181,12 +208,41 @@ func onAuthSdkDataRecv(channelId int, data *authentication.AuthChannelData) {
 	log.Infof("[TRANS_AUTH] ========== AUTH_SDK Processing END ==========\n")
 }
 
+// onAuthSdkFinish HiChain认证成功，将对端设备加入REQ_AUTH时创建的群组
+func onAuthSdkFinish(channelId int, returnData string) {
+	ctx, err := context.GetAuthSessionContext(channelId)
+	if err != nil || ctx.GroupID == "" {
+		return
+	}
+
+	peerDeviceId := ctx.PeerDeviceID
+	var result struct {
+		PeerUdid string `json:"peerUdid"`
+	}
+	if json.Unmarshal([]byte(returnData), &result) == nil && result.PeerUdid != "" {
+		peerDeviceId = result.PeerUdid
+	}
+	if peerDeviceId == "" {
+		return
+	}
+
+	if err := addDeviceToGroup(ctx.GroupID, peerDeviceId); err != nil {
+		log.Warnf("[TRANS_AUTH] Failed to add device to group: %v", err)
+	}
+}
+
 // ============================================================================
 // AUTH_MSG 处理 (业务数据)
 // ============================================================================
 
 // onAuthMsgDataRecv 处理AUTH_MSG数据
 func onAuthMsgDataRecv(channelId int, data *authentication.AuthChannelData) {
+	// 会话数据分发到会话服务器
+	if isSessionPkt(data.Data) {
+		handleSessionData(channelId, data.Data)
+		return
+	}
+
 	cleanData := cleanJSONData(data.Data)
 	log.Infof("[TRANS_AUTH] Received AUTH_MSG: channelId=%d, len=%d, data=%s",
 		channelId, data.Len, string(cleanData))
@@ -234,6 +290,8 @@ func onDisconnect(channelId int) {
 	log.Infof("[TRANS_AUTH] Disconnected: channelId=%d", channelId)
 	// 清理会话上下文
 	context.DeleteAuthSessionContext(channelId)
+	// 关闭该连接上的会话
+	closeChannelSessions(channelId)
 }
 
 // ============================================================================
@@ -264,33 +322,53 @@ func handleDMAuthRequest(channelId int, seq int64, data []byte) {
 
 	log.Infof("[TRANS_AUTH] DM REQ_AUTH: AuthType=%d, Token=%s", req.AuthType, req.Token)
 
-	// 生成PIN码
-	pinCode := 888888
-	log.Infof("[TRANS_AUTH] ")
-	log.Infof("[TRANS_AUTH] ┌─────────────────────────────────┐")
-	log.Infof("[TRANS_AUTH] │   请在鸿蒙设备上输入PIN码：       │")
-	log.Infof("[TRANS_AUTH] │         %06d                    │", pinCode)
-	log.Infof("[TRANS_AUTH] └─────────────────────────────────┘")
+	// 生成唯一的RequestID（使用channelId作为会话标识）
+	requestId := int64(channelId)
+
+	// 对端IP用于配对失败统计，锁定期内不再生成PIN码
+	peerIp := ""
+	if connInfo, _, err := authentication.SocketGetConnInfo(channelId); err == nil {
+		peerIp = connInfo.Ip
+	}
+	if device, addr := device_auth.GetPairingLockout(req.LocalDeviceID, peerIp); device.Locked() || addr.Locked() {
+		log.Warnf("[TRANS_AUTH] Pairing locked: channelId=%d, peer=%s, ip=%s", channelId, req.LocalDeviceID, peerIp)
+		return
+	}
+
+	// 由应用层确认是否接受配对请求
+	pairingReq := &device_auth.PairingRequest{
+		RequestId:    requestId,
+		PeerDeviceId: req.LocalDeviceID,
+	}
+	if !device_auth.ConfirmPairingRequest(pairingReq) {
+		log.Warnf("[TRANS_AUTH] Pairing request rejected: channelId=%d, peer=%s", channelId, req.LocalDeviceID)
+		return
+	}
+
+	// 生成本次配对的随机PIN码，并交给应用层展示
+	pinCode, err := device_auth.GeneratePairingPin(pairingReq)
+	if err != nil {
+		log.Errorf("[TRANS_AUTH] Failed to generate pin code: %v", err)
+		return
+	}
 
 	// 获取本地设备信息
 	localDevInfo, _ := authentication.GetLocalDeviceInfo()
 
-	// 生成唯一的RequestID（使用channelId作为会话标识）
-	requestId := int64(channelId)
-
 	// 保存会话上下文（用于后续HiChain认证时获取pinCode等信息）
-	log.Infof("[TRANS_AUTH] 💾 Setting AuthSessionContext: ChannelID=%d, RequestID=%d, LocalDeviceID=%s, PeerDeviceID=%s, PinCode=%d",
-		channelId, requestId, localDevInfo.UDID, req.LocalDeviceID, pinCode)
+	log.Infof("[TRANS_AUTH] 💾 Setting AuthSessionContext: ChannelID=%d, RequestID=%d, LocalDeviceID=%s, PeerDeviceID=%s",
+		channelId, requestId, localDevInfo.UDID, req.LocalDeviceID)
 	context.SetAuthSessionContext(channelId, &context.AuthSessionContext{
 		ChannelID:     channelId,
-		PinCode:       fmt.Sprintf("%d", pinCode),
+		PinCode:       pinCode,
 		RequestID:     requestId,
 		LocalDeviceID: localDevInfo.UDID,
 		PeerDeviceID:  req.LocalDeviceID,
+		PeerIp:        peerIp,
 	})
 
 	// 调用device_auth创建群组并添加设备
-	groupId, groupName, err := createDeviceGroup(req.LocalDeviceID, pinCode)
+	groupId, groupName, err := createDeviceGroup(req.LocalDeviceID)
 	if err != nil {
 		log.Errorf("[TRANS_AUTH] Failed to create group: %v", err)
 		return
@@ -302,33 +380,15 @@ func handleDMAuthRequest(channelId int, seq int64, data []byte) {
 		context.SetAuthSessionContext(channelId, ctx)
 	}
 
-	// 将对端设备添加到群组（预添加，实际认证成功后才生效）
-	if err := addDeviceToGroup(groupId, req.LocalDeviceID); err != nil {
-		log.Warnf("[TRANS_AUTH] Failed to add device to group: %v", err)
-	}
+	// 对端设备在HiChain认证成功后才加入群组（见onAuthSdkDataRecv）
 
 	// 构建响应(MSG_TYPE 200)
-	// 根据抓包数据，正确的格式是：
-	// groupId: JSON字符串 "{\"groupId\":\"xxx\"}"
-	// authToken: JSON字符串 "{\"pinCode\":888888}"
-	groupIdJson := fmt.Sprintf(`{\"groupId\":\"%s\"}`, groupId)
-	authTokenJson := fmt.Sprintf(`{\"pinCode\":%d}`, pinCode)
-
-	// 使用map构建响应，字段名必须小写开头
-	respMap := map[string]interface{}{
-		"ITF_VER":   "1.1",
-		"MSG_TYPE":  200,
-		"REPLY":     0,
-		"DEVICEID":  localDevInfo.UDID,
-		"TOKEN":     req.Token,
-		"NETID":     localDevInfo.UDID,
-		"REQUESTID": requestId,
-		"groupId":   groupIdJson, // 小写，JSON字符串
-		"GROUPNAME": groupName,
-		"authToken": authTokenJson, // 小写，JSON字符串
+	respJSON, err := buildDMAuthResponse(&req, localDevInfo.UDID, requestId, groupId, groupName)
+	if err != nil {
+		log.Errorf("[TRANS_AUTH] Failed to build RESP_AUTH: %v", err)
+		return
 	}
 
-	respJSON, _ := json.Marshal(respMap)
 	respData := &authentication.AuthChannelData{
 		Module: authentication.ModuleAuthMsg,
 		Flag:   0,
@@ -347,6 +407,43 @@ func handleDMAuthRequest(channelId int, seq int64, data []byte) {
 	// 我们在onAuthSdkDataRecv中通过ProcessData响应即可
 }
 
+// buildDMAuthResponse 构建DM认证响应(MSG_TYPE 200)
+// 根据抓包数据，正确的格式是：
+// groupId: JSON字符串 "{\"groupId\":\"xxx\"}"
+// authToken: JSON字符串 "{\"pinToken\":\"xxx\"}"，不携带PIN码
+func buildDMAuthResponse(req *DMAuthRequest, localUdid string, requestId int64, groupId, groupName string) ([]byte, error) {
+	groupIdJson := fmt.Sprintf(`{\"groupId\":\"%s\"}`, groupId)
+	authTokenJson, err := buildDMAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用map构建响应，字段名必须小写开头
+	respMap := map[string]interface{}{
+		"ITF_VER":   "1.1",
+		"MSG_TYPE":  200,
+		"REPLY":     0,
+		"DEVICEID":  localUdid,
+		"TOKEN":     req.Token,
+		"NETID":     localUdid,
+		"REQUESTID": requestId,
+		"groupId":   groupIdJson, // 小写，JSON字符串
+		"GROUPNAME": groupName,
+		"authToken": authTokenJson, // 小写，JSON字符串
+	}
+	return json.Marshal(respMap)
+}
+
+// buildDMAuthToken 构建DM认证响应中的authToken："{\"pinToken\":\"xxx\"}"
+// 无论对端ITF_VER为何值都不携带PIN码本身，PIN码只在本端展示，由用户在对端输入
+func buildDMAuthToken() (string, error) {
+	pinToken := make([]byte, 8)
+	if _, err := rand.Read(pinToken); err != nil {
+		return "", fmt.Errorf("failed to generate pin token: %w", err)
+	}
+	return fmt.Sprintf(`{\"pinToken\":\"%s\"}`, hex.EncodeToString(pinToken)), nil
+}
+
 // handleDMNegotiate 处理DM协商请求
 func handleDMNegotiate(channelId int, seq int64, req *DMNegotiateRequest) {
 	log.Infof("[TRANS_AUTH] DM NEGOTIATE request: ITFVer=%s, AuthType=%d, Reply=%d",
@@ -407,24 +504,34 @@ func handleDMNegotiate(channelId int, seq int64, req *DMNegotiateRequest) {
 
 // AuthChannelRequestMsg AUTH_CHANNEL请求消息
 type AuthChannelRequestMsg struct {
-	Code       int    `json:"CODE"`
-	DeviceID   string `json:"DEVICE_ID"`
-	PkgName    string `json:"PKG_NAME"`
-	SrcBusName string `json:"SRC_BUS_NAME"`
-	DstBusName string `json:"DST_BUS_NAME"`
-	ReqID      string `json:"REQ_ID"`
-	MTUSize    int    `json:"MTU_SIZE"`
+	Code           int         `json:"CODE"`
+	DeviceID       string      `json:"DEVICE_ID"`
+	PkgName        string      `json:"PKG_NAME"`
+	SrcBusName     string      `json:"SRC_BUS_NAME"`
+	DstBusName     string      `json:"DST_BUS_NAME"`
+	ReqID          string      `json:"REQ_ID"`
+	MTUSize        int         `json:"MTU_SIZE"`
+	SessionID      int32       `json:"SESSION_ID,omitempty"`       // 发送方会话ID（打开/关闭会话请求）
+	PeerSessionID  int32       `json:"PEER_SESSION_ID,omitempty"`  // 接收方会话ID（关闭会话请求）
+	DataType       SessionType `json:"BUSINESS_TYPE,omitempty"`    // 会话数据类型（打开会话请求）
+	StreamPort     int         `json:"STREAM_PORT,omitempty"`      // 发起方流数据UDP端口（TypeStream）
+	StreamKeyIndex int32       `json:"STREAM_KEY_INDEX,omitempty"` // 派生流数据密钥的会话密钥索引（TypeStream）
 }
 
 // AuthChannelReplyMsg AUTH_CHANNEL回复消息
 type AuthChannelReplyMsg struct {
-	Code       int    `json:"CODE"`
-	DeviceID   string `json:"DEVICE_ID"`
-	PkgName    string `json:"PKG_NAME"`
-	SrcBusName string `json:"SRC_BUS_NAME"`
-	DstBusName string `json:"DST_BUS_NAME"`
-	ReqID      string `json:"REQ_ID"`
-	MTUSize    int    `json:"MTU_SIZE"`
+	Code          int    `json:"CODE"`
+	DeviceID      string `json:"DEVICE_ID"`
+	PkgName       string `json:"PKG_NAME"`
+	SrcBusName    string `json:"SRC_BUS_NAME"`
+	DstBusName    string `json:"DST_BUS_NAME"`
+	ReqID         string `json:"REQ_ID"`
+	MTUSize       int    `json:"MTU_SIZE"`
+	SessionID     int32  `json:"SESSION_ID,omitempty"`      // 服务端会话ID
+	PeerSessionID int32  `json:"PEER_SESSION_ID,omitempty"` // 发起方会话ID
+	ErrCode       int32  `json:"ERR_CODE,omitempty"`        // 拒绝原因
+	ErrDesc       string `json:"ERR_DESC,omitempty"`        // 拒绝原因描述
+	StreamPort    int    `json:"STREAM_PORT,omitempty"`     // 服务端流数据UDP端口（TypeStream）
 }
 
 // DMNegotiateRequest DM协商请求(MSG_TYPE 80)
@@ -492,8 +599,8 @@ func checkDeviceAuthStatus(peerDeviceId string) (authed bool, haveCredential boo
 		return false, false
 	}
 
-	// 查询与该设备相关的所有群组
-	groups, err := gm.GetRelatedGroups(device_auth.AnyOsAccount, "softbus", peerDeviceId)
+	// 查询与该设备相关的所有群组（以软总线内部身份按真实UDID查询）
+	groups, err := gm.GetRelatedGroups(device_auth.AnyOsAccount, device_auth.AUTH_APPID, peerDeviceId)
 	if err != nil || len(groups) == 0 {
 		return false, false
 	}
@@ -526,7 +633,7 @@ func addDeviceToGroup(groupId string, deviceId string) error {
 }
 
 // createDeviceGroup 创建device_auth群组
-func createDeviceGroup(peerDeviceId string, pinCode int) (groupId string, groupName string, err error) {
+func createDeviceGroup(peerDeviceId string) (groupId string, groupName string, err error) {
 	gm, err := device_auth.GetGmInstance()
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get GM instance: %w", err)
@@ -0,0 +1,42 @@
+package transmission
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// 测试RESP_AUTH不随对端ITF_VER泄露PIN码：空版本、1.1与新版本都只携带pinToken
+func TestBuildDMAuthResponseNeverCarriesPin(t *testing.T) {
+	for _, itfVer := range []string{"", "1.1", "1.2"} {
+		req := &DMAuthRequest{ITFVer: itfVer, Token: "tok", LocalDeviceID: "peer-udid"}
+		respJSON, err := buildDMAuthResponse(req, "local-udid", 1, "group-id", "group-name")
+		if err != nil {
+			t.Fatalf("ITF_VER=%q: buildDMAuthResponse failed: %v", itfVer, err)
+		}
+
+		var resp map[string]interface{}
+		if err := json.Unmarshal(respJSON, &resp); err != nil {
+			t.Fatalf("ITF_VER=%q: invalid response: %v", itfVer, err)
+		}
+		authToken, _ := resp["authToken"].(string)
+		if !strings.Contains(authToken, "pinToken") || strings.Contains(authToken, "pinCode") {
+			t.Errorf("ITF_VER=%q: expected pinToken only, got %s", itfVer, authToken)
+		}
+		if strings.Contains(string(respJSON), "pinCode") {
+			t.Errorf("ITF_VER=%q: response carries pin code: %s", itfVer, respJSON)
+		}
+	}
+}
+
+// 测试每次生成的pinToken互不相同
+func TestBuildDMAuthToken(t *testing.T) {
+	a, err := buildDMAuthToken()
+	if err != nil {
+		t.Fatalf("buildDMAuthToken failed: %v", err)
+	}
+	b, _ := buildDMAuthToken()
+	if a == b {
+		t.Errorf("Expected random pin tokens, got %s twice", a)
+	}
+}
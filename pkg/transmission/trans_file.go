@@ -0,0 +1,1000 @@
+package transmission
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 文件传输
+// 对应C代码: sdk/transmission/trans_channel/tcp_direct/src/client_trans_file.c
+// ============================================================================
+//
+// 文件通过TypeFile会话以FlagFile数据帧传输，帧内容首字节为帧类型:
+//   - fileFrameStart:    发送方→接收方，JSON，文件列表（目标相对路径、大小、SHA-256）
+//   - fileFrameStartAck: 接收方→发送方，JSON，各文件的续传偏移，或拒绝原因
+//   - fileFrameData:     发送方→接收方，[帧类型(1)][传输ID(4)][文件序号(4)][偏移(8)][数据]
+//   - fileFrameAck:      接收方→发送方，JSON，已写入并落盘的偏移
+//   - fileFrameDone:     接收方→发送方，JSON，文件SHA-256校验结果
+//   - fileFrameError:    任意一方，JSON，中止本次传输
+// 接收方将数据写入"<目标路径>.part"，并在"<目标路径>.part.json"中记录文件大小、摘要、已确认偏移
+// 和已确认数据的SHA-256中间状态，连接断开后重新打开会话并以相同参数调用SendFile，从上次确认的偏移续传；
+// SHA-256随写入增量计算（续传时从中间状态恢复），文件收齐后与发送方摘要比较，通过后重命名为目标文件。
+// 目标路径限定在接收根目录内（不跟随指向根目录外的符号链接），单个文件不超过MaxReceiveFileSize
+
+// 文件数据帧类型
+const (
+	fileFrameStart    byte = 1
+	fileFrameStartAck byte = 2
+	fileFrameData     byte = 3
+	fileFrameAck      byte = 4
+	fileFrameDone     byte = 5
+	fileFrameError    byte = 6
+)
+
+// 文件传输错误码（fileReplyMsg.ErrCode）
+const (
+	fileErrRejected       int32 = -1 // 接收方拒绝
+	fileErrDigestMismatch int32 = -2 // SHA-256校验失败
+	fileErrIO             int32 = -3 // 读写文件失败
+	fileErrProtocol       int32 = -4 // 帧内容不合法
+)
+
+const (
+	// MaxSendFileNum 单次SendFile的文件数上限
+	MaxSendFileNum = 32
+
+	// fileDataHeadLen 文件数据帧头长度
+	fileDataHeadLen = 1 + 4 + 4 + 8
+	// fileDataMaxLen 单个文件数据帧承载的数据长度
+	fileDataMaxLen = FileFrameMaxLen - fileDataHeadLen
+
+	filePartSuffix = ".part"      // 接收中的文件
+	fileMetaSuffix = ".part.json" // 续传信息
+)
+
+var (
+	// FileAckTimeout 发送方等待接收方回复的超时时间
+	FileAckTimeout = 10 * time.Second
+	// FileSendWindow 发送方未确认数据的上限
+	FileSendWindow int64 = 1024 * 1024
+	// FileAckInterval 接收方确认间隔（字节）
+	FileAckInterval int64 = 256 * 1024
+	// MaxReceiveFileSize 接收方允许的单个文件大小上限，超过时拒绝整个传输
+	MaxReceiveFileSize int64 = 4 * 1024 * 1024 * 1024
+)
+
+var (
+	// ErrFileRejected 接收方拒绝文件传输
+	ErrFileRejected = errors.New("file transfer rejected by peer")
+	// ErrFileDigestMismatch 文件SHA-256校验失败
+	ErrFileDigestMismatch = errors.New("file digest mismatch")
+	// ErrFileTransTimeout 等待对端回复超时
+	ErrFileTransTimeout = errors.New("file transfer timeout")
+	// ErrFileTransAborted 对端中止文件传输
+	ErrFileTransAborted = errors.New("file transfer aborted by peer")
+	// ErrInvalidFilePath 文件路径不合法（绝对路径或跳出接收根目录）
+	ErrInvalidFilePath = errors.New("invalid file path")
+)
+
+// FileSendListener 文件发送监听器（对应C的IFileSendListener）
+type FileSendListener struct {
+	OnSendFileProcess  func(sessionID int32, bytesUpload uint64, bytesTotal uint64) // 发送进度（接收方已确认的字节数）
+	OnSendFileFinished func(sessionID int32, firstFile string)                      // 全部文件发送完成并校验通过
+	OnFileTransError   func(sessionID int32, err error)                             // 传输失败
+}
+
+// FileReceiveListener 文件接收监听器（对应C的IFileReceiveListener）
+type FileReceiveListener struct {
+	OnReceiveFileStarted  func(sessionID int32, files []string)                                          // 开始接收
+	OnReceiveFileProcess  func(sessionID int32, firstFile string, bytesUpload uint64, bytesTotal uint64) // 接收进度
+	OnReceiveFileFinished func(sessionID int32, files []string)                                          // 全部文件接收完成并校验通过
+	OnFileTransError      func(sessionID int32, err error)                                               // 传输失败
+}
+
+// fileStartMsg fileFrameStart内容
+type fileStartMsg struct {
+	TransID uint32        `json:"TRANS_ID"`
+	Files   []fileInfoMsg `json:"FILES"`
+}
+
+// fileInfoMsg 文件信息
+type fileInfoMsg struct {
+	Path   string `json:"PATH"`   // 目标相对路径
+	Size   int64  `json:"SIZE"`   // 文件大小
+	Digest string `json:"SHA256"` // 文件SHA-256（十六进制）
+}
+
+// fileReplyMsg fileFrameStartAck/Ack/Done/Error内容
+type fileReplyMsg struct {
+	TransID uint32  `json:"TRANS_ID"`
+	Index   int     `json:"INDEX"`             // 文件序号
+	Offset  int64   `json:"OFFSET,omitempty"`  // 已确认偏移（Ack）
+	Offsets []int64 `json:"OFFSETS,omitempty"` // 各文件续传偏移（StartAck）
+	ErrCode int32   `json:"ERR_CODE,omitempty"`
+	ErrDesc string  `json:"ERR_DESC,omitempty"`
+}
+
+// filePartMeta 续传信息
+type filePartMeta struct {
+	Size      int64  `json:"SIZE"`
+	Digest    string `json:"SHA256"`
+	Offset    int64  `json:"OFFSET"`               // 已确认偏移
+	HashState string `json:"HASH_STATE,omitempty"` // 已确认数据的SHA-256中间状态（十六进制）
+}
+
+// fileRecvConfig 文件接收配置
+type fileRecvConfig struct {
+	listener *FileReceiveListener
+	rootDir  string
+}
+
+var (
+	gFileMu            sync.Mutex
+	gFileSendListeners = make(map[string]*FileSendListener) // sessionName -> listener
+	gFileRecvConfigs   = make(map[string]*fileRecvConfig)   // sessionName -> config
+	gFileSenders       = make(map[int32]*fileSender)        // sessionID -> 发送中的传输
+	gFileReceivers     = make(map[int32]*fileReceiver)      // sessionID -> 接收中的传输
+	gFileTransID       uint32
+)
+
+// SetFileSendListener 设置文件发送监听器
+func SetFileSendListener(pkgName string, sessionName string, listener *FileSendListener) error {
+	if sessionName == "" || listener == nil {
+		return fmt.Errorf("invalid parameters")
+	}
+
+	gFileMu.Lock()
+	gFileSendListeners[sessionName] = listener
+	gFileMu.Unlock()
+
+	log.Infof("[FILE] Set file send listener: %s", sessionName)
+	return nil
+}
+
+// SetFileReceiveListener 设置文件接收监听器
+// rootDir: 接收根目录，对端给出的目标路径均解析到该目录下
+func SetFileReceiveListener(pkgName string, sessionName string, listener *FileReceiveListener, rootDir string) error {
+	if sessionName == "" || listener == nil || rootDir == "" {
+		return fmt.Errorf("invalid parameters")
+	}
+
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return fmt.Errorf("invalid root dir: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create root dir: %w", err)
+	}
+
+	gFileMu.Lock()
+	gFileRecvConfigs[sessionName] = &fileRecvConfig{listener: listener, rootDir: root}
+	gFileMu.Unlock()
+
+	log.Infof("[FILE] Set file receive listener: %s, rootDir=%s", sessionName, root)
+	return nil
+}
+
+// SendFile 通过TypeFile会话发送文件
+// 校验参数后立即返回，传输结果通过SetFileSendListener设置的监听器通知
+// srcPaths: 本地文件路径
+// dstPaths: 对端接收根目录下的相对路径
+func SendFile(sessionID int32, srcPaths []string, dstPaths []string) error {
+	if len(srcPaths) == 0 || len(srcPaths) != len(dstPaths) || len(srcPaths) > MaxSendFileNum {
+		return fmt.Errorf("invalid file count: src=%d, dst=%d (max %d)", len(srcPaths), len(dstPaths), MaxSendFileNum)
+	}
+
+	mgr := getSessionManager()
+	mgr.mu.RLock()
+	session, exists := mgr.sessions[sessionID]
+	opened := exists && session.IsOpened
+	mgr.mu.RUnlock()
+	if !opened {
+		return fmt.Errorf("session not opened: %d", sessionID)
+	}
+	if session.DataType != TypeFile {
+		return fmt.Errorf("session type mismatch: session=%d, type=%d", session.DataType, TypeFile)
+	}
+
+	files := make([]fileInfoMsg, len(srcPaths))
+	for i := range srcPaths {
+		if _, err := cleanFilePath(dstPaths[i]); err != nil {
+			return err
+		}
+		info, err := os.Stat(srcPaths[i])
+		if err != nil {
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("not a regular file: %s", srcPaths[i])
+		}
+		files[i].Path = filepath.ToSlash(dstPaths[i])
+	}
+
+	gFileMu.Lock()
+	if gFileSenders[sessionID] != nil {
+		gFileMu.Unlock()
+		return fmt.Errorf("file transfer in progress: session=%d", sessionID)
+	}
+	sender := &fileSender{
+		sessionID: sessionID,
+		transID:   atomic.AddUint32(&gFileTransID, 1),
+		srcPaths:  append([]string(nil), srcPaths...),
+		files:     files,
+		listener:  gFileSendListeners[session.SessionName],
+		events:    make(chan fileEvent, 16),
+		closed:    make(chan struct{}),
+		exited:    make(chan struct{}),
+	}
+	gFileSenders[sessionID] = sender
+	gFileMu.Unlock()
+
+	go sender.run()
+	log.Infof("[FILE] Start sending files: sessionID=%d, transID=%d, count=%d", sessionID, sender.transID, len(files))
+	return nil
+}
+
+// ============================================================================
+// 发送方
+// ============================================================================
+
+// fileEvent 发送方收到的接收方回复
+type fileEvent struct {
+	frameType byte
+	msg       fileReplyMsg
+}
+
+// fileSender 发送中的文件传输
+type fileSender struct {
+	sessionID int32
+	transID   uint32
+	srcPaths  []string
+	files     []fileInfoMsg
+	listener  *FileSendListener
+
+	events    chan fileEvent
+	closed    chan struct{} // 会话关闭
+	closeOnce sync.Once
+	exited    chan struct{} // 发送goroutine退出
+}
+
+// run 执行文件传输并通知结果
+func (s *fileSender) run() {
+	err := s.transfer()
+
+	gFileMu.Lock()
+	delete(gFileSenders, s.sessionID)
+	gFileMu.Unlock()
+	close(s.exited)
+
+	if err != nil {
+		log.Errorf("[FILE] Send files failed: sessionID=%d, transID=%d, err=%v", s.sessionID, s.transID, err)
+		if !errors.Is(err, ErrFileTransAborted) && !errors.Is(err, ErrSessionChannelClosed) {
+			sendFileReply(s.sessionID, fileFrameError, &fileReplyMsg{TransID: s.transID, ErrCode: fileErrIO, ErrDesc: err.Error()})
+		}
+		if s.listener != nil && s.listener.OnFileTransError != nil {
+			s.listener.OnFileTransError(s.sessionID, err)
+		}
+		return
+	}
+
+	log.Infof("[FILE] Send files finished: sessionID=%d, transID=%d", s.sessionID, s.transID)
+	if s.listener != nil && s.listener.OnSendFileFinished != nil {
+		s.listener.OnSendFileFinished(s.sessionID, s.files[0].Path)
+	}
+}
+
+// transfer 发送文件列表，按接收方返回的偏移续传每个文件，并等待校验结果
+func (s *fileSender) transfer() error {
+	var total int64
+	for i, src := range s.srcPaths {
+		size, digest, err := hashFile(src)
+		if err != nil {
+			return err
+		}
+		s.files[i].Size = size
+		s.files[i].Digest = digest
+		total += size
+	}
+
+	start, _ := json.Marshal(&fileStartMsg{TransID: s.transID, Files: s.files})
+	if 1+len(start) > FileFrameMaxLen {
+		return fmt.Errorf("too many files: file list exceeds %d bytes", FileFrameMaxLen)
+	}
+	if err := sendFileFrame(s.sessionID, fileFrameStart, start); err != nil {
+		return err
+	}
+
+	reply, err := s.wait()
+	if err != nil {
+		return err
+	}
+	if reply.frameType != fileFrameStartAck {
+		return fmt.Errorf("unexpected file frame: %d", reply.frameType)
+	}
+	if reply.msg.ErrCode != 0 {
+		return fileTransError(reply.msg.ErrCode, reply.msg.ErrDesc)
+	}
+	if len(reply.msg.Offsets) != len(s.files) {
+		return fmt.Errorf("invalid resume offsets: %d", len(reply.msg.Offsets))
+	}
+
+	var done int64 // 已完成文件的字节数
+	for i := range s.files {
+		if err := s.sendOne(i, reply.msg.Offsets[i], done, total); err != nil {
+			return err
+		}
+		done += s.files[i].Size
+	}
+	return nil
+}
+
+// sendOne 从offset开始发送第index个文件，并等待接收方校验结果
+func (s *fileSender) sendOne(index int, offset int64, done int64, total int64) error {
+	size := s.files[index].Size
+	if offset < 0 || offset > size {
+		return fmt.Errorf("invalid resume offset: file=%d, offset=%d, size=%d", index, offset, size)
+	}
+	if offset > 0 {
+		log.Infof("[FILE] Resume file: sessionID=%d, file=%s, offset=%d/%d", s.sessionID, s.files[index].Path, offset, size)
+	}
+
+	f, err := os.Open(s.srcPaths[index])
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	acked, sent := offset, offset
+	s.notifyProcess(done+acked, total)
+
+	frame := make([]byte, fileDataHeadLen+fileDataMaxLen)
+	frame[0] = fileFrameData
+	binary.LittleEndian.PutUint32(frame[1:], s.transID)
+	binary.LittleEndian.PutUint32(frame[5:], uint32(index))
+	for {
+		// 未确认数据超过窗口或数据已发完时，处理接收方回复
+		for sent == size || sent-acked >= FileSendWindow {
+			ev, err := s.wait()
+			if err != nil {
+				return err
+			}
+			if ev.msg.Index != index {
+				return fmt.Errorf("unexpected file index: %d, expected %d", ev.msg.Index, index)
+			}
+			switch ev.frameType {
+			case fileFrameAck:
+				if ev.msg.Offset > acked && ev.msg.Offset <= sent {
+					acked = ev.msg.Offset
+					s.notifyProcess(done+acked, total)
+				}
+			case fileFrameDone:
+				if ev.msg.ErrCode != 0 {
+					return fileTransError(ev.msg.ErrCode, ev.msg.ErrDesc)
+				}
+				if sent != size {
+					return fmt.Errorf("unexpected file done: file=%d, sent=%d, size=%d", index, sent, size)
+				}
+				return nil
+			default:
+				return fmt.Errorf("unexpected file frame: %d", ev.frameType)
+			}
+		}
+
+		n := size - sent
+		if n > fileDataMaxLen {
+			n = fileDataMaxLen
+		}
+		data := frame[:fileDataHeadLen+int(n)]
+		if _, err := io.ReadFull(f, data[fileDataHeadLen:]); err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		binary.LittleEndian.PutUint64(data[9:], uint64(sent))
+		if err := sendSessionData(s.sessionID, TypeFile, data); err != nil {
+			return err
+		}
+		sent += n
+	}
+}
+
+// wait 等待接收方回复
+func (s *fileSender) wait() (fileEvent, error) {
+	select {
+	case ev := <-s.events:
+		if ev.frameType == fileFrameError {
+			return ev, fmt.Errorf("%w: %s", ErrFileTransAborted, ev.msg.ErrDesc)
+		}
+		return ev, nil
+	case <-s.closed:
+		return fileEvent{}, ErrSessionChannelClosed
+	case <-time.After(FileAckTimeout):
+		return fileEvent{}, ErrFileTransTimeout
+	}
+}
+
+// notifyProcess 通知发送进度
+func (s *fileSender) notifyProcess(upload int64, total int64) {
+	if s.listener != nil && s.listener.OnSendFileProcess != nil {
+		s.listener.OnSendFileProcess(s.sessionID, uint64(upload), uint64(total))
+	}
+}
+
+// deliver 将接收方回复交给发送goroutine
+func (s *fileSender) deliver(ev fileEvent) {
+	select {
+	case s.events <- ev:
+	case <-s.exited:
+	}
+}
+
+// ============================================================================
+// 接收方
+// ============================================================================
+
+// fileReceiver 接收中的文件传输（仅在会话回调goroutine中访问）
+type fileReceiver struct {
+	sessionID int32
+	transID   uint32
+	files     []fileInfoMsg
+	paths     []string    // 目标文件绝对路径
+	written   []int64     // 已写入偏移
+	acked     []int64     // 已确认偏移
+	hashes    []hash.Hash // 已写入数据的SHA-256（增量计算）
+	current   int         // 当前接收的文件序号
+	file      *os.File    // 当前文件的.part文件
+	received  int64       // 已接收字节数（所有文件）
+	total     int64       // 总字节数
+	listener  *FileReceiveListener
+}
+
+// handleFileFrame 处理TypeFile会话的数据帧（在会话回调goroutine中执行）
+func handleFileFrame(session *Session, payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+
+	frameType := payload[0]
+	if frameType == fileFrameData {
+		handleFileData(session.SessionID, payload)
+		return
+	}
+
+	if frameType == fileFrameStart {
+		var msg fileStartMsg
+		if err := json.Unmarshal(payload[1:], &msg); err != nil {
+			log.Errorf("[FILE] Failed to parse file start: %v", err)
+			return
+		}
+		handleFileStart(session, &msg)
+		return
+	}
+
+	var msg fileReplyMsg
+	if err := json.Unmarshal(payload[1:], &msg); err != nil {
+		log.Errorf("[FILE] Failed to parse file frame %d: %v", frameType, err)
+		return
+	}
+
+	gFileMu.Lock()
+	sender := gFileSenders[session.SessionID]
+	receiver := gFileReceivers[session.SessionID]
+	gFileMu.Unlock()
+
+	switch frameType {
+	case fileFrameStartAck, fileFrameAck, fileFrameDone:
+		if sender == nil || sender.transID != msg.TransID {
+			log.Warnf("[FILE] Drop file frame for unknown transfer: sessionID=%d, frame=%d, transID=%d",
+				session.SessionID, frameType, msg.TransID)
+			return
+		}
+		sender.deliver(fileEvent{frameType: frameType, msg: msg})
+	case fileFrameError:
+		if sender != nil && sender.transID == msg.TransID {
+			sender.deliver(fileEvent{frameType: frameType, msg: msg})
+		}
+		if receiver != nil && receiver.transID == msg.TransID {
+			receiver.abort(fmt.Errorf("%w: %s", ErrFileTransAborted, msg.ErrDesc), 0)
+		}
+	default:
+		log.Warnf("[FILE] Unknown file frame: sessionID=%d, frame=%d", session.SessionID, frameType)
+	}
+}
+
+// handleFileStart 处理文件列表：校验路径，返回各文件的续传偏移
+func handleFileStart(session *Session, msg *fileStartMsg) {
+	sessionID := session.SessionID
+	reply := &fileReplyMsg{TransID: msg.TransID}
+
+	gFileMu.Lock()
+	cfg := gFileRecvConfigs[session.SessionName]
+	previous := gFileReceivers[sessionID]
+	gFileMu.Unlock()
+
+	// 新的传输取代未完成的传输
+	if previous != nil {
+		previous.abort(fmt.Errorf("%w: superseded by new transfer", ErrFileTransAborted), 0)
+	}
+
+	reject := func(code int32, desc string) {
+		log.Warnf("[FILE] Reject file transfer: sessionID=%d, transID=%d, %s", sessionID, msg.TransID, desc)
+		reply.ErrCode = code
+		reply.ErrDesc = desc
+		sendFileReply(sessionID, fileFrameStartAck, reply)
+	}
+	if cfg == nil {
+		reject(fileErrRejected, "no file receive listener: "+session.SessionName)
+		return
+	}
+	if len(msg.Files) == 0 || len(msg.Files) > MaxSendFileNum {
+		reject(fileErrProtocol, fmt.Sprintf("invalid file count: %d", len(msg.Files)))
+		return
+	}
+
+	r := &fileReceiver{
+		sessionID: sessionID,
+		transID:   msg.TransID,
+		files:     msg.Files,
+		paths:     make([]string, len(msg.Files)),
+		written:   make([]int64, len(msg.Files)),
+		acked:     make([]int64, len(msg.Files)),
+		hashes:    make([]hash.Hash, len(msg.Files)),
+		listener:  cfg.listener,
+	}
+	// 先校验全部文件信息，再创建目录和.part文件
+	for _, f := range msg.Files {
+		if digest, err := hex.DecodeString(f.Digest); err != nil || len(digest) != sha256.Size || f.Size < 0 {
+			reject(fileErrProtocol, "invalid file info: "+f.Path)
+			return
+		}
+		if f.Size > MaxReceiveFileSize {
+			reject(fileErrRejected, fmt.Sprintf("file too large: %s, size=%d (max %d)", f.Path, f.Size, MaxReceiveFileSize))
+			return
+		}
+	}
+
+	names := make([]string, len(msg.Files))
+	for i, f := range msg.Files {
+		path, err := resolveFilePath(cfg.rootDir, f.Path)
+		if err != nil {
+			reject(fileErrRejected, err.Error())
+			return
+		}
+		offset, h, err := preparePartFile(path, &f)
+		if err != nil {
+			reject(fileErrIO, err.Error())
+			return
+		}
+		r.paths[i] = path
+		r.written[i], r.acked[i] = offset, offset
+		r.hashes[i] = h
+		r.received += offset
+		r.total += f.Size
+		names[i] = f.Path
+	}
+
+	reply.Offsets = r.acked
+	if err := sendFileReply(sessionID, fileFrameStartAck, reply); err != nil {
+		return
+	}
+
+	gFileMu.Lock()
+	gFileReceivers[sessionID] = r
+	gFileMu.Unlock()
+
+	log.Infof("[FILE] Start receiving files: sessionID=%d, transID=%d, count=%d, resumed=%d/%d",
+		sessionID, r.transID, len(r.files), r.received, r.total)
+	if r.listener.OnReceiveFileStarted != nil {
+		r.listener.OnReceiveFileStarted(sessionID, names)
+	}
+
+	// 已收齐的文件（含空文件）直接校验
+	r.advance()
+}
+
+// handleFileData 处理文件数据帧
+func handleFileData(sessionID int32, payload []byte) {
+	if len(payload) < fileDataHeadLen {
+		log.Warnf("[FILE] Drop short file data: sessionID=%d, len=%d", sessionID, len(payload))
+		return
+	}
+	transID := binary.LittleEndian.Uint32(payload[1:])
+	index := int(binary.LittleEndian.Uint32(payload[5:]))
+	offset := int64(binary.LittleEndian.Uint64(payload[9:]))
+	data := payload[fileDataHeadLen:]
+
+	gFileMu.Lock()
+	r := gFileReceivers[sessionID]
+	gFileMu.Unlock()
+	if r == nil || r.transID != transID {
+		log.Warnf("[FILE] Drop file data for unknown transfer: sessionID=%d, transID=%d", sessionID, transID)
+		return
+	}
+
+	// 连接保证有序，数据须紧接当前文件已写入的位置
+	if index != r.current || offset != r.written[index] || offset+int64(len(data)) > r.files[index].Size {
+		r.abort(fmt.Errorf("unexpected file data: file=%d, offset=%d, len=%d", index, offset, len(data)), fileErrProtocol)
+		return
+	}
+	if _, err := r.file.Write(data); err != nil {
+		r.abort(fmt.Errorf("failed to write file: %w", err), fileErrIO)
+		return
+	}
+	r.hashes[index].Write(data)
+	r.written[index] += int64(len(data))
+	r.received += int64(len(data))
+
+	if r.written[index]-r.acked[index] >= FileAckInterval || r.written[index] == r.files[index].Size {
+		if err := r.ack(index); err != nil {
+			r.abort(err, fileErrIO)
+			return
+		}
+	}
+	if r.written[index] == r.files[index].Size {
+		r.advance()
+	}
+}
+
+// ack 将已写入的数据落盘并记录续传偏移，然后确认给发送方
+func (r *fileReceiver) ack(index int) error {
+	if err := r.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := savePartMeta(r.paths[index], &filePartMeta{
+		Size:      r.files[index].Size,
+		Digest:    r.files[index].Digest,
+		Offset:    r.written[index],
+		HashState: marshalHashState(r.hashes[index]),
+	}); err != nil {
+		return err
+	}
+	r.acked[index] = r.written[index]
+
+	sendFileReply(r.sessionID, fileFrameAck, &fileReplyMsg{TransID: r.transID, Index: index, Offset: r.acked[index]})
+	if r.listener.OnReceiveFileProcess != nil {
+		r.listener.OnReceiveFileProcess(r.sessionID, r.files[0].Path, uint64(r.received), uint64(r.total))
+	}
+	return nil
+}
+
+// advance 校验已收齐的文件，并打开下一个待接收文件
+func (r *fileReceiver) advance() {
+	for r.current < len(r.files) {
+		index := r.current
+		if r.file == nil {
+			f, err := os.OpenFile(r.paths[index]+filePartSuffix, os.O_WRONLY|os.O_CREATE|fileOpenNoFollow, 0644)
+			if err != nil {
+				r.abort(fmt.Errorf("failed to open file: %w", err), fileErrIO)
+				return
+			}
+			if _, err := f.Seek(r.written[index], io.SeekStart); err != nil {
+				f.Close()
+				r.abort(fmt.Errorf("failed to seek file: %w", err), fileErrIO)
+				return
+			}
+			r.file = f
+		}
+		if r.written[index] < r.files[index].Size {
+			return
+		}
+
+		r.file.Close()
+		r.file = nil
+		actual := hex.EncodeToString(r.hashes[index].Sum(nil))
+		if err := finishPartFile(r.paths[index], r.files[index].Digest, actual); err != nil {
+			code := fileErrIO
+			if errors.Is(err, ErrFileDigestMismatch) {
+				code = fileErrDigestMismatch
+			}
+			sendFileReply(r.sessionID, fileFrameDone, &fileReplyMsg{TransID: r.transID, Index: index, ErrCode: code, ErrDesc: err.Error()})
+			r.abort(err, 0)
+			return
+		}
+		sendFileReply(r.sessionID, fileFrameDone, &fileReplyMsg{TransID: r.transID, Index: index})
+		log.Infof("[FILE] File received: sessionID=%d, file=%s, size=%d", r.sessionID, r.paths[index], r.files[index].Size)
+		r.current++
+	}
+
+	gFileMu.Lock()
+	delete(gFileReceivers, r.sessionID)
+	gFileMu.Unlock()
+
+	names := make([]string, len(r.files))
+	for i, f := range r.files {
+		names[i] = f.Path
+	}
+	log.Infof("[FILE] Receive files finished: sessionID=%d, transID=%d", r.sessionID, r.transID)
+	if r.listener.OnReceiveFileFinished != nil {
+		r.listener.OnReceiveFileFinished(r.sessionID, names)
+	}
+}
+
+// abort 中止接收，保留.part文件用于续传
+// code非0时以fileFrameError通知发送方
+func (r *fileReceiver) abort(err error, code int32) {
+	gFileMu.Lock()
+	if gFileReceivers[r.sessionID] == r {
+		delete(gFileReceivers, r.sessionID)
+	}
+	gFileMu.Unlock()
+
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	log.Errorf("[FILE] Receive files failed: sessionID=%d, transID=%d, err=%v", r.sessionID, r.transID, err)
+	if code != 0 {
+		sendFileReply(r.sessionID, fileFrameError, &fileReplyMsg{TransID: r.transID, ErrCode: code, ErrDesc: err.Error()})
+	}
+	if r.listener.OnFileTransError != nil {
+		r.listener.OnFileTransError(r.sessionID, err)
+	}
+}
+
+// closeFileTrans 会话关闭时中止该会话上的文件传输（在会话回调goroutine中执行）
+func closeFileTrans(sessionID int32) {
+	gFileMu.Lock()
+	sender := gFileSenders[sessionID]
+	receiver := gFileReceivers[sessionID]
+	gFileMu.Unlock()
+
+	if sender != nil {
+		sender.closeOnce.Do(func() { close(sender.closed) })
+	}
+	if receiver != nil {
+		receiver.abort(ErrSessionChannelClosed, 0)
+	}
+}
+
+// ============================================================================
+// 工具函数
+// ============================================================================
+
+// sendFileFrame 发送文件数据帧
+func sendFileFrame(sessionID int32, frameType byte, body []byte) error {
+	payload := make([]byte, 1+len(body))
+	payload[0] = frameType
+	copy(payload[1:], body)
+	return sendSessionData(sessionID, TypeFile, payload)
+}
+
+// sendFileReply 发送JSON格式的文件数据帧
+func sendFileReply(sessionID int32, frameType byte, msg *fileReplyMsg) error {
+	body, _ := json.Marshal(msg)
+	if err := sendFileFrame(sessionID, frameType, body); err != nil {
+		log.Errorf("[FILE] Failed to send file frame %d: sessionID=%d, err=%v", frameType, sessionID, err)
+		return err
+	}
+	return nil
+}
+
+// fileTransError 将接收方的错误码转换为错误
+func fileTransError(code int32, desc string) error {
+	switch code {
+	case fileErrRejected:
+		return fmt.Errorf("%w: %s", ErrFileRejected, desc)
+	case fileErrDigestMismatch:
+		return fmt.Errorf("%w: %s", ErrFileDigestMismatch, desc)
+	default:
+		return fmt.Errorf("%w: code=%d, %s", ErrFileTransAborted, code, desc)
+	}
+}
+
+// hashFile 计算文件大小和SHA-256
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cleanFilePath 校验并清理对端目标相对路径，拒绝绝对路径和包含".."的路径
+func cleanFilePath(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, 0) || filepath.IsAbs(name) ||
+		strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidFilePath, name)
+	}
+	for _, elem := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if elem == ".." {
+			return "", fmt.Errorf("%w: %q", ErrInvalidFilePath, name)
+		}
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "." || strings.HasSuffix(name, "/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidFilePath, name)
+	}
+	return clean, nil
+}
+
+// resolveFilePath 将目标相对路径解析到接收根目录下，并创建父目录
+// 先解析已存在的最近祖先目录（可以经过根目录内的符号链接），确认位于根目录内，
+// 再逐级创建缺少的目录，每级创建后以Lstat确认是目录而非符号链接，不会在根目录外创建目录
+func resolveFilePath(rootDir string, name string) (string, error) {
+	clean, err := cleanFilePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	realRoot, err := filepath.EvalSymlinks(rootDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root dir: %w", err)
+	}
+
+	// 最近的已存在祖先目录和其下缺少的目录
+	dir := filepath.Dir(clean)
+	var missing []string
+	for dir != "." {
+		if _, err := os.Lstat(filepath.Join(realRoot, dir)); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to stat dir: %w", err)
+		}
+		missing = append([]string{filepath.Base(dir)}, missing...)
+		dir = filepath.Dir(dir)
+	}
+
+	realDir, err := filepath.EvalSymlinks(filepath.Join(realRoot, dir))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve dir: %w", err)
+	}
+	if !isWithinDir(realRoot, realDir) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidFilePath, name)
+	}
+	if info, err := os.Stat(realDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: %q is not a directory", ErrInvalidFilePath, name)
+	}
+
+	for _, elem := range missing {
+		realDir = filepath.Join(realDir, elem)
+		if err := os.Mkdir(realDir, 0755); err != nil && !os.IsExist(err) {
+			return "", fmt.Errorf("failed to create dir: %w", err)
+		}
+		// 并发创建的同名项可能是符号链接
+		if info, err := os.Lstat(realDir); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("%w: %q is not a directory", ErrInvalidFilePath, name)
+		}
+	}
+
+	// 目标文件和临时文件不能是符号链接或目录
+	path := filepath.Join(realDir, filepath.Base(clean))
+	for _, p := range []string{path, path + filePartSuffix, path + fileMetaSuffix} {
+		if info, err := os.Lstat(p); err == nil && !info.Mode().IsRegular() {
+			return "", fmt.Errorf("%w: %q is not a regular file", ErrInvalidFilePath, name)
+		}
+	}
+	return path, nil
+}
+
+// isWithinDir 判断path是否为root或其子路径（均为已解析符号链接的绝对路径）
+func isWithinDir(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// preparePartFile 准备.part文件，返回续传偏移和已确认数据的SHA-256
+// 续传信息与文件大小、摘要一致且包含SHA-256中间状态时从已确认偏移续传，否则从头接收
+func preparePartFile(path string, info *fileInfoMsg) (int64, hash.Hash, error) {
+	var offset int64
+	h := sha256.New()
+	if data, err := readPartMeta(path); err == nil {
+		var meta filePartMeta
+		if json.Unmarshal(data, &meta) == nil && meta.Size == info.Size && meta.Digest == info.Digest &&
+			meta.Offset >= 0 && meta.Offset <= info.Size {
+			if resumed := unmarshalHashState(meta.HashState); resumed != nil {
+				offset, h = meta.Offset, resumed
+			}
+		}
+	}
+
+	// 丢弃已确认偏移之后的数据
+	f, err := os.OpenFile(path+filePartSuffix, os.O_WRONLY|os.O_CREATE|fileOpenNoFollow, 0644)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	if stat, err := f.Stat(); err != nil || !stat.Mode().IsRegular() || stat.Size() < offset {
+		offset, h = 0, sha256.New()
+	}
+	if err := f.Truncate(offset); err != nil {
+		return 0, nil, fmt.Errorf("failed to truncate file: %w", err)
+	}
+
+	if err := savePartMeta(path, &filePartMeta{
+		Size:      info.Size,
+		Digest:    info.Digest,
+		Offset:    offset,
+		HashState: marshalHashState(h),
+	}); err != nil {
+		return 0, nil, err
+	}
+	return offset, h, nil
+}
+
+// marshalHashState 导出SHA-256中间状态（十六进制）
+func marshalHashState(h hash.Hash) string {
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(state)
+}
+
+// unmarshalHashState 从中间状态恢复SHA-256，状态无效时返回nil
+func unmarshalHashState(state string) hash.Hash {
+	data, err := hex.DecodeString(state)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	return h
+}
+
+// readPartMeta 读取续传信息（不跟随符号链接）
+func readPartMeta(path string) ([]byte, error) {
+	f, err := os.OpenFile(path+fileMetaSuffix, os.O_RDONLY|fileOpenNoFollow, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, 4096))
+}
+
+// savePartMeta 保存续传信息（不跟随符号链接）
+func savePartMeta(path string, meta *filePartMeta) error {
+	data, _ := json.Marshal(meta)
+	f, err := os.OpenFile(path+fileMetaSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|fileOpenNoFollow, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to save resume info: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to save resume info: %w", err)
+	}
+	return nil
+}
+
+// finishPartFile 比较接收时计算的SHA-256，通过后将.part文件重命名为目标文件
+// 校验失败时删除.part文件和续传信息
+func finishPartFile(path string, digest string, actual string) error {
+	partPath := path + filePartSuffix
+	if actual != digest {
+		os.Remove(partPath)
+		os.Remove(path + fileMetaSuffix)
+		return fmt.Errorf("%w: %s", ErrFileDigestMismatch, filepath.Base(path))
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	os.Remove(path + fileMetaSuffix)
+	return nil
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package transmission
+
+// fileOpenNoFollow 不支持O_NOFOLLOW的平台依赖resolveFilePath的Lstat检查
+const fileOpenNoFollow = 0
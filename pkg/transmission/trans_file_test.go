@@ -0,0 +1,321 @@
+package transmission
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestRoot 创建接收根目录和根目录外的目录
+func newTestRoot(t *testing.T) (root string, outside string) {
+	t.Helper()
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Mkdir failed: %v", err)
+		}
+	}
+	return root, outside
+}
+
+// symlinkOrSkip 创建符号链接，平台不支持时跳过测试
+func symlinkOrSkip(t *testing.T, target string, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Symlink not supported: %v", err)
+	}
+}
+
+// 测试目标路径解析拒绝绝对路径和跳出根目录的路径，且不创建任何目录
+func TestResolveFilePathTraversal(t *testing.T) {
+	root, outside := newTestRoot(t)
+
+	names := []string{
+		"",
+		"/etc/passwd",
+		"\\windows\\system32",
+		"../outside/file",
+		"a/../../outside/file",
+		"a\\..\\..\\outside\\file",
+		"a/b/",
+		".",
+		"a/\x00/b",
+	}
+	for _, name := range names {
+		if _, err := resolveFilePath(root, name); !errors.Is(err, ErrInvalidFilePath) {
+			t.Errorf("resolveFilePath(%q): expected ErrInvalidFilePath, got %v", name, err)
+		}
+	}
+
+	for _, dir := range []string{root, outside} {
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("Expected no entries created in %s, got %d", dir, len(entries))
+		}
+	}
+
+	// 合法路径逐级创建父目录
+	path, err := resolveFilePath(root, "a/b/c.txt")
+	if err != nil {
+		t.Fatalf("resolveFilePath failed: %v", err)
+	}
+	realRoot, _ := filepath.EvalSymlinks(root)
+	if path != filepath.Join(realRoot, "a", "b", "c.txt") {
+		t.Errorf("Unexpected path: %s", path)
+	}
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		t.Errorf("Expected parent dir created: %v", err)
+	}
+}
+
+// 测试符号链接：指向根目录外的目录不能被用于写入，也不会在根目录外创建目录；
+// 根目录内的符号链接目录可以使用；目标文件和临时文件不能是符号链接
+func TestResolveFilePathSymlink(t *testing.T) {
+	root, outside := newTestRoot(t)
+	symlinkOrSkip(t, outside, filepath.Join(root, "escape"))
+
+	for _, name := range []string{"escape/file", "escape/new/dir/file"} {
+		if _, err := resolveFilePath(root, name); !errors.Is(err, ErrInvalidFilePath) {
+			t.Errorf("resolveFilePath(%q): expected ErrInvalidFilePath, got %v", name, err)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("Expected nothing created outside root, got %d entries", len(entries))
+	}
+
+	// 根目录内的符号链接目录
+	if err := os.Mkdir(filepath.Join(root, "real"), 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	symlinkOrSkip(t, filepath.Join(root, "real"), filepath.Join(root, "alias"))
+	path, err := resolveFilePath(root, "alias/sub/file")
+	if err != nil {
+		t.Fatalf("resolveFilePath via in-root symlink failed: %v", err)
+	}
+	realRoot, _ := filepath.EvalSymlinks(root)
+	if path != filepath.Join(realRoot, "real", "sub", "file") {
+		t.Errorf("Unexpected path: %s", path)
+	}
+
+	// 已存在的路径组件是普通文件
+	if err := os.WriteFile(filepath.Join(root, "plain"), nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := resolveFilePath(root, "plain/file"); !errors.Is(err, ErrInvalidFilePath) {
+		t.Errorf("Expected ErrInvalidFilePath for file component, got %v", err)
+	}
+
+	// 目标文件、.part和.part.json为符号链接
+	target := filepath.Join(outside, "target")
+	if err := os.WriteFile(target, []byte("secret"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	for i, suffix := range []string{"", filePartSuffix, fileMetaSuffix} {
+		name := "link" + string(rune('a'+i))
+		symlinkOrSkip(t, target, filepath.Join(root, name+suffix))
+		if _, err := resolveFilePath(root, name); !errors.Is(err, ErrInvalidFilePath) {
+			t.Errorf("Expected ErrInvalidFilePath for symlinked %q, got %v", name+suffix, err)
+		}
+	}
+}
+
+// 测试.part和.part.json不跟随符号链接（resolveFilePath检查之后被替换为符号链接的情况）
+func TestPartFileNoFollow(t *testing.T) {
+	if fileOpenNoFollow == 0 {
+		t.Skip("O_NOFOLLOW not supported")
+	}
+	root, outside := newTestRoot(t)
+	target := filepath.Join(outside, "target")
+	if err := os.WriteFile(target, []byte("secret"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	info := &fileInfoMsg{Size: 10, Digest: hex.EncodeToString(make([]byte, sha256.Size))}
+	for i, suffix := range []string{filePartSuffix, fileMetaSuffix} {
+		path := filepath.Join(root, fmt.Sprintf("file%d", i))
+		symlinkOrSkip(t, target, path+suffix)
+		if _, _, err := preparePartFile(path, info); err == nil {
+			t.Errorf("Expected preparePartFile to fail for symlinked %s", suffix)
+		}
+	}
+	if data, _ := os.ReadFile(target); string(data) != "secret" {
+		t.Errorf("Symlink target modified: %q", data)
+	}
+}
+
+// 测试续传偏移：续传信息与文件一致且包含SHA-256中间状态时从已确认偏移续传并丢弃之后的数据，否则从头接收
+func TestPreparePartFileResume(t *testing.T) {
+	root, _ := newTestRoot(t)
+	digest := hex.EncodeToString(make([]byte, sha256.Size))
+	info := &fileInfoMsg{Size: 100, Digest: digest}
+	prefix := sha256.New()
+	prefix.Write(make([]byte, 40))
+	state := marshalHashState(prefix)
+
+	tests := []struct {
+		name    string
+		meta    *filePartMeta
+		partLen int
+		want    int64
+	}{
+		{"no resume info", nil, 30, 0},
+		{"resume", &filePartMeta{Size: 100, Digest: digest, Offset: 40, HashState: state}, 60, 40},
+		{"no hash state", &filePartMeta{Size: 100, Digest: digest, Offset: 40}, 60, 0},
+		{"invalid hash state", &filePartMeta{Size: 100, Digest: digest, Offset: 40, HashState: "00"}, 60, 0},
+		{"size changed", &filePartMeta{Size: 200, Digest: digest, Offset: 40, HashState: state}, 60, 0},
+		{"digest changed", &filePartMeta{Size: 100, Digest: "00", Offset: 40, HashState: state}, 60, 0},
+		{"offset beyond size", &filePartMeta{Size: 100, Digest: digest, Offset: 120, HashState: state}, 120, 0},
+		{"part file shorter", &filePartMeta{Size: 100, Digest: digest, Offset: 40, HashState: state}, 20, 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(root, "file"+string(rune('a'+i)))
+			if err := os.WriteFile(path+filePartSuffix, make([]byte, tt.partLen), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			if tt.meta != nil {
+				data, _ := json.Marshal(tt.meta)
+				if err := os.WriteFile(path+fileMetaSuffix, data, 0644); err != nil {
+					t.Fatalf("WriteFile failed: %v", err)
+				}
+			}
+
+			offset, h, err := preparePartFile(path, info)
+			if err != nil {
+				t.Fatalf("preparePartFile failed: %v", err)
+			}
+			if offset != tt.want {
+				t.Errorf("Expected offset %d, got %d", tt.want, offset)
+			}
+			if stat, err := os.Stat(path + filePartSuffix); err != nil || stat.Size() != offset {
+				t.Errorf("Expected part file truncated to %d: %v", offset, err)
+			}
+			// 恢复的SHA-256与续传前缀一致，之后的数据继续增量计算
+			h.Write(make([]byte, 100-offset))
+			if full := sha256.Sum256(make([]byte, 100)); !bytes.Equal(h.Sum(nil), full[:]) {
+				t.Error("Expected resumed digest to match the whole file")
+			}
+
+			var meta filePartMeta
+			data, _ := os.ReadFile(path + fileMetaSuffix)
+			if err := json.Unmarshal(data, &meta); err != nil || meta.Offset != offset || meta.Size != info.Size ||
+				unmarshalHashState(meta.HashState) == nil {
+				t.Errorf("Unexpected resume info: %s", data)
+			}
+		})
+	}
+}
+
+// 测试接收方拒绝超过大小上限的文件，不创建任何文件
+func TestHandleFileStartSizeLimit(t *testing.T) {
+	root, _ := newTestRoot(t)
+	session := addTestSession(t, "file_limit", TypeFile, &SessionServer{})
+	if err := SetFileReceiveListener("test_pkg", session.SessionName, &FileReceiveListener{}, root); err != nil {
+		t.Fatalf("SetFileReceiveListener failed: %v", err)
+	}
+	defer func() {
+		gFileMu.Lock()
+		delete(gFileRecvConfigs, session.SessionName)
+		gFileMu.Unlock()
+	}()
+
+	digest := hex.EncodeToString(make([]byte, sha256.Size))
+	handleFileStart(session, &fileStartMsg{TransID: 1, Files: []fileInfoMsg{
+		{Path: "small", Size: 10, Digest: digest},
+		{Path: "dir/huge", Size: MaxReceiveFileSize + 1, Digest: digest},
+	}})
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Errorf("Expected oversized transfer rejected before creating files, got %d entries", len(entries))
+	}
+
+	// 上限以内的文件准备.part文件
+	handleFileStart(session, &fileStartMsg{TransID: 2, Files: []fileInfoMsg{
+		{Path: "small", Size: 10, Digest: digest},
+	}})
+	if _, err := os.Stat(filepath.Join(root, "small"+filePartSuffix)); err != nil {
+		t.Errorf("Expected part file prepared: %v", err)
+	}
+}
+
+// 测试接收方增量计算SHA-256：中断后从续传信息恢复中间状态，收齐后无需重新读取文件即可校验
+func TestFileReceiverResumeDigest(t *testing.T) {
+	root, _ := newTestRoot(t)
+	ackInterval := FileAckInterval
+	FileAckInterval = 16
+	defer func() { FileAckInterval = ackInterval }()
+
+	content := bytes.Repeat([]byte("0123456789"), 10)
+	sum := sha256.Sum256(content)
+	info := fileInfoMsg{Path: "resume", Size: int64(len(content)), Digest: hex.EncodeToString(sum[:])}
+	path := filepath.Join(root, info.Path)
+
+	var finished, failed int
+	listener := &FileReceiveListener{
+		OnReceiveFileFinished: func(sessionID int32, files []string) { finished++ },
+		OnFileTransError:      func(sessionID int32, err error) { failed++ },
+	}
+	// receive 准备.part文件并从续传偏移接收数据，最多接收到limit
+	receive := func(transID uint32, limit int) int64 {
+		offset, h, err := preparePartFile(path, &info)
+		if err != nil {
+			t.Fatalf("preparePartFile failed: %v", err)
+		}
+		r := &fileReceiver{
+			sessionID: 9101,
+			transID:   transID,
+			files:     []fileInfoMsg{info},
+			paths:     []string{path},
+			written:   []int64{offset},
+			acked:     []int64{offset},
+			hashes:    []hash.Hash{h},
+			total:     info.Size,
+			listener:  listener,
+		}
+		gFileMu.Lock()
+		gFileReceivers[r.sessionID] = r
+		gFileMu.Unlock()
+		r.advance()
+
+		for pos := int(offset); pos < limit; pos += 16 {
+			end := pos + 16
+			if end > limit {
+				end = limit
+			}
+			frame := make([]byte, fileDataHeadLen, fileDataHeadLen+end-pos)
+			frame[0] = fileFrameData
+			binary.LittleEndian.PutUint32(frame[1:], transID)
+			binary.LittleEndian.PutUint64(frame[9:], uint64(pos))
+			handleFileData(r.sessionID, append(frame, content[pos:end]...))
+		}
+		return offset
+	}
+
+	// 接收40字节后连接断开（已确认32字节）
+	receive(1, 40)
+	gFileMu.Lock()
+	r := gFileReceivers[9101]
+	gFileMu.Unlock()
+	r.abort(ErrSessionChannelClosed, 0)
+
+	if offset := receive(2, len(content)); offset != 32 {
+		t.Fatalf("Expected resume from acked offset 32, got %d", offset)
+	}
+	if finished != 1 || failed != 1 {
+		t.Fatalf("Expected one finished and one aborted transfer, finished=%d failed=%d", finished, failed)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+		t.Errorf("Unexpected received file: %v", err)
+	}
+	if _, err := os.Stat(path + fileMetaSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected resume info removed: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build unix
+
+package transmission
+
+import "syscall"
+
+// fileOpenNoFollow 打开接收文件时不跟随符号链接
+const fileOpenNoFollow = syscall.O_NOFOLLOW
@@ -0,0 +1,324 @@
+package transmission
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/authentication"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 会话数据收发
+// 对应C代码: core/transmission/trans_channel/tcp_direct/src/trans_tcp_direct_message.c
+// ============================================================================
+//
+// 会话数据以MODULE_AUTH_MSG经认证连接加密发送，数据内容格式:
+//   [会话包头(20字节)] + [数据]
+// 会话包头字段（小端序）:
+//   - Magic:     魔数（与DM/HiChain的JSON消息区分）
+//   - SessionID: 接收方会话ID
+//   - Seq:       发送方会话内的序列号（从1开始递增）
+//   - Flags:     数据类型（FlagBytes/FlagMessage/FlagFile/FlagStream，须与会话数据类型一致），分片时置位FlagFragment
+//   - DataLen:   数据长度
+// 接收方按SessionID分发到会话，按会话数据类型回调，同一会话的回调（OnBind/OnBytes/OnMessage/OnShutdown）按序串行执行
+
+const (
+	// SessionPktHeadLen 会话包头长度
+	SessionPktHeadLen = 20
+
+	// sessionFrameMaxLen 单个加密数据包可承载的会话数据长度，超过时分片发送
+	sessionFrameMaxLen = authentication.AuthSocketMaxDataLen - authentication.AuthEncryptOverhead - SessionPktHeadLen
+
+	// sessionPktMagic 会话包头魔数
+	sessionPktMagic uint32 = authentication.MagicNumber
+)
+
+// 会话数据类型（会话包头Flags）
+const (
+	FlagBytes   int32 = 0 // 字节数据（TypeBytes），回调OnBytes
+	FlagMessage int32 = 2 // 消息数据（TypeMessage），回调OnMessage
+	FlagFile    int32 = 3 // 文件数据帧（TypeFile）
+	FlagStream  int32 = 4 // 流数据帧（TypeStream）
+)
+
+// sessionTypeFlag 会话数据类型对应的会话包头Flags
+func sessionTypeFlag(t SessionType) int32 {
+	switch t {
+	case TypeMessage:
+		return FlagMessage
+	case TypeFile:
+		return FlagFile
+	case TypeStream:
+		return FlagStream
+	default:
+		return FlagBytes
+	}
+}
+
+// SessionPktHead 会话包头（对应C的TdcPacketHead）
+type SessionPktHead struct {
+	Magic     uint32 // 魔数
+	SessionID int32  // 接收方会话ID
+	Seq       int32  // 会话内序列号
+	Flags     int32  // 数据类型
+	DataLen   uint32 // 数据长度
+}
+
+// packSessionPkt 打包会话数据
+func packSessionPkt(head *SessionPktHead, data []byte) []byte {
+	buf := make([]byte, SessionPktHeadLen+len(data))
+	binary.LittleEndian.PutUint32(buf[0:], head.Magic)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(head.SessionID))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(head.Seq))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(head.Flags))
+	binary.LittleEndian.PutUint32(buf[16:], head.DataLen)
+	copy(buf[SessionPktHeadLen:], data)
+	return buf
+}
+
+// unpackSessionPkt 解析会话数据，魔数不匹配时返回错误
+func unpackSessionPkt(buf []byte) (*SessionPktHead, []byte, error) {
+	if len(buf) < SessionPktHeadLen {
+		return nil, nil, fmt.Errorf("data too short: need %d bytes, got %d", SessionPktHeadLen, len(buf))
+	}
+
+	head := &SessionPktHead{
+		Magic:     binary.LittleEndian.Uint32(buf[0:]),
+		SessionID: int32(binary.LittleEndian.Uint32(buf[4:])),
+		Seq:       int32(binary.LittleEndian.Uint32(buf[8:])),
+		Flags:     int32(binary.LittleEndian.Uint32(buf[12:])),
+		DataLen:   binary.LittleEndian.Uint32(buf[16:]),
+	}
+	if head.Magic != sessionPktMagic {
+		return nil, nil, fmt.Errorf("invalid magic number: 0x%X", head.Magic)
+	}
+	if int(head.DataLen) != len(buf)-SessionPktHeadLen {
+		return nil, nil, fmt.Errorf("data length mismatch: head.DataLen=%d, actual=%d", head.DataLen, len(buf)-SessionPktHeadLen)
+	}
+	return head, buf[SessionPktHeadLen:], nil
+}
+
+// isSessionPkt 判断AUTH_MSG数据是否为会话数据
+func isSessionPkt(buf []byte) bool {
+	return len(buf) >= SessionPktHeadLen && binary.LittleEndian.Uint32(buf) == sessionPktMagic
+}
+
+// sendSessionData 向会话对端发送数据，dataType须与会话数据类型一致
+func sendSessionData(sessionID int32, dataType SessionType, data []byte) error {
+	mgr := getSessionManager()
+	mgr.mu.RLock()
+	session, exists := mgr.sessions[sessionID]
+	opened := exists && session.IsOpened
+	mgr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("session not found: %d", sessionID)
+	}
+	if !opened {
+		return fmt.Errorf("session not opened: %d", sessionID)
+	}
+	if session.DataType != dataType {
+		return fmt.Errorf("session type mismatch: session=%d, type=%d", session.DataType, dataType)
+	}
+	if len(data) == 0 || len(data) > dataType.maxSendLen() {
+		return fmt.Errorf("invalid data length: %d bytes (max %d)", len(data), dataType.maxSendLen())
+	}
+
+	// 只有TypeBytes的上限超过单个数据包，TypeMessage和TypeFile帧总是整包发送
+	flags := sessionTypeFlag(dataType)
+	if len(data) > sessionFrameMaxLen {
+		return sendFragments(session, flags, data)
+	}
+	return postSessionPkt(session, flags, data)
+}
+
+// postSessionPkt 发送单个会话数据包
+func postSessionPkt(session *Session, flags int32, payload []byte) error {
+	head := &SessionPktHead{
+		Magic:     sessionPktMagic,
+		SessionID: session.PeerSessionID,
+		Seq:       atomic.AddInt32(&session.sendSeq, 1),
+		Flags:     flags,
+		DataLen:   uint32(len(payload)),
+	}
+
+	// 通过认证管理器发送，数据使用会话密钥加密
+	return authentication.AuthDevicePostTransData(session.AuthID, authentication.ModuleAuthMsg, 0, packSessionPkt(head, payload))
+}
+
+// handleSessionData 处理接收到的会话数据，按会话串行投递到会话服务器回调
+func handleSessionData(channelId int, buf []byte) {
+	head, data, err := unpackSessionPkt(buf)
+	if err != nil {
+		log.Errorf("[SESSION] Drop session data: channelId=%d, err=%v", channelId, err)
+		return
+	}
+
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	session, exists := mgr.sessions[head.SessionID]
+	if !exists || !session.IsOpened || session.ChannelID != channelId {
+		log.Warnf("[SESSION] Drop data for unknown session: channelId=%d, sessionID=%d", channelId, head.SessionID)
+		return
+	}
+
+	// 连接保证有序，序列号回退说明是重复数据
+	if head.Seq <= session.recvSeq {
+		log.Warnf("[SESSION] Drop duplicate data: sessionID=%d, seq=%d, last=%d", head.SessionID, head.Seq, session.recvSeq)
+		return
+	}
+	if head.Seq != session.recvSeq+1 {
+		log.Warnf("[SESSION] Session data lost: sessionID=%d, seq=%d, expected=%d", head.SessionID, head.Seq, session.recvSeq+1)
+	}
+	session.recvSeq = head.Seq
+
+	server := mgr.servers[session.SessionName]
+	if server == nil {
+		log.Warnf("[SESSION] Session server removed, drop data: sessionID=%d", head.SessionID)
+		return
+	}
+
+	// 数据帧类型须与会话数据类型一致
+	if head.Flags&^FlagFragment != sessionTypeFlag(session.DataType) {
+		log.Warnf("[SESSION] Drop data with mismatched flags: sessionID=%d, type=%d, flags=%d",
+			head.SessionID, session.DataType, head.Flags)
+		return
+	}
+
+	// 分片收齐后整体回调
+	if head.Flags&FlagFragment != 0 {
+		var complete bool
+		if data, complete = session.reassemble(data); !complete {
+			return
+		}
+	}
+	if len(data) > session.DataType.maxSendLen() {
+		log.Warnf("[SESSION] Drop oversized data: sessionID=%d, type=%d, len=%d", head.SessionID, session.DataType, len(data))
+		return
+	}
+
+	var onData func(sessionID int32, data []byte)
+	switch session.DataType {
+	case TypeBytes:
+		onData = server.OnBytes
+	case TypeMessage:
+		onData = server.OnMessage
+	case TypeFile:
+		// 文件数据帧由文件传输处理，可能读写磁盘，不在锁内执行
+		if !session.receiver.postData(len(data), func() { handleFileFrame(session, data) }) {
+			closeOverflowedSessionLocked(mgr, session)
+		}
+		return
+	default:
+		// 流数据经UDP数据通道收发，见trans_stream.go
+		log.Warnf("[SESSION] Drop stream data on auth channel: sessionID=%d, type=%d", head.SessionID, session.DataType)
+		return
+	}
+
+	log.Infof("[SESSION] Received data: sessionID=%d, seq=%d, flags=%d, len=%d", head.SessionID, head.Seq, head.Flags, len(data))
+	if onData != nil {
+		sessionID := session.SessionID
+		if !session.receiver.postData(len(data), func() { onData(sessionID, data) }) {
+			closeOverflowedSessionLocked(mgr, session)
+		}
+	}
+}
+
+// closeOverflowedSessionLocked 回调队列已满（应用处理过慢），丢弃数据会破坏会话数据的完整性，
+// 因此关闭会话并通知对端（调用方持有锁）
+func closeOverflowedSessionLocked(mgr *SessionManager, session *Session) {
+	log.Warnf("[SESSION] Receive queue full, closing session: sessionID=%d, maxPending=%d, maxPendingBytes=%d",
+		session.SessionID, sessionReceiverMaxPending, sessionReceiverMaxPendingBytes)
+	mgr.removeSessionLocked(session)
+	go sendCloseSessionRequest(session.AuthID, session.SessionID, session.PeerSessionID)
+}
+
+// ============================================================================
+// 会话回调串行执行
+// ============================================================================
+
+const (
+	// sessionReceiverMaxPending 会话回调队列中待执行的数据回调上限
+	sessionReceiverMaxPending = 256
+	// sessionReceiverMaxPendingBytes 会话回调队列中待执行的数据回调的数据量上限（不小于单次数据上限BytesMaxLen）
+	sessionReceiverMaxPendingBytes = 16 * 1024 * 1024
+)
+
+// sessionReceiver 会话回调队列
+// 同一会话的回调按投递顺序在单个goroutine中执行，不同会话互不阻塞，也不阻塞连接的接收
+// 数据回调受sessionReceiverMaxPending和sessionReceiverMaxPendingBytes限制，先达到任一上限即拒绝；
+// OnBind/OnShutdown等控制回调总是入队，保证会话状态通知不丢失
+type sessionReceiver struct {
+	mu           sync.Mutex
+	pending      []receiverTask
+	pendingData  int // 队列中的数据回调数
+	pendingBytes int // 队列中的数据回调的数据量
+	running      bool
+}
+
+// receiverTask 队列中的回调
+type receiverTask struct {
+	run    func()
+	size   int // 数据回调的数据长度
+	isData bool
+}
+
+// post 追加控制回调，当前没有执行中的goroutine时启动一个
+func (r *sessionReceiver) post(task func()) {
+	r.enqueue(receiverTask{run: task}, 0, 0)
+}
+
+// postData 追加数据回调（size为数据长度），队列中的数据回调数或数据量达到上限时返回false
+func (r *sessionReceiver) postData(size int, task func()) bool {
+	return r.enqueue(receiverTask{run: task, size: size, isData: true}, sessionReceiverMaxPending, sessionReceiverMaxPendingBytes)
+}
+
+// enqueue 追加回调，maxCount>0、maxBytes>0时分别限制队列中的数据回调数和数据量
+func (r *sessionReceiver) enqueue(task receiverTask, maxCount int, maxBytes int) bool {
+	r.mu.Lock()
+	if (maxCount > 0 && r.pendingData >= maxCount) || (maxBytes > 0 && r.pendingBytes+task.size > maxBytes) {
+		r.mu.Unlock()
+		return false
+	}
+	r.pending = append(r.pending, task)
+	if task.isData {
+		r.pendingData++
+		r.pendingBytes += task.size
+	}
+	if r.running {
+		r.mu.Unlock()
+		return true
+	}
+	r.running = true
+	r.mu.Unlock()
+
+	go r.run()
+	return true
+}
+
+// run 按序执行队列中的回调，队列为空时退出
+func (r *sessionReceiver) run() {
+	for {
+		r.mu.Lock()
+		if len(r.pending) == 0 {
+			r.running = false
+			r.mu.Unlock()
+			return
+		}
+		task := r.pending[0]
+		r.pending[0] = receiverTask{}
+		r.pending = r.pending[1:]
+		if task.isData {
+			r.pendingData--
+			r.pendingBytes -= task.size
+		}
+		r.mu.Unlock()
+
+		task.run()
+	}
+}
@@ -0,0 +1,345 @@
+package transmission
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildTestSessionPkt 构造发往指定会话的数据包
+func buildTestSessionPkt(sessionID int32, seq int32, flags int32, data []byte) []byte {
+	return packSessionPkt(&SessionPktHead{
+		Magic:     sessionPktMagic,
+		SessionID: sessionID,
+		Seq:       seq,
+		Flags:     flags,
+		DataLen:   uint32(len(data)),
+	}, data)
+}
+
+// 测试会话回调队列按投递顺序串行执行
+func TestSessionReceiverOrder(t *testing.T) {
+	r := &sessionReceiver{}
+	var mu sync.Mutex
+	var got []int
+	done := make(chan struct{})
+
+	const count = 100
+	for i := 0; i < count; i++ {
+		i := i
+		task := func() {
+			mu.Lock()
+			got = append(got, i)
+			mu.Unlock()
+			if i == count-1 {
+				close(done)
+			}
+		}
+		if i%10 == 0 {
+			r.post(task)
+		} else if !r.postData(0, task) {
+			t.Fatalf("postData failed at %d", i)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Callbacks not executed")
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("Unexpected callback order at %d: %v", i, got)
+		}
+	}
+}
+
+// 测试数据回调队列上限：超出上限的数据回调被拒绝，控制回调仍然入队
+func TestSessionReceiverLimit(t *testing.T) {
+	r := &sessionReceiver{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.postData(0, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	for i := 0; i < sessionReceiverMaxPending; i++ {
+		if !r.postData(0, func() {}) {
+			t.Fatalf("postData rejected at %d (limit %d)", i, sessionReceiverMaxPending)
+		}
+	}
+	if r.postData(0, func() {}) {
+		t.Error("Expected postData to be rejected when queue is full")
+	}
+
+	controlDone := make(chan struct{})
+	r.post(func() { close(controlDone) })
+	close(release)
+
+	select {
+	case <-controlDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Control callback not executed")
+	}
+
+	// 队列清空后可以继续投递
+	if !r.postData(0, func() {}) {
+		t.Error("Expected postData to succeed after queue drained")
+	}
+}
+
+// 测试数据回调队列数据量上限：数据量先达到上限时拒绝，回调数未达上限
+func TestSessionReceiverByteLimit(t *testing.T) {
+	r := &sessionReceiver{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.postData(0, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	const size = BytesMaxLen
+	count := sessionReceiverMaxPendingBytes / size
+	for i := 0; i < count; i++ {
+		if !r.postData(size, func() {}) {
+			t.Fatalf("postData rejected at %d (limit %d bytes)", i, sessionReceiverMaxPendingBytes)
+		}
+	}
+	if r.postData(1, func() {}) {
+		t.Errorf("Expected postData to be rejected when pending bytes reach the limit (%d callbacks)", count)
+	}
+
+	done := make(chan struct{})
+	r.post(func() { close(done) })
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Control callback not executed")
+	}
+
+	// 队列清空后数据量归零
+	r.mu.Lock()
+	pendingBytes := r.pendingBytes
+	r.mu.Unlock()
+	if pendingBytes != 0 || !r.postData(size, func() {}) {
+		t.Errorf("Expected queue drained, pendingBytes=%d", pendingBytes)
+	}
+}
+
+// 测试同一连接上多个会话的数据分发：按SessionID分发到各自会话并保持会话内顺序，
+// 丢弃未知会话、其他连接、重复序列号和类型不匹配的数据
+func TestHandleSessionDataDemux(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[int32][]string)
+	onBytes := func(sessionID int32, data []byte) {
+		mu.Lock()
+		received[sessionID] = append(received[sessionID], string(data))
+		mu.Unlock()
+	}
+	a := addTestSession(t, "demux_a", TypeBytes, &SessionServer{OnBytes: onBytes})
+	b := addTestSession(t, "demux_b", TypeBytes, &SessionServer{OnBytes: onBytes})
+
+	const count = 20
+	for i := 1; i <= count; i++ {
+		handleSessionData(a.ChannelID, buildTestSessionPkt(a.SessionID, int32(i), FlagBytes, []byte(fmt.Sprintf("a%d", i))))
+		handleSessionData(b.ChannelID, buildTestSessionPkt(b.SessionID, int32(i), FlagBytes, []byte(fmt.Sprintf("b%d", i))))
+	}
+
+	// 以下数据均应被丢弃
+	handleSessionData(a.ChannelID, buildTestSessionPkt(a.SessionID, count, FlagBytes, []byte("dup")))
+	handleSessionData(a.ChannelID+1, buildTestSessionPkt(a.SessionID, count+1, FlagBytes, []byte("other channel")))
+	handleSessionData(a.ChannelID, buildTestSessionPkt(a.SessionID+b.SessionID, 1, FlagBytes, []byte("unknown")))
+	handleSessionData(b.ChannelID, buildTestSessionPkt(b.SessionID, count+1, FlagMessage, []byte("wrong type")))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		done := len(received[a.SessionID]) >= count && len(received[b.SessionID]) >= count
+		mu.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Errorf("Expected data for 2 sessions, got %v", received)
+	}
+	for _, s := range []struct {
+		id     int32
+		prefix string
+	}{{a.SessionID, "a"}, {b.SessionID, "b"}} {
+		got := received[s.id]
+		if len(got) != count {
+			t.Fatalf("Session %d: expected %d callbacks, got %d: %v", s.id, count, len(got), got)
+		}
+		for i, v := range got {
+			if want := fmt.Sprintf("%s%d", s.prefix, i+1); v != want {
+				t.Errorf("Session %d: callback %d = %q, want %q", s.id, i, v, want)
+			}
+		}
+	}
+}
+
+// 测试回调队列已满时关闭会话并调用OnShutdown
+func TestHandleSessionDataOverflow(t *testing.T) {
+	release := make(chan struct{})
+	shutdown := make(chan int32, 1)
+	var once sync.Once
+	session := addTestSession(t, "overflow", TypeBytes, &SessionServer{
+		OnBytes:    func(sessionID int32, data []byte) { once.Do(func() { <-release }) },
+		OnShutdown: func(sessionID int32) { shutdown <- sessionID },
+	})
+
+	for seq := int32(1); seq <= sessionReceiverMaxPending+2; seq++ {
+		handleSessionData(session.ChannelID, buildTestSessionPkt(session.SessionID, seq, FlagBytes, []byte("x")))
+	}
+
+	mgr := getSessionManager()
+	mgr.mu.RLock()
+	_, exists := mgr.sessions[session.SessionID]
+	mgr.mu.RUnlock()
+	if exists {
+		t.Error("Expected session closed on queue overflow")
+	}
+
+	close(release)
+	select {
+	case id := <-shutdown:
+		if id != session.SessionID {
+			t.Errorf("Unexpected OnShutdown sessionID: %d", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected OnShutdown after queue overflow")
+	}
+}
+
+// buildTestFragments 将数据按chunkLen拆分为分片（不含会话包头）
+func buildTestFragments(msgID uint32, data []byte, chunkLen int) [][]byte {
+	count := (len(data) + chunkLen - 1) / chunkLen
+	head := &FragmentHead{
+		MessageID: msgID,
+		TotalLen:  uint32(len(data)),
+		Count:     uint32(count),
+		Digest:    sha256.Sum256(data),
+	}
+	var frags [][]byte
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkLen
+		if end > len(data) {
+			end = len(data)
+		}
+		head.Index = uint32(i)
+		frags = append(frags, packFragment(head, data[i*chunkLen:end]))
+	}
+	return frags
+}
+
+// blockReceiver 阻塞会话回调队列，返回释放函数；阻塞期间可通过pendingData观察投递的数据回调
+func blockReceiver(t *testing.T, session *Session) func() {
+	t.Helper()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	session.receiver.postData(0, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	var once sync.Once
+	return func() { once.Do(func() { close(release) }) }
+}
+
+// 测试各会话类型的发送长度上限和类型检查
+func TestSendSessionDataTypeLimits(t *testing.T) {
+	// TypeMessage和TypeFile帧不超过单个数据包，不经过分片
+	if MessageMaxLen > sessionFrameMaxLen || FileFrameMaxLen > sessionFrameMaxLen {
+		t.Fatalf("Message/file frame limit exceeds single packet: message=%d, file=%d, packet=%d",
+			MessageMaxLen, FileFrameMaxLen, sessionFrameMaxLen)
+	}
+
+	for _, dataType := range []SessionType{TypeMessage, TypeBytes, TypeFile} {
+		session := addTestSession(t, fmt.Sprintf("limit_%d", dataType), dataType, &SessionServer{})
+
+		err := sendSessionData(session.SessionID, dataType, make([]byte, dataType.maxSendLen()+1))
+		if err == nil || !strings.Contains(err.Error(), "invalid data length") {
+			t.Errorf("type=%d: expected length error for oversized data, got %v", dataType, err)
+		}
+		if err := sendSessionData(session.SessionID, dataType, nil); err == nil {
+			t.Errorf("type=%d: expected error for empty data", dataType)
+		}
+
+		other := TypeMessage
+		if dataType == TypeMessage {
+			other = TypeBytes
+		}
+		err = sendSessionData(session.SessionID, other, []byte("x"))
+		if err == nil || !strings.Contains(err.Error(), "type mismatch") {
+			t.Errorf("type=%d: expected type mismatch error, got %v", dataType, err)
+		}
+	}
+}
+
+// 测试接收端按会话类型检查数据帧类型和长度：不匹配或超长的数据被丢弃，
+// TypeFile整帧（最大FileFrameMaxLen）直接投递，对端分片发送的文件帧重组后同样受FileFrameMaxLen限制
+func TestHandleSessionDataTypeFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		dataType   SessionType
+		flags      int32
+		len        int
+		fragmented bool
+		want       bool
+	}{
+		{"message", TypeMessage, FlagMessage, 16, false, true},
+		{"message as bytes", TypeMessage, FlagBytes, 16, false, false},
+		{"message as file", TypeMessage, FlagFile, 16, false, false},
+		{"message oversized", TypeMessage, FlagMessage, MessageMaxLen + 1, false, false},
+		{"message fragmented oversized", TypeMessage, FlagMessage, MessageMaxLen + 1, true, false},
+		{"bytes as message", TypeBytes, FlagMessage, 16, false, false},
+		{"bytes as stream", TypeBytes, FlagStream, 16, false, false},
+		{"bytes fragmented", TypeBytes, FlagBytes, 3 * 1024, true, true},
+		{"file frame max", TypeFile, FlagFile, FileFrameMaxLen, false, true},
+		{"file as bytes", TypeFile, FlagBytes, 16, false, false},
+		{"file oversized", TypeFile, FlagFile, FileFrameMaxLen + 1, false, false},
+		{"file fragmented max", TypeFile, FlagFile, FileFrameMaxLen, true, true},
+		{"file fragmented oversized", TypeFile, FlagFile, FileFrameMaxLen + 1, true, false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := addTestSession(t, fmt.Sprintf("flags_%d", i), tt.dataType, &SessionServer{
+				OnBytes:   func(sessionID int32, data []byte) {},
+				OnMessage: func(sessionID int32, data []byte) {},
+			})
+			release := blockReceiver(t, session)
+			defer release()
+
+			data := make([]byte, tt.len)
+			data[0] = fileFrameData
+			if tt.fragmented {
+				for seq, frag := range buildTestFragments(1, data, tt.len/2+1) {
+					handleSessionData(session.ChannelID, buildTestSessionPkt(session.SessionID, int32(seq+1), tt.flags|FlagFragment, frag))
+				}
+			} else {
+				handleSessionData(session.ChannelID, buildTestSessionPkt(session.SessionID, 1, tt.flags, data))
+			}
+
+			session.receiver.mu.Lock()
+			queued := session.receiver.pendingData == 1
+			session.receiver.mu.Unlock()
+			if queued != tt.want {
+				t.Errorf("Expected delivered=%v, got %v", tt.want, queued)
+			}
+		})
+	}
+}
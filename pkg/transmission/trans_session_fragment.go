@@ -0,0 +1,280 @@
+package transmission
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 会话数据分片与重组
+// ============================================================================
+//
+// 超过单个数据包承载能力（sessionFrameMaxLen）的数据拆分为多个分片发送，
+// 会话包头Flags置位FlagFragment，数据内容格式:
+//   [分片头(48字节)] + [分片数据]
+// 分片头字段（小端序）:
+//   - MessageID: 发送方会话内的消息ID
+//   - TotalLen:  消息总长度
+//   - Index:     分片序号（从0开始）
+//   - Count:     分片总数
+//   - Digest:    消息整体的SHA-256摘要
+// 除最后一个分片外，各分片长度相同。接收方收齐后校验长度和摘要，再整体回调一次；
+// 重组缓冲区受内存预算限制（全局预算、每个通道的份额、每个会话未完成的消息数），
+// 单个对端无法占满全局预算阻塞其他会话的分片数据；超时未收齐的消息被丢弃
+
+const (
+	// FlagFragment 会话包头Flags的分片标志，与数据类型标志组合使用
+	FlagFragment int32 = 0x100
+
+	// FragmentHeadLen 分片头长度
+	FragmentHeadLen = 16 + sha256.Size
+
+	// fragmentDataMaxLen 单个分片可承载的数据长度
+	fragmentDataMaxLen = sessionFrameMaxLen - FragmentHeadLen
+)
+
+var (
+	// ReassemblyMemoryBudget 分片重组缓冲区占用内存上限（所有会话合计）
+	ReassemblyMemoryBudget int64 = 32 * 1024 * 1024
+	// ReassemblyChannelBudget 单个通道（同一对端连接上的所有会话）可占用的重组内存上限
+	ReassemblyChannelBudget int64 = 8 * 1024 * 1024
+	// ReassemblyMaxPending 单个会话同时重组的消息数上限
+	ReassemblyMaxPending = 4
+	// ReassemblyTimeout 分片重组超时时间
+	ReassemblyTimeout = 30 * time.Second
+
+	// gReassemblyMemUsed 分片重组缓冲区已占用的内存
+	gReassemblyMemUsed int64
+	// gReassemblyChannelMemUsed 各通道已占用的重组内存（channelId -> bytes），需持有会话管理器锁
+	gReassemblyChannelMemUsed = make(map[int]int64)
+)
+
+// FragmentHead 分片头
+type FragmentHead struct {
+	MessageID uint32            // 消息ID
+	TotalLen  uint32            // 消息总长度
+	Index     uint32            // 分片序号
+	Count     uint32            // 分片总数
+	Digest    [sha256.Size]byte // 消息SHA-256摘要
+}
+
+// reassemblyBuffer 单条消息的重组缓冲区
+type reassemblyBuffer struct {
+	head     FragmentHead
+	data     []byte
+	received []bool
+	remain   uint32      // 未收到的分片数
+	chunkLen int         // 非最后分片的长度（0表示尚未确定）
+	timer    *time.Timer // 重组超时定时器
+}
+
+// packFragment 打包分片
+func packFragment(head *FragmentHead, data []byte) []byte {
+	buf := make([]byte, FragmentHeadLen+len(data))
+	binary.LittleEndian.PutUint32(buf[0:], head.MessageID)
+	binary.LittleEndian.PutUint32(buf[4:], head.TotalLen)
+	binary.LittleEndian.PutUint32(buf[8:], head.Index)
+	binary.LittleEndian.PutUint32(buf[12:], head.Count)
+	copy(buf[16:], head.Digest[:])
+	copy(buf[FragmentHeadLen:], data)
+	return buf
+}
+
+// unpackFragment 解析分片
+func unpackFragment(buf []byte) (*FragmentHead, []byte, error) {
+	if len(buf) <= FragmentHeadLen {
+		return nil, nil, fmt.Errorf("fragment too short: %d bytes", len(buf))
+	}
+
+	head := &FragmentHead{
+		MessageID: binary.LittleEndian.Uint32(buf[0:]),
+		TotalLen:  binary.LittleEndian.Uint32(buf[4:]),
+		Index:     binary.LittleEndian.Uint32(buf[8:]),
+		Count:     binary.LittleEndian.Uint32(buf[12:]),
+	}
+	copy(head.Digest[:], buf[16:FragmentHeadLen])
+	return head, buf[FragmentHeadLen:], nil
+}
+
+// sendFragments 将数据分片发送
+func sendFragments(session *Session, flags int32, data []byte) error {
+	count := (len(data) + fragmentDataMaxLen - 1) / fragmentDataMaxLen
+	head := &FragmentHead{
+		MessageID: atomic.AddUint32(&session.fragMsgID, 1),
+		TotalLen:  uint32(len(data)),
+		Count:     uint32(count),
+		Digest:    sha256.Sum256(data),
+	}
+
+	for i := 0; i < count; i++ {
+		start := i * fragmentDataMaxLen
+		end := start + fragmentDataMaxLen
+		if end > len(data) {
+			end = len(data)
+		}
+
+		head.Index = uint32(i)
+		if err := postSessionPkt(session, flags|FlagFragment, packFragment(head, data[start:end])); err != nil {
+			return fmt.Errorf("failed to send fragment %d/%d: %w", i, count, err)
+		}
+	}
+
+	log.Infof("[SESSION] Sent fragmented data: sessionID=%d, msgID=%d, len=%d, fragments=%d",
+		session.SessionID, head.MessageID, len(data), count)
+	return nil
+}
+
+// reassemble 处理接收到的分片，收齐并校验通过后返回完整数据
+// 调用方需持有会话管理器锁
+func (s *Session) reassemble(buf []byte) ([]byte, bool) {
+	head, chunk, err := unpackFragment(buf)
+	if err != nil {
+		log.Warnf("[SESSION] Drop fragment: sessionID=%d, err=%v", s.SessionID, err)
+		return nil, false
+	}
+
+	// 分片数不超过按本端分片长度计算的分片数+1，避免少量数据声明大量分片占用received表
+	maxCount := (uint64(head.TotalLen)+uint64(fragmentDataMaxLen)-1)/uint64(fragmentDataMaxLen) + 1
+	if head.Count < 2 || head.Index >= head.Count || head.TotalLen > uint32(s.DataType.maxSendLen()) ||
+		uint64(head.Count) > maxCount {
+		log.Warnf("[SESSION] Drop invalid fragment: sessionID=%d, msgID=%d, index=%d, count=%d, total=%d",
+			s.SessionID, head.MessageID, head.Index, head.Count, head.TotalLen)
+		return nil, false
+	}
+
+	rb := s.reassembly[head.MessageID]
+	if rb == nil {
+		if rb = s.newReassemblyBuffer(head); rb == nil {
+			return nil, false
+		}
+	} else if rb.head.TotalLen != head.TotalLen || rb.head.Count != head.Count || rb.head.Digest != head.Digest {
+		log.Warnf("[SESSION] Drop inconsistent fragment: sessionID=%d, msgID=%d, index=%d",
+			s.SessionID, head.MessageID, head.Index)
+		return nil, false
+	}
+
+	if rb.received[head.Index] {
+		log.Warnf("[SESSION] Drop duplicate fragment: sessionID=%d, msgID=%d, index=%d", s.SessionID, head.MessageID, head.Index)
+		return nil, false
+	}
+
+	// 非最后分片长度相同，最后分片位于消息末尾
+	offset := int(head.TotalLen) - len(chunk)
+	if head.Index != head.Count-1 {
+		if rb.chunkLen == 0 {
+			rb.chunkLen = len(chunk)
+		}
+		offset = int(head.Index) * rb.chunkLen
+	}
+	if (head.Index != head.Count-1 && len(chunk) != rb.chunkLen) || offset < 0 || offset+len(chunk) > len(rb.data) {
+		log.Warnf("[SESSION] Drop fragment out of range: sessionID=%d, msgID=%d, index=%d, len=%d",
+			s.SessionID, head.MessageID, head.Index, len(chunk))
+		s.dropReassembly(head.MessageID)
+		return nil, false
+	}
+
+	copy(rb.data[offset:], chunk)
+	rb.received[head.Index] = true
+	rb.remain--
+	if rb.remain > 0 {
+		return nil, false
+	}
+
+	data := rb.data
+	s.dropReassembly(head.MessageID)
+
+	if sha256.Sum256(data) != head.Digest {
+		log.Errorf("[SESSION] Drop reassembled data: digest mismatch: sessionID=%d, msgID=%d", s.SessionID, head.MessageID)
+		return nil, false
+	}
+
+	log.Infof("[SESSION] Reassembled data: sessionID=%d, msgID=%d, len=%d, fragments=%d",
+		s.SessionID, head.MessageID, len(data), head.Count)
+	return data, true
+}
+
+// newReassemblyBuffer 在会话消息数、通道份额和全局内存预算内分配重组缓冲区
+// 调用方需持有会话管理器锁
+func (s *Session) newReassemblyBuffer(head *FragmentHead) *reassemblyBuffer {
+	if len(s.reassembly) >= ReassemblyMaxPending {
+		log.Warnf("[SESSION] Drop fragment: too many pending messages: sessionID=%d, msgID=%d, pending=%d",
+			s.SessionID, head.MessageID, len(s.reassembly))
+		return nil
+	}
+
+	size := int64(head.TotalLen)
+	if gReassemblyChannelMemUsed[s.ChannelID]+size > ReassemblyChannelBudget {
+		log.Warnf("[SESSION] Drop fragment: channel reassembly budget exceeded: sessionID=%d, channelId=%d, msgID=%d, total=%d",
+			s.SessionID, s.ChannelID, head.MessageID, head.TotalLen)
+		return nil
+	}
+	if atomic.AddInt64(&gReassemblyMemUsed, size) > ReassemblyMemoryBudget {
+		atomic.AddInt64(&gReassemblyMemUsed, -size)
+		log.Warnf("[SESSION] Drop fragment: reassembly memory budget exceeded: sessionID=%d, msgID=%d, total=%d",
+			s.SessionID, head.MessageID, head.TotalLen)
+		return nil
+	}
+	gReassemblyChannelMemUsed[s.ChannelID] += size
+
+	rb := &reassemblyBuffer{
+		head:     *head,
+		data:     make([]byte, head.TotalLen),
+		received: make([]bool, head.Count),
+		remain:   head.Count,
+	}
+	sessionID, msgID, timeout := s.SessionID, head.MessageID, ReassemblyTimeout
+	rb.timer = time.AfterFunc(timeout, func() {
+		onReassemblyTimeout(sessionID, msgID, timeout)
+	})
+
+	if s.reassembly == nil {
+		s.reassembly = make(map[uint32]*reassemblyBuffer)
+	}
+	s.reassembly[msgID] = rb
+	return rb
+}
+
+// dropReassembly 释放重组缓冲区
+// 调用方需持有会话管理器锁
+func (s *Session) dropReassembly(msgID uint32) {
+	rb := s.reassembly[msgID]
+	if rb == nil {
+		return
+	}
+	rb.timer.Stop()
+	delete(s.reassembly, msgID)
+	atomic.AddInt64(&gReassemblyMemUsed, -int64(rb.head.TotalLen))
+	gReassemblyChannelMemUsed[s.ChannelID] -= int64(rb.head.TotalLen)
+	if gReassemblyChannelMemUsed[s.ChannelID] <= 0 {
+		delete(gReassemblyChannelMemUsed, s.ChannelID)
+	}
+}
+
+// releaseReassembly 会话关闭时释放所有重组缓冲区
+// 调用方需持有会话管理器锁
+func (s *Session) releaseReassembly() {
+	for msgID := range s.reassembly {
+		s.dropReassembly(msgID)
+	}
+}
+
+// onReassemblyTimeout 重组超时，丢弃未收齐的消息
+func onReassemblyTimeout(sessionID int32, msgID uint32, timeout time.Duration) {
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	session, exists := mgr.sessions[sessionID]
+	if !exists || session.reassembly[msgID] == nil {
+		return
+	}
+	rb := session.reassembly[msgID]
+	log.Warnf("[SESSION] Reassembly timeout in %v: sessionID=%d, msgID=%d, received=%d/%d",
+		timeout, sessionID, msgID, rb.head.Count-rb.remain, rb.head.Count)
+	session.dropReassembly(msgID)
+}
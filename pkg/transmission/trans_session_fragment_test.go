@@ -0,0 +1,178 @@
+package transmission
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync/atomic"
+	"testing"
+)
+
+// 测试分片头打包与解析
+func TestPackUnpackFragment(t *testing.T) {
+	head := &FragmentHead{MessageID: 7, TotalLen: 300, Index: 1, Count: 3, Digest: sha256.Sum256([]byte("data"))}
+	chunk := []byte("chunk")
+
+	got, data, err := unpackFragment(packFragment(head, chunk))
+	if err != nil {
+		t.Fatalf("unpackFragment failed: %v", err)
+	}
+	if *got != *head || !bytes.Equal(data, chunk) {
+		t.Errorf("Round trip mismatch: head=%+v, data=%q", got, data)
+	}
+
+	// 分片头不完整或没有分片数据
+	for _, buf := range [][]byte{nil, make([]byte, FragmentHeadLen-1), packFragment(head, nil)} {
+		if _, _, err := unpackFragment(buf); err == nil {
+			t.Errorf("Expected error for %d-byte fragment", len(buf))
+		}
+	}
+}
+
+// 测试分片重组：乱序、重复、头部不一致、分片数越界、内存预算、摘要校验
+func TestReassemble(t *testing.T) {
+	payload := func(n int) []byte {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+		return data
+	}
+	large := payload(2*fragmentDataMaxLen + 100)
+	small := payload(300)
+
+	// 小数据分为2片（不超过分片数上限），大数据按本端分片长度分为3片
+	smallFrags := buildTestFragments(1, small, 200)
+	largeFrags := buildTestFragments(1, large, fragmentDataMaxLen)
+
+	// 修改分片头字段后重新打包
+	modify := func(frag []byte, fn func(h *FragmentHead)) []byte {
+		head, chunk, _ := unpackFragment(frag)
+		fn(head)
+		return packFragment(head, chunk)
+	}
+
+	tests := []struct {
+		name     string
+		dataType SessionType
+		budget   int64
+		frags    [][]byte
+		want     []byte // nil表示不应重组出数据
+		rejected bool   // 分片头校验失败，不分配重组缓冲区
+	}{
+		{"in order", TypeBytes, 0, largeFrags, large, false},
+		{"out of order", TypeBytes, 0, [][]byte{largeFrags[2], largeFrags[0], largeFrags[1]}, large, false},
+		{"last fragment first", TypeBytes, 0, [][]byte{smallFrags[1], smallFrags[0]}, small, false},
+		// 重复分片被丢弃，不提前完成重组
+		{"duplicate", TypeBytes, 0, [][]byte{smallFrags[0], smallFrags[0], smallFrags[1]}, small, false},
+		{"inconsistent total length", TypeBytes, 0, [][]byte{
+			smallFrags[0], modify(smallFrags[1], func(h *FragmentHead) { h.TotalLen-- }),
+		}, nil, false},
+		{"inconsistent count", TypeBytes, 0, [][]byte{
+			largeFrags[0], modify(largeFrags[1], func(h *FragmentHead) { h.Count = 2 }), largeFrags[2],
+		}, nil, false},
+		{"inconsistent digest", TypeBytes, 0, [][]byte{
+			smallFrags[0], modify(smallFrags[1], func(h *FragmentHead) { h.Digest[0] ^= 0xFF }),
+		}, nil, false},
+		{"inconsistent chunk length", TypeBytes, 0, [][]byte{
+			largeFrags[0], largeFrags[1][:FragmentHeadLen+100], largeFrags[2],
+		}, nil, false},
+		{"index out of range", TypeBytes, 0, [][]byte{modify(smallFrags[0], func(h *FragmentHead) { h.Index = 2 })}, nil, true},
+		{"single fragment", TypeBytes, 0, [][]byte{modify(smallFrags[0], func(h *FragmentHead) { h.Count = 1 })}, nil, true},
+		{"too many fragments", TypeBytes, 0, [][]byte{modify(smallFrags[0], func(h *FragmentHead) { h.Count = 3 })}, nil, true},
+		{"chunk exceeds total length", TypeBytes, 0, [][]byte{
+			modify(smallFrags[0], func(h *FragmentHead) { h.TotalLen = 1 }),
+		}, nil, false},
+		{"exceeds type limit", TypeMessage, 0, buildTestFragments(1, payload(MessageMaxLen+1), MessageMaxLen/2+1), nil, true},
+		{"over memory budget", TypeBytes, int64(len(small)) - 1, smallFrags, nil, true},
+		{"digest mismatch", TypeBytes, 0, func() [][]byte {
+			frags := buildTestFragments(1, small, 200)
+			for _, frag := range frags {
+				frag[16] ^= 0xFF
+			}
+			return frags
+		}(), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.budget > 0 {
+				saved := ReassemblyMemoryBudget
+				ReassemblyMemoryBudget = tt.budget
+				defer func() { ReassemblyMemoryBudget = saved }()
+			}
+			memUsed := atomic.LoadInt64(&gReassemblyMemUsed)
+
+			s := &Session{SessionID: 1, DataType: tt.dataType}
+			var got []byte
+			for i, frag := range tt.frags {
+				data, complete := s.reassemble(frag)
+				if complete {
+					if got != nil || i != len(tt.frags)-1 {
+						t.Fatalf("Unexpected completion at fragment %d", i)
+					}
+					got = data
+				}
+			}
+			if tt.rejected && len(s.reassembly) != 0 {
+				t.Error("Expected fragment to be rejected before allocating reassembly buffer")
+			}
+			if !bytes.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("Expected reassembled=%v (len=%d), got len=%d", tt.want != nil, len(tt.want), len(got))
+			}
+
+			s.releaseReassembly()
+			if used := atomic.LoadInt64(&gReassemblyMemUsed); used != memUsed {
+				t.Errorf("Reassembly memory leaked: before=%d, after=%d", memUsed, used)
+			}
+		})
+	}
+}
+
+// 测试单个会话无法占满重组内存：超出会话消息数和通道份额的消息被丢弃，其他通道的会话仍可重组
+func TestReassembleSessionLimits(t *testing.T) {
+	savedBudget, savedChannel := ReassemblyMemoryBudget, ReassemblyChannelBudget
+	defer func() { ReassemblyMemoryBudget, ReassemblyChannelBudget = savedBudget, savedChannel }()
+
+	data := make([]byte, 300)
+	frags := func(msgID uint32) [][]byte { return buildTestFragments(msgID, data, 200) }
+
+	// 全局预算可容纳攻击方会话的所有未完成消息，但通道份额与会话消息数限制其占用
+	ReassemblyChannelBudget = int64(len(data)) * int64(ReassemblyMaxPending)
+	ReassemblyMemoryBudget = ReassemblyChannelBudget + int64(len(data))
+	memUsed := atomic.LoadInt64(&gReassemblyMemUsed)
+
+	attacker := &Session{SessionID: 1, ChannelID: 101, DataType: TypeBytes}
+	for msgID := uint32(1); msgID <= uint32(ReassemblyMaxPending)+1; msgID++ {
+		attacker.reassemble(frags(msgID)[0])
+	}
+	if len(attacker.reassembly) != ReassemblyMaxPending {
+		t.Fatalf("Expected %d pending messages, got %d", ReassemblyMaxPending, len(attacker.reassembly))
+	}
+
+	// 同一通道上的其他会话共享通道份额
+	sibling := &Session{SessionID: 2, ChannelID: 101, DataType: TypeBytes}
+	sibling.reassemble(frags(1)[0])
+	if len(sibling.reassembly) != 0 {
+		t.Error("Expected channel budget to be shared by sessions on the same channel")
+	}
+
+	// 其他通道的会话不受影响
+	victim := &Session{SessionID: 3, ChannelID: 102, DataType: TypeBytes}
+	var got []byte
+	for _, frag := range frags(1) {
+		if d, complete := victim.reassemble(frag); complete {
+			got = d
+		}
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Expected session on another channel to reassemble data")
+	}
+
+	attacker.releaseReassembly()
+	if used := atomic.LoadInt64(&gReassemblyMemUsed); used != memUsed {
+		t.Errorf("Reassembly memory leaked: before=%d, after=%d", memUsed, used)
+	}
+	if len(gReassemblyChannelMemUsed) != 0 {
+		t.Errorf("Channel reassembly memory leaked: %v", gReassemblyChannelMemUsed)
+	}
+}
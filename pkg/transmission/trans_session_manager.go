@@ -1,31 +1,98 @@
 package transmission
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/junbin-yang/dsoftbus-go/pkg/authentication"
 	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
 )
 
+// SessionType 会话数据类型（对应C的SessionType）
+type SessionType int32
+
+const (
+	TypeMessage SessionType = 1 // 消息：小数据量、时延敏感（TYPE_MESSAGE）
+	TypeBytes   SessionType = 2 // 字节：可靠有序、数据量较大（TYPE_BYTES）
+	TypeFile    SessionType = 3 // 文件（TYPE_FILE）
+	TypeStream  SessionType = 4 // 流（TYPE_STREAM）
+)
+
+// 各会话类型单次发送的数据长度上限
+const (
+	// MessageMaxLen TypeMessage单条消息上限
+	MessageMaxLen = 4 * 1024
+	// BytesMaxLen TypeBytes单次发送上限（超过单个数据包时自动分片）
+	BytesMaxLen = 4 * 1024 * 1024
+	// FileFrameMaxLen TypeFile单帧上限
+	// 不超过单个会话数据包的承载能力（sessionFrameMaxLen），文件数据帧总是整帧发送，不经过分片
+	FileFrameMaxLen = 32 * 1024
+	// StreamMaxLen TypeStream单帧上限
+	StreamMaxLen = 60 * 1024
+)
+
+// isValid 判断会话数据类型是否有效
+func (t SessionType) isValid() bool {
+	return t >= TypeMessage && t <= TypeStream
+}
+
+// maxSendLen 会话数据类型单次发送的数据长度上限
+func (t SessionType) maxSendLen() int {
+	switch t {
+	case TypeMessage:
+		return MessageMaxLen
+	case TypeBytes:
+		return BytesMaxLen
+	case TypeFile:
+		return FileFrameMaxLen
+	default:
+		return StreamMaxLen
+	}
+}
+
+// SessionAttribute 会话属性（对应C的SessionAttribute）
+type SessionAttribute struct {
+	DataType SessionType // 会话数据类型
+}
+
 // Session 会话
 type Session struct {
-	SessionID   int32  // 会话ID
-	SessionName string // 会话名称
-	PeerName    string // 对端会话名称
-	AuthID      int64  // 认证ID
-	ChannelID   int    // 通道ID
-	IsServer    bool   // 是否服务端
+	SessionID     int32       // 会话ID
+	SessionName   string      // 会话名称
+	PeerName      string      // 对端会话名称
+	AuthID        int64       // 认证ID
+	ChannelID     int         // 通道ID（认证连接的fd）
+	IsServer      bool        // 是否服务端
+	DataType      SessionType // 会话数据类型
+	PeerSessionID int32       // 对端会话ID（握手完成后确定）
+	IsOpened      bool        // 打开会话握手是否完成
+
+	reqID     string           // 打开会话请求ID（仅发起方）
+	openTimer *time.Timer      // 打开会话超时定时器（仅发起方）
+	sendSeq   int32            // 发送序列号（原子递增）
+	recvSeq   int32            // 最后接收的序列号
+	receiver  *sessionReceiver // 回调队列，保证同一会话的回调按序执行
+
+	fragMsgID  uint32                       // 分片消息ID（原子递增）
+	reassembly map[uint32]*reassemblyBuffer // 分片重组缓冲区（msgID -> buffer）
+
+	stream *streamChannel // 流数据通道（仅TypeStream）
 }
 
 // SessionServer 会话服务器
 type SessionServer struct {
-	SessionName string                 // 会话名称
-	OnBind      func(sessionID int32)  // 绑定回调
-	OnShutdown  func(sessionID int32)  // 关闭回调
-	OnBytes     func(sessionID int32, data []byte) // 接收字节回调
-	OnMessage   func(sessionID int32, data []byte) // 接收消息回调
+	PkgName          string                                                                 // 包名
+	SessionName      string                                                                 // 会话名称
+	OnBind           func(sessionID int32)                                                  // 绑定回调（双方握手完成后调用）
+	OnOpenFailed     func(sessionID int32, reason error)                                    // 打开失败回调（仅发起方）
+	OnShutdown       func(sessionID int32)                                                  // 关闭回调
+	OnBytes          func(sessionID int32, data []byte)                                     // 接收字节回调
+	OnMessage        func(sessionID int32, data []byte)                                     // 接收消息回调
+	OnStreamReceived func(sessionID int32, data []byte, ext []byte, param *StreamFrameInfo) // 接收流数据回调
 }
 
 // SessionManager 会话管理器
@@ -63,6 +130,7 @@ func CreateSessionServer(pkgName string, sessionName string, listener *SessionSe
 		return fmt.Errorf("session server already exists: %s", sessionName)
 	}
 
+	listener.PkgName = pkgName
 	listener.SessionName = sessionName
 	mgr.servers[sessionName] = listener
 
@@ -82,73 +150,129 @@ func RemoveSessionServer(pkgName string, sessionName string) error {
 }
 
 // OpenSession 打开会话
-func OpenSession(sessionName string, peerName string, peerNetworkID string, authID int64) (int32, error) {
+// 向对端发送打开会话请求后立即返回会话ID，握手完成后通过本端会话服务器的OnBind通知，
+// 对端拒绝、超时或连接断开时通过OnOpenFailed通知
+// sessionName: 本端会话名称（需已通过CreateSessionServer创建）
+// peerName: 对端会话名称
+// authID: 与对端设备的认证ID（需已认证通过）
+// attr: 会话属性，对端按同一数据类型创建会话
+func OpenSession(sessionName string, peerName string, peerNetworkID string, authID int64, attr *SessionAttribute) (int32, error) {
+	if attr == nil || !attr.DataType.isValid() {
+		return -1, fmt.Errorf("invalid session attribute")
+	}
+
 	mgr := getSessionManager()
-	mgr.mu.Lock()
-	defer mgr.mu.Unlock()
+	mgr.mu.RLock()
+	server, exists := mgr.servers[sessionName]
+	mgr.mu.RUnlock()
+	if !exists {
+		return -1, fmt.Errorf("session server not found: %s", sessionName)
+	}
+
+	authMgr, err := authentication.GetAuthManagerByAuthId(authID)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open session: %w", err)
+	}
+
+	reqID := make([]byte, 8)
+	if _, err := rand.Read(reqID); err != nil {
+		return -1, fmt.Errorf("failed to generate request id: %w", err)
+	}
 
 	// 生成会话ID
 	sessionID := atomic.AddInt32(&mgr.sessionCounter, 1)
 
-	// 创建会话
+	// 创建会话，握手完成前不可收发数据
 	session := &Session{
 		SessionID:   sessionID,
 		SessionName: sessionName,
 		PeerName:    peerName,
 		AuthID:      authID,
-		ChannelID:   int(authID), // 使用authID作为channelID
+		ChannelID:   int(authentication.GetFd(authMgr.ConnId)),
 		IsServer:    false,
+		DataType:    attr.DataType,
+		reqID:       hex.EncodeToString(reqID),
+		receiver:    &sessionReceiver{},
 	}
 
+	// 流会话预先监听UDP端口，端口和密钥索引随请求发送
+	if attr.DataType == TypeStream {
+		key, err := authentication.AuthManagerGetLatestSessionKey(authID)
+		if err != nil {
+			return -1, fmt.Errorf("failed to open session: %w", err)
+		}
+		if session.stream, err = newStreamChannel(authID, key.Index, session.reqID, false); err != nil {
+			return -1, fmt.Errorf("failed to open session: %w", err)
+		}
+	}
+
+	timeout := OpenSessionTimeout
+	mgr.mu.Lock()
 	mgr.sessions[sessionID] = session
+	session.openTimer = time.AfterFunc(timeout, func() {
+		onOpenSessionTimeout(sessionID, timeout)
+	})
+	mgr.mu.Unlock()
+
+	if err := sendOpenSessionRequest(server, session); err != nil {
+		mgr.mu.Lock()
+		session.openTimer.Stop()
+		session.releaseStream()
+		delete(mgr.sessions, sessionID)
+		mgr.mu.Unlock()
+		return -1, fmt.Errorf("failed to open session: %w", err)
+	}
 
-	log.Infof("[SESSION] Opened session: sessionID=%d, name=%s, peer=%s, channelID=%d", sessionID, sessionName, peerName, session.ChannelID)
+	log.Infof("[SESSION] Opening session: sessionID=%d, name=%s, peer=%s, channelID=%d", sessionID, sessionName, peerName, session.ChannelID)
 	return sessionID, nil
 }
 
 // CloseSession 关闭会话
+// 已打开的会话同时通知对端关闭，对端调用OnShutdown
 func CloseSession(sessionID int32) error {
 	mgr := getSessionManager()
 	mgr.mu.Lock()
-	defer mgr.mu.Unlock()
-
 	session, exists := mgr.sessions[sessionID]
 	if !exists {
+		mgr.mu.Unlock()
 		return fmt.Errorf("session not found: %d", sessionID)
 	}
-
-	delete(mgr.sessions, sessionID)
+	opened := session.IsOpened
+	mgr.removeSessionLocked(session)
+	mgr.mu.Unlock()
 	log.Infof("[SESSION] Closed session: sessionID=%d", sessionID)
 
-	// 触发关闭回调
-	if server, ok := mgr.servers[session.SessionName]; ok && server.OnShutdown != nil {
-		go server.OnShutdown(sessionID)
+	// 对端不可达（如连接已断开）时对端通过连接断开自行清理，不影响本端关闭
+	if opened {
+		sendCloseSessionRequest(session.AuthID, sessionID, session.PeerSessionID)
 	}
-
 	return nil
 }
 
-// SendBytes 发送字节数据
-func SendBytes(sessionID int32, data []byte) error {
-	mgr := getSessionManager()
-	mgr.mu.RLock()
-	session, exists := mgr.sessions[sessionID]
-	mgr.mu.RUnlock()
+// removeSessionLocked 移除会话并释放资源，已打开的会话调用OnShutdown（调用方持有锁）
+func (mgr *SessionManager) removeSessionLocked(session *Session) {
+	sessionID := session.SessionID
+	delete(mgr.sessions, sessionID)
+	if session.openTimer != nil {
+		session.openTimer.Stop()
+	}
+	session.releaseReassembly()
+	session.releaseStream()
 
-	if !exists {
-		return fmt.Errorf("session not found: %d", sessionID)
+	// 中止会话上的文件传输
+	if session.DataType == TypeFile {
+		session.receiver.post(func() { closeFileTrans(sessionID) })
 	}
 
-	// 使用认证通道发送数据
-	channelData := &authentication.AuthChannelData{
-		Module: authentication.ModuleAuthMsg,
-		Flag:   0,
-		Seq:    int64(sessionID),
-		Len:    uint32(len(data)),
-		Data:   data,
+	// 触发关闭回调
+	if server, ok := mgr.servers[session.SessionName]; ok && session.IsOpened && server.OnShutdown != nil {
+		session.receiver.post(func() { server.OnShutdown(sessionID) })
 	}
+}
 
-	if err := authentication.AuthPostChannelData(session.ChannelID, channelData); err != nil {
+// SendBytes 发送字节数据，对端通过OnBytes接收（仅TypeBytes会话）
+func SendBytes(sessionID int32, data []byte) error {
+	if err := sendSessionData(sessionID, TypeBytes, data); err != nil {
 		return fmt.Errorf("failed to send bytes: %w", err)
 	}
 
@@ -156,10 +280,14 @@ func SendBytes(sessionID int32, data []byte) error {
 	return nil
 }
 
-// SendMessage 发送消息数据
+// SendMessage 发送消息数据，对端通过OnMessage接收（仅TypeMessage会话）
 func SendMessage(sessionID int32, data []byte) error {
-	// SendMessage 与 SendBytes 实现相同
-	return SendBytes(sessionID, data)
+	if err := sendSessionData(sessionID, TypeMessage, data); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	log.Infof("[SESSION] Sent message: sessionID=%d, len=%d", sessionID, len(data))
+	return nil
 }
 
 // GetSession 获取会话
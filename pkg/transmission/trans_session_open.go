@@ -0,0 +1,387 @@
+package transmission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/authentication"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 打开会话握手
+// ============================================================================
+//
+// 发起方通过已认证的连接以MODULE_AUTH_CHANNEL发送打开会话请求（flag=0，加密）：
+//   - SRC_BUS_NAME/DST_BUS_NAME 为本端/对端会话名称，SESSION_ID 为发起方会话ID，BUSINESS_TYPE 为会话数据类型
+// 对端查找DST_BUS_NAME对应的会话服务器，创建服务端会话后回复（flag=1）：
+//   - 成功: SESSION_ID 为服务端会话ID，PEER_SESSION_ID 回填发起方会话ID
+//   - 失败: ERR_CODE/ERR_DESC 说明拒绝原因，不创建会话
+// 服务端发送回复后调用OnBind，发起方收到成功回复后调用OnBind，否则调用OnOpenFailed
+// TypeStream会话同时交换UDP数据通道端口，见trans_stream.go
+//
+// 任一方关闭已打开的会话时发送关闭会话请求（CODE=2，flag=0，不需要回复）：
+//   - SESSION_ID 为本端会话ID，PEER_SESSION_ID 为对端会话ID
+// 对端移除对应会话并调用OnShutdown。发起方在握手完成前关闭会话，之后收到的成功回复同样以关闭会话请求通知对端
+
+// OpenSessionTimeout 打开会话等待对端回复的超时时间
+var OpenSessionTimeout = 10 * time.Second
+
+const (
+	// authChannelCodeOpenSession 打开会话请求的CODE
+	authChannelCodeOpenSession = 1
+	// authChannelCodeCloseSession 关闭会话请求的CODE
+	authChannelCodeCloseSession = 2
+
+	// 打开会话回复的ERR_CODE
+	openSessionErrServerNotFound int32 = -1 // 对端会话服务器不存在
+	openSessionErrInvalidType    int32 = -2 // 不支持的会话数据类型
+	openSessionErrStreamSetup    int32 = -3 // 流数据通道建立失败
+)
+
+var (
+	// ErrSessionServerNotFound 对端未创建目标会话服务器
+	ErrSessionServerNotFound = errors.New("peer session server not found")
+	// ErrOpenSessionRejected 对端拒绝打开会话
+	ErrOpenSessionRejected = errors.New("open session rejected by peer")
+	// ErrOpenSessionTimeout 等待对端回复超时
+	ErrOpenSessionTimeout = errors.New("open session timeout")
+	// ErrSessionChannelClosed 会话所在的认证连接已断开
+	ErrSessionChannelClosed = errors.New("session channel closed")
+)
+
+// sendOpenSessionRequest 发送打开会话请求
+func sendOpenSessionRequest(server *SessionServer, session *Session) error {
+	localDevInfo, err := authentication.GetLocalDeviceInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get local device info: %w", err)
+	}
+
+	req := AuthChannelRequestMsg{
+		Code:       authChannelCodeOpenSession,
+		DeviceID:   localDevInfo.UDID,
+		PkgName:    server.PkgName,
+		SrcBusName: session.SessionName,
+		DstBusName: session.PeerName,
+		ReqID:      session.reqID,
+		MTUSize:    authentication.AuthSocketMaxDataLen,
+		SessionID:  session.SessionID,
+		DataType:   session.DataType,
+	}
+	if session.stream != nil {
+		req.StreamPort = session.stream.localPort()
+		req.StreamKeyIndex = session.stream.keyIndex
+	}
+	reqJSON, _ := json.Marshal(req)
+
+	log.Infof("[TRANS_AUTH] Sending open session request: sessionID=%d, authID=%d, %s",
+		session.SessionID, session.AuthID, string(reqJSON))
+	return authentication.AuthDevicePostTransData(session.AuthID, authentication.ModuleAuthChannel, 0, reqJSON)
+}
+
+// handleOpenSessionRequest 处理对端的打开会话请求，创建服务端会话并回复
+func handleOpenSessionRequest(channelId int, req *AuthChannelRequestMsg) {
+	authId, err := getChannelAuthId(channelId)
+	if err != nil {
+		log.Errorf("[TRANS_AUTH] Drop open session request: channelId=%d, err=%v", channelId, err)
+		return
+	}
+
+	reply := AuthChannelReplyMsg{
+		Code:          req.Code,
+		PkgName:       req.PkgName,
+		SrcBusName:    req.DstBusName, // 交换
+		DstBusName:    req.SrcBusName, // 交换
+		ReqID:         req.ReqID,
+		MTUSize:       authentication.AuthSocketMaxDataLen,
+		PeerSessionID: req.SessionID,
+	}
+	if localDevInfo, err := authentication.GetLocalDeviceInfo(); err == nil {
+		reply.DeviceID = localDevInfo.UDID
+	}
+
+	// 未携带会话数据类型时按TypeBytes处理
+	dataType := req.DataType
+	if dataType == 0 {
+		dataType = TypeBytes
+	}
+	if !dataType.isValid() {
+		log.Warnf("[TRANS_AUTH] Reject open session: invalid session type: %d", req.DataType)
+		reply.ErrCode = openSessionErrInvalidType
+		reply.ErrDesc = fmt.Sprintf("invalid session type: %d", req.DataType)
+		sendOpenSessionReply(authId, &reply)
+		return
+	}
+
+	// 流会话监听本端UDP端口，派生与发起方相同的密钥
+	var stream *streamChannel
+	var peerIp string
+	if dataType == TypeStream {
+		if peerIp, err = getChannelPeerIp(channelId); err == nil {
+			if req.StreamPort <= 0 {
+				err = fmt.Errorf("missing stream port")
+			} else {
+				stream, err = newStreamChannel(authId, req.StreamKeyIndex, req.ReqID, true)
+			}
+		}
+		if err != nil {
+			log.Warnf("[TRANS_AUTH] Reject open session: failed to set up stream channel: %v", err)
+			reply.ErrCode = openSessionErrStreamSetup
+			reply.ErrDesc = fmt.Sprintf("failed to set up stream channel: %v", err)
+			sendOpenSessionReply(authId, &reply)
+			return
+		}
+		reply.StreamPort = stream.localPort()
+	}
+
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	server, exists := mgr.servers[req.DstBusName]
+	if !exists {
+		mgr.mu.Unlock()
+		if stream != nil {
+			stream.close()
+		}
+		log.Warnf("[TRANS_AUTH] Reject open session: session server not found: %s", req.DstBusName)
+		reply.ErrCode = openSessionErrServerNotFound
+		reply.ErrDesc = fmt.Sprintf("session server not found: %s", req.DstBusName)
+		sendOpenSessionReply(authId, &reply)
+		return
+	}
+
+	session := &Session{
+		SessionID:     atomic.AddInt32(&mgr.sessionCounter, 1),
+		SessionName:   req.DstBusName,
+		PeerName:      req.SrcBusName,
+		AuthID:        authId,
+		ChannelID:     channelId,
+		IsServer:      true,
+		DataType:      dataType,
+		PeerSessionID: req.SessionID,
+		IsOpened:      true,
+		receiver:      &sessionReceiver{},
+		stream:        stream,
+	}
+	if stream != nil {
+		if err := stream.connect(session.SessionID, peerIp, req.StreamPort); err != nil {
+			mgr.mu.Unlock()
+			stream.close()
+			log.Warnf("[TRANS_AUTH] Reject open session: %v", err)
+			reply.ErrCode = openSessionErrStreamSetup
+			reply.ErrDesc = err.Error()
+			sendOpenSessionReply(authId, &reply)
+			return
+		}
+	}
+	mgr.sessions[session.SessionID] = session
+	mgr.mu.Unlock()
+
+	reply.SessionID = session.SessionID
+	if err := sendOpenSessionReply(authId, &reply); err != nil {
+		mgr.mu.Lock()
+		session.releaseStream()
+		delete(mgr.sessions, session.SessionID)
+		mgr.mu.Unlock()
+		return
+	}
+
+	log.Infof("[SESSION] Session opened by peer: sessionID=%d, name=%s, peer=%s, type=%d, peerSessionID=%d, channelID=%d",
+		session.SessionID, session.SessionName, session.PeerName, session.DataType, session.PeerSessionID, channelId)
+	if server.OnBind != nil {
+		session.receiver.post(func() { server.OnBind(session.SessionID) })
+	}
+}
+
+// sendOpenSessionReply 发送打开会话回复
+func sendOpenSessionReply(authId int64, reply *AuthChannelReplyMsg) error {
+	replyJSON, _ := json.Marshal(reply)
+	if err := authentication.AuthDevicePostTransData(authId, authentication.ModuleAuthChannel, 1, replyJSON); err != nil {
+		log.Errorf("[TRANS_AUTH] Failed to send open session reply: %v", err)
+		return err
+	}
+	log.Infof("[TRANS_AUTH] Sent open session reply: %s", string(replyJSON))
+	return nil
+}
+
+// handleOpenSessionReply 处理对端的打开会话回复
+func handleOpenSessionReply(channelId int, reply *AuthChannelReplyMsg) {
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	session, exists := mgr.sessions[reply.PeerSessionID]
+	if !exists || session.IsServer || session.IsOpened ||
+		session.reqID != reply.ReqID || session.ChannelID != channelId {
+		mgr.mu.Unlock()
+		log.Warnf("[TRANS_AUTH] Drop unexpected open session reply: channelId=%d, sessionID=%d, reqID=%s",
+			channelId, reply.PeerSessionID, reply.ReqID)
+		// 本端已在握手完成前关闭会话，通知对端释放已创建的会话
+		if !exists && reply.ErrCode == 0 && reply.SessionID != 0 {
+			if authId, err := getChannelAuthId(channelId); err == nil {
+				sendCloseSessionRequest(authId, reply.PeerSessionID, reply.SessionID)
+			}
+		}
+		return
+	}
+
+	session.openTimer.Stop()
+	server := mgr.servers[session.SessionName]
+	var reason error
+	if reply.ErrCode != 0 {
+		reason = openSessionError(reply.ErrCode, reply.ErrDesc)
+	} else if session.stream != nil {
+		reason = connectSessionStream(session, channelId, reply.StreamPort)
+	}
+	if reason != nil {
+		session.releaseStream()
+		delete(mgr.sessions, session.SessionID)
+		mgr.mu.Unlock()
+		notifyOpenFailed(server, session.SessionID, reason)
+		return
+	}
+
+	session.PeerSessionID = reply.SessionID
+	session.IsOpened = true
+	if server != nil && server.OnBind != nil {
+		session.receiver.post(func() { server.OnBind(session.SessionID) })
+	}
+	mgr.mu.Unlock()
+
+	log.Infof("[SESSION] Opened session: sessionID=%d, name=%s, peer=%s, peerSessionID=%d, channelID=%d",
+		session.SessionID, session.SessionName, session.PeerName, session.PeerSessionID, channelId)
+}
+
+// sendCloseSessionRequest 通知对端关闭会话
+func sendCloseSessionRequest(authId int64, sessionID int32, peerSessionID int32) error {
+	req := AuthChannelRequestMsg{
+		Code:          authChannelCodeCloseSession,
+		SessionID:     sessionID,
+		PeerSessionID: peerSessionID,
+	}
+	if localDevInfo, err := authentication.GetLocalDeviceInfo(); err == nil {
+		req.DeviceID = localDevInfo.UDID
+	}
+	reqJSON, _ := json.Marshal(req)
+
+	if err := authentication.AuthDevicePostTransData(authId, authentication.ModuleAuthChannel, 0, reqJSON); err != nil {
+		log.Warnf("[TRANS_AUTH] Failed to send close session request: sessionID=%d, err=%v", sessionID, err)
+		return err
+	}
+	log.Infof("[TRANS_AUTH] Sent close session request: sessionID=%d, peerSessionID=%d", sessionID, peerSessionID)
+	return nil
+}
+
+// handleCloseSessionRequest 处理对端的关闭会话请求，移除会话并调用OnShutdown
+func handleCloseSessionRequest(channelId int, req *AuthChannelRequestMsg) {
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	session, exists := mgr.sessions[req.PeerSessionID]
+	if !exists || !session.IsOpened || session.ChannelID != channelId || session.PeerSessionID != req.SessionID {
+		log.Warnf("[TRANS_AUTH] Drop unexpected close session request: channelId=%d, sessionID=%d, peerSessionID=%d",
+			channelId, req.PeerSessionID, req.SessionID)
+		return
+	}
+
+	log.Infof("[SESSION] Session closed by peer: sessionID=%d, peerSessionID=%d, channelID=%d",
+		session.SessionID, session.PeerSessionID, channelId)
+	mgr.removeSessionLocked(session)
+}
+
+// onOpenSessionTimeout 打开会话超时，移除未完成握手的会话
+func onOpenSessionTimeout(sessionID int32, timeout time.Duration) {
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	session, exists := mgr.sessions[sessionID]
+	if !exists || session.IsOpened {
+		mgr.mu.Unlock()
+		return
+	}
+	delete(mgr.sessions, sessionID)
+	session.releaseStream()
+	server := mgr.servers[session.SessionName]
+	mgr.mu.Unlock()
+
+	notifyOpenFailed(server, sessionID, fmt.Errorf("%w: no reply in %v", ErrOpenSessionTimeout, timeout))
+}
+
+// closeChannelSessions 认证连接断开，移除该连接上的所有会话
+// 已打开的会话调用OnShutdown，握手中的会话调用OnOpenFailed
+func closeChannelSessions(channelId int) {
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	var closed []*Session
+	var servers []*SessionServer
+	for id, session := range mgr.sessions {
+		if session.ChannelID != channelId {
+			continue
+		}
+		delete(mgr.sessions, id)
+		if session.openTimer != nil {
+			session.openTimer.Stop()
+		}
+		session.releaseReassembly()
+		session.releaseStream()
+		closed = append(closed, session)
+		servers = append(servers, mgr.servers[session.SessionName])
+	}
+	mgr.mu.Unlock()
+
+	for i, session := range closed {
+		log.Infof("[SESSION] Session closed by channel disconnect: sessionID=%d, channelID=%d", session.SessionID, channelId)
+		if !session.IsOpened {
+			notifyOpenFailed(servers[i], session.SessionID, ErrSessionChannelClosed)
+			continue
+		}
+		sessionID := session.SessionID
+		if session.DataType == TypeFile {
+			session.receiver.post(func() { closeFileTrans(sessionID) })
+		}
+		if server := servers[i]; server != nil && server.OnShutdown != nil {
+			session.receiver.post(func() { server.OnShutdown(sessionID) })
+		}
+	}
+}
+
+// connectSessionStream 发起方收到回复后连接服务端的UDP数据通道
+func connectSessionStream(session *Session, channelId int, peerPort int) error {
+	peerIp, err := getChannelPeerIp(channelId)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionChannelClosed, err)
+	}
+	if err := session.stream.connect(session.SessionID, peerIp, peerPort); err != nil {
+		return fmt.Errorf("%w: %v", ErrOpenSessionRejected, err)
+	}
+	return nil
+}
+
+// notifyOpenFailed 通知发起方打开会话失败
+func notifyOpenFailed(server *SessionServer, sessionID int32, reason error) {
+	log.Warnf("[SESSION] Open session failed: sessionID=%d, reason=%v", sessionID, reason)
+	if server != nil && server.OnOpenFailed != nil {
+		server.OnOpenFailed(sessionID, reason)
+	}
+}
+
+// openSessionError 将回复中的ERR_CODE转换为错误
+func openSessionError(code int32, desc string) error {
+	if code == openSessionErrServerNotFound {
+		return fmt.Errorf("%w: %s", ErrSessionServerNotFound, desc)
+	}
+	return fmt.Errorf("%w: code=%d, %s", ErrOpenSessionRejected, code, desc)
+}
+
+// getChannelAuthId 根据通道ID（认证连接的fd）获取认证ID
+func getChannelAuthId(channelId int) (int64, error) {
+	conn, err := authentication.GetAuthConnectionByFd(channelId)
+	if err != nil {
+		return 0, err
+	}
+	manager, err := authentication.GetAuthManagerByConnId(conn.ConnId)
+	if err != nil {
+		return 0, err
+	}
+	return manager.AuthId, nil
+}
@@ -0,0 +1,93 @@
+package transmission
+
+import (
+	"testing"
+	"time"
+)
+
+// addTestSession 注册会话服务器和一个已打开的会话，测试结束时移除
+func addTestSession(t *testing.T, name string, dataType SessionType, server *SessionServer) *Session {
+	t.Helper()
+
+	server.PkgName = "test_pkg"
+	server.SessionName = name
+	if err := CreateSessionServer(server.PkgName, name, server); err != nil {
+		t.Fatalf("CreateSessionServer failed: %v", err)
+	}
+
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	session := &Session{
+		SessionID:     mgr.sessionCounter + 1,
+		SessionName:   name,
+		PeerName:      name,
+		ChannelID:     9001,
+		IsServer:      true,
+		DataType:      dataType,
+		PeerSessionID: 77,
+		IsOpened:      true,
+		receiver:      &sessionReceiver{},
+	}
+	mgr.sessionCounter++
+	mgr.sessions[session.SessionID] = session
+	mgr.mu.Unlock()
+
+	t.Cleanup(func() {
+		mgr.mu.Lock()
+		delete(mgr.sessions, session.SessionID)
+		mgr.mu.Unlock()
+		RemoveSessionServer(server.PkgName, name)
+	})
+	return session
+}
+
+// 测试对端关闭会话：只处理与会话ID、对端会话ID和连接都匹配的请求，移除会话并调用OnShutdown
+func TestHandleCloseSessionRequest(t *testing.T) {
+	shutdown := make(chan int32, 2)
+	session := addTestSession(t, "close_test", TypeBytes, &SessionServer{
+		OnShutdown: func(sessionID int32) { shutdown <- sessionID },
+	})
+
+	mismatched := []AuthChannelRequestMsg{
+		{Code: authChannelCodeCloseSession, SessionID: session.PeerSessionID + 1, PeerSessionID: session.SessionID},
+		{Code: authChannelCodeCloseSession, SessionID: session.PeerSessionID, PeerSessionID: session.SessionID + 1},
+	}
+	for i := range mismatched {
+		handleCloseSessionRequest(session.ChannelID, &mismatched[i])
+	}
+	req := &AuthChannelRequestMsg{Code: authChannelCodeCloseSession, SessionID: session.PeerSessionID, PeerSessionID: session.SessionID}
+	handleCloseSessionRequest(session.ChannelID+1, req)
+
+	mgr := getSessionManager()
+	mgr.mu.RLock()
+	_, exists := mgr.sessions[session.SessionID]
+	mgr.mu.RUnlock()
+	if !exists {
+		t.Fatal("Expected mismatched close requests to be ignored")
+	}
+
+	handleCloseSessionRequest(session.ChannelID, req)
+	select {
+	case id := <-shutdown:
+		if id != session.SessionID {
+			t.Errorf("Unexpected OnShutdown sessionID: %d", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnShutdown after close request")
+	}
+
+	mgr.mu.RLock()
+	_, exists = mgr.sessions[session.SessionID]
+	mgr.mu.RUnlock()
+	if exists {
+		t.Error("Expected session removed")
+	}
+
+	// 重复的关闭请求不再回调
+	handleCloseSessionRequest(session.ChannelID, req)
+	select {
+	case <-shutdown:
+		t.Error("Unexpected OnShutdown for closed session")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,393 @@
+package transmission
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/authentication"
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/crypto"
+	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
+)
+
+// ============================================================================
+// 流会话UDP数据通道
+// 对应C代码: core/transmission/trans_channel/udp_negotiation/src/trans_udp_negotiation.c
+// ============================================================================
+//
+// TypeStream会话在打开会话握手时协商独立的UDP数据通道:
+//   - 双方各自监听一个UDP端口，发起方在请求中携带本端端口(STREAM_PORT)和会话密钥索引(STREAM_KEY_INDEX)，
+//     服务端在回复中携带本端端口(STREAM_PORT)，对端IP取自认证连接
+//   - 收发密钥由该索引对应的会话密钥经HKDF-SHA256派生，salt为REQ_ID，两个方向使用不同的密钥
+// 每个流数据帧为一个UDP报文，不重传、不保证有序:
+//   [流帧头(24字节)] + AES-GCM([扩展数据] + [数据])
+// 流帧头字段（小端序，作为AAD参与认证）:
+//   - Magic:     魔数
+//   - SessionID: 接收方会话ID
+//   - Seq:       发送方帧序列号（从1开始递增）
+//   - FrameType: 帧类型
+//   - Reserved:  保留
+//   - ExtLen:    扩展数据长度
+//   - TimeStamp: 发送方时间戳（毫秒）
+// 接收方只接收来自对端地址（IP和端口）的报文，以64帧滑动窗口丢弃重复和过旧的帧，统计丢帧、乱序和到达抖动。
+// TimeStamp由应用给出，不保证是时钟时间，因此到达抖动按本端接收时间的到达间隔变化计算（平滑方式同RFC 3550）
+
+const (
+	// StreamFrameHeadLen 流帧头长度
+	StreamFrameHeadLen = 24
+	// StreamExtMaxLen 流帧扩展数据上限
+	StreamExtMaxLen = 1024
+
+	streamKeyInfoC2S   = "softbus_stream_c2s" // 发起方→服务端密钥
+	streamKeyInfoS2C   = "softbus_stream_s2c" // 服务端→发起方密钥
+	streamReplayWindow = 64                   // 重放窗口（帧数）
+	streamRecvBufLen   = 64 * 1024            // UDP接收缓冲区
+)
+
+// StreamFrameType 流帧类型（对应C的FrameType）
+type StreamFrameType uint8
+
+const (
+	FrameTypeNone StreamFrameType = 0 // 未指定
+	FrameTypeI    StreamFrameType = 1 // I帧（关键帧）
+	FrameTypeP    StreamFrameType = 2 // P帧
+)
+
+// StreamFrameInfo 流帧信息（对应C的StreamFrameInfo）
+type StreamFrameInfo struct {
+	FrameType StreamFrameType // 帧类型
+	TimeStamp int64           // 时间戳（毫秒），发送时为0则取当前时间
+	SeqNum    uint32          // 帧序列号（仅接收时有效）
+}
+
+// StreamStats 流数据通道统计
+type StreamStats struct {
+	SentFrames       uint64        // 发送帧数
+	SentBytes        uint64        // 发送字节数（数据+扩展数据）
+	RecvFrames       uint64        // 接收帧数
+	RecvBytes        uint64        // 接收字节数（数据+扩展数据）
+	LostFrames       uint64        // 丢失帧数（按序列号缺口估计，迟到帧到达后扣减）
+	OutOfOrderFrames uint64        // 乱序到达帧数
+	DroppedFrames    uint64        // 丢弃帧数（来源地址不符、重复、过旧或校验失败）
+	Jitter           time.Duration // 到达抖动（按本端接收时间计算）
+}
+
+// streamChannel 流会话的UDP数据通道
+type streamChannel struct {
+	conn     *net.UDPConn
+	keyIndex int32  // 派生密钥使用的会话密钥索引
+	sendKey  []byte // 本端发送密钥
+	recvKey  []byte // 本端接收密钥
+
+	sessionID int32        // 本端会话ID（connect后有效）
+	peerAddr  *net.UDPAddr // 对端地址（connect后有效）
+	sendSeq   uint32       // 发送序列号（原子递增）
+	closed    int32        // 是否已关闭（原子）
+
+	mu          sync.Mutex
+	maxSeq      uint32    // 已接收的最大序列号
+	window      uint64    // 重放窗口，第i位表示maxSeq-i已接收
+	lastArrival time.Time // 上一帧的本端接收时间
+	interval    float64   // 上一帧的到达间隔（毫秒）
+	hasInterval bool
+	jitter      float64 // 到达抖动（毫秒）
+	stats       StreamStats
+}
+
+// newStreamChannel 监听UDP端口并派生收发密钥
+// isServer: 是否为服务端（决定收发方向使用的密钥）
+func newStreamChannel(authId int64, keyIndex int32, reqID string, isServer bool) (*streamChannel, error) {
+	key, err := authentication.AuthManagerGetSessionKey(authId, keyIndex)
+	if err != nil {
+		return nil, err
+	}
+	c2s, err := crypto.DeriveKeyHKDF(key.Key, []byte(reqID), []byte(streamKeyInfoC2S), len(key.Key))
+	if err != nil {
+		return nil, err
+	}
+	s2c, err := crypto.DeriveKeyHKDF(key.Key, []byte(reqID), []byte(streamKeyInfoS2C), len(key.Key))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen udp: %w", err)
+	}
+
+	ch := &streamChannel{conn: conn, keyIndex: keyIndex, sendKey: c2s, recvKey: s2c}
+	if isServer {
+		ch.sendKey, ch.recvKey = s2c, c2s
+	}
+	return ch, nil
+}
+
+// localPort 本端UDP端口
+func (ch *streamChannel) localPort() int {
+	return ch.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// connect 设置对端地址并开始接收
+func (ch *streamChannel) connect(sessionID int32, peerIp string, peerPort int) error {
+	ip := net.ParseIP(peerIp)
+	if ip == nil || peerPort <= 0 || peerPort > math.MaxUint16 {
+		return fmt.Errorf("invalid stream peer address: %s:%d", peerIp, peerPort)
+	}
+
+	ch.sessionID = sessionID
+	ch.peerAddr = &net.UDPAddr{IP: ip, Port: peerPort}
+	go ch.recvLoop()
+
+	log.Infof("[STREAM] Stream channel connected: sessionID=%d, local=%d, peer=%s", sessionID, ch.localPort(), ch.peerAddr)
+	return nil
+}
+
+// close 关闭UDP数据通道
+func (ch *streamChannel) close() {
+	if atomic.CompareAndSwapInt32(&ch.closed, 0, 1) {
+		ch.conn.Close()
+	}
+}
+
+// send 加密并发送一个流帧
+func (ch *streamChannel) send(peerSessionID int32, data []byte, ext []byte, param *StreamFrameInfo) error {
+	head := make([]byte, StreamFrameHeadLen)
+	binary.LittleEndian.PutUint32(head[0:], sessionPktMagic)
+	binary.LittleEndian.PutUint32(head[4:], uint32(peerSessionID))
+	binary.LittleEndian.PutUint32(head[8:], atomic.AddUint32(&ch.sendSeq, 1))
+	head[12] = byte(param.FrameType)
+	binary.LittleEndian.PutUint16(head[14:], uint16(len(ext)))
+	timestamp := param.TimeStamp
+	if timestamp == 0 {
+		timestamp = time.Now().UnixMilli()
+	}
+	binary.LittleEndian.PutUint64(head[16:], uint64(timestamp))
+
+	plain := make([]byte, len(ext)+len(data))
+	copy(plain, ext)
+	copy(plain[len(ext):], data)
+	encrypted, err := crypto.EncryptAESGCMWithAAD(ch.sendKey, plain, head)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt stream frame: %w", err)
+	}
+
+	if _, err := ch.conn.WriteToUDP(append(head, encrypted...), ch.peerAddr); err != nil {
+		return fmt.Errorf("failed to send stream frame: %w", err)
+	}
+
+	ch.mu.Lock()
+	ch.stats.SentFrames++
+	ch.stats.SentBytes += uint64(len(plain))
+	ch.mu.Unlock()
+	return nil
+}
+
+// recvLoop 接收流帧，通道关闭时退出
+func (ch *streamChannel) recvLoop() {
+	buf := make([]byte, streamRecvBufLen)
+	for {
+		n, addr, err := ch.conn.ReadFromUDP(buf)
+		if err != nil {
+			if atomic.LoadInt32(&ch.closed) == 0 {
+				log.Errorf("[STREAM] Stream channel receive failed: sessionID=%d, err=%v", ch.sessionID, err)
+			}
+			return
+		}
+		recvTime := time.Now()
+		// 同一主机的其他进程或同一IP后的其他主机可能从其他端口发送报文，须同时比较IP和端口
+		if !addr.IP.Equal(ch.peerAddr.IP) || addr.Port != ch.peerAddr.Port {
+			ch.drop(fmt.Sprintf("unknown source address %s", addr))
+			continue
+		}
+		ch.handleFrame(buf[:n], recvTime)
+	}
+}
+
+// handleFrame 解密流帧并投递到会话服务器的OnStreamReceived
+// recvTime: 本端接收时间，用于计算到达抖动
+func (ch *streamChannel) handleFrame(buf []byte, recvTime time.Time) {
+	if len(buf) < StreamFrameHeadLen+crypto.OverheadLen ||
+		binary.LittleEndian.Uint32(buf[0:]) != sessionPktMagic ||
+		int32(binary.LittleEndian.Uint32(buf[4:])) != ch.sessionID {
+		ch.drop("invalid stream frame head")
+		return
+	}
+
+	head := buf[:StreamFrameHeadLen]
+	info := &StreamFrameInfo{
+		FrameType: StreamFrameType(head[12]),
+		TimeStamp: int64(binary.LittleEndian.Uint64(head[16:])),
+		SeqNum:    binary.LittleEndian.Uint32(head[8:]),
+	}
+	extLen := int(binary.LittleEndian.Uint16(head[14:]))
+
+	plain, err := crypto.DecryptAESGCMWithAAD(ch.recvKey, buf[StreamFrameHeadLen:], head)
+	if err != nil {
+		ch.drop(err.Error())
+		return
+	}
+	if extLen > StreamExtMaxLen || extLen >= len(plain) || len(plain)-extLen > StreamMaxLen {
+		ch.drop(fmt.Sprintf("invalid stream frame length: ext=%d, total=%d", extLen, len(plain)))
+		return
+	}
+	if !ch.accept(info, len(plain), recvTime) {
+		return
+	}
+
+	mgr := getSessionManager()
+	mgr.mu.RLock()
+	session, exists := mgr.sessions[ch.sessionID]
+	var server *SessionServer
+	if exists && session.stream == ch {
+		server = mgr.servers[session.SessionName]
+	}
+	mgr.mu.RUnlock()
+	if server == nil || server.OnStreamReceived == nil {
+		return
+	}
+
+	sessionID := ch.sessionID
+	ext, data := plain[:extLen], plain[extLen:]
+	// 应用处理过慢时丢弃流数据帧（流数据允许丢帧），不影响会话
+	if !session.receiver.postData(len(plain), func() { server.OnStreamReceived(sessionID, data, ext, info) }) {
+		ch.drop("receive queue full")
+	}
+}
+
+// accept 按重放窗口检查序列号，更新统计
+func (ch *streamChannel) accept(info *StreamFrameInfo, length int, recvTime time.Time) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	seq := info.SeqNum
+	switch {
+	case seq > ch.maxSeq:
+		shift := seq - ch.maxSeq
+		ch.stats.LostFrames += uint64(shift - 1)
+		if shift >= streamReplayWindow {
+			ch.window = 0
+		} else {
+			ch.window <<= shift
+		}
+		ch.window |= 1
+		ch.maxSeq = seq
+	case seq != 0 && ch.maxSeq-seq < streamReplayWindow && ch.window&(1<<(ch.maxSeq-seq)) == 0:
+		// 迟到帧，之前按丢失统计
+		ch.window |= 1 << (ch.maxSeq - seq)
+		ch.stats.OutOfOrderFrames++
+		if ch.stats.LostFrames > 0 {
+			ch.stats.LostFrames--
+		}
+	default:
+		ch.stats.DroppedFrames++
+		log.Debugf("[STREAM] Drop duplicate or stale stream frame: sessionID=%d, seq=%d, max=%d", ch.sessionID, seq, ch.maxSeq)
+		return false
+	}
+
+	// J += (|D| - J) / 16，D为相邻两次到达间隔之差（均取本端接收时间）
+	if !ch.lastArrival.IsZero() {
+		interval := float64(recvTime.Sub(ch.lastArrival)) / float64(time.Millisecond)
+		if ch.hasInterval {
+			ch.jitter += (math.Abs(interval-ch.interval) - ch.jitter) / 16
+		}
+		ch.interval = interval
+		ch.hasInterval = true
+	}
+	ch.lastArrival = recvTime
+
+	ch.stats.RecvFrames++
+	ch.stats.RecvBytes += uint64(length)
+	return true
+}
+
+// drop 丢弃无法解析或校验失败的帧
+func (ch *streamChannel) drop(reason string) {
+	ch.mu.Lock()
+	ch.stats.DroppedFrames++
+	ch.mu.Unlock()
+	log.Warnf("[STREAM] Drop stream frame: sessionID=%d, %s", ch.sessionID, reason)
+}
+
+// getStats 获取统计快照
+func (ch *streamChannel) getStats() *StreamStats {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	stats := ch.stats
+	stats.Jitter = time.Duration(ch.jitter * float64(time.Millisecond))
+	return &stats
+}
+
+// releaseStream 关闭流数据通道
+// 调用方需持有会话管理器锁
+func (s *Session) releaseStream() {
+	if s.stream != nil {
+		s.stream.close()
+		s.stream = nil
+	}
+}
+
+// SendStream 发送流数据帧（仅TypeStream会话），经UDP数据通道加密发送，不保证送达
+// ext: 扩展数据，与数据一起加密，对端在OnStreamReceived中原样收到
+// param: 帧信息，为nil时使用默认值
+func SendStream(sessionID int32, data []byte, ext []byte, param *StreamFrameInfo) error {
+	mgr := getSessionManager()
+	mgr.mu.RLock()
+	session, exists := mgr.sessions[sessionID]
+	opened := exists && session.IsOpened
+	var ch *streamChannel
+	if opened {
+		ch = session.stream
+	}
+	mgr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("session not found: %d", sessionID)
+	}
+	if !opened {
+		return fmt.Errorf("session not opened: %d", sessionID)
+	}
+	if session.DataType != TypeStream || ch == nil {
+		return fmt.Errorf("session type mismatch: session=%d, type=%d", session.DataType, TypeStream)
+	}
+	if len(data) == 0 || len(data) > StreamMaxLen {
+		return fmt.Errorf("invalid data length: %d bytes (max %d)", len(data), StreamMaxLen)
+	}
+	if len(ext) > StreamExtMaxLen {
+		return fmt.Errorf("invalid ext length: %d bytes (max %d)", len(ext), StreamExtMaxLen)
+	}
+	if param == nil {
+		param = &StreamFrameInfo{}
+	}
+
+	return ch.send(session.PeerSessionID, data, ext, param)
+}
+
+// GetStreamStats 获取流会话的收发统计
+func GetStreamStats(sessionID int32) (*StreamStats, error) {
+	mgr := getSessionManager()
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	session, exists := mgr.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %d", sessionID)
+	}
+	if session.stream == nil {
+		return nil, fmt.Errorf("stream channel not available: %d", sessionID)
+	}
+	return session.stream.getStats(), nil
+}
+
+// getChannelPeerIp 根据通道ID（认证连接的fd）获取对端IP
+func getChannelPeerIp(channelId int) (string, error) {
+	connInfo, _, err := authentication.SocketGetConnInfo(channelId)
+	if err != nil {
+		return "", err
+	}
+	return connInfo.Ip, nil
+}
@@ -0,0 +1,224 @@
+package transmission
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestStreamChannel 在回环地址上创建使用固定密钥的流数据通道
+func newTestStreamChannel(t *testing.T, sendKey, recvKey []byte) *streamChannel {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %v", err)
+	}
+	ch := &streamChannel{conn: conn, sendKey: sendKey, recvKey: recvKey}
+	t.Cleanup(ch.close)
+	return ch
+}
+
+// sealTestStreamFrame 用指定密钥和序列号加密一个流帧，返回完整的UDP报文
+func sealTestStreamFrame(t *testing.T, key []byte, peerSessionID int32, seq uint32, data, ext []byte) []byte {
+	t.Helper()
+
+	ch := newTestStreamChannel(t, key, nil)
+	ch.peerAddr = ch.conn.LocalAddr().(*net.UDPAddr)
+	ch.sendSeq = seq - 1
+	if err := ch.send(peerSessionID, data, ext, &StreamFrameInfo{FrameType: FrameTypeP}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	buf := make([]byte, streamRecvBufLen)
+	ch.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := ch.conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("ReadFromUDP failed: %v", err)
+	}
+	return buf[:n]
+}
+
+// waitStreamDropped 等待通道的丢弃帧数达到预期
+func waitStreamDropped(t *testing.T, ch *streamChannel, want uint64) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ch.getStats().DroppedFrames < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d dropped frames, got %d", want, ch.getStats().DroppedFrames)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 测试重放窗口：按序、缺口、迟到、重复和超出窗口的帧
+func TestStreamReplayWindow(t *testing.T) {
+	ch := &streamChannel{}
+	now := time.Now()
+	cases := []struct {
+		seq    uint32
+		accept bool
+	}{
+		{1, true},
+		{2, true},
+		{3, true},
+		{3, false}, // 重复
+		{0, false}, // 序列号从1开始
+		{6, true},  // 4、5按丢失统计
+		{4, true},  // 迟到
+		{4, false}, // 迟到帧重复
+		{100, true},
+		{36, false}, // 超出窗口（100-64）
+		{37, true},  // 窗口内迟到
+		{37, false},
+	}
+	for i, c := range cases {
+		if got := ch.accept(&StreamFrameInfo{SeqNum: c.seq}, 10, now); got != c.accept {
+			t.Fatalf("Case %d: accept(seq=%d) = %v, want %v", i, c.seq, got, c.accept)
+		}
+	}
+
+	stats := ch.getStats()
+	// 丢失: 4、5以及7~99，减去迟到的4和37
+	if stats.LostFrames != 2+93-2 {
+		t.Errorf("Unexpected lost frames: %d", stats.LostFrames)
+	}
+	if stats.OutOfOrderFrames != 2 {
+		t.Errorf("Unexpected out-of-order frames: %d", stats.OutOfOrderFrames)
+	}
+	if stats.RecvFrames != 7 || stats.RecvBytes != 70 {
+		t.Errorf("Unexpected received frames: %d, bytes: %d", stats.RecvFrames, stats.RecvBytes)
+	}
+	if stats.DroppedFrames != 5 {
+		t.Errorf("Unexpected dropped frames: %d", stats.DroppedFrames)
+	}
+}
+
+// 测试到达抖动按本端接收时间计算，不受应用时间戳影响
+func TestStreamJitterUsesLocalTime(t *testing.T) {
+	ch := &streamChannel{}
+	base := time.Now()
+	timestamps := []int64{0, 999999999, -5, 42, 1 << 40}
+	for i, ts := range timestamps {
+		info := &StreamFrameInfo{SeqNum: uint32(i + 1), TimeStamp: ts}
+		if !ch.accept(info, 1, base.Add(time.Duration(i)*10*time.Millisecond)) {
+			t.Fatalf("Frame %d rejected", i)
+		}
+	}
+	if jitter := ch.getStats().Jitter; jitter != 0 {
+		t.Fatalf("Expected zero jitter for evenly spaced frames, got %v", jitter)
+	}
+
+	// 到达间隔由10ms变为50ms: J = 40ms / 16
+	info := &StreamFrameInfo{SeqNum: uint32(len(timestamps) + 1)}
+	ch.accept(info, 1, base.Add(time.Duration(len(timestamps)-1)*10*time.Millisecond+50*time.Millisecond))
+	if jitter := ch.getStats().Jitter; jitter != 2500*time.Microsecond {
+		t.Fatalf("Unexpected jitter: %v", jitter)
+	}
+}
+
+// 测试流帧加解密往返，以及重放、篡改和来自其他端口的报文被丢弃
+func TestStreamChannelRoundTrip(t *testing.T) {
+	type frame struct {
+		data, ext []byte
+		info      *StreamFrameInfo
+	}
+	received := make(chan frame, 8)
+	session := addTestSession(t, "stream_test", TypeStream, &SessionServer{
+		OnStreamReceived: func(sessionID int32, data, ext []byte, info *StreamFrameInfo) {
+			received <- frame{data, ext, info}
+		},
+	})
+
+	c2s := bytes.Repeat([]byte{0x11}, 16)
+	s2c := bytes.Repeat([]byte{0x22}, 16)
+	client := newTestStreamChannel(t, c2s, s2c)
+	server := newTestStreamChannel(t, s2c, c2s)
+
+	mgr := getSessionManager()
+	mgr.mu.Lock()
+	session.stream = server
+	mgr.mu.Unlock()
+
+	if err := server.connect(session.SessionID, "127.0.0.1", client.localPort()); err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	if err := client.connect(session.PeerSessionID, "127.0.0.1", server.localPort()); err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+
+	expectFrame := func(data, ext []byte, seq uint32) *StreamFrameInfo {
+		t.Helper()
+		select {
+		case f := <-received:
+			if !bytes.Equal(f.data, data) || !bytes.Equal(f.ext, ext) || f.info.SeqNum != seq {
+				t.Fatalf("Unexpected frame: data=%q, ext=%q, seq=%d", f.data, f.ext, f.info.SeqNum)
+			}
+			return f.info
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Frame seq=%d not received", seq)
+			return nil
+		}
+	}
+	expectNoFrame := func() {
+		t.Helper()
+		select {
+		case f := <-received:
+			t.Fatalf("Unexpected frame delivered: seq=%d", f.info.SeqNum)
+		default:
+		}
+	}
+
+	// 正常往返，帧信息原样送达
+	if err := client.send(session.SessionID, []byte("frame-1"), []byte("ext"), &StreamFrameInfo{FrameType: FrameTypeI, TimeStamp: 12345}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	info := expectFrame([]byte("frame-1"), []byte("ext"), 1)
+	if info.FrameType != FrameTypeI || info.TimeStamp != 12345 {
+		t.Fatalf("Unexpected frame info: %+v", info)
+	}
+
+	// 从对端地址重放有效报文
+	valid := sealTestStreamFrame(t, c2s, session.SessionID, 2, []byte("frame-2"), nil)
+	serverAddr := server.conn.LocalAddr().(*net.UDPAddr)
+	for i := 0; i < 2; i++ {
+		if _, err := client.conn.WriteToUDP(valid, serverAddr); err != nil {
+			t.Fatalf("WriteToUDP failed: %v", err)
+		}
+	}
+	expectFrame([]byte("frame-2"), nil, 2)
+	waitStreamDropped(t, server, 1)
+	expectNoFrame()
+
+	// 篡改帧头（作为AAD）或密文
+	for _, offset := range []int{12, StreamFrameHeadLen + 1} {
+		tampered := sealTestStreamFrame(t, c2s, session.SessionID, 3, []byte("frame-3"), nil)
+		tampered[offset] ^= 0x01
+		if _, err := client.conn.WriteToUDP(tampered, serverAddr); err != nil {
+			t.Fatalf("WriteToUDP failed: %v", err)
+		}
+	}
+	waitStreamDropped(t, server, 3)
+	expectNoFrame()
+
+	// 相同IP、不同端口发送的有效报文
+	spoofer := newTestStreamChannel(t, nil, nil)
+	spoofed := sealTestStreamFrame(t, c2s, session.SessionID, 4, []byte("frame-4"), nil)
+	if _, err := spoofer.conn.WriteToUDP(spoofed, serverAddr); err != nil {
+		t.Fatalf("WriteToUDP failed: %v", err)
+	}
+	waitStreamDropped(t, server, 4)
+	expectNoFrame()
+
+	// 篡改帧被丢弃后不占用窗口，未篡改的seq 3仍可接收
+	if _, err := client.conn.WriteToUDP(sealTestStreamFrame(t, c2s, session.SessionID, 3, []byte("frame-3"), nil), serverAddr); err != nil {
+		t.Fatalf("WriteToUDP failed: %v", err)
+	}
+	expectFrame([]byte("frame-3"), nil, 3)
+
+	stats := server.getStats()
+	if stats.RecvFrames != 3 || stats.DroppedFrames != 4 {
+		t.Fatalf("Unexpected server stats: %+v", stats)
+	}
+}
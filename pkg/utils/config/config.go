@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 
 	log "github.com/junbin-yang/dsoftbus-go/pkg/utils/logger"
 	"gopkg.in/yaml.v2"
@@ -11,6 +12,9 @@ import (
 
 var (
 	APPNAME string = "dsoftbus"
+
+	current *Config
+	mu      sync.RWMutex
 )
 
 type Config struct {
@@ -18,7 +22,19 @@ type Config struct {
 	DeviceName string
 	UDID       string
 	Interface  string
-	Logger     struct {
+	DataDir    string // 持久化数据目录（会话密钥、可信组等）
+	// 认证准入策略（未配置的数值项使用默认值，负数表示不限制）
+	Admission struct {
+		UdidAllowList  []string
+		UdidDenyList   []string
+		IpAllowList    []string
+		IpDenyList     []string
+		MaxUnauthConns int // 未认证连接上限
+		RateLimit      int // 单个IP每个统计窗口内的新建连接数
+		RateWindow     int // 速率统计窗口（秒）
+		AuthTimeout    int // 未认证连接超时（秒）
+	}
+	Logger struct {
 		Dir    string
 		Level  string
 		Rotate bool
@@ -43,6 +59,10 @@ func Parse() *Config {
 	}
 	yaml.Unmarshal(data, &conf)
 
+	if len(conf.DataDir) == 0 {
+		conf.DataDir = filepath.Join(filepath.Dir(ex), "data")
+	}
+
 	defer log.Sync()
 	if conf.Logger.Rotate {
 		if len(conf.Logger.Dir) == 0 {
@@ -65,5 +85,16 @@ func Parse() *Config {
 		log.SetLevel(log.InfoLevel)
 	}
 
+	mu.Lock()
+	current = conf
+	mu.Unlock()
+
 	return conf
 }
+
+// Get 返回最近一次Parse的配置，未解析时返回nil
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
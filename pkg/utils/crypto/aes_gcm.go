@@ -113,3 +113,70 @@ func DecryptAESGCM(key []byte, cipherData []byte) ([]byte, error) {
 
 	return plaintext, nil
 }
+
+// EncryptAESGCMWithAAD 使用AES-GCM算法加密数据，并绑定附加认证数据(AAD)
+// 参数：
+//   - key：AES密钥（长度需为16/24/32字节）
+//   - plaintext：待加密的明文数据
+//   - aad：附加认证数据（不加密，但参与认证标签计算）
+// 返回：
+//   - 加密后的数据（格式：IV(12字节) + 密文 + 认证标签(16字节)）
+//   - 错误信息（若加密失败）
+func EncryptAESGCMWithAAD(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	iv, err := GenerateRandomIV()
+	if err != nil {
+		return nil, fmt.Errorf("生成IV失败: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建cipher失败: %w", err)
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建GCM失败: %w", err)
+	}
+
+	ciphertext := aesgcm.Seal(nil, iv, plaintext, aad)
+
+	result := make([]byte, len(iv)+len(ciphertext))
+	copy(result, iv)
+	copy(result[len(iv):], ciphertext)
+
+	return result, nil
+}
+
+// DecryptAESGCMWithAAD 使用AES-GCM算法解密数据，并校验附加认证数据(AAD)
+// 参数：
+//   - key：AES密钥（与加密时使用的密钥一致）
+//   - cipherData：加密后的数据（格式：IV + 密文 + 标签）
+//   - aad：附加认证数据（必须与加密时一致）
+// 返回：
+//   - 解密后的明文数据
+//   - 错误信息（若解密失败、数据或AAD被篡改）
+func DecryptAESGCMWithAAD(key []byte, cipherData []byte, aad []byte) ([]byte, error) {
+	if len(cipherData) < OverheadLen {
+		return nil, fmt.Errorf("密文长度过短，至少需要%d字节", OverheadLen)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建cipher失败: %w", err)
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建GCM失败: %w", err)
+	}
+
+	iv := cipherData[:GcmNonceLen]
+	ciphertext := cipherData[GcmNonceLen:]
+
+	plaintext, err := aesgcm.Open(nil, iv, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("解密失败（可能是密钥错误或数据被篡改）: %w", err)
+	}
+
+	return plaintext, nil
+}
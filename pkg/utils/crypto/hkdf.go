@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveKeyHKDF 使用HKDF-SHA256派生密钥
+// 参数：
+//   - secret：输入密钥材料
+//   - salt：盐值（可为nil）
+//   - info：上下文信息，用于区分不同用途的派生密钥
+//   - length：输出密钥长度（字节）
+//
+// 返回：
+//   - 派生的密钥
+//   - 错误信息
+func DeriveKeyHKDF(secret []byte, salt []byte, info []byte, length int) ([]byte, error) {
+	if len(secret) == 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid hkdf parameters")
+	}
+
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, fmt.Errorf("hkdf derive failed: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/junbin-yang/dsoftbus-go/pkg/utils/crypto"
+)
+
+const (
+	DirPerm    os.FileMode = 0700 // 新建数据目录权限（仅属主可访问）
+	DirMaxPerm os.FileMode = 0755 // 已有数据目录允许的最大权限（不允许组/其他用户写入）
+	FilePerm   os.FileMode = 0600 // 数据文件权限（仅属主可读写）
+
+	WrapKeyLen     = 32           // 包装密钥长度（AES-256）
+	wrapSeedLen    = 32           // 种子长度
+	wrapSeedFile   = ".wrap_seed" // 种子文件名
+	wrapKeyInfoTag = "dsoftbus_wrap_"
+)
+
+// EnsureDir 确保目录存在且权限安全
+// 参数：
+//   - dir：目录路径
+//
+// 返回：
+//   - 错误信息（目录创建失败或权限过宽）
+func EnsureDir(dir string) error {
+	if err := os.MkdirAll(dir, DirPerm); err != nil {
+		return fmt.Errorf("create dir failed: %w", err)
+	}
+	return CheckPermission(dir, DirMaxPerm)
+}
+
+// CheckPermission 检查文件或目录权限不超过maxPerm
+// 参数：
+//   - path：文件或目录路径
+//   - maxPerm：允许的最大权限
+//
+// 返回：
+//   - 错误信息（不存在或权限过宽）
+func CheckPermission(path string, maxPerm os.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if perm := info.Mode().Perm(); perm&^maxPerm != 0 {
+		return fmt.Errorf("insecure permission %04o on %s (max %04o)", perm, path, maxPerm)
+	}
+	return nil
+}
+
+// ReadFile 检查权限后读取文件
+// 参数：
+//   - path：文件路径
+//
+// 返回：
+//   - 文件内容
+//   - 错误信息（文件不存在时返回的错误满足os.IsNotExist）
+func ReadFile(path string) ([]byte, error) {
+	if err := CheckPermission(path, FilePerm); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// WriteFileAtomic 原子写入文件
+// 先写入同目录的临时文件并fsync，再rename覆盖目标文件，避免进程崩溃留下半写文件
+// 参数：
+//   - path：目标文件路径
+//   - data：文件内容
+//
+// 返回：
+//   - 错误信息
+func WriteFileAtomic(path string, data []byte) error {
+	tmpName, err := writeTempFile(path, data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("rename temp file failed: %w", err)
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// writeTempFile 在目标文件同目录写入临时文件并fsync，返回临时文件路径
+func writeTempFile(path string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return "", fmt.Errorf("create temp file failed: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	// 出错时清理临时文件
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if err := tmp.Chmod(FilePerm); err != nil {
+		return "", fmt.Errorf("chmod temp file failed: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return "", fmt.Errorf("write temp file failed: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("sync temp file failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("close temp file failed: %w", err)
+	}
+	success = true
+	return tmpName, nil
+}
+
+// syncDir 同步目录项，保证rename/link落盘（失败不影响结果）
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// LoadOrCreateWrapKey 加载或创建本地包装密钥
+// 包装密钥由数据目录下的随机种子文件通过HKDF派生，不同用途使用不同的purpose得到独立的密钥
+// 参数：
+//   - dir：数据目录
+//   - purpose：密钥用途标识
+//
+// 返回：
+//   - 32字节包装密钥
+//   - 错误信息
+func LoadOrCreateWrapKey(dir string, purpose string) ([]byte, error) {
+	if err := EnsureDir(dir); err != nil {
+		return nil, err
+	}
+
+	seed, err := loadOrCreateSeed(filepath.Join(dir, wrapSeedFile))
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.DeriveKeyHKDF(seed, nil, []byte(wrapKeyInfoTag+purpose), WrapKeyLen)
+}
+
+// loadOrCreateSeed 读取种子文件，不存在时创建
+// 种子完整写入同目录的临时文件并fsync后，通过os.Link发布（目标已存在时失败），
+// 进程崩溃不会留下不完整的种子文件，并发创建时只有一方成功，其他方读取胜出方的种子
+func loadOrCreateSeed(path string) ([]byte, error) {
+	seed, err := readSeed(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return seed, err
+	}
+
+	seed, err = crypto.GenerateRandomBytes(wrapSeedLen)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpName, err := writeTempFile(path, seed)
+	if err != nil {
+		return nil, fmt.Errorf("create seed file failed: %w", err)
+	}
+	err = os.Link(tmpName, path)
+	os.Remove(tmpName)
+	if err != nil {
+		// 并发创建时读取胜出方的种子
+		if errors.Is(err, os.ErrExist) {
+			return readSeed(path)
+		}
+		return nil, fmt.Errorf("create seed file failed: %w", err)
+	}
+	syncDir(filepath.Dir(path))
+	return seed, nil
+}
+
+// readSeed 读取并校验种子文件
+func readSeed(path string) ([]byte, error) {
+	seed, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != wrapSeedLen {
+		return nil, fmt.Errorf("corrupted seed file: %s", path)
+	}
+	return seed, nil
+}
+
+// Seal 使用包装密钥加密数据
+// 参数：
+//   - wrapKey：包装密钥
+//   - plaintext：明文
+//   - aad：附加认证数据（通常为记录标识，防止密文被挪用到其他记录）
+//
+// 返回：
+//   - 密文（IV + 密文 + Tag）
+//   - 错误信息
+func Seal(wrapKey []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	return crypto.EncryptAESGCMWithAAD(wrapKey, plaintext, aad)
+}
+
+// Open 使用包装密钥解密数据
+// 参数：
+//   - wrapKey：包装密钥
+//   - sealed：Seal输出的密文
+//   - aad：附加认证数据（必须与Seal时一致）
+//
+// 返回：
+//   - 明文
+//   - 错误信息
+func Open(wrapKey []byte, sealed []byte, aad []byte) ([]byte, error) {
+	return crypto.DecryptAESGCMWithAAD(wrapKey, sealed, aad)
+}
+
+// ReadSealedFile 读取并解密文件
+func ReadSealedFile(path string, wrapKey []byte, aad []byte) ([]byte, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Open(wrapKey, data, aad)
+}
+
+// WriteSealedFile 加密并原子写入文件
+func WriteSealedFile(path string, wrapKey []byte, plaintext []byte, aad []byte) error {
+	sealed, err := Seal(wrapKey, plaintext, aad)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, sealed)
+}
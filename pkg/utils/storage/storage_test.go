@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.bin")
+
+	if err := WriteFileAtomic(path, []byte("v1")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("v2")); err != nil {
+		t.Fatalf("覆盖写入失败: %v", err)
+	}
+
+	data, err := ReadFile(path)
+	if err != nil || string(data) != "v2" {
+		t.Fatalf("读取结果错误: %q, %v", data, err)
+	}
+
+	// 不应残留临时文件
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("目录中存在残留文件: %d", len(entries))
+	}
+}
+
+func TestCheckPermission(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.bin")
+	if err := WriteFileAtomic(path, []byte("secret")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Error("权限过宽的文件应拒绝读取")
+	}
+
+	if _, err := ReadFile(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("不存在的文件应返回NotExist错误: %v", err)
+	}
+}
+
+func TestWrapKeyAndSeal(t *testing.T) {
+	dir := t.TempDir()
+
+	k1, err := LoadOrCreateWrapKey(dir, "session_key")
+	if err != nil {
+		t.Fatalf("创建包装密钥失败: %v", err)
+	}
+	k2, _ := LoadOrCreateWrapKey(dir, "session_key")
+	k3, _ := LoadOrCreateWrapKey(dir, "group")
+	if !bytes.Equal(k1, k2) || len(k1) != WrapKeyLen {
+		t.Error("相同用途应得到相同的包装密钥")
+	}
+	if bytes.Equal(k1, k3) {
+		t.Error("不同用途应得到不同的包装密钥")
+	}
+
+	path := filepath.Join(dir, "sealed.bin")
+	if err := WriteSealedFile(path, k1, []byte("hello"), []byte("id-1")); err != nil {
+		t.Fatalf("加密写入失败: %v", err)
+	}
+
+	plain, err := ReadSealedFile(path, k1, []byte("id-1"))
+	if err != nil || string(plain) != "hello" {
+		t.Fatalf("解密结果错误: %q, %v", plain, err)
+	}
+	if _, err := ReadSealedFile(path, k1, []byte("id-2")); err == nil {
+		t.Error("AAD不一致时应解密失败")
+	}
+	if _, err := ReadSealedFile(path, k3, []byte("id-1")); err == nil {
+		t.Error("包装密钥不一致时应解密失败")
+	}
+}
+
+func TestLoadOrCreateSeedConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, wrapSeedFile)
+
+	// 崩溃残留的临时文件不影响创建
+	if err := os.WriteFile(filepath.Join(dir, "."+wrapSeedFile+".tmp123"), []byte("short"), FilePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 16
+	seeds := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			seeds[i], errs[i] = loadOrCreateSeed(path)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("创建种子失败: %v", errs[i])
+		}
+		if len(seeds[i]) != wrapSeedLen || !bytes.Equal(seeds[i], seeds[0]) {
+			t.Fatal("并发创建应得到相同的种子")
+		}
+	}
+	if data, err := ReadFile(path); err != nil || !bytes.Equal(data, seeds[0]) {
+		t.Errorf("种子文件内容错误: %v", err)
+	}
+
+	// 只残留预置的临时文件
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("目录中存在残留文件: %d", len(entries))
+	}
+}